@@ -0,0 +1,31 @@
+-- Bin / rack locations below gudang_lantai (zona -> rak -> bin)
+-- stock_gudang stays the per-lantai total; stock_lokasi holds the part of it
+-- that has been put away into a specific bin. The rest is "unbinned".
+
+CREATE TABLE IF NOT EXISTS gudang_lokasi (
+    lokasi_id     VARCHAR(20)  NOT NULL,
+    lantai_id     VARCHAR(20)  NOT NULL,
+    lokasi_zona   VARCHAR(20)  NOT NULL,
+    lokasi_rak    VARCHAR(20)  NOT NULL,
+    lokasi_bin    VARCHAR(20)  NOT NULL,
+    lokasi_nama   VARCHAR(100) NOT NULL,
+    lokasi_status TINYINT      NOT NULL DEFAULT 1,
+    PRIMARY KEY (lokasi_id),
+    UNIQUE KEY uq_lokasi_kode (lantai_id, lokasi_zona, lokasi_rak, lokasi_bin),
+    CONSTRAINT fk_lokasi_lantai FOREIGN KEY (lantai_id) REFERENCES gudang_lantai (lantai_id)
+);
+
+CREATE TABLE IF NOT EXISTS stock_lokasi (
+    stock_lokasi_id VARCHAR(20) NOT NULL,
+    barang_id       VARCHAR(20) NOT NULL,
+    lokasi_id       VARCHAR(20) NOT NULL,
+    stock_barang    INT         NOT NULL DEFAULT 0,
+    PRIMARY KEY (stock_lokasi_id),
+    UNIQUE KEY uq_stock_lokasi (barang_id, lokasi_id),
+    CONSTRAINT fk_stock_lokasi_barang FOREIGN KEY (barang_id) REFERENCES barang (barang_id),
+    CONSTRAINT fk_stock_lokasi_lokasi FOREIGN KEY (lokasi_id) REFERENCES gudang_lokasi (lokasi_id)
+);
+
+-- Put-away bin chosen on receiving and pick bin chosen on sale lines
+ALTER TABLE orders_masuk ADD COLUMN lokasi_id VARCHAR(20) NULL AFTER lantai_id;
+ALTER TABLE sale_items ADD COLUMN lokasi_id VARCHAR(20) NULL AFTER lantai_id;
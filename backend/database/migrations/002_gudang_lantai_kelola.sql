@@ -0,0 +1,7 @@
+-- Floor management: capacity and retirement of gudang_lantai
+-- Inactive floors keep their lantai_id so historic orders and sale items
+-- still resolve, but they no longer receive stock. lantai_no stays
+-- contiguous (1..n) over the active floors, inactive floors follow them.
+
+ALTER TABLE gudang_lantai ADD COLUMN lantai_kapasitas INT NULL AFTER lantai_nama;
+ALTER TABLE gudang_lantai ADD COLUMN lantai_status TINYINT NOT NULL DEFAULT 1 AFTER lantai_kapasitas;
@@ -0,0 +1,6 @@
+-- Archival of list_gudang instead of deleting it
+-- Archived gudangs keep their rows (and floors) so historic orders_masuk,
+-- orders_keluar and sale_items still resolve gudang_nama in reports.
+
+ALTER TABLE list_gudang ADD COLUMN gudang_status TINYINT NOT NULL DEFAULT 1 AFTER gudang_alamat;
+ALTER TABLE list_gudang ADD COLUMN gudang_archived_at DATETIME NULL AFTER gudang_status;
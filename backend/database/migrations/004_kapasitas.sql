@@ -0,0 +1,10 @@
+-- Capacity per gudang_lantai and per-barang dimensions
+-- lantai_kapasitas (units) was added in 002; a floor may also be limited by
+-- volume (m3) and/or weight (kg). NULL means "no limit" for that dimension.
+
+ALTER TABLE gudang_lantai ADD COLUMN lantai_kapasitas_volume DECIMAL(12,3) NULL AFTER lantai_kapasitas;
+ALTER TABLE gudang_lantai ADD COLUMN lantai_kapasitas_berat DECIMAL(12,3) NULL AFTER lantai_kapasitas_volume;
+
+-- Volume (m3) and weight (kg) of one unit of barang
+ALTER TABLE barang ADD COLUMN barang_volume DECIMAL(12,4) NULL AFTER barang_harga_jual;
+ALTER TABLE barang ADD COLUMN barang_berat DECIMAL(12,3) NULL AFTER barang_volume;
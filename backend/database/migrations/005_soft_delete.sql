@@ -0,0 +1,12 @@
+-- Soft delete for master data
+-- Deleted rows keep their id so sales, orders and logs keep resolving them.
+-- deleted_by holds the users_id sent in the X-Users-ID header (NULL if unknown).
+
+ALTER TABLE barang ADD COLUMN deleted_at DATETIME NULL, ADD COLUMN deleted_by VARCHAR(20) NULL;
+ALTER TABLE brand ADD COLUMN deleted_at DATETIME NULL, ADD COLUMN deleted_by VARCHAR(20) NULL;
+ALTER TABLE customer ADD COLUMN deleted_at DATETIME NULL, ADD COLUMN deleted_by VARCHAR(20) NULL;
+ALTER TABLE users ADD COLUMN deleted_at DATETIME NULL, ADD COLUMN deleted_by VARCHAR(20) NULL;
+
+-- list_gudang already has an archive timestamp (003); it becomes deleted_at
+ALTER TABLE list_gudang CHANGE COLUMN gudang_archived_at deleted_at DATETIME NULL;
+ALTER TABLE list_gudang ADD COLUMN deleted_by VARCHAR(20) NULL AFTER deleted_at;
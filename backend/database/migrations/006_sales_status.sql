@@ -0,0 +1,19 @@
+-- Sales lifecycle
+-- sales_status keeps its existing values (1 Selesai, 2 Diproses) and gains:
+--   0 Draft       no stock taken yet, lines freely editable
+--   3 Dipick      goods picked from the gudang
+--   4 Lunas       shipped and paid
+--   5 Dibatalkan  cancelled, stock returned
+-- Flow: 0 -confirm-> 2 -pick-> 3 -ship-> 1 -pay-> 4, cancel from 0/2/3.
+
+CREATE TABLE sales_status_log (
+    log_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    sales_id VARCHAR(20) NOT NULL,
+    status_from TINYINT NULL,
+    status_to TINYINT NOT NULL,
+    log_action VARCHAR(20) NOT NULL,
+    users_id VARCHAR(20) NULL,
+    log_date DATETIME NOT NULL,
+    log_note VARCHAR(255) NULL,
+    KEY idx_sales_status_log_sales (sales_id)
+);
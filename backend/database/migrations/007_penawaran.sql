@@ -0,0 +1,31 @@
+-- Quotations (penawaran) sent to customers before any stock moves
+-- penawaran_status: 1 Terkirim (sent), 2 Diterima (accepted), 3 Kedaluwarsa (expired), 4 Ditolak (rejected)
+-- sales_id is filled once an accepted penawaran has been converted into a sale.
+
+CREATE TABLE penawaran (
+    penawaran_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    customer_id VARCHAR(20) NOT NULL,
+    penawaran_date DATE NOT NULL,
+    penawaran_valid_until DATE NOT NULL,
+    penawaran_status TINYINT NOT NULL DEFAULT 1,
+    penawaran_total INT NOT NULL DEFAULT 0,
+    penawaran_note VARCHAR(255) NULL,
+    sales_id VARCHAR(20) NULL,
+    users_id VARCHAR(20) NULL,
+    KEY idx_penawaran_customer (customer_id)
+);
+
+-- Prices are fixed when the penawaran is made: harga_jual and diskon are
+-- copied from barang, harga_akhir is the offered unit price.
+CREATE TABLE penawaran_items (
+    penawaran_items_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    penawaran_id VARCHAR(20) NOT NULL,
+    barang_id VARCHAR(20) NOT NULL,
+    gudang_id VARCHAR(20) NOT NULL,
+    lantai_id VARCHAR(20) NULL,
+    items_amount INT NOT NULL,
+    harga_jual INT NOT NULL,
+    diskon DECIMAL(5,2) NOT NULL DEFAULT 0,
+    harga_akhir INT NOT NULL,
+    KEY idx_penawaran_items_penawaran (penawaran_id)
+);
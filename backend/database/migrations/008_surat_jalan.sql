@@ -0,0 +1,41 @@
+-- Delivery orders (surat jalan) for outbound shipments
+-- A surat jalan ships lines of a sale (sales_id) or of an outbound log (logs_id).
+-- sj_status: 1 Disiapkan (prepared), 2 Dikirim (dispatched), 3 Diterima (delivered), 4 Dibatalkan
+-- Several surat jalan may cover one sale; sji_delivered below sji_amount leaves
+-- the rest outstanding for a later surat jalan.
+
+CREATE TABLE surat_jalan (
+    sj_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    sales_id VARCHAR(20) NULL,
+    logs_id VARCHAR(20) NULL,
+    customer_id VARCHAR(20) NULL,
+    sj_penerima VARCHAR(100) NOT NULL,
+    sj_alamat VARCHAR(255) NOT NULL,
+    sj_kontak VARCHAR(50) NULL,
+    sj_kendaraan VARCHAR(50) NULL,
+    sj_supir VARCHAR(100) NULL,
+    sj_date DATE NOT NULL,
+    sj_status TINYINT NOT NULL DEFAULT 1,
+    sj_dispatched_at DATETIME NULL,
+    sj_delivered_at DATETIME NULL,
+    sj_receiver_nama VARCHAR(100) NULL,
+    sj_note VARCHAR(255) NULL,
+    users_id VARCHAR(20) NULL,
+    KEY idx_surat_jalan_sales (sales_id),
+    KEY idx_surat_jalan_logs (logs_id)
+);
+
+CREATE TABLE surat_jalan_items (
+    sji_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    sj_id VARCHAR(20) NOT NULL,
+    sale_items_id VARCHAR(20) NULL,
+    orders_id VARCHAR(20) NULL,
+    barang_id VARCHAR(20) NOT NULL,
+    gudang_id VARCHAR(20) NOT NULL,
+    lantai_id VARCHAR(20) NULL,
+    sji_amount INT NOT NULL,
+    sji_delivered INT NULL,
+    KEY idx_surat_jalan_items_sj (sj_id),
+    KEY idx_surat_jalan_items_sale_item (sale_items_id),
+    KEY idx_surat_jalan_items_orders (orders_id)
+);
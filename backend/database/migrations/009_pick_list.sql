@@ -0,0 +1,35 @@
+-- Pick lists for confirmed sales and outbound (keluar) logs
+-- pick_status: 1 Dibuat (open), 2 Selesai (picked), 3 Dibatalkan
+-- pick_list_items aggregates the same barang on the same lantai/bin across
+-- all documents of the pick list. pli_picked/pli_short are filled on confirm.
+
+CREATE TABLE pick_list (
+    pick_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    pick_date DATETIME NOT NULL,
+    pick_status TINYINT NOT NULL DEFAULT 1,
+    pick_note VARCHAR(255) NULL,
+    users_id VARCHAR(20) NULL,
+    picked_by VARCHAR(20) NULL,
+    picked_at DATETIME NULL
+);
+
+CREATE TABLE pick_list_documents (
+    pick_id VARCHAR(20) NOT NULL,
+    doc_type VARCHAR(10) NOT NULL, -- 'sales' or 'logs'
+    doc_id VARCHAR(20) NOT NULL,
+    PRIMARY KEY (pick_id, doc_type, doc_id),
+    KEY idx_pick_list_documents_doc (doc_type, doc_id)
+);
+
+CREATE TABLE pick_list_items (
+    pli_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    pick_id VARCHAR(20) NOT NULL,
+    barang_id VARCHAR(20) NOT NULL,
+    gudang_id VARCHAR(20) NOT NULL,
+    lantai_id VARCHAR(20) NOT NULL,
+    lokasi_id VARCHAR(20) NULL,
+    pli_required INT NOT NULL,
+    pli_picked INT NULL,
+    pli_short INT NULL,
+    KEY idx_pick_list_items_pick (pick_id)
+);
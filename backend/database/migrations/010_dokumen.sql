@@ -0,0 +1,20 @@
+-- Archive of server-rendered PDF documents
+-- dokumen_type: invoice, receipt, surat_jalan, purchase_order
+-- One row per (type, ref_id, variant); re-rendering keeps dokumen_no and
+-- overwrites dokumen_file. dokumen_variant holds the receipt paper width
+-- ('58' / '80') and is empty for the other types.
+
+CREATE TABLE dokumen (
+    dokumen_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    dokumen_no VARCHAR(50) NOT NULL,
+    dokumen_type VARCHAR(20) NOT NULL,
+    ref_id VARCHAR(20) NOT NULL,
+    dokumen_variant VARCHAR(10) NOT NULL DEFAULT '',
+    dokumen_file VARCHAR(255) NOT NULL,
+    dokumen_size INT NOT NULL,
+    dokumen_created DATETIME NOT NULL,
+    dokumen_rendered DATETIME NOT NULL,
+    users_id VARCHAR(20) NULL,
+    UNIQUE KEY uq_dokumen_ref (dokumen_type, ref_id, dokumen_variant),
+    KEY idx_dokumen_no (dokumen_no)
+);
@@ -0,0 +1,39 @@
+-- Legal document numbering (e.g. INV/2026/10/0001)
+-- nomor_seri holds the pattern and reset period of each document series.
+-- Pattern tokens: {BRANCH} {YYYY} {YY} {MM} {SEQ} {SEQ:n} (n = zero padded width)
+-- seri_reset: none, yearly, monthly
+-- nomor_counter keeps the last number per series, period ('', '2026' or
+-- '2026-10') and branch (BRANCH_CODE of the server). Numbers are taken with
+-- SELECT ... FOR UPDATE inside the transaction that confirms the document, so
+-- a rolled back confirmation does not leave a gap and a voided document keeps
+-- its number.
+
+CREATE TABLE nomor_seri (
+    seri_type VARCHAR(20) NOT NULL PRIMARY KEY,
+    seri_pattern VARCHAR(100) NOT NULL,
+    seri_reset VARCHAR(10) NOT NULL DEFAULT 'monthly',
+    seri_updated DATETIME NULL
+);
+
+INSERT INTO nomor_seri (seri_type, seri_pattern, seri_reset) VALUES
+    ('invoice', 'INV/{YYYY}/{MM}/{SEQ:4}', 'monthly'),
+    ('purchase_order', 'PO/{YYYY}/{MM}/{SEQ:4}', 'monthly'),
+    ('surat_jalan', 'SJ/{YYYY}/{MM}/{SEQ:4}', 'monthly'),
+    ('credit_note', 'CN/{YYYY}/{SEQ:5}', 'yearly');
+
+CREATE TABLE nomor_counter (
+    seri_type VARCHAR(20) NOT NULL,
+    counter_period VARCHAR(7) NOT NULL DEFAULT '',
+    counter_branch VARCHAR(20) NOT NULL DEFAULT '',
+    counter_last INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (seri_type, counter_period, counter_branch)
+);
+
+ALTER TABLE sales ADD COLUMN sales_invoice_no VARCHAR(50) NULL AFTER sales_status;
+ALTER TABLE sales ADD UNIQUE KEY uq_sales_invoice_no (sales_invoice_no);
+
+ALTER TABLE barang_logs ADD COLUMN logs_po_no VARCHAR(50) NULL AFTER logs_desc;
+ALTER TABLE barang_logs ADD UNIQUE KEY uq_logs_po_no (logs_po_no);
+
+ALTER TABLE surat_jalan ADD COLUMN sj_no VARCHAR(50) NULL AFTER sj_id;
+ALTER TABLE surat_jalan ADD UNIQUE KEY uq_sj_no (sj_no);
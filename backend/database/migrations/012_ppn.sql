@@ -0,0 +1,41 @@
+-- PPN (VAT) on sales and purchases
+-- pajak_setting is a single row (setting_id = 1). With *_termasuk_ppn = 1 the
+-- entered prices already contain PPN and the tax is extracted from them;
+-- otherwise PPN is added on top. Both default to inclusive so totals of
+-- existing flows do not change.
+-- A barang may override the rate (barang_ppn_persen) or be exempt; exempt
+-- customers pay no PPN on any line.
+
+CREATE TABLE pajak_setting (
+    setting_id TINYINT NOT NULL PRIMARY KEY,
+    pajak_aktif TINYINT NOT NULL DEFAULT 1,
+    ppn_persen DECIMAL(5,2) NOT NULL DEFAULT 11.00,
+    harga_jual_termasuk_ppn TINYINT NOT NULL DEFAULT 1,
+    harga_beli_termasuk_ppn TINYINT NOT NULL DEFAULT 1,
+    setting_updated DATETIME NULL
+);
+
+INSERT INTO pajak_setting (setting_id, pajak_aktif, ppn_persen, harga_jual_termasuk_ppn, harga_beli_termasuk_ppn)
+VALUES (1, 1, 11.00, 1, 1);
+
+ALTER TABLE barang ADD COLUMN barang_ppn_persen DECIMAL(5,2) NULL;
+ALTER TABLE barang ADD COLUMN barang_bebas_pajak TINYINT NOT NULL DEFAULT 0;
+ALTER TABLE customer ADD COLUMN customer_bebas_pajak TINYINT NOT NULL DEFAULT 0;
+
+-- Per-line tax: rate applied, tax base (DPP) and PPN amount
+ALTER TABLE sale_items ADD COLUMN sale_tax_persen DECIMAL(5,2) NOT NULL DEFAULT 0;
+ALTER TABLE sale_items ADD COLUMN sale_tax_dpp INT NOT NULL DEFAULT 0;
+ALTER TABLE sale_items ADD COLUMN sale_tax_amount INT NOT NULL DEFAULT 0;
+
+ALTER TABLE orders_masuk ADD COLUMN orders_tax_persen DECIMAL(5,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders_masuk ADD COLUMN orders_tax_dpp INT NOT NULL DEFAULT 0;
+ALTER TABLE orders_masuk ADD COLUMN orders_tax_amount INT NOT NULL DEFAULT 0;
+
+-- Document totals; sales_total stays the amount payable (DPP + PPN)
+ALTER TABLE sales ADD COLUMN sales_dpp INT NOT NULL DEFAULT 0 AFTER sales_total;
+ALTER TABLE sales ADD COLUMN sales_ppn INT NOT NULL DEFAULT 0 AFTER sales_dpp;
+
+-- Existing documents were recorded without PPN
+UPDATE sale_items SET sale_tax_dpp = sale_items_amount * sale_value;
+UPDATE orders_masuk SET orders_tax_dpp = orders_amount * orders_value;
+UPDATE sales SET sales_dpp = sales_total;
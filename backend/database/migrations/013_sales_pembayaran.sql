@@ -0,0 +1,33 @@
+-- Split and mixed payments per sale
+-- A sale may be paid with several lines: tunai, transfer (with bank account),
+-- qris, ewallet or kredit. bayar_amount is the part of sales_total the line
+-- settles; for tunai bayar_diterima is the cash handed over and
+-- bayar_kembalian the change given back. The lines of a sale always add up to
+-- sales_total, an unpaid remainder is booked as a kredit line.
+-- sales.sales_payment stays as the summary code: 3 (Kredit) when any part is
+-- on credit, 1 (Tunai) when everything is cash, otherwise 2 (Transfer).
+
+CREATE TABLE sales_pembayaran (
+    bayar_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    sales_id VARCHAR(20) NOT NULL,
+    bayar_metode VARCHAR(10) NOT NULL,
+    bayar_amount INT NOT NULL,
+    bayar_diterima INT NOT NULL DEFAULT 0,
+    bayar_kembalian INT NOT NULL DEFAULT 0,
+    bayar_bank VARCHAR(50) NULL,
+    bayar_rekening VARCHAR(50) NULL,
+    bayar_referensi VARCHAR(100) NULL,
+    bayar_date DATETIME NOT NULL,
+    users_id VARCHAR(20) NULL,
+    KEY idx_sales_pembayaran_sales (sales_id)
+);
+
+-- Existing paid sales get one line matching their payment method
+INSERT INTO sales_pembayaran (bayar_id, sales_id, bayar_metode, bayar_amount, bayar_diterima, bayar_date)
+SELECT CONCAT('SP_', LPAD(ROW_NUMBER() OVER (ORDER BY sales_id), 7, '0')), sales_id,
+       CASE sales_payment WHEN '1' THEN 'tunai' WHEN '3' THEN 'kredit' ELSE 'transfer' END,
+       sales_total,
+       CASE sales_payment WHEN '1' THEN sales_total ELSE 0 END,
+       sales_date
+FROM sales
+WHERE sales_status = 4;
@@ -0,0 +1,37 @@
+-- Cashier shifts and cash drawer reconciliation
+-- A cashier opens a shift with a starting float (shift_modal_awal) and closes
+-- it with the counted cash. Every tunai payment line recorded by a user with
+-- an open shift is tied to it (sales_pembayaran.shift_id); petty cash moving
+-- in or out of the drawer is booked in kasir_kas.
+-- Expected cash = modal awal + tunai payments (sales not cancelled)
+--               + kas masuk - kas keluar; shift_selisih = counted - expected.
+-- shift_status: 1 Buka, 2 Tutup. A user has at most one open shift.
+
+CREATE TABLE kasir_shift (
+    shift_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    users_id VARCHAR(20) NOT NULL,
+    shift_status TINYINT NOT NULL DEFAULT 1,
+    shift_open DATETIME NOT NULL,
+    shift_close DATETIME NULL,
+    shift_modal_awal INT NOT NULL DEFAULT 0,
+    shift_kas_seharusnya INT NULL,
+    shift_kas_dihitung INT NULL,
+    shift_selisih INT NULL,
+    shift_note VARCHAR(255) NULL,
+    closed_by VARCHAR(20) NULL,
+    KEY idx_kasir_shift_users (users_id, shift_status)
+);
+
+CREATE TABLE kasir_kas (
+    kas_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    shift_id VARCHAR(20) NOT NULL,
+    kas_type VARCHAR(10) NOT NULL,
+    kas_amount INT NOT NULL,
+    kas_note VARCHAR(255) NOT NULL,
+    kas_date DATETIME NOT NULL,
+    users_id VARCHAR(20) NULL,
+    KEY idx_kasir_kas_shift (shift_id)
+);
+
+ALTER TABLE sales_pembayaran ADD COLUMN shift_id VARCHAR(20) NULL AFTER sales_id;
+ALTER TABLE sales_pembayaran ADD KEY idx_sales_pembayaran_shift (shift_id);
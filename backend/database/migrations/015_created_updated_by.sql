@@ -0,0 +1,26 @@
+-- User attribution on transactional tables
+-- created_by is the user that recorded the row, updated_by / updated_at the
+-- last user that changed it (X-Users-ID of the request). Rows written before
+-- this migration, or by requests without a user, stay NULL.
+
+ALTER TABLE sales ADD COLUMN created_by VARCHAR(20) NULL;
+ALTER TABLE sales ADD COLUMN updated_by VARCHAR(20) NULL;
+ALTER TABLE sales ADD COLUMN updated_at DATETIME NULL;
+ALTER TABLE sales ADD KEY idx_sales_created_by (created_by, sales_date);
+
+ALTER TABLE sale_items ADD COLUMN created_by VARCHAR(20) NULL;
+ALTER TABLE sale_items ADD COLUMN updated_by VARCHAR(20) NULL;
+ALTER TABLE sale_items ADD COLUMN updated_at DATETIME NULL;
+
+ALTER TABLE barang_logs ADD COLUMN created_by VARCHAR(20) NULL;
+ALTER TABLE barang_logs ADD COLUMN updated_by VARCHAR(20) NULL;
+ALTER TABLE barang_logs ADD COLUMN updated_at DATETIME NULL;
+ALTER TABLE barang_logs ADD KEY idx_barang_logs_created_by (created_by, logs_date);
+
+ALTER TABLE orders_masuk ADD COLUMN created_by VARCHAR(20) NULL;
+ALTER TABLE orders_masuk ADD COLUMN updated_by VARCHAR(20) NULL;
+ALTER TABLE orders_masuk ADD COLUMN updated_at DATETIME NULL;
+
+ALTER TABLE orders_keluar ADD COLUMN created_by VARCHAR(20) NULL;
+ALTER TABLE orders_keluar ADD COLUMN updated_by VARCHAR(20) NULL;
+ALTER TABLE orders_keluar ADD COLUMN updated_at DATETIME NULL;
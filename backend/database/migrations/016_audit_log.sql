@@ -0,0 +1,28 @@
+-- Audit trail of master data and configuration changes
+-- One row per mutating request: entity and entity_id, the action, the changed
+-- fields as JSON ({"field": {"before": .., "after": ..}}), the acting user
+-- (X-Users-ID), client IP and time. Password hashes are never written.
+-- audit_id is AUTO_INCREMENT instead of a prefixed id: rows are written
+-- outside the handlers' transactions and must never collide.
+-- The table is append-only: the triggers below reject UPDATE and DELETE, and
+-- the API has no endpoint that changes audit rows.
+
+CREATE TABLE audit_log (
+    audit_id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    audit_entity VARCHAR(30) NOT NULL,
+    entity_id VARCHAR(50) NOT NULL,
+    audit_action VARCHAR(30) NOT NULL,
+    audit_changes TEXT NULL,
+    users_id VARCHAR(20) NULL,
+    audit_ip VARCHAR(45) NULL,
+    audit_date DATETIME NOT NULL,
+    KEY idx_audit_entity (audit_entity, entity_id),
+    KEY idx_audit_users (users_id, audit_date),
+    KEY idx_audit_date (audit_date)
+);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
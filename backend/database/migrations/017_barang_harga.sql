@@ -0,0 +1,31 @@
+-- Price history and scheduled price changes per barang
+-- Every cost (harga_asli) / selling price (harga_jual) a barang has had, with
+-- the date it takes effect. barang.barang_harga_asli / barang_harga_jual stay
+-- the current price; the history is what reports and quotations read for
+-- other dates.
+-- harga_status: 1 Terjadwal (scheduled, not yet applied to barang),
+-- 2 Berlaku (applied), 0 Dibatalkan (cancelled schedule, never applied).
+-- A due schedule is valid from berlaku_mulai on even before the background job
+-- has copied it to barang.
+
+CREATE TABLE barang_harga (
+    harga_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    barang_id VARCHAR(20) NOT NULL,
+    harga_asli INT NOT NULL,
+    harga_jual INT NOT NULL,
+    berlaku_mulai DATE NOT NULL,
+    harga_status TINYINT NOT NULL DEFAULT 2,
+    harga_note VARCHAR(255) NULL,
+    created_by VARCHAR(20) NULL,
+    created_at DATETIME NOT NULL,
+    applied_at DATETIME NULL,
+    KEY idx_barang_harga_barang (barang_id, berlaku_mulai),
+    KEY idx_barang_harga_status (harga_status, berlaku_mulai)
+);
+
+-- The price known today is the oldest entry; there is no record of earlier
+-- prices, so it also answers for dates before this migration.
+INSERT INTO barang_harga (harga_id, barang_id, harga_asli, harga_jual, berlaku_mulai, harga_status, harga_note, created_at, applied_at)
+SELECT CONCAT('HH_', LPAD(ROW_NUMBER() OVER (ORDER BY barang_id), 7, '0')), barang_id,
+       barang_harga_asli, barang_harga_jual, '1970-01-01', 2, 'Harga awal', NOW(), NOW()
+FROM barang;
@@ -0,0 +1,31 @@
+-- Customer price lists with quantity-break tiers
+-- daftar_tipe: retail, reseller, grosir or custom. A customer is assigned at
+-- most one list (customer.daftar_id); customers without one pay
+-- barang_harga_jual. A list item prices one barang from min_qty pieces on;
+-- the item with the highest min_qty not above the line amount applies, barang
+-- missing from the list fall back to barang_harga_jual.
+
+CREATE TABLE daftar_harga (
+    daftar_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    daftar_nama VARCHAR(100) NOT NULL,
+    daftar_tipe VARCHAR(10) NOT NULL,
+    daftar_status TINYINT NOT NULL DEFAULT 1,
+    daftar_note VARCHAR(255) NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE daftar_harga_item (
+    item_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    daftar_id VARCHAR(20) NOT NULL,
+    barang_id VARCHAR(20) NOT NULL,
+    min_qty INT NOT NULL DEFAULT 1,
+    harga INT NOT NULL,
+    UNIQUE KEY uq_daftar_harga_item (daftar_id, barang_id, min_qty)
+);
+
+ALTER TABLE customer ADD COLUMN daftar_id VARCHAR(20) NULL;
+
+INSERT INTO daftar_harga (daftar_id, daftar_nama, daftar_tipe, created_at) VALUES
+    ('DH_0001', 'Retail', 'retail', NOW()),
+    ('DH_0002', 'Reseller', 'reseller', NOW()),
+    ('DH_0003', 'Grosir', 'grosir', NOW());
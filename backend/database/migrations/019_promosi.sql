@@ -0,0 +1,55 @@
+-- Rule-based promotions
+-- promo_tipe:
+--   persen            promo_nilai % off each line from promo_min_qty pieces on
+--   potongan          promo_nilai rupiah off per piece from promo_min_qty pieces on
+--   beli_gratis       buy promo_min_qty, get promo_gratis_qty of the same barang free
+--   bundle            every promo_min_qty pieces of a barang cost promo_nilai together
+--   belanja_persen    promo_nilai % off the in-scope lines once they reach promo_min_belanja
+--   belanja_potongan  promo_nilai rupiah off the in-scope lines once they reach promo_min_belanja
+-- promo_scope: semua, barang (promo_barang_id) or brand (promo_brand_id).
+-- promo_daftar_id limits the promotion to customers on that price list
+-- (customer group), NULL is every customer. promo_maks caps the discount a
+-- promotion gives on one sale, 0 is no cap.
+-- Promotions are evaluated on the sales date in promo_prioritas order (lowest
+-- first). A promotion that is not stackable only applies to lines no earlier
+-- promotion discounted, and no later promotion touches the lines it
+-- discounted.
+
+CREATE TABLE promosi (
+    promo_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    promo_nama VARCHAR(100) NOT NULL,
+    promo_tipe VARCHAR(20) NOT NULL,
+    promo_scope VARCHAR(10) NOT NULL DEFAULT 'semua',
+    promo_barang_id VARCHAR(20) NULL,
+    promo_brand_id VARCHAR(20) NULL,
+    promo_daftar_id VARCHAR(20) NULL,
+    promo_nilai DECIMAL(12,2) NOT NULL DEFAULT 0,
+    promo_min_qty INT NOT NULL DEFAULT 1,
+    promo_gratis_qty INT NOT NULL DEFAULT 0,
+    promo_min_belanja INT NOT NULL DEFAULT 0,
+    promo_maks INT NOT NULL DEFAULT 0,
+    promo_mulai DATE NOT NULL,
+    promo_selesai DATE NOT NULL,
+    promo_prioritas INT NOT NULL DEFAULT 100,
+    promo_stackable TINYINT NOT NULL DEFAULT 0,
+    promo_status TINYINT NOT NULL DEFAULT 1,
+    promo_note VARCHAR(255) NULL,
+    created_by VARCHAR(20) NULL,
+    created_at DATETIME NOT NULL,
+    KEY idx_promosi_periode (promo_status, promo_mulai, promo_selesai)
+);
+
+-- Promotions applied to each sale line, rewritten whenever the sale is priced
+CREATE TABLE sales_promosi (
+    sale_items_id VARCHAR(20) NOT NULL,
+    promo_id VARCHAR(20) NOT NULL,
+    sales_id VARCHAR(20) NOT NULL,
+    potongan INT NOT NULL,
+    PRIMARY KEY (sale_items_id, promo_id),
+    KEY idx_sales_promosi_sales (sales_id),
+    KEY idx_sales_promosi_promo (promo_id)
+);
+
+-- Total promotion discount of the line; the line is worth
+-- sale_items_amount * sale_value - sale_promo_potongan
+ALTER TABLE sale_items ADD COLUMN sale_promo_potongan INT NOT NULL DEFAULT 0;
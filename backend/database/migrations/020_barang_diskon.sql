@@ -0,0 +1,72 @@
+-- Typed barang discounts with start/end dates and history
+-- diskon_tipe: persen (diskon_nilai % off barang_harga_jual) or nominal
+-- (diskon_nilai rupiah off per piece). A discount is valid from diskon_mulai
+-- through diskon_selesai (NULL: no end date).
+-- diskon_status: 1 Aktif (running or scheduled), 2 Berakhir (expired or
+-- ended early, diskon_selesai is the last valid day), 0 Dibatalkan (removed
+-- before it started, never valid).
+-- barang.barang_diskon_tipe / barang_diskon_nilai / barang_diskon_mulai /
+-- barang_deadline_diskon hold the discount running today; barang_diskon is
+-- its display text ("10%", "Rp5000"). The discount scheduler keeps them in
+-- step with the history.
+
+CREATE TABLE barang_diskon_history (
+    diskon_id VARCHAR(20) NOT NULL PRIMARY KEY,
+    barang_id VARCHAR(20) NOT NULL,
+    diskon_tipe VARCHAR(10) NOT NULL,
+    diskon_nilai DECIMAL(12,2) NOT NULL,
+    diskon_mulai DATE NOT NULL,
+    diskon_selesai DATE NULL,
+    diskon_status TINYINT NOT NULL DEFAULT 1,
+    diskon_note VARCHAR(255) NULL,
+    created_by VARCHAR(20) NULL,
+    created_at DATETIME NOT NULL,
+    ended_by VARCHAR(20) NULL,
+    ended_at DATETIME NULL,
+    KEY idx_barang_diskon_barang (barang_id, diskon_mulai),
+    KEY idx_barang_diskon_status (diskon_status, diskon_selesai)
+);
+
+ALTER TABLE barang
+    ADD COLUMN barang_diskon_tipe VARCHAR(10) NULL,
+    ADD COLUMN barang_diskon_nilai DECIMAL(12,2) NULL,
+    ADD COLUMN barang_diskon_mulai DATE NULL;
+
+-- Existing discounts were percentages typed as text ("10", "10%", "12,5 %");
+-- anything else ("-", out of range) was never applied and is dropped
+UPDATE barang
+SET barang_diskon_tipe = 'persen',
+    barang_diskon_nilai = CAST(REPLACE(TRIM(TRAILING '%' FROM TRIM(barang_diskon)), ',', '.') AS DECIMAL(12,2)),
+    barang_diskon_mulai = '1970-01-01'
+WHERE TRIM(barang_diskon) REGEXP '^[0-9]+([.,][0-9]+)? *%?$';
+
+UPDATE barang
+SET barang_diskon_tipe = NULL, barang_diskon_nilai = NULL, barang_diskon_mulai = NULL
+WHERE barang_diskon_nilai <= 0 OR barang_diskon_nilai > 100;
+
+UPDATE barang
+SET barang_deadline_diskon = NULL
+WHERE barang_diskon_tipe IS NULL OR barang_deadline_diskon NOT REGEXP '^[0-9]{4}-[0-9]{2}-[0-9]{2}';
+
+UPDATE barang
+SET barang_deadline_diskon = LEFT(barang_deadline_diskon, 10)
+WHERE barang_deadline_diskon IS NOT NULL;
+
+INSERT INTO barang_diskon_history (diskon_id, barang_id, diskon_tipe, diskon_nilai, diskon_mulai, diskon_selesai,
+    diskon_status, diskon_note, created_at)
+SELECT CONCAT('BD_', LPAD(ROW_NUMBER() OVER (ORDER BY barang_id), 7, '0')), barang_id, barang_diskon_tipe,
+       barang_diskon_nilai, barang_diskon_mulai, barang_deadline_diskon,
+       IF(barang_deadline_diskon IS NOT NULL AND barang_deadline_diskon < CURDATE(), 2, 1), 'Diskon awal', NOW()
+FROM barang
+WHERE barang_diskon_tipe IS NOT NULL;
+
+-- Expired discounts stay in the history only
+UPDATE barang
+SET barang_diskon = NULL, barang_diskon_tipe = NULL, barang_diskon_nilai = NULL,
+    barang_diskon_mulai = NULL, barang_deadline_diskon = NULL
+WHERE barang_diskon_tipe IS NULL
+   OR (barang_deadline_diskon IS NOT NULL AND barang_deadline_diskon < CURDATE());
+
+UPDATE barang
+SET barang_diskon = CONCAT(TRIM(TRAILING '.' FROM TRIM(TRAILING '0' FROM barang_diskon_nilai)), '%')
+WHERE barang_diskon_tipe = 'persen';
@@ -0,0 +1,12 @@
+-- Full-text indexes for /search
+-- The ngram parser indexes every ngram_token_size (default 2) character slice
+-- of a name, so a misspelled query still shares most of its tokens with the
+-- name it was meant for. InnoDB keeps the indexes up to date on every write;
+-- /search ranks the candidates itself. Document numbers (sales_id,
+-- sales_invoice_no, logs_id, logs_po_no) are matched on their own keys.
+
+ALTER TABLE barang ADD FULLTEXT INDEX ft_barang_nama (barang_nama) WITH PARSER ngram;
+
+ALTER TABLE brand ADD FULLTEXT INDEX ft_brand_nama (brand_nama) WITH PARSER ngram;
+
+ALTER TABLE customer ADD FULLTEXT INDEX ft_customer (customer_nama, customer_kontak) WITH PARSER ngram;
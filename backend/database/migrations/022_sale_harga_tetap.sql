@@ -0,0 +1,4 @@
+-- Lines converted from an accepted penawaran keep the quoted price: the
+-- quote's harga_akhir already holds the price list and barang discount, so
+-- terapkanPromosi leaves these lines alone
+ALTER TABLE sale_items ADD COLUMN sale_harga_tetap TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- Credit note of a cancelled invoiced sale. The invoice keeps its number; the
+-- credit note (series credit_note) is taken in the cancel transaction.
+ALTER TABLE sales ADD COLUMN sales_credit_note_no VARCHAR(50) NULL AFTER sales_invoice_no;
+ALTER TABLE sales ADD UNIQUE KEY uq_sales_credit_note_no (sales_credit_note_no);
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"src/database"
 	"src/router"
@@ -18,7 +19,8 @@ func enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Users-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count, Content-Disposition")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -44,6 +46,9 @@ func handleRoutes() {
 	// Business routes
 	router.SetupBrandRoutes(r)
 	router.SetupGudangRoutes(r)
+	router.SetupLantaiRoutes(r)
+	router.SetupLokasiRoutes(r)
+	router.SetupKapasitasRoutes(r)
 	router.SetupBarangRoutes(r)
 	router.SetupCustomerRoutes(r)
 	router.SetupBarangLogsRoutes(r)
@@ -51,6 +56,23 @@ func handleRoutes() {
 	router.SetupOrdersOutRoutes(r)
 	router.SetupDiscountRoutes(r)
 	router.SetupSalesRoutes(r)
+	router.SetupSalesStatusRoutes(r)
+	router.SetupHargaRoutes(r)
+	router.SetupHargaMassalRoutes(r)
+	router.SetupDaftarHargaRoutes(r)
+	router.SetupPromosiRoutes(r)
+	router.SetupPenawaranRoutes(r)
+	router.SetupSuratJalanRoutes(r)
+	router.SetupPickListRoutes(r)
+	router.SetupDokumenRoutes(r)
+	router.SetupNomorRoutes(r)
+	router.SetupPajakRoutes(r)
+	router.SetupPembayaranRoutes(r)
+	router.SetupKasirRoutes(r)
+	router.SetupTrashRoutes(r)
+	router.SetupImportRoutes(r)
+	router.SetupAuditRoutes(r)
+	router.SetupSearchRoutes(r)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -73,5 +95,9 @@ func main() {
 
 	log.Println("✅ Successfully connected to database")
 
+	// Apply scheduled price changes as they come due
+	router.StartHargaScheduler(time.Hour)
+	router.StartDiskonScheduler(time.Hour)
+
 	handleRoutes()
 }
@@ -0,0 +1,374 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"src/database"
+	"strconv"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// auditTable is where the rows of an audited entity live
+type auditTable struct {
+	Table string
+	Key   string
+}
+
+// Entities whose changes are written to audit_log
+var auditEntities = map[string]auditTable{
+	"barang":            {"barang", "barang_id"},
+	"brand":             {"brand", "brand_id"},
+	"customer":          {"customer", "customer_id"},
+	"daftar_harga":      {"daftar_harga", "daftar_id"},
+	"daftar_harga_item": {"daftar_harga_item", "item_id"},
+	"promosi":           {"promosi", "promo_id"},
+	"gudang":            {"list_gudang", "gudang_id"},
+	"lantai":            {"gudang_lantai", "lantai_id"},
+	"lokasi":            {"gudang_lokasi", "lokasi_id"},
+	"users":             {"users", "users_id"},
+	"pajak_setting":     {"pajak_setting", "setting_id"},
+	"nomor_seri":        {"nomor_seri", "seri_type"},
+	"barang_harga":      {"barang_harga", "harga_id"},
+	"sales":             {"sales", "sales_id"},
+	"sale_items":        {"sale_items", "sale_items_id"},
+	"orders_masuk":      {"orders_masuk", "orders_id"},
+	"orders_keluar":     {"orders_keluar", "orders_id"},
+	"kasir_shift":       {"kasir_shift", "shift_id"},
+	"kasir_kas":         {"kasir_kas", "kas_id"},
+	"penawaran":         {"penawaran", "penawaran_id"},
+	"surat_jalan":       {"surat_jalan", "sj_id"},
+	"pick_list":         {"pick_list", "pick_id"},
+	"barang_logs":       {"barang_logs", "logs_id"},
+	"stock_gudang":      {"stock_gudang", "stock_id"},
+	"stock_lokasi":      {"stock_lokasi", "stock_lokasi_id"},
+}
+
+// Columns that are audited as changed without their values
+var auditRedacted = map[string]bool{
+	"users_pass": true,
+}
+
+// AuditLog is one audited change
+type AuditLog struct {
+	AuditID   int64                  `json:"audit_id"`
+	Entity    string                 `json:"audit_entity"`
+	EntityID  string                 `json:"entity_id"`
+	Action    string                 `json:"audit_action"`
+	Changes   map[string]auditChange `json:"audit_changes"`
+	UsersID   string                 `json:"users_id,omitempty"`
+	UsersNama string                 `json:"users_nama,omitempty"`
+	IP        string                 `json:"audit_ip,omitempty"`
+	Date      string                 `json:"audit_date"`
+}
+
+type auditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// auditSnapshot reads the current row of an entity as column -> value
+// (nil for NULL). Returns nil when the row does not exist.
+func auditSnapshot(q sqlExecutor, entity, id string) (map[string]interface{}, error) {
+	t, ok := auditEntities[entity]
+	if !ok {
+		return nil, fmt.Errorf("entity '%s' is not audited", entity)
+	}
+	rows, err := q.Query("SELECT * FROM "+t.Table+" WHERE "+t.Key+" = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	snapshot := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if values[i] == nil {
+			snapshot[column] = nil
+		} else {
+			snapshot[column] = string(values[i])
+		}
+	}
+	return snapshot, nil
+}
+
+// auditDiff lists the fields that differ between two snapshots. A nil before
+// is a create (every field is new), a nil after a delete.
+func auditDiff(before, after map[string]interface{}) map[string]auditChange {
+	changes := map[string]auditChange{}
+	for column, value := range after {
+		old, existed := before[column]
+		if before != nil && existed && old == value {
+			continue
+		}
+		changes[column] = auditChange{Before: old, After: value}
+	}
+	for column, old := range before {
+		if _, ok := after[column]; !ok {
+			changes[column] = auditChange{Before: old, After: nil}
+		}
+	}
+	for column, change := range changes {
+		if auditRedacted[column] {
+			changes[column] = auditChange{Before: "[redacted]", After: "[redacted]"}
+			if change.Before == nil {
+				changes[column] = auditChange{After: "[redacted]"}
+			}
+		}
+	}
+	return changes
+}
+
+// requestIP is the client address, honouring a reverse proxy's X-Forwarded-For
+func requestIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		return realIP
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// auditTrail captures an entity before a change and records the difference
+// afterwards:
+//
+//	audit := startAudit(db, r, "barang", id)
+//	... change the row ...
+//	audit.record("update")
+type auditTrail struct {
+	q        sqlExecutor
+	r        *http.Request
+	entity   string
+	entityID string
+	before   map[string]interface{}
+}
+
+func startAudit(q sqlExecutor, r *http.Request, entity, entityID string) *auditTrail {
+	before, err := auditSnapshot(q, entity, entityID)
+	if err != nil {
+		log.Printf("audit: reading %s %s: %v", entity, entityID, err)
+	}
+	return &auditTrail{q: q, r: r, entity: entity, entityID: entityID, before: before}
+}
+
+// auditCreated records a row inserted by code that generates its own id, so
+// there was nothing to capture before
+func auditCreated(q sqlExecutor, r *http.Request, entity, entityID, action string) {
+	a := &auditTrail{q: q, r: r, entity: entity, entityID: entityID}
+	a.record(action)
+}
+
+// record writes the change made since startAudit. An update that changed
+// nothing is not written. Failures are logged, the request itself already
+// succeeded.
+func (a *auditTrail) record(action string) {
+	after, err := auditSnapshot(a.q, a.entity, a.entityID)
+	if err != nil {
+		log.Printf("audit: reading %s %s: %v", a.entity, a.entityID, err)
+		return
+	}
+	if a.before == nil && after == nil {
+		return
+	}
+	changes := auditDiff(a.before, after)
+	if len(changes) == 0 {
+		return
+	}
+	if err := writeAudit(a.q, a.r, a.entity, a.entityID, action, changes); err != nil {
+		log.Printf("audit: %v", err)
+	}
+}
+
+// rowsAudit audits every row of an entity matching a filter, such as the
+// stock rows of a barang, including the rows the change inserts
+type rowsAudit struct {
+	q      sqlExecutor
+	r      *http.Request
+	entity string
+	where  string
+	args   []interface{}
+	trails map[string]*auditTrail
+	order  []string
+}
+
+func startRowsAudit(q sqlExecutor, r *http.Request, entity, where string, args ...interface{}) *rowsAudit {
+	a := &rowsAudit{q: q, r: r, entity: entity, where: where, args: args, trails: map[string]*auditTrail{}}
+	ids, err := a.ids()
+	if err != nil {
+		log.Printf("audit: reading %s: %v", entity, err)
+	}
+	for _, id := range ids {
+		a.trails[id] = startAudit(q, r, entity, id)
+		a.order = append(a.order, id)
+	}
+	return a
+}
+
+func (a *rowsAudit) ids() ([]string, error) {
+	t := auditEntities[a.entity]
+	rows, err := a.q.Query("SELECT "+t.Key+" FROM "+t.Table+" WHERE "+a.where+" ORDER BY "+t.Key, a.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// record writes the change of every row seen by startRowsAudit or matching
+// the filter now
+func (a *rowsAudit) record(action string) {
+	ids, err := a.ids()
+	if err != nil {
+		log.Printf("audit: reading %s: %v", a.entity, err)
+	}
+	for _, id := range ids {
+		if _, ok := a.trails[id]; !ok {
+			a.trails[id] = &auditTrail{q: a.q, r: a.r, entity: a.entity, entityID: id}
+			a.order = append(a.order, id)
+		}
+	}
+	for _, id := range a.order {
+		a.trails[id].record(action)
+	}
+}
+
+// writeAudit appends one row to audit_log. The actor is the X-Users-ID user
+// only when it resolves to an active user; anything else is stored as NULL.
+func writeAudit(q sqlExecutor, r *http.Request, entity, entityID, action string, changes map[string]auditChange) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("error encoding audit changes: %v", err)
+	}
+	user, _ := requestUser(q, r) // nil for a missing, unknown or inactive user
+	_, err = q.Exec(`INSERT INTO audit_log (audit_entity, entity_id, audit_action, audit_changes, users_id, audit_ip, audit_date)
+		VALUES (?, ?, ?, ?, ?, ?, NOW())`,
+		entity, entityID, action, string(data),
+		requestUserID(user), processNullableStringValue(requestIP(r)))
+	if err != nil {
+		return fmt.Errorf("error writing audit log for %s %s: %v", entity, entityID, err)
+	}
+	return nil
+}
+
+// getAuditLog lists audit rows for admins, newest first. Filters: entity,
+// entity_id, action, users_id, date_from, date_to (YYYY-MM-DD), limit.
+func getAuditLog(w http.ResponseWriter, r *http.Request) {
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if user == nil || user.UsersLevel != 1 {
+		respondWithError(w, http.StatusForbidden, "Only admin can read the audit log")
+		return
+	}
+
+	query := `
+		SELECT a.audit_id, a.audit_entity, a.entity_id, a.audit_action, a.audit_changes,
+		       a.users_id, u.users_nama, a.audit_ip, a.audit_date
+		FROM audit_log a
+		LEFT JOIN users u ON a.users_id = u.users_id
+		WHERE 1=1`
+	var args []interface{}
+	filters := []struct{ param, condition string }{
+		{"entity", "a.audit_entity = ?"},
+		{"entity_id", "a.entity_id = ?"},
+		{"action", "a.audit_action = ?"},
+		{"users_id", "a.users_id = ?"},
+		{"date_from", "a.audit_date >= ?"},
+		{"date_to", "a.audit_date < DATE_ADD(?, INTERVAL 1 DAY)"},
+	}
+	for _, f := range filters {
+		if value := r.URL.Query().Get(f.param); value != "" {
+			query += " AND " + f.condition
+			args = append(args, value)
+		}
+	}
+	limit := 500
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			respondWithError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+	query += " ORDER BY a.audit_id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	entries := []AuditLog{}
+	for rows.Next() {
+		var a AuditLog
+		var changes, usersID, usersNama, ip sql.NullString
+		if err := rows.Scan(&a.AuditID, &a.Entity, &a.EntityID, &a.Action, &changes,
+			&usersID, &usersNama, &ip, &a.Date); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		if changes.Valid {
+			if err := json.Unmarshal([]byte(changes.String), &a.Changes); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Error decoding audit changes: "+err.Error())
+				return
+			}
+		}
+		a.UsersID = usersID.String
+		a.UsersNama = usersNama.String
+		a.IP = ip.String
+		entries = append(entries, a)
+	}
+
+	respondWithJSON(w, entries)
+}
+
+// SetupAuditRoutes sets up the audit log routes. Audit rows are read-only:
+// there is deliberately no route to change or delete them.
+func SetupAuditRoutes(router *mux.Router) {
+	router.HandleFunc("/getauditlog", getAuditLog).Methods("GET")
+}
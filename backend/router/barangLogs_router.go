@@ -9,6 +9,7 @@ import (
 	"sort"
 	"src/database"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -22,6 +23,22 @@ type BarangLogs struct {
 	LogsDesc   string `json:"logs_desc"`
 }
 
+// logsAttribution holds who created and last changed a barang log
+type logsAttribution struct {
+	CreatedBy, CreatedByNama sql.NullString
+	UpdatedBy, UpdatedByNama sql.NullString
+	UpdatedAt                sql.NullString
+}
+
+// Helper function to add the attribution fields to a log response
+func (a logsAttribution) addTo(log map[string]interface{}) {
+	log["created_by"] = a.CreatedBy.String
+	log["created_by_nama"] = a.CreatedByNama.String
+	log["updated_by"] = a.UpdatedBy.String
+	log["updated_by_nama"] = a.UpdatedByNama.String
+	log["updated_at"] = a.UpdatedAt.String
+}
+
 type BarangLogsRequest struct {
 	LogsStatus int    `json:"logs_status"`
 	LogsDate   string `json:"logs_date"`
@@ -52,6 +69,12 @@ func createBarangLogs(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
 	// Get last logs_id
 	var lastID string
 	err = db.QueryRow("SELECT logs_id FROM barang_logs ORDER BY logs_id DESC LIMIT 1").Scan(&lastID)
@@ -75,24 +98,27 @@ func createBarangLogs(w http.ResponseWriter, r *http.Request) {
 		logsDate = logs.LogsDate
 	}
 
-	stmt, err := db.Prepare("INSERT INTO barang_logs (logs_id, logs_status, logs_date, logs_desc) VALUES (?, ?, ?, ?)")
+	stmt, err := db.Prepare("INSERT INTO barang_logs (logs_id, logs_status, logs_date, logs_desc, created_by) VALUES (?, ?, ?, ?, ?)")
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Prepare statement error")
 		return
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(newID, logs.LogsStatus, logsDate, logs.LogsDesc)
+	audit := startAudit(db, r, "barang_logs", newID)
+	_, err = stmt.Exec(newID, logs.LogsStatus, logsDate, logs.LogsDesc, requestUserID(user))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Insert error: "+err.Error())
 		return
 	}
+	audit.record("create")
 
 	respondWithJSON(w, map[string]interface{}{
 		"logs_id":     newID,
 		"logs_status": logs.LogsStatus,
 		"logs_date":   logsDate,
 		"logs_desc":   logs.LogsDesc,
+		"created_by":  requestUserID(user),
 		"status":      "Created",
 		"message":     "Barang logs created successfully",
 	})
@@ -106,9 +132,58 @@ func getBarangLogs(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	// Get query parameters for filtering
-	statusFilter := r.URL.Query().Get("status") // Filter by logs_status
-	dateFilter := r.URL.Query().Get("date")     // Filter by logs_date
+	list, err := parseListQuery(r, barangLogsListSpec, "bl.logs_status IN (1, 2)")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	statusFilter := r.URL.Query().Get("status") // Only query the matching side
+
+	if format := exportFormat(r); format != "" {
+		exportBarangLogs(w, db, format, list)
+		return
+	}
+
+	// The logs of this page in order, picked from barang_logs alone
+	limit, limitArgs := list.limitSQL()
+	idRows, err := db.Query("SELECT bl.logs_id FROM barang_logs bl"+list.whereSQL()+" ORDER BY "+list.orderBy+limit,
+		append(list.args, limitArgs...)...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	var logsIDs []interface{}
+	logsPos := make(map[string]int)
+	for idRows.Next() {
+		var id string
+		if err := idRows.Scan(&id); err != nil {
+			idRows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		logsPos[id] = len(logsIDs)
+		logsIDs = append(logsIDs, id)
+	}
+	idRows.Close()
+
+	total, err := list.total(db, "COUNT(*)", "FROM barang_logs bl", len(logsIDs))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Count error: "+err.Error())
+		return
+	}
+	logs := []map[string]interface{}{}
+	if len(logsIDs) == 0 {
+		respondWithList(w, list, total, logs)
+		return
+	}
+
+	// Details of those logs: the same filters, or the ids of this page
+	filter, filterArgs := list.whereSQL(), list.args
+	if list.limit > 0 {
+		filter = " WHERE bl.logs_id IN (" + sqlPlaceholders(len(logsIDs)) + ")"
+		filterArgs = logsIDs
+	}
+	filter = " AND" + strings.TrimPrefix(filter, " WHERE")
 
 	// Query for logs_status = 1 (Masuk) from orders_masuk table
 	queryMasuk := `
@@ -117,6 +192,11 @@ func getBarangLogs(w http.ResponseWriter, r *http.Request) {
 			bl.logs_status,
 			bl.logs_date,
 			bl.logs_desc,
+			bl.created_by,
+			uc.users_nama,
+			bl.updated_by,
+			uu.users_nama,
+			bl.updated_at,
 			GROUP_CONCAT(
 				CONCAT_WS('|',
 					om.orders_id,
@@ -138,7 +218,9 @@ func getBarangLogs(w http.ResponseWriter, r *http.Request) {
 		LEFT JOIN barang b ON om.barang_id = b.barang_id
 		LEFT JOIN brand br ON b.brand_id = br.brand_id
 		LEFT JOIN list_gudang lg ON om.gudang_id = lg.gudang_id
-		WHERE bl.logs_status = 1`
+		LEFT JOIN users uc ON bl.created_by = uc.users_id
+		LEFT JOIN users uu ON bl.updated_by = uu.users_id
+		WHERE bl.logs_status = 1` + filter + " GROUP BY bl.logs_id"
 
 	// Query for logs_status = 2 (Keluar) from orders_keluar table
 	queryKeluar := `
@@ -147,6 +229,11 @@ func getBarangLogs(w http.ResponseWriter, r *http.Request) {
 			bl.logs_status,
 			bl.logs_date,
 			bl.logs_desc,
+			bl.created_by,
+			uc.users_nama,
+			bl.updated_by,
+			uu.users_nama,
+			bl.updated_at,
 			GROUP_CONCAT(
 				CONCAT_WS('|',
 					ok.orders_id,
@@ -168,30 +255,14 @@ func getBarangLogs(w http.ResponseWriter, r *http.Request) {
 		LEFT JOIN barang b ON ok.barang_id = b.barang_id
 		LEFT JOIN brand br ON b.brand_id = br.brand_id
 		LEFT JOIN list_gudang lg ON ok.gudang_id = lg.gudang_id
-		WHERE bl.logs_status = 2`
-
-	var argsMasuk, argsKeluar []interface{}
-
-	// Add filter conditions for Masuk
-	if dateFilter != "" {
-		queryMasuk += " AND bl.logs_date = ?"
-		argsMasuk = append(argsMasuk, dateFilter)
-	}
-	queryMasuk += " GROUP BY bl.logs_id"
-
-	// Add filter conditions for Keluar
-	if dateFilter != "" {
-		queryKeluar += " AND bl.logs_date = ?"
-		argsKeluar = append(argsKeluar, dateFilter)
-	}
-	queryKeluar += " GROUP BY bl.logs_id"
-
-	var logs []map[string]interface{}
+		LEFT JOIN users uc ON bl.created_by = uc.users_id
+		LEFT JOIN users uu ON bl.updated_by = uu.users_id
+		WHERE bl.logs_status = 2` + filter + " GROUP BY bl.logs_id"
 
 	// Determine which query to run based on status filter
 	if statusFilter == "" || statusFilter == "1" {
 		// Get Masuk orders
-		rowsMasuk, err := db.Query(queryMasuk, argsMasuk...)
+		rowsMasuk, err := db.Query(queryMasuk, filterArgs...)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Query error (masuk): "+err.Error())
 			return
@@ -203,10 +274,12 @@ func getBarangLogs(w http.ResponseWriter, r *http.Request) {
 				logsID             string
 				logsStatus         int
 				logsDate, logsDesc string
+				attribution        logsAttribution
 				ordersData         sql.NullString
 			)
 
-			if err := rowsMasuk.Scan(&logsID, &logsStatus, &logsDate, &logsDesc, &ordersData); err != nil {
+			if err := rowsMasuk.Scan(&logsID, &logsStatus, &logsDate, &logsDesc, &attribution.CreatedBy, &attribution.CreatedByNama,
+				&attribution.UpdatedBy, &attribution.UpdatedByNama, &attribution.UpdatedAt, &ordersData); err != nil {
 				respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
 				return
 			}
@@ -218,6 +291,7 @@ func getBarangLogs(w http.ResponseWriter, r *http.Request) {
 				"logs_desc":   logsDesc,
 				"orders":      []map[string]interface{}{},
 			}
+			attribution.addTo(log)
 
 			// Parse orders data
 			if ordersData.Valid && ordersData.String != "" {
@@ -231,7 +305,7 @@ func getBarangLogs(w http.ResponseWriter, r *http.Request) {
 
 	if statusFilter == "" || statusFilter == "2" {
 		// Get Keluar orders
-		rowsKeluar, err := db.Query(queryKeluar, argsKeluar...)
+		rowsKeluar, err := db.Query(queryKeluar, filterArgs...)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Query error (keluar): "+err.Error())
 			return
@@ -243,10 +317,12 @@ func getBarangLogs(w http.ResponseWriter, r *http.Request) {
 				logsID             string
 				logsStatus         int
 				logsDate, logsDesc string
+				attribution        logsAttribution
 				ordersData         sql.NullString
 			)
 
-			if err := rowsKeluar.Scan(&logsID, &logsStatus, &logsDate, &logsDesc, &ordersData); err != nil {
+			if err := rowsKeluar.Scan(&logsID, &logsStatus, &logsDate, &logsDesc, &attribution.CreatedBy, &attribution.CreatedByNama,
+				&attribution.UpdatedBy, &attribution.UpdatedByNama, &attribution.UpdatedAt, &ordersData); err != nil {
 				respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
 				return
 			}
@@ -258,6 +334,7 @@ func getBarangLogs(w http.ResponseWriter, r *http.Request) {
 				"logs_desc":   logsDesc,
 				"orders":      []map[string]interface{}{},
 			}
+			attribution.addTo(log)
 
 			// Parse orders data
 			if ordersData.Valid && ordersData.String != "" {
@@ -269,17 +346,31 @@ func getBarangLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Sort by logs_date DESC, logs_id DESC
+	// Put masuk and keluar back in the order of the id query
 	sort.Slice(logs, func(i, j int) bool {
-		dateI := logs[i]["logs_date"].(string)
-		dateJ := logs[j]["logs_date"].(string)
-		if dateI != dateJ {
-			return dateI > dateJ
-		}
-		return logs[i]["logs_id"].(string) > logs[j]["logs_id"].(string)
+		return logsPos[logs[i]["logs_id"].(string)] < logsPos[logs[j]["logs_id"].(string)]
 	})
 
-	respondWithJSON(w, logs)
+	respondWithList(w, list, total, logs)
+}
+
+// barangLogsListSpec is the list contract of getBarangLogs and its export
+var barangLogsListSpec = listSpec{
+	filters: []listFilter{
+		{"status", "bl.logs_status = ?"},
+		{"created_by", "bl.created_by = ?"},
+		{"date", "bl.logs_date = ?"},
+		{"date_from", "bl.logs_date >= ?"},
+		{"date_to", "bl.logs_date <= ?"},
+	},
+	search: []string{"bl.logs_id", "bl.logs_desc"},
+	sorts: map[string]string{
+		"date": "bl.logs_date",
+		"id":   "bl.logs_id",
+	},
+	sortDefault:  "date",
+	orderDefault: "desc",
+	key:          "bl.logs_id",
 }
 
 // Helper function to parse concatenated orders data
@@ -370,6 +461,11 @@ func getBarangLog(w http.ResponseWriter, r *http.Request) {
 			bl.logs_status,
 			bl.logs_date,
 			bl.logs_desc,
+			bl.created_by,
+			uc.users_nama,
+			bl.updated_by,
+			uu.users_nama,
+			bl.updated_at,
 			GROUP_CONCAT(
 				CONCAT_WS('|',
 					o.orders_id,
@@ -391,6 +487,8 @@ func getBarangLog(w http.ResponseWriter, r *http.Request) {
 		LEFT JOIN barang b ON o.barang_id = b.barang_id
 		LEFT JOIN brand br ON b.brand_id = br.brand_id
 		LEFT JOIN list_gudang lg ON o.gudang_id = lg.gudang_id
+		LEFT JOIN users uc ON bl.created_by = uc.users_id
+		LEFT JOIN users uu ON bl.updated_by = uu.users_id
 		WHERE bl.logs_id = ?
 		GROUP BY bl.logs_id`
 
@@ -398,11 +496,13 @@ func getBarangLog(w http.ResponseWriter, r *http.Request) {
 		logsID             string
 		logsStatus         int
 		logsDate, logsDesc string
+		attribution        logsAttribution
 		ordersData         sql.NullString
 	)
 
 	err = db.QueryRow(query, id).Scan(&logsID, &logsStatus,
-		&logsDate, &logsDesc, &ordersData)
+		&logsDate, &logsDesc, &attribution.CreatedBy, &attribution.CreatedByNama,
+		&attribution.UpdatedBy, &attribution.UpdatedByNama, &attribution.UpdatedAt, &ordersData)
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Barang logs not found")
 		return
@@ -418,6 +518,7 @@ func getBarangLog(w http.ResponseWriter, r *http.Request) {
 		"logs_desc":   logsDesc,
 		"orders":      []map[string]interface{}{},
 	}
+	attribution.addTo(log)
 
 	// Parse orders data
 	if ordersData.Valid && ordersData.String != "" {
@@ -511,6 +612,12 @@ func updateBarangLogs(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
 	// First check if logs exists
 	var existingLogsID string
 	err = db.QueryRow("SELECT logs_id FROM barang_logs WHERE logs_id = ?", id).Scan(&existingLogsID)
@@ -522,24 +629,28 @@ func updateBarangLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stmt, err := db.Prepare("UPDATE barang_logs SET logs_status = ?, logs_date = ?, logs_desc = ? WHERE logs_id = ?")
+	audit := startAudit(db, r, "barang_logs", id)
+
+	stmt, err := db.Prepare("UPDATE barang_logs SET logs_status = ?, logs_date = ?, logs_desc = ?, updated_by = ?, updated_at = NOW() WHERE logs_id = ?")
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Prepare statement error")
 		return
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(logs.LogsStatus, logs.LogsDate, logs.LogsDesc, id)
+	_, err = stmt.Exec(logs.LogsStatus, logs.LogsDate, logs.LogsDesc, requestUserID(user), id)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
 		return
 	}
+	audit.record("update")
 
 	respondWithJSON(w, map[string]interface{}{
 		"logs_id":     id,
 		"logs_status": logs.LogsStatus,
 		"logs_date":   logs.LogsDate,
 		"logs_desc":   logs.LogsDesc,
+		"updated_by":  requestUserID(user),
 		"status":      "Updated",
 		"message":     "Barang logs updated successfully",
 	})
@@ -628,6 +739,13 @@ func deleteBarangLogs(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tx.Rollback() // Will be no-op if commit succeeds
 
+	ordersEntity := "orders_masuk"
+	if logsStatus == 2 {
+		ordersEntity = "orders_keluar"
+	}
+	audit := startAudit(tx, r, "barang_logs", id)
+	ordersAudit := startRowsAudit(tx, r, ordersEntity, "logs_id = ?", id)
+
 	// Delete orders first (cascading delete)
 	if logsStatus == 1 {
 		_, err = tx.Exec("DELETE FROM orders_masuk WHERE logs_id = ?", id)
@@ -663,6 +781,9 @@ func deleteBarangLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ordersAudit.record("delete")
+	audit.record("delete")
+
 	// Commit the transaction
 	if err := tx.Commit(); err != nil {
 		log.Printf("Error committing transaction: %v", err)
@@ -682,7 +803,7 @@ func deleteBarangLogs(w http.ResponseWriter, r *http.Request) {
 				err = db.QueryRow(`
 					SELECT lantai_id 
 					FROM gudang_lantai 
-					WHERE gudang_id = ? 
+					WHERE gudang_id = ? AND lantai_status = 1
 					ORDER BY lantai_no 
 					LIMIT 1`, update.GudangID).Scan(&lantaiID)
 
@@ -707,12 +828,12 @@ func deleteBarangLogs(w http.ResponseWriter, r *http.Request) {
 
 			if err != nil {
 				if err == sql.ErrNoRows {
-					warning := fmt.Sprintf("Stock record not found for barang_id=%s, lantai_id=%d", update.BarangID, lantaiID)
+					warning := fmt.Sprintf("Stock record not found for barang_id=%s, lantai_id=%s", update.BarangID, lantaiID)
 					log.Printf("Warning: %s", warning)
 					stockRestoreWarnings = append(stockRestoreWarnings, warning)
 					continue
 				} else {
-					warning := fmt.Sprintf("Error fetching stock for barang_id=%s, lantai_id=%d: %v", update.BarangID, lantaiID, err)
+					warning := fmt.Sprintf("Error fetching stock for barang_id=%s, lantai_id=%s: %v", update.BarangID, lantaiID, err)
 					log.Printf("Warning: %s", warning)
 					stockRestoreWarnings = append(stockRestoreWarnings, warning)
 					continue
@@ -733,10 +854,20 @@ func deleteBarangLogs(w http.ResponseWriter, r *http.Request) {
 				newStock = 0 // Prevent negative stock
 			}
 
+			stockAudit := startRowsAudit(db, r, "stock_gudang", "barang_id = ? AND lantai_id = ?", update.BarangID, lantaiID)
 			_, err = db.Exec("UPDATE stock_gudang SET stock_barang = ? WHERE barang_id = ? AND lantai_id = ?",
 				newStock, update.BarangID, lantaiID)
 			if err != nil {
-				warning := fmt.Sprintf("Error updating stock for barang_id=%s, lantai_id=%d: %v", update.BarangID, lantaiID, err)
+				warning := fmt.Sprintf("Error updating stock for barang_id=%s, lantai_id=%s: %v", update.BarangID, lantaiID, err)
+				log.Printf("Warning: %s", warning)
+				stockRestoreWarnings = append(stockRestoreWarnings, warning)
+				continue
+			}
+			stockAudit.record("restore")
+
+			// Keep bin stock within the reduced lantai total
+			if err := trimStockLokasi(db, update.BarangID, lantaiID); err != nil {
+				warning := fmt.Sprintf("Error adjusting lokasi stock for barang_id=%s, lantai_id=%s: %v", update.BarangID, lantaiID, err)
 				log.Printf("Warning: %s", warning)
 				stockRestoreWarnings = append(stockRestoreWarnings, warning)
 			}
@@ -768,3 +899,57 @@ func SetupBarangLogsRoutes(router *mux.Router) {
 	router.HandleFunc("/updatebaranglogs/{id}", updateBarangLogs).Methods("PUT")
 	router.HandleFunc("/deletebaranglogs/{id}", deleteBarangLogs).Methods("DELETE")
 }
+
+var barangLogsExportKolom = []exportKolom{
+	{"No. Log", exportTeks},
+	{"Tanggal", exportTanggal},
+	{"Jenis", exportTeks},
+	{"Keterangan", exportTeks},
+	{"Kode Barang", exportTeks},
+	{"Nama Barang", exportTeks},
+	{"Brand", exportTeks},
+	{"Gudang", exportTeks},
+	{"Jumlah", exportAngka},
+	{"Harga", exportRupiah},
+	{"Dibuat Oleh", exportTeks},
+}
+
+// exportBarangLogs streams the barang logs as a spreadsheet, one row per
+// order line, with the same filters and sort as getBarangLogs
+func exportBarangLogs(w http.ResponseWriter, db *sql.DB, format string, list *listQuery) {
+	filter := " AND" + strings.TrimPrefix(list.whereSQL(), " WHERE")
+	query := `
+		SELECT bl.logs_id, bl.logs_date, 'Masuk', bl.logs_desc, om.barang_id, b.barang_nama,
+		       br.brand_nama, lg.gudang_nama, om.orders_amount, om.orders_value, uc.users_nama
+		FROM barang_logs bl
+		JOIN orders_masuk om ON bl.logs_id = om.logs_id
+		LEFT JOIN barang b ON om.barang_id = b.barang_id
+		LEFT JOIN brand br ON b.brand_id = br.brand_id
+		LEFT JOIN list_gudang lg ON om.gudang_id = lg.gudang_id
+		LEFT JOIN users uc ON bl.created_by = uc.users_id
+		WHERE bl.logs_status = 1` + filter + `
+		UNION ALL
+		SELECT bl.logs_id, bl.logs_date, 'Keluar', bl.logs_desc, ok.barang_id, b.barang_nama,
+		       br.brand_nama, lg.gudang_nama, ok.orders_amount, NULL, uc.users_nama
+		FROM barang_logs bl
+		JOIN orders_keluar ok ON bl.logs_id = ok.logs_id
+		LEFT JOIN barang b ON ok.barang_id = b.barang_id
+		LEFT JOIN brand br ON b.brand_id = br.brand_id
+		LEFT JOIN list_gudang lg ON ok.gudang_id = lg.gudang_id
+		LEFT JOIN users uc ON bl.created_by = uc.users_id
+		WHERE bl.logs_status = 2` + filter
+
+	// A UNION is ordered by its own column names
+	query += " ORDER BY " + strings.ReplaceAll(list.orderBy, "bl.", "")
+
+	rows, err := db.Query(query, append(append([]interface{}{}, list.args...), list.args...)...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	if err := exportRows(w, format, "log-barang", barangLogsExportKolom, rows); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Export error: "+err.Error())
+	}
+}
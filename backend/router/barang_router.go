@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"sort"
 	"src/database"
 	"strconv"
 	"strings"
@@ -115,18 +114,10 @@ func processNullableStringValue(value string) interface{} {
 	return value
 }
 
-// Helper function to handle deadline diskon date values
-func processDeadlineDiskonValue(deadline string) interface{} {
-	if deadline == "" || deadline == "-" {
-		return nil
-	}
-	return deadline
-}
-
 // Helper function to get brand_id from brand_nama
-func getBrandIDFromName(db *sql.DB, brandNama string) (string, error) {
+func getBrandIDFromName(db sqlExecutor, brandNama string) (string, error) {
 	var brandID string
-	err := db.QueryRow("SELECT brand_id FROM brand WHERE brand_nama = ?", brandNama).Scan(&brandID)
+	err := db.QueryRow("SELECT brand_id FROM brand WHERE brand_nama = ? AND deleted_at IS NULL", brandNama).Scan(&brandID)
 	if err == sql.ErrNoRows {
 		return "", fmt.Errorf("invalid brand_nama: brand '%s' does not exist", brandNama)
 	} else if err != nil {
@@ -136,11 +127,11 @@ func getBrandIDFromName(db *sql.DB, brandNama string) (string, error) {
 }
 
 // Helper function to update stock information
-func updateStockInfo(db *sql.DB, barangID string, stockUpdates []StockUpdateInfo) error {
+func updateStockInfo(db sqlExecutor, barangID string, stockUpdates []StockUpdateInfo) error {
 	for i, stockUpdate := range stockUpdates {
 		// Get gudang_id from gudang_nama
 		var gudangID string
-		err := db.QueryRow("SELECT gudang_id FROM list_gudang WHERE gudang_nama = ?", stockUpdate.GudangNama).Scan(&gudangID)
+		err := db.QueryRow("SELECT gudang_id FROM list_gudang WHERE gudang_nama = ? AND gudang_status = 1", stockUpdate.GudangNama).Scan(&gudangID)
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("invalid gudang_nama at index %d: '%s' does not exist", i, stockUpdate.GudangNama)
 		} else if err != nil {
@@ -149,7 +140,7 @@ func updateStockInfo(db *sql.DB, barangID string, stockUpdates []StockUpdateInfo
 
 		// Get the first lantai_id for this gudang (default to lantai 1)
 		var lantaiID string
-		err = db.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? ORDER BY lantai_no LIMIT 1", gudangID).Scan(&lantaiID)
+		err = db.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1 ORDER BY lantai_no LIMIT 1", gudangID).Scan(&lantaiID)
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("no floors found for gudang '%s' at index %d", stockUpdate.GudangNama, i)
 		} else if err != nil {
@@ -192,16 +183,21 @@ func updateStockInfo(db *sql.DB, barangID string, stockUpdates []StockUpdateInfo
 				return fmt.Errorf("error updating stock record for gudang '%s' at index %d: %v", stockUpdate.GudangNama, i, err)
 			}
 		}
+
+		// Keep bin stock within the new lantai total
+		if err := trimStockLokasi(db, barangID, lantaiID); err != nil {
+			return fmt.Errorf("error adjusting lokasi stock for gudang '%s' at index %d: %v", stockUpdate.GudangNama, i, err)
+		}
 	}
 	return nil
 }
 
 // Helper function to update stock information per floor
-func updateStockInfoByLantai(db *sql.DB, barangID string, stockUpdates []StockLantaiUpdateInfo) error {
+func updateStockInfoByLantai(db sqlExecutor, barangID string, stockUpdates []StockLantaiUpdateInfo) error {
 	for i, stockUpdate := range stockUpdates {
 		// Verify lantai exists
 		var existsCheck int
-		err := db.QueryRow("SELECT COUNT(*) FROM gudang_lantai WHERE lantai_id = ?", stockUpdate.LantaiID).Scan(&existsCheck)
+		err := db.QueryRow("SELECT COUNT(*) FROM gudang_lantai WHERE lantai_id = ? AND lantai_status = 1", stockUpdate.LantaiID).Scan(&existsCheck)
 		if err != nil {
 			return fmt.Errorf("error checking lantai at index %d: %v", i, err)
 		}
@@ -245,6 +241,11 @@ func updateStockInfoByLantai(db *sql.DB, barangID string, stockUpdates []StockLa
 				return fmt.Errorf("error updating stock record for lantai '%s' at index %d: %v", stockUpdate.LantaiID, i, err)
 			}
 		}
+
+		// Keep bin stock within the new lantai total
+		if err := trimStockLokasi(db, barangID, stockUpdate.LantaiID); err != nil {
+			return fmt.Errorf("error adjusting lokasi stock for lantai '%s' at index %d: %v", stockUpdate.LantaiID, i, err)
+		}
 	}
 	return nil
 }
@@ -269,6 +270,12 @@ func createBarang(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
 	// Get brand_id from brand_nama
 	brandID, err := getBrandIDFromName(db, barang.BrandNama)
 	if err != nil {
@@ -276,6 +283,16 @@ func createBarang(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The discount goes into the discount history once the barang exists
+	diskon, err := diskonDariRequest(DiscountRequest{Diskon: barang.Diskon, DeadlineDiskon: barang.DeadlineDiskon})
+	if err == nil && diskon != nil {
+		err = validasiDiskon(diskon, barang.HargaAsli, barang.HargaJual)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Get last barang_id
 	var lastID string
 	err = db.QueryRow("SELECT barang_id FROM barang ORDER BY barang_id DESC LIMIT 1").Scan(&lastID)
@@ -291,22 +308,36 @@ func createBarang(w http.ResponseWriter, r *http.Request) {
 	}
 	newID := fmt.Sprintf("BA_%05d", nextNum) // "BA_00003"
 
-	// Handle NULL values for diskon and deadline_diskon
-	diskonValue := processNullableStringValue(barang.Diskon)
-	deadlineDiskonValue := processDeadlineDiskonValue(barang.DeadlineDiskon)
-
-	stmt, err := db.Prepare("INSERT INTO barang (barang_id, barang_nama, brand_id, barang_harga_asli, barang_harga_jual, barang_diskon, barang_deadline_diskon, barang_status) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	stmt, err := db.Prepare("INSERT INTO barang (barang_id, barang_nama, brand_id, barang_harga_asli, barang_harga_jual, barang_status) VALUES (?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Prepare statement error")
 		return
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(newID, barang.GetNama(), brandID, barang.HargaAsli, barang.HargaJual, diskonValue, deadlineDiskonValue, barang.Status)
+	audit := startAudit(db, r, "barang", newID)
+	_, err = stmt.Exec(newID, barang.GetNama(), brandID, barang.HargaAsli, barang.HargaJual, barang.Status)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Insert error: "+err.Error())
 		return
 	}
+	audit.record("create")
+
+	if _, err := catatHarga(db, newID, barang.HargaAsli, barang.HargaJual, time.Now().Format("2006-01-02"), hargaBerlaku, "Harga awal", user); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	barang.Diskon, barang.DeadlineDiskon = "-", "-"
+	if diskon != nil {
+		if _, err := simpanDiskon(db, newID, diskon, user); err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		barang.Diskon = teksDiskon(diskon.Tipe, diskon.Nilai)
+		if diskon.Selesai != "" {
+			barang.DeadlineDiskon = diskon.Selesai
+		}
+	}
 
 	// Return the created barang with the new ID for the next page
 	respondWithJSON(w, map[string]interface{}{
@@ -332,11 +363,60 @@ func getBarangs(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	// Get query parameters for search and filter
-	searchName := r.URL.Query().Get("search")
-	filterBrand := r.URL.Query().Get("brand")
+	// Deleted barang only show up in the trash
+	list, err := parseListQuery(r, barangListSpec, "b.deleted_at IS NULL")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if format := exportFormat(r); format != "" {
+		exportBarangs(w, db, format, list)
+		return
+	}
+
+	// A page is picked from barang first; the stock query below returns a
+	// row per barang and gudang
+	from := "FROM barang b LEFT JOIN brand br ON b.brand_id = br.brand_id"
+	where, args := list.whereSQL(), list.args
+	var pageIDs []string
+	total := 0
+	if list.limit > 0 {
+		limit, limitArgs := list.limitSQL()
+		idRows, err := db.Query("SELECT b.barang_id "+from+where+" ORDER BY "+list.orderBy+limit,
+			append(list.args, limitArgs...)...)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		for idRows.Next() {
+			var id string
+			if err := idRows.Scan(&id); err != nil {
+				idRows.Close()
+				respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+				return
+			}
+			pageIDs = append(pageIDs, id)
+		}
+		idRows.Close()
+
+		total, err = list.total(db, "COUNT(*)", from, len(pageIDs))
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Count error: "+err.Error())
+			return
+		}
+		if len(pageIDs) == 0 {
+			respondWithList(w, list, total, []Barang{})
+			return
+		}
+
+		where = " WHERE b.barang_id IN (" + sqlPlaceholders(len(pageIDs)) + ")"
+		args = make([]interface{}, len(pageIDs))
+		for i, id := range pageIDs {
+			args[i] = id
+		}
+	}
 
-	// Build dynamic query based on parameters
 	query := `
 		SELECT 
 			b.barang_id,
@@ -353,32 +433,9 @@ func getBarangs(w http.ResponseWriter, r *http.Request) {
 		LEFT JOIN brand br ON b.brand_id = br.brand_id
 		LEFT JOIN stock_gudang sg ON b.barang_id = sg.barang_id
 		LEFT JOIN gudang_lantai gl ON sg.lantai_id = gl.lantai_id
-		LEFT JOIN list_gudang lg ON gl.gudang_id = lg.gudang_id`
+		LEFT JOIN list_gudang lg ON gl.gudang_id = lg.gudang_id` + where
 
-	var args []interface{}
-	var conditions []string
-
-	// Add search condition if search parameter is provided
-	if searchName != "" {
-		conditions = append(conditions, "b.barang_nama LIKE ?")
-		args = append(args, "%"+searchName+"%")
-	}
-
-	// Add brand filter condition if brand parameter is provided
-	if filterBrand != "" && filterBrand != "Semua Brand" {
-		conditions = append(conditions, "br.brand_nama = ?")
-		args = append(args, filterBrand)
-	}
-
-	// Add WHERE clause if there are conditions
-	if len(conditions) > 0 {
-		query += " WHERE " + conditions[0]
-		for i := 1; i < len(conditions); i++ {
-			query += " AND " + conditions[i]
-		}
-	}
-
-	query += " GROUP BY b.barang_id, b.barang_nama, b.barang_harga_asli, b.barang_harga_jual, b.barang_diskon, b.barang_deadline_diskon, b.barang_status, br.brand_nama, lg.gudang_nama ORDER BY b.barang_id, lg.gudang_nama"
+	query += " GROUP BY b.barang_id, b.barang_nama, b.barang_harga_asli, b.barang_harga_jual, b.barang_diskon, b.barang_deadline_diskon, b.barang_status, br.brand_nama, lg.gudang_nama ORDER BY " + list.orderBy + ", lg.gudang_nama"
 
 	rows, err := db.Query(query, args...)
 	if err != nil {
@@ -388,6 +445,7 @@ func getBarangs(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 
 	barangMap := make(map[string]*Barang)
+	var barangOrder []string // rows come sorted, keep that order
 
 	for rows.Next() {
 		var barangID, nama, brandNama string
@@ -402,6 +460,7 @@ func getBarangs(w http.ResponseWriter, r *http.Request) {
 
 		// Check if barang already exists in map
 		if _, exists := barangMap[barangID]; !exists {
+			barangOrder = append(barangOrder, barangID)
 			barangMap[barangID] = &Barang{
 				ID:             barangID,
 				Nama:           nama,
@@ -428,17 +487,78 @@ func getBarangs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Convert map to slice
-	var barangs []Barang
-	for _, barang := range barangMap {
-		barangs = append(barangs, *barang)
+	barangs := []Barang{}
+	for _, barangID := range barangOrder {
+		barangs = append(barangs, *barangMap[barangID])
 	}
 
-	// Sort by barang_id to ensure consistent ordering
-	sort.Slice(barangs, func(i, j int) bool {
-		return barangs[i].ID < barangs[j].ID
-	})
+	if list.limit == 0 {
+		total = len(barangs)
+	}
+	respondWithList(w, list, total, barangs)
+}
 
-	respondWithJSON(w, barangs)
+// barangListSpec is the list contract of getBarangs. brand is the brand name,
+// "Semua Brand" meaning all; gudang lists barang with stock in that gudang.
+var barangListSpec = listSpec{
+	filters: []listFilter{
+		{"brand", "(? = 'Semua Brand' OR br.brand_nama = ?)"},
+		{"brand_id", "b.brand_id = ?"},
+		{"status", "b.barang_status = ?"},
+		{"gudang", `b.barang_id IN (SELECT s2.barang_id FROM stock_gudang s2
+			JOIN gudang_lantai l2 ON s2.lantai_id = l2.lantai_id
+			WHERE l2.gudang_id = ? AND s2.stock_barang > 0)`},
+	},
+	search: []string{"b.barang_id", "b.barang_nama"},
+	sorts: map[string]string{
+		"id":         "b.barang_id",
+		"nama":       "b.barang_nama",
+		"brand":      "br.brand_nama",
+		"harga_asli": "b.barang_harga_asli",
+		"harga_jual": "b.barang_harga_jual",
+	},
+	sortDefault:  "id",
+	orderDefault: "asc",
+	key:          "b.barang_id",
+}
+
+var barangExportKolom = []exportKolom{
+	{"Kode Barang", exportTeks},
+	{"Nama Barang", exportTeks},
+	{"Brand", exportTeks},
+	{"Harga Asli", exportRupiah},
+	{"Harga Jual", exportRupiah},
+	{"Diskon", exportTeks},
+	{"Batas Diskon", exportTanggal},
+	{"Status", exportAngka},
+	{"Stok", exportAngka},
+}
+
+// exportBarangs streams the barang list as a spreadsheet, one row per barang
+// with its stock summed over all gudang. Filters and sort follow the list
+// query; the whole list is exported, not one page.
+func exportBarangs(w http.ResponseWriter, db *sql.DB, format string, list *listQuery) {
+	query := `
+		SELECT b.barang_id, b.barang_nama, br.brand_nama, b.barang_harga_asli, b.barang_harga_jual,
+		       b.barang_diskon, b.barang_deadline_diskon, b.barang_status,
+		       COALESCE(SUM(sg.stock_barang), 0)
+		FROM barang b
+		LEFT JOIN brand br ON b.brand_id = br.brand_id
+		LEFT JOIN stock_gudang sg ON b.barang_id = sg.barang_id` + list.whereSQL() + `
+		GROUP BY b.barang_id, b.barang_nama, br.brand_nama, b.barang_harga_asli, b.barang_harga_jual,
+		         b.barang_diskon, b.barang_deadline_diskon, b.barang_status
+		ORDER BY ` + list.orderBy
+
+	rows, err := db.Query(query, list.args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	if err := exportRows(w, format, "barang", barangExportKolom, rows); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Export error: "+err.Error())
+	}
 }
 
 func getWarehousesForStock(w http.ResponseWriter, r *http.Request) {
@@ -449,7 +569,7 @@ func getWarehousesForStock(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	query := "SELECT gudang_id, gudang_nama FROM list_gudang ORDER BY gudang_nama"
+	query := "SELECT gudang_id, gudang_nama FROM list_gudang WHERE gudang_status = 1 ORDER BY gudang_nama"
 	rows, err := db.Query(query)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
@@ -538,7 +658,7 @@ func getCurrentStockInfo(w http.ResponseWriter, r *http.Request) {
 
 	// First check if barang exists and get its name
 	var existingBarangID, barangNama string
-	err = db.QueryRow("SELECT barang_id, barang_nama FROM barang WHERE barang_id = ?", barangID).Scan(&existingBarangID, &barangNama)
+	err = db.QueryRow("SELECT barang_id, barang_nama FROM barang WHERE barang_id = ? AND deleted_at IS NULL", barangID).Scan(&existingBarangID, &barangNama)
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Barang with ID "+barangID+" not found")
 		return
@@ -557,8 +677,9 @@ func getCurrentStockInfo(w http.ResponseWriter, r *http.Request) {
 			gl.lantai_nama,
 			COALESCE(sg.stock_barang, 0) as current_stock
 		FROM list_gudang lg
-		INNER JOIN gudang_lantai gl ON lg.gudang_id = gl.gudang_id
+		INNER JOIN gudang_lantai gl ON lg.gudang_id = gl.gudang_id AND gl.lantai_status = 1
 		LEFT JOIN stock_gudang sg ON gl.lantai_id = sg.lantai_id AND sg.barang_id = ?
+		WHERE lg.gudang_status = 1
 		ORDER BY lg.gudang_id ASC, gl.lantai_no`
 
 	currentStockRows, err := db.Query(currentStockQuery, barangID)
@@ -620,7 +741,7 @@ func createBarangStock(w http.ResponseWriter, r *http.Request) {
 
 	// First check if barang exists and get its name for user-friendly response
 	var existingBarangID, barangNama string
-	err = db.QueryRow("SELECT barang_id, barang_nama FROM barang WHERE barang_id = ?", stockReq.BarangID).Scan(&existingBarangID, &barangNama)
+	err = db.QueryRow("SELECT barang_id, barang_nama FROM barang WHERE barang_id = ? AND deleted_at IS NULL", stockReq.BarangID).Scan(&existingBarangID, &barangNama)
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Barang with ID "+stockReq.BarangID+" not found")
 		return
@@ -629,13 +750,29 @@ func createBarangStock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	audit := startRowsAudit(tx, r, "stock_gudang", "barang_id = ?", stockReq.BarangID)
+
 	// Process stock information for multiple warehouses
-	err = updateStockInfo(db, stockReq.BarangID, stockReq.StockGudang)
+	err = updateStockInfo(tx, stockReq.BarangID, stockReq.StockGudang)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Stock creation error: "+err.Error())
 		return
 	}
 
+	audit.record("create_stock")
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
 	// Return success response with created stock information
 	var createdStocks []map[string]interface{}
 	for _, stock := range stockReq.StockGudang {
@@ -750,7 +887,7 @@ func getBrandsForFilter(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	query := "SELECT DISTINCT brand_nama FROM brand ORDER BY brand_nama"
+	query := "SELECT DISTINCT brand_nama FROM brand WHERE deleted_at IS NULL ORDER BY brand_nama"
 	rows, err := db.Query(query)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
@@ -799,9 +936,17 @@ func updateBarang(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
 	// First check if barang exists
-	var existingBarangID string
-	err = db.QueryRow("SELECT barang_id FROM barang WHERE barang_id = ?", id).Scan(&existingBarangID)
+	var oldHargaAsli, oldHargaJual int
+	var oldDiskon, oldDeadline sql.NullString
+	err = db.QueryRow(`SELECT barang_harga_asli, barang_harga_jual, barang_diskon, DATE_FORMAT(barang_deadline_diskon, '%Y-%m-%d')
+		FROM barang WHERE barang_id = ? AND deleted_at IS NULL`, id).Scan(&oldHargaAsli, &oldHargaJual, &oldDiskon, &oldDeadline)
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Barang with ID "+id+" not found")
 		return
@@ -817,22 +962,61 @@ func updateBarang(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Handle NULL values for diskon and deadline_diskon
-	diskonValue := processNullableStringValue(barang.Diskon)
-	deadlineDiskonValue := processDeadlineDiskonValue(barang.DeadlineDiskon)
+	// The discount text is sent back as loaded; only a changed discount
+	// replaces (or ends) the running one in the discount history
+	if barang.Diskon == "" {
+		barang.Diskon = "-"
+	}
+	if barang.DeadlineDiskon == "" {
+		barang.DeadlineDiskon = "-"
+	}
+	diskonBerubah := barang.Diskon != nullStringToString(oldDiskon) || barang.DeadlineDiskon != nullStringToString(oldDeadline)
+	var diskon *DiskonBarang
+	if diskonBerubah {
+		diskon, err = diskonDariRequest(DiscountRequest{Diskon: barang.Diskon, DeadlineDiskon: barang.DeadlineDiskon})
+		if err == nil && diskon != nil {
+			err = validasiDiskon(diskon, barang.HargaAsli, barang.HargaJual)
+		}
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 
-	stmt, err := db.Prepare("UPDATE barang SET barang_nama = ?, brand_id = ?, barang_harga_asli = ?, barang_harga_jual = ?, barang_diskon = ?, barang_deadline_diskon = ?, barang_status = ? WHERE barang_id = ?")
+	stmt, err := db.Prepare("UPDATE barang SET barang_nama = ?, brand_id = ?, barang_harga_asli = ?, barang_harga_jual = ?, barang_status = ? WHERE barang_id = ?")
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Prepare statement error")
 		return
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(barang.GetNama(), brandID, barang.HargaAsli, barang.HargaJual, diskonValue, deadlineDiskonValue, barang.Status, id)
+	audit := startAudit(db, r, "barang", id)
+	_, err = stmt.Exec(barang.GetNama(), brandID, barang.HargaAsli, barang.HargaJual, barang.Status, id)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
 		return
 	}
+	if diskonBerubah {
+		if diskon != nil {
+			_, err = simpanDiskon(db, id, diskon, user)
+			barang.Diskon = teksDiskon(diskon.Tipe, diskon.Nilai)
+		} else {
+			err = hentikanDiskon(db, id, user)
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	audit.record("update")
+
+	// A price change takes effect today; scheduled changes stay queued
+	if barang.HargaAsli != oldHargaAsli || barang.HargaJual != oldHargaJual {
+		if _, err := catatHarga(db, id, barang.HargaAsli, barang.HargaJual, time.Now().Format("2006-01-02"), hargaBerlaku, "", user); err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
 
 	respondWithJSON(w, map[string]interface{}{
 		"barang_id":              id,
@@ -885,7 +1069,7 @@ func updateBarangStock(w http.ResponseWriter, r *http.Request) {
 
 	// First check if barang exists and get its name for user-friendly response
 	var existingBarangID, barangNama string
-	err = db.QueryRow("SELECT barang_id, barang_nama FROM barang WHERE barang_id = ?", stockReq.BarangID).Scan(&existingBarangID, &barangNama)
+	err = db.QueryRow("SELECT barang_id, barang_nama FROM barang WHERE barang_id = ? AND deleted_at IS NULL", stockReq.BarangID).Scan(&existingBarangID, &barangNama)
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Barang with ID "+stockReq.BarangID+" not found")
 		return
@@ -894,14 +1078,30 @@ func updateBarangStock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	audit := startRowsAudit(tx, r, "stock_gudang", "barang_id = ?", stockReq.BarangID)
+
 	if useFloorLevel {
 		// Process floor-level stock updates
-		err = updateStockInfoByLantai(db, stockReq.BarangID, stockReq.StockLantai)
+		err = updateStockInfoByLantai(tx, stockReq.BarangID, stockReq.StockLantai)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, "Stock update error: "+err.Error())
 			return
 		}
 
+		audit.record("update_stock")
+
+		if err := tx.Commit(); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Commit error")
+			return
+		}
+
 		respondWithJSON(w, map[string]interface{}{
 			"barang_id":   stockReq.BarangID,
 			"barang_nama": barangNama,
@@ -929,7 +1129,7 @@ func updateBarangStock(w http.ResponseWriter, r *http.Request) {
 		args = append(args, stock.GudangNama)
 	}
 
-	currentStockRows, err := db.Query(currentStockQuery, args...)
+	currentStockRows, err := tx.Query(currentStockQuery, args...)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error fetching current stock: "+err.Error())
 		return
@@ -950,13 +1150,22 @@ func updateBarangStock(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	currentStockRows.Close()
+
 	// Process stock information for multiple warehouses
-	err = updateStockInfo(db, stockReq.BarangID, stockReq.StockGudang)
+	err = updateStockInfo(tx, stockReq.BarangID, stockReq.StockGudang)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Stock update error: "+err.Error())
 		return
 	}
 
+	audit.record("update_stock")
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
 	// Combine previous and updated stock information
 	var stockComparisons []map[string]interface{}
 	for _, stock := range stockReq.StockGudang {
@@ -997,22 +1206,15 @@ func deleteBarang(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	// First delete related stock_gudang records
-	_, err = db.Exec("DELETE FROM stock_gudang WHERE barang_id = ?", id)
+	user, err := requestUser(db, r)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error deleting related stock records: "+err.Error())
+		respondWithError(w, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	// Then delete the barang
-	stmt, err := db.Prepare("DELETE FROM barang WHERE barang_id = ?")
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Prepare statement error")
-		return
-	}
-	defer stmt.Close()
-
-	res, err := stmt.Exec(id)
+	// Soft delete only; stock rows are kept so a restore brings them back
+	audit := startAudit(db, r, "barang", id)
+	res, err := db.Exec("UPDATE barang SET deleted_at = NOW(), deleted_by = ? WHERE barang_id = ? AND deleted_at IS NULL", requestUserID(user), id)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Delete error: "+err.Error())
 		return
@@ -1023,6 +1225,7 @@ func deleteBarang(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusNotFound, "Barang not found")
 		return
 	}
+	audit.record("delete")
 
 	respondWithJSON(w, map[string]string{
 		"barang_id": id,
@@ -1087,11 +1290,11 @@ func getInventorySummary(w http.ResponseWriter, r *http.Request) {
 		LEFT JOIN gudang_lantai gl ON sg.lantai_id = gl.lantai_id
 		LEFT JOIN list_gudang lg ON gl.gudang_id = lg.gudang_id
 		LEFT JOIN sale_items si ON b.barang_id = si.barang_id
-		LEFT JOIN sales s ON si.sales_id = s.sales_id AND s.sales_status = 1
+		LEFT JOIN sales s ON si.sales_id = s.sales_id AND s.sales_status IN (1, 4)
 	`
 
 	var args []interface{}
-	var conditions []string
+	conditions := []string{"b.deleted_at IS NULL"}
 
 	// Add brand filter if specified
 	if brandFilter != "" && brandFilter != "Semua Brand" {
@@ -1113,6 +1316,17 @@ func getInventorySummary(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	// A spreadsheet export writes each matching item as it is scanned and
+	// skips the per-gudang breakdown
+	var export *exportWriter
+	if format := exportFormat(r); format != "" {
+		export, err = newExportWriter(w, format, "ringkasan-inventaris", inventoryExportKolom)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Export error: "+err.Error())
+			return
+		}
+	}
+
 	var allItems []InventoryItemSummary
 	availableCount := 0
 	lowStockCount := 0
@@ -1135,6 +1349,9 @@ func getInventorySummary(w http.ResponseWriter, r *http.Request) {
 			&item.TotalSalesCount,
 		)
 		if err != nil {
+			if export != nil {
+				err = export.Gagal(err)
+			}
 			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
 			return
 		}
@@ -1172,6 +1389,23 @@ func getInventorySummary(w http.ResponseWriter, r *http.Request) {
 			inactiveCount++
 		}
 
+		if export != nil {
+			if !inventoryFilterCocok(item, filter, inactiveDays) {
+				continue
+			}
+			var lastSale interface{}
+			if lastSaleDate.Valid {
+				lastSale = lastSaleDate.String
+			}
+			if err := export.Tulis(item.BarangID, item.BarangNama, item.BrandNama, item.BarangHargaAsli,
+				item.BarangHargaJual, item.BarangStatus, item.TotalStock, inventoryStatusNama[item.StockStatus],
+				lastSale, item.TotalSalesCount); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Export error: "+export.Gagal(err).Error())
+				return
+			}
+			continue
+		}
+
 		// Get detailed stock per warehouse (aggregated from all floors)
 		stockQuery := `
 			SELECT lg.gudang_nama, COALESCE(SUM(sg.stock_barang), 0) as stock_barang
@@ -1202,27 +1436,22 @@ func getInventorySummary(w http.ResponseWriter, r *http.Request) {
 		allItems = append(allItems, item)
 	}
 
+	if export != nil {
+		if err := rows.Err(); err != nil {
+			err = export.Gagal(err)
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		if err := export.Selesai(); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Export error: "+export.Gagal(err).Error())
+		}
+		return
+	}
+
 	// Filter items based on filter parameter
 	var filteredItems []InventoryItemSummary
 	for _, item := range allItems {
-		include := false
-
-		switch filter {
-		case "all":
-			include = true
-		case "available":
-			include = item.StockStatus == "available"
-		case "low_stock":
-			include = item.StockStatus == "low_stock"
-		case "out_of_stock":
-			include = item.StockStatus == "out_of_stock"
-		case "inactive":
-			include = item.DaysSinceLastSale == -1 || item.DaysSinceLastSale >= inactiveDays
-		default:
-			include = true
-		}
-
-		if include {
+		if inventoryFilterCocok(item, filter, inactiveDays) {
 			filteredItems = append(filteredItems, item)
 		}
 	}
@@ -1248,6 +1477,41 @@ func getInventorySummary(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, response)
 }
 
+// inventoryFilterCocok reports whether an item passes the summary's filter
+// parameter
+func inventoryFilterCocok(item InventoryItemSummary, filter string, inactiveDays int) bool {
+	switch filter {
+	case "available":
+		return item.StockStatus == "available"
+	case "low_stock":
+		return item.StockStatus == "low_stock"
+	case "out_of_stock":
+		return item.StockStatus == "out_of_stock"
+	case "inactive":
+		return item.DaysSinceLastSale == -1 || item.DaysSinceLastSale >= inactiveDays
+	}
+	return true
+}
+
+var inventoryStatusNama = map[string]string{
+	"available":    "Tersedia",
+	"low_stock":    "Stok Menipis",
+	"out_of_stock": "Habis",
+}
+
+var inventoryExportKolom = []exportKolom{
+	{"Kode Barang", exportTeks},
+	{"Nama Barang", exportTeks},
+	{"Brand", exportTeks},
+	{"Harga Asli", exportRupiah},
+	{"Harga Jual", exportRupiah},
+	{"Status", exportAngka},
+	{"Total Stok", exportAngka},
+	{"Status Stok", exportTeks},
+	{"Penjualan Terakhir", exportTanggal},
+	{"Jumlah Transaksi", exportAngka},
+}
+
 /*
 Two-Step Barang Creation Workflow:
 
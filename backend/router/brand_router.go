@@ -74,11 +74,13 @@ func createBrand(w http.ResponseWriter, r *http.Request) {
 	}
 	defer stmt.Close()
 
+	audit := startAudit(db, r, "brand", newID)
 	_, err = stmt.Exec(newID, brand.Nama, brand.Kontak, brand.Tlp)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Insert error: "+err.Error())
 		return
 	}
+	audit.record("create")
 
 	respondWithJSON(w, Brand{
 		ID:     newID,
@@ -96,7 +98,7 @@ func getBrands(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	rows, err := db.Query("SELECT brand_id, brand_nama, brand_kontak, brand_tlp FROM brand")
+	rows, err := db.Query("SELECT brand_id, brand_nama, brand_kontak, brand_tlp FROM brand WHERE deleted_at IS NULL")
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
 		return
@@ -160,13 +162,14 @@ func updateBrand(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare("UPDATE brand SET brand_nama = ?, brand_kontak = ?, brand_tlp = ? WHERE brand_id = ?")
+	stmt, err := db.Prepare("UPDATE brand SET brand_nama = ?, brand_kontak = ?, brand_tlp = ? WHERE brand_id = ? AND deleted_at IS NULL")
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Prepare statement error")
 		return
 	}
 	defer stmt.Close()
 
+	audit := startAudit(db, r, "brand", id)
 	res, err := stmt.Exec(brand.Nama, brand.Kontak, brand.Tlp, id)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
@@ -178,6 +181,7 @@ func updateBrand(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusNotFound, "Brand not found")
 		return
 	}
+	audit.record("update")
 
 	respondWithJSON(w, Brand{
 		ID:     id,
@@ -198,14 +202,14 @@ func deleteBrand(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare("DELETE FROM brand WHERE brand_id = ?")
+	user, err := requestUser(db, r)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Prepare statement error")
+		respondWithError(w, http.StatusUnauthorized, err.Error())
 		return
 	}
-	defer stmt.Close()
 
-	res, err := stmt.Exec(id)
+	audit := startAudit(db, r, "brand", id)
+	res, err := db.Exec("UPDATE brand SET deleted_at = NOW(), deleted_by = ? WHERE brand_id = ? AND deleted_at IS NULL", requestUserID(user), id)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Delete error: "+err.Error())
 		return
@@ -216,6 +220,7 @@ func deleteBrand(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusNotFound, "Brand not found")
 		return
 	}
+	audit.record("delete")
 
 	respondWithJSON(w, map[string]string{
 		"brand_id": id,
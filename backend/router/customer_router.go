@@ -17,6 +17,8 @@ type Customer struct {
 	Nama   string `json:"customer_nama"`
 	Kontak string `json:"customer_kontak"`
 	Alamat string `json:"customer_alamat"`
+	// Price list assigned to the customer, empty for barang_harga_jual
+	DaftarID string `json:"daftar_id,omitempty"`
 }
 
 type CustomerRequest struct {
@@ -71,11 +73,13 @@ func createCustomer(w http.ResponseWriter, r *http.Request) {
 	}
 	defer stmt.Close()
 
+	audit := startAudit(db, r, "customer", newID)
 	_, err = stmt.Exec(newID, customer.Nama, customer.Kontak, customer.Alamat)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Insert error: "+err.Error())
 		return
 	}
+	audit.record("create")
 
 	respondWithJSON(w, map[string]interface{}{
 		"customer_id":     newID,
@@ -95,37 +99,55 @@ func getCustomers(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	// Get query parameters for search
-	searchName := r.URL.Query().Get("search")
-
-	var query string
-	var args []interface{}
-
-	if searchName != "" {
-		query = "SELECT customer_id, customer_nama, customer_kontak, customer_alamat FROM customer WHERE customer_nama LIKE ? ORDER BY customer_id"
-		args = append(args, "%"+searchName+"%")
-	} else {
-		query = "SELECT customer_id, customer_nama, customer_kontak, customer_alamat FROM customer ORDER BY customer_id"
+	list, err := parseListQuery(r, customerListSpec, "deleted_at IS NULL")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	rows, err := db.Query(query, args...)
+	query := "SELECT customer_id, customer_nama, customer_kontak, customer_alamat, daftar_id FROM customer" +
+		list.whereSQL() + " ORDER BY " + list.orderBy
+	limit, limitArgs := list.limitSQL()
+	rows, err := db.Query(query+limit, append(list.args, limitArgs...)...)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
 		return
 	}
 	defer rows.Close()
 
-	var customers []Customer
+	customers := []Customer{}
 	for rows.Next() {
 		var c Customer
-		if err := rows.Scan(&c.ID, &c.Nama, &c.Kontak, &c.Alamat); err != nil {
+		var daftarID sql.NullString
+		if err := rows.Scan(&c.ID, &c.Nama, &c.Kontak, &c.Alamat, &daftarID); err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
 			return
 		}
+		c.DaftarID = daftarID.String
 		customers = append(customers, c)
 	}
 
-	respondWithJSON(w, customers)
+	total, err := list.total(db, "COUNT(*)", "FROM customer", len(customers))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Count error: "+err.Error())
+		return
+	}
+	respondWithList(w, list, total, customers)
+}
+
+// customerListSpec is the list contract of getCustomers
+var customerListSpec = listSpec{
+	filters: []listFilter{
+		{"daftar", "daftar_id = ?"},
+	},
+	search: []string{"customer_id", "customer_nama", "customer_kontak"},
+	sorts: map[string]string{
+		"id":   "customer_id",
+		"nama": "customer_nama",
+	},
+	sortDefault:  "id",
+	orderDefault: "asc",
+	key:          "customer_id",
 }
 
 func getCustomer(w http.ResponseWriter, r *http.Request) {
@@ -140,7 +162,8 @@ func getCustomer(w http.ResponseWriter, r *http.Request) {
 	defer db.Close()
 
 	var c Customer
-	err = db.QueryRow("SELECT customer_id, customer_nama, customer_kontak, customer_alamat FROM customer WHERE customer_id = ?", id).Scan(&c.ID, &c.Nama, &c.Kontak, &c.Alamat)
+	var daftarID sql.NullString
+	err = db.QueryRow("SELECT customer_id, customer_nama, customer_kontak, customer_alamat, daftar_id FROM customer WHERE customer_id = ?", id).Scan(&c.ID, &c.Nama, &c.Kontak, &c.Alamat, &daftarID)
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Customer not found")
 		return
@@ -148,6 +171,7 @@ func getCustomer(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
 		return
 	}
+	c.DaftarID = daftarID.String
 
 	respondWithJSON(w, c)
 }
@@ -181,7 +205,7 @@ func updateCustomer(w http.ResponseWriter, r *http.Request) {
 
 	// First check if customer exists
 	var existingCustomerID string
-	err = db.QueryRow("SELECT customer_id FROM customer WHERE customer_id = ?", id).Scan(&existingCustomerID)
+	err = db.QueryRow("SELECT customer_id FROM customer WHERE customer_id = ? AND deleted_at IS NULL", id).Scan(&existingCustomerID)
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Customer with ID "+id+" not found")
 		return
@@ -197,11 +221,13 @@ func updateCustomer(w http.ResponseWriter, r *http.Request) {
 	}
 	defer stmt.Close()
 
+	audit := startAudit(db, r, "customer", id)
 	_, err = stmt.Exec(customer.Nama, customer.Kontak, customer.Alamat, id)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
 		return
 	}
+	audit.record("update")
 
 	respondWithJSON(w, map[string]interface{}{
 		"customer_id":     id,
@@ -224,14 +250,14 @@ func deleteCustomer(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare("DELETE FROM customer WHERE customer_id = ?")
+	user, err := requestUser(db, r)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Prepare statement error")
+		respondWithError(w, http.StatusUnauthorized, err.Error())
 		return
 	}
-	defer stmt.Close()
 
-	res, err := stmt.Exec(id)
+	audit := startAudit(db, r, "customer", id)
+	res, err := db.Exec("UPDATE customer SET deleted_at = NOW(), deleted_by = ? WHERE customer_id = ? AND deleted_at IS NULL", requestUserID(user), id)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Delete error: "+err.Error())
 		return
@@ -242,6 +268,7 @@ func deleteCustomer(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusNotFound, "Customer not found")
 		return
 	}
+	audit.record("delete")
 
 	respondWithJSON(w, map[string]string{
 		"customer_id": id,
@@ -0,0 +1,555 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"src/database"
+	"strconv"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// Price list types (daftar_harga.daftar_tipe)
+var daftarTipeValid = map[string]bool{
+	"retail":   true,
+	"reseller": true,
+	"grosir":   true,
+	"custom":   true,
+}
+
+// DaftarHarga is a price list that can be assigned to customers
+type DaftarHarga struct {
+	DaftarID       string            `json:"daftar_id"`
+	DaftarNama     string            `json:"daftar_nama"`
+	DaftarTipe     string            `json:"daftar_tipe"`
+	DaftarStatus   int               `json:"daftar_status"`
+	DaftarNote     string            `json:"daftar_note,omitempty"`
+	CreatedAt      string            `json:"created_at"`
+	JumlahItem     int               `json:"jumlah_item"`
+	JumlahCustomer int               `json:"jumlah_customer"`
+	Items          []DaftarHargaItem `json:"items,omitempty"`
+}
+
+// DaftarHargaItem is the price of one barang on a list from min_qty pieces on
+type DaftarHargaItem struct {
+	ItemID     string `json:"item_id"`
+	DaftarID   string `json:"daftar_id"`
+	BarangID   string `json:"barang_id"`
+	BarangNama string `json:"barang_nama"`
+	HargaJual  int    `json:"barang_harga_jual"`
+	MinQty     int    `json:"min_qty"`
+	Harga      int    `json:"harga"`
+}
+
+type DaftarHargaRequest struct {
+	DaftarNama   string `json:"daftar_nama"`
+	DaftarTipe   string `json:"daftar_tipe"`
+	DaftarStatus *int   `json:"daftar_status,omitempty"`
+	DaftarNote   string `json:"daftar_note"`
+}
+
+type DaftarHargaItemRequest struct {
+	BarangID string `json:"barang_id"`
+	MinQty   int    `json:"min_qty"` // default 1
+	Harga    int    `json:"harga"`
+}
+
+type CustomerDaftarHargaRequest struct {
+	DaftarID string `json:"daftar_id"` // empty removes the list
+}
+
+// hargaDaftar looks up the list price of barang for a line of qty pieces: the
+// tier with the highest min_qty not above qty. ok is false when the list is
+// inactive or has no tier for barang.
+func hargaDaftar(q sqlExecutor, daftarID, barangID string, qty int) (harga int, minQty int, ok bool, err error) {
+	err = q.QueryRow(`SELECT i.harga, i.min_qty
+		FROM daftar_harga_item i
+		JOIN daftar_harga d ON i.daftar_id = d.daftar_id
+		WHERE i.daftar_id = ? AND i.barang_id = ? AND i.min_qty <= ? AND d.daftar_status = 1
+		ORDER BY i.min_qty DESC LIMIT 1`, daftarID, barangID, qty).Scan(&harga, &minQty)
+	if err == sql.ErrNoRows {
+		return 0, 0, false, nil
+	} else if err != nil {
+		return 0, 0, false, fmt.Errorf("error fetching price list: %v", err)
+	}
+	return harga, minQty, true, nil
+}
+
+// hitungHargaPelanggan prices qty pieces of barang for a customer on day. The
+// customer's price list wins when it is cheaper than the discounted selling
+// price; without a list or a matching tier this is hitungHargaBarang.
+func hitungHargaPelanggan(q sqlExecutor, barangID, customerID string, qty int, day time.Time) (*HargaBarang, error) {
+	h, err := hitungHargaBarang(q, barangID, day)
+	if err != nil || customerID == "" {
+		return h, err
+	}
+
+	var daftarID, daftarNama sql.NullString
+	err = q.QueryRow(`SELECT c.daftar_id, d.daftar_nama FROM customer c
+		LEFT JOIN daftar_harga d ON c.daftar_id = d.daftar_id
+		WHERE c.customer_id = ?`, customerID).Scan(&daftarID, &daftarNama)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("customer '%s' does not exist", customerID)
+	} else if err != nil {
+		return nil, fmt.Errorf("error fetching customer: %v", err)
+	}
+	if !daftarID.Valid {
+		return h, nil
+	}
+
+	harga, minQty, ok, err := hargaDaftar(q, daftarID.String, barangID, qty)
+	if err != nil {
+		return nil, err
+	}
+	if ok && harga < h.HargaAkhir {
+		h.HargaAkhir = harga
+		h.Diskon = 0
+		h.DiskonNominal = 0
+		h.DeadlineDiskon = ""
+		h.DaftarID = daftarID.String
+		h.DaftarNama = daftarNama.String
+		h.MinQty = minQty
+	}
+	return h, nil
+}
+
+// hargaSaleLine is the unit price of a sale line sent without sale_value:
+// the customer's price for qty pieces on the sale date
+func hargaSaleLine(q sqlExecutor, customerID, salesDate, barangID string, qty int) (int, error) {
+	day := time.Now()
+	if len(salesDate) >= 10 {
+		if d, err := time.ParseInLocation("2006-01-02", salesDate[:10], time.Local); err == nil {
+			day = d
+		}
+	}
+	h, err := hitungHargaPelanggan(q, barangID, customerID, qty, day)
+	if err != nil {
+		return 0, err
+	}
+	return h.HargaAkhir, nil
+}
+
+// hargaSaleLineForSales prices a line added to or changed on an existing sale
+func hargaSaleLineForSales(q sqlExecutor, salesID, barangID string, qty int) (int, error) {
+	var customerID, salesDate string
+	err := q.QueryRow("SELECT customer_id, sales_date FROM sales WHERE sales_id = ?", salesID).Scan(&customerID, &salesDate)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching sales: %v", err)
+	}
+	return hargaSaleLine(q, customerID, salesDate, barangID, qty)
+}
+
+// Helper function to load one price list with its counts
+func loadDaftarHarga(q sqlExecutor, daftarID string) (*DaftarHarga, error) {
+	var d DaftarHarga
+	var note sql.NullString
+	err := q.QueryRow(`SELECT d.daftar_id, d.daftar_nama, d.daftar_tipe, d.daftar_status, d.daftar_note, d.created_at,
+			(SELECT COUNT(*) FROM daftar_harga_item i WHERE i.daftar_id = d.daftar_id),
+			(SELECT COUNT(*) FROM customer c WHERE c.daftar_id = d.daftar_id AND c.deleted_at IS NULL)
+		FROM daftar_harga d WHERE d.daftar_id = ?`, daftarID).
+		Scan(&d.DaftarID, &d.DaftarNama, &d.DaftarTipe, &d.DaftarStatus, &note, &d.CreatedAt, &d.JumlahItem, &d.JumlahCustomer)
+	if err != nil {
+		return nil, err
+	}
+	d.DaftarNote = note.String
+	return &d, nil
+}
+
+func getDaftarHargaList(w http.ResponseWriter, r *http.Request) {
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT daftar_id FROM daftar_harga ORDER BY daftar_id")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	lists := []DaftarHarga{}
+	for _, id := range ids {
+		d, err := loadDaftarHarga(db, id)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		lists = append(lists, *d)
+	}
+	respondWithJSON(w, lists)
+}
+
+// getDaftarHarga returns a price list with its items. Optional ?barang_id=.
+func getDaftarHarga(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	d, err := loadDaftarHarga(db, id)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Price list not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	query := `SELECT i.item_id, i.daftar_id, i.barang_id, b.barang_nama, b.barang_harga_jual, i.min_qty, i.harga
+		FROM daftar_harga_item i
+		JOIN barang b ON i.barang_id = b.barang_id
+		WHERE i.daftar_id = ?`
+	args := []interface{}{id}
+	if barangID := r.URL.Query().Get("barang_id"); barangID != "" {
+		query += " AND i.barang_id = ?"
+		args = append(args, barangID)
+	}
+	query += " ORDER BY b.barang_nama, i.min_qty"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	d.Items = []DaftarHargaItem{}
+	for rows.Next() {
+		var item DaftarHargaItem
+		if err := rows.Scan(&item.ItemID, &item.DaftarID, &item.BarangID, &item.BarangNama, &item.HargaJual, &item.MinQty, &item.Harga); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		d.Items = append(d.Items, item)
+	}
+	respondWithJSON(w, d)
+}
+
+func createDaftarHarga(w http.ResponseWriter, r *http.Request) {
+	var req DaftarHargaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.DaftarNama == "" {
+		respondWithError(w, http.StatusBadRequest, "daftar_nama is required")
+		return
+	}
+	if !daftarTipeValid[req.DaftarTipe] {
+		respondWithError(w, http.StatusBadRequest, "daftar_tipe must be retail, reseller, grosir or custom")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	// Get last daftar_id
+	var lastID string
+	err = db.QueryRow("SELECT daftar_id FROM daftar_harga ORDER BY daftar_id DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching last daftar_id")
+		return
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:]) // "DH_0002" -> "0002"
+		nextNum = n + 1
+	}
+	newID := fmt.Sprintf("DH_%04d", nextNum)
+
+	audit := startAudit(db, r, "daftar_harga", newID)
+	_, err = db.Exec("INSERT INTO daftar_harga (daftar_id, daftar_nama, daftar_tipe, daftar_status, daftar_note, created_at) VALUES (?, ?, ?, 1, ?, NOW())",
+		newID, req.DaftarNama, req.DaftarTipe, processNullableStringValue(req.DaftarNote))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Insert error: "+err.Error())
+		return
+	}
+	audit.record("create")
+
+	d, err := loadDaftarHarga(db, newID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	respondWithJSON(w, d)
+}
+
+func updateDaftarHarga(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req DaftarHargaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.DaftarNama == "" {
+		respondWithError(w, http.StatusBadRequest, "daftar_nama is required")
+		return
+	}
+	if !daftarTipeValid[req.DaftarTipe] {
+		respondWithError(w, http.StatusBadRequest, "daftar_tipe must be retail, reseller, grosir or custom")
+		return
+	}
+	status := 1
+	if req.DaftarStatus != nil {
+		if *req.DaftarStatus != 0 && *req.DaftarStatus != 1 {
+			respondWithError(w, http.StatusBadRequest, "daftar_status must be 0 or 1")
+			return
+		}
+		status = *req.DaftarStatus
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	audit := startAudit(db, r, "daftar_harga", id)
+	res, err := db.Exec("UPDATE daftar_harga SET daftar_nama = ?, daftar_tipe = ?, daftar_status = ?, daftar_note = ? WHERE daftar_id = ?",
+		req.DaftarNama, req.DaftarTipe, status, processNullableStringValue(req.DaftarNote), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		var exists bool
+		db.QueryRow("SELECT EXISTS(SELECT 1 FROM daftar_harga WHERE daftar_id = ?)", id).Scan(&exists)
+		if !exists {
+			respondWithError(w, http.StatusNotFound, "Price list not found")
+			return
+		}
+	}
+	audit.record("update")
+
+	d, err := loadDaftarHarga(db, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	respondWithJSON(w, d)
+}
+
+// saveDaftarHargaItem sets the price of a barang tier on a list, replacing
+// the price of an existing tier with the same min_qty
+func saveDaftarHargaItem(w http.ResponseWriter, r *http.Request) {
+	daftarID := mux.Vars(r)["id"]
+
+	var req DaftarHargaItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.BarangID == "" {
+		respondWithError(w, http.StatusBadRequest, "barang_id is required")
+		return
+	}
+	if req.MinQty == 0 {
+		req.MinQty = 1
+	}
+	if req.MinQty < 1 {
+		respondWithError(w, http.StatusBadRequest, "min_qty must be at least 1")
+		return
+	}
+	if req.Harga <= 0 {
+		respondWithError(w, http.StatusBadRequest, "harga must be greater than 0")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	var daftarExists, barangExists bool
+	db.QueryRow("SELECT EXISTS(SELECT 1 FROM daftar_harga WHERE daftar_id = ?)", daftarID).Scan(&daftarExists)
+	if !daftarExists {
+		respondWithError(w, http.StatusNotFound, "Price list not found")
+		return
+	}
+	db.QueryRow("SELECT EXISTS(SELECT 1 FROM barang WHERE barang_id = ? AND deleted_at IS NULL)", req.BarangID).Scan(&barangExists)
+	if !barangExists {
+		respondWithError(w, http.StatusBadRequest, "Invalid barang_id: barang does not exist")
+		return
+	}
+
+	itemID, err := upsertDaftarHargaItem(db, r, daftarID, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, map[string]interface{}{
+		"item_id":   itemID,
+		"daftar_id": daftarID,
+		"barang_id": req.BarangID,
+		"min_qty":   req.MinQty,
+		"harga":     req.Harga,
+		"status":    "Saved",
+	})
+}
+
+// upsertDaftarHargaItem writes one price list tier and audits it
+func upsertDaftarHargaItem(q sqlExecutor, r *http.Request, daftarID string, req DaftarHargaItemRequest) (string, error) {
+	var itemID string
+	err := q.QueryRow("SELECT item_id FROM daftar_harga_item WHERE daftar_id = ? AND barang_id = ? AND min_qty = ?",
+		daftarID, req.BarangID, req.MinQty).Scan(&itemID)
+	if err == nil {
+		audit := startAudit(q, r, "daftar_harga_item", itemID)
+		if _, err := q.Exec("UPDATE daftar_harga_item SET harga = ? WHERE item_id = ?", req.Harga, itemID); err != nil {
+			return "", fmt.Errorf("update error: %v", err)
+		}
+		audit.record("update")
+		return itemID, nil
+	} else if err != sql.ErrNoRows {
+		return "", fmt.Errorf("query error: %v", err)
+	}
+
+	var lastID string
+	err = q.QueryRow("SELECT item_id FROM daftar_harga_item ORDER BY item_id DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("error fetching last item_id: %v", err)
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:]) // "DI_0000002" -> "0000002"
+		nextNum = n + 1
+	}
+	itemID = fmt.Sprintf("DI_%07d", nextNum)
+
+	audit := startAudit(q, r, "daftar_harga_item", itemID)
+	_, err = q.Exec("INSERT INTO daftar_harga_item (item_id, daftar_id, barang_id, min_qty, harga) VALUES (?, ?, ?, ?, ?)",
+		itemID, daftarID, req.BarangID, req.MinQty, req.Harga)
+	if err != nil {
+		return "", fmt.Errorf("insert error: %v", err)
+	}
+	audit.record("create")
+	return itemID, nil
+}
+
+func deleteDaftarHargaItem(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	daftarID := params["id"]
+	itemID := params["item_id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	audit := startAudit(db, r, "daftar_harga_item", itemID)
+	res, err := db.Exec("DELETE FROM daftar_harga_item WHERE item_id = ? AND daftar_id = ?", itemID, daftarID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Delete error: "+err.Error())
+		return
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Price list item not found")
+		return
+	}
+	audit.record("delete")
+
+	respondWithJSON(w, map[string]string{
+		"item_id": itemID,
+		"status":  "Deleted",
+	})
+}
+
+// setCustomerDaftarHarga assigns a price list to a customer, an empty
+// daftar_id removes it
+func setCustomerDaftarHarga(w http.ResponseWriter, r *http.Request) {
+	customerID := mux.Vars(r)["customer_id"]
+
+	var req CustomerDaftarHargaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	if req.DaftarID != "" {
+		var status int
+		err = db.QueryRow("SELECT daftar_status FROM daftar_harga WHERE daftar_id = ?", req.DaftarID).Scan(&status)
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusBadRequest, "Invalid daftar_id: price list does not exist")
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		if status != 1 {
+			respondWithError(w, http.StatusBadRequest, "Price list is inactive")
+			return
+		}
+	}
+
+	audit := startAudit(db, r, "customer", customerID)
+	res, err := db.Exec("UPDATE customer SET daftar_id = ? WHERE customer_id = ? AND deleted_at IS NULL", processNullableStringValue(req.DaftarID), customerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		var exists bool
+		db.QueryRow("SELECT EXISTS(SELECT 1 FROM customer WHERE customer_id = ? AND deleted_at IS NULL)", customerID).Scan(&exists)
+		if !exists {
+			respondWithError(w, http.StatusNotFound, "Customer not found")
+			return
+		}
+	}
+	audit.record("update_daftar_harga")
+
+	respondWithJSON(w, map[string]interface{}{
+		"customer_id": customerID,
+		"daftar_id":   req.DaftarID,
+		"status":      "Updated",
+	})
+}
+
+// SetupDaftarHargaRoutes sets up the customer price list routes
+func SetupDaftarHargaRoutes(router *mux.Router) {
+	router.HandleFunc("/getdaftarharga", getDaftarHargaList).Methods("GET")
+	router.HandleFunc("/getdaftarharga/{id}", getDaftarHarga).Methods("GET")
+	router.HandleFunc("/createdaftarharga", createDaftarHarga).Methods("POST")
+	router.HandleFunc("/updatedaftarharga/{id}", updateDaftarHarga).Methods("PUT")
+	router.HandleFunc("/daftarharga/{id}/item", saveDaftarHargaItem).Methods("POST")
+	router.HandleFunc("/daftarharga/{id}/item/{item_id}", deleteDaftarHargaItem).Methods("DELETE")
+	router.HandleFunc("/setcustomerdaftarharga/{customer_id}", setCustomerDaftarHarga).Methods("PUT")
+}
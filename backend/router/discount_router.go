@@ -3,30 +3,414 @@ package router
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"src/database"
+	"strconv"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
 )
 
+// Discount types (barang_diskon_history.diskon_tipe)
+const (
+	diskonPersen  = "persen"
+	diskonNominal = "nominal"
+)
+
+// Discount history status values (barang_diskon_history.diskon_status)
+const (
+	diskonDibatalkan = 0
+	diskonAktif      = 1
+	diskonBerakhir   = 2
+)
+
+var diskonStatusNama = map[int]string{
+	diskonDibatalkan: "Dibatalkan",
+	diskonAktif:      "Aktif",
+	diskonBerakhir:   "Berakhir",
+}
+
 type DiscountBarang struct {
-	BarangID       string `json:"barang_id"`
-	BarangNama     string `json:"barang_nama"`
-	BrandID        string `json:"brand_id"`
-	BrandNama      string `json:"brand_nama"`
-	HargaAsli      int    `json:"barang_harga_asli"`
-	HargaJual      int    `json:"barang_harga_jual"`
-	Diskon         string `json:"barang_diskon"`
-	DeadlineDiskon string `json:"barang_deadline_diskon"`
-	Status         int    `json:"barang_status"`
+	BarangID       string  `json:"barang_id"`
+	BarangNama     string  `json:"barang_nama"`
+	BrandID        string  `json:"brand_id"`
+	BrandNama      string  `json:"brand_nama"`
+	HargaAsli      int     `json:"barang_harga_asli"`
+	HargaJual      int     `json:"barang_harga_jual"`
+	Diskon         string  `json:"barang_diskon"`
+	DeadlineDiskon string  `json:"barang_deadline_diskon"`
+	DiskonTipe     string  `json:"diskon_tipe,omitempty"`
+	DiskonNilai    float64 `json:"diskon_nilai,omitempty"`
+	DiskonMulai    string  `json:"diskon_mulai,omitempty"`
+	HargaDiskon    int     `json:"harga_diskon"`
+	Status         int     `json:"barang_status"`
 }
 
 type DiscountRequest struct {
+	DiskonTipe    string  `json:"diskon_tipe"` // persen or nominal
+	DiskonNilai   float64 `json:"diskon_nilai"`
+	DiskonMulai   string  `json:"diskon_mulai"`   // YYYY-MM-DD, default today
+	DiskonSelesai string  `json:"diskon_selesai"` // YYYY-MM-DD, empty: no end date
+	DiskonNote    string  `json:"diskon_note"`
+
+	// Older clients send the discount as text ("10%") with a deadline
 	Diskon         string `json:"barang_diskon"`
 	DeadlineDiskon string `json:"barang_deadline_diskon"`
 }
 
+// DiskonBarang is one entry of the discount history of a barang
+type DiskonBarang struct {
+	DiskonID   string  `json:"diskon_id"`
+	BarangID   string  `json:"barang_id"`
+	Tipe       string  `json:"diskon_tipe"`
+	Nilai      float64 `json:"diskon_nilai"`
+	Mulai      string  `json:"diskon_mulai"`
+	Selesai    string  `json:"diskon_selesai,omitempty"`
+	Status     int     `json:"diskon_status"`
+	StatusNama string  `json:"diskon_status_nama"`
+	Note       string  `json:"diskon_note,omitempty"`
+	CreatedBy  string  `json:"created_by,omitempty"`
+	CreatedAt  string  `json:"created_at"`
+	EndedBy    string  `json:"ended_by,omitempty"`
+	EndedAt    string  `json:"ended_at,omitempty"`
+}
+
+const diskonBarangSelect = `SELECT diskon_id, barang_id, diskon_tipe, diskon_nilai, DATE_FORMAT(diskon_mulai, '%Y-%m-%d'),
+		DATE_FORMAT(diskon_selesai, '%Y-%m-%d'), diskon_status, diskon_note, created_by, created_at, ended_by, ended_at
+	FROM barang_diskon_history`
+
+// Helper function to scan one barang_diskon_history row
+func scanDiskonBarang(row interface{ Scan(...interface{}) error }) (*DiskonBarang, error) {
+	var d DiskonBarang
+	var selesai, note, createdBy, endedBy, endedAt sql.NullString
+	if err := row.Scan(&d.DiskonID, &d.BarangID, &d.Tipe, &d.Nilai, &d.Mulai, &selesai, &d.Status,
+		&note, &createdBy, &d.CreatedAt, &endedBy, &endedAt); err != nil {
+		return nil, err
+	}
+	d.Selesai = selesai.String
+	d.StatusNama = diskonStatusNama[d.Status]
+	d.Note = note.String
+	d.CreatedBy = createdBy.String
+	d.EndedBy = endedBy.String
+	d.EndedAt = endedAt.String
+	return &d, nil
+}
+
+// diskonPada returns the discount of barang valid on day, nil if there is none
+func diskonPada(q sqlExecutor, barangID string, day time.Time) (*DiskonBarang, error) {
+	value := day.Format("2006-01-02")
+	d, err := scanDiskonBarang(q.QueryRow(diskonBarangSelect+`
+		WHERE barang_id = ? AND diskon_status IN (1, 2) AND diskon_mulai <= ?
+		  AND (diskon_selesai IS NULL OR diskon_selesai >= ?)
+		ORDER BY diskon_mulai DESC, diskon_id DESC LIMIT 1`, barangID, value, value))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error fetching discount: %v", err)
+	}
+	return d, nil
+}
+
+// hargaDiskon applies a discount to a unit price
+func hargaDiskon(harga int, tipe string, nilai float64) int {
+	if tipe == diskonNominal {
+		return int(math.Max(0, float64(harga)-math.Round(nilai)))
+	}
+	return hargaSetelahDiskon(harga, nilai)
+}
+
+// Helper function to format a discount for barang.barang_diskon
+func teksDiskon(tipe string, nilai float64) string {
+	if tipe == diskonNominal {
+		return "Rp" + strconv.Itoa(int(math.Round(nilai)))
+	}
+	return strconv.FormatFloat(nilai, 'f', -1, 64) + "%"
+}
+
+// diskonDariRequest reads a discount request, the typed fields or else the
+// older text fields. nil means the request removes the discount.
+func diskonDariRequest(req DiscountRequest) (*DiskonBarang, error) {
+	d := &DiskonBarang{
+		Tipe:    req.DiskonTipe,
+		Nilai:   req.DiskonNilai,
+		Mulai:   req.DiskonMulai,
+		Selesai: req.DiskonSelesai,
+		Note:    req.DiskonNote,
+	}
+	if d.Tipe == "" {
+		if req.Diskon == "" || req.Diskon == "-" {
+			return nil, nil
+		}
+		pct, ok := parseDiskonPersen(req.Diskon)
+		if !ok {
+			return nil, fmt.Errorf("barang_diskon must be a percentage between 0 and 100")
+		}
+		d.Tipe = diskonPersen
+		d.Nilai = pct
+		if req.DeadlineDiskon != "-" {
+			d.Selesai = req.DeadlineDiskon
+		}
+	}
+	if len(d.Selesai) > 10 {
+		d.Selesai = d.Selesai[:10]
+	}
+	return d, nil
+}
+
+// validasiDiskon checks a discount against the price of barang; the
+// discounted price may not fall below the cost (barang_harga_asli)
+func validasiDiskon(d *DiskonBarang, hargaAsli, hargaJual int) error {
+	switch d.Tipe {
+	case diskonPersen:
+		if d.Nilai <= 0 || d.Nilai > 100 {
+			return fmt.Errorf("diskon_nilai must be a percentage between 0 and 100")
+		}
+	case diskonNominal:
+		if d.Nilai <= 0 || d.Nilai > float64(hargaJual) {
+			return fmt.Errorf("diskon_nilai must be between 0 and barang_harga_jual (%d)", hargaJual)
+		}
+	default:
+		return fmt.Errorf("diskon_tipe must be persen or nominal")
+	}
+
+	today := time.Now().Format("2006-01-02")
+	if d.Mulai == "" {
+		d.Mulai = today
+	}
+	if _, err := time.ParseInLocation("2006-01-02", d.Mulai, time.Local); err != nil {
+		return fmt.Errorf("diskon_mulai must be YYYY-MM-DD")
+	}
+	if d.Mulai < today {
+		return fmt.Errorf("diskon_mulai cannot be in the past")
+	}
+	if d.Selesai != "" {
+		if _, err := time.ParseInLocation("2006-01-02", d.Selesai, time.Local); err != nil {
+			return fmt.Errorf("diskon_selesai must be YYYY-MM-DD")
+		}
+		if d.Selesai < d.Mulai {
+			return fmt.Errorf("diskon_selesai cannot be before diskon_mulai")
+		}
+	}
+
+	if harga := hargaDiskon(hargaJual, d.Tipe, d.Nilai); harga < hargaAsli {
+		return fmt.Errorf("discounted price %d would be below barang_harga_asli %d", harga, hargaAsli)
+	}
+	return nil
+}
+
+// simpanDiskon adds a discount to the history of barang. Discounts it
+// overlaps end the day before it starts, or are cancelled when they would
+// only start on or after it.
+func simpanDiskon(q sqlExecutor, barangID string, d *DiskonBarang, user *UserData) (string, error) {
+	_, err := q.Exec(`UPDATE barang_diskon_history SET diskon_status = ?, ended_by = ?, ended_at = NOW()
+		WHERE barang_id = ? AND diskon_status = ? AND diskon_mulai >= ?`,
+		diskonDibatalkan, requestUserID(user), barangID, diskonAktif, d.Mulai)
+	if err != nil {
+		return "", fmt.Errorf("error cancelling discounts: %v", err)
+	}
+	_, err = q.Exec(`UPDATE barang_diskon_history
+		SET diskon_selesai = DATE_SUB(?, INTERVAL 1 DAY),
+		    diskon_status = IF(DATE_SUB(?, INTERVAL 1 DAY) < CURDATE(), ?, diskon_status),
+		    ended_by = ?, ended_at = NOW()
+		WHERE barang_id = ? AND diskon_status = ? AND diskon_mulai < ?
+		  AND (diskon_selesai IS NULL OR diskon_selesai >= ?)`,
+		d.Mulai, d.Mulai, diskonBerakhir, requestUserID(user), barangID, diskonAktif, d.Mulai, d.Mulai)
+	if err != nil {
+		return "", fmt.Errorf("error ending discounts: %v", err)
+	}
+
+	var lastID string
+	err = q.QueryRow("SELECT diskon_id FROM barang_diskon_history ORDER BY diskon_id DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("error fetching last diskon_id: %v", err)
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:]) // "BD_0000002" -> "0000002"
+		nextNum = n + 1
+	}
+	newID := fmt.Sprintf("BD_%07d", nextNum)
+
+	_, err = q.Exec(`INSERT INTO barang_diskon_history (diskon_id, barang_id, diskon_tipe, diskon_nilai, diskon_mulai, diskon_selesai,
+			diskon_status, diskon_note, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())`,
+		newID, barangID, d.Tipe, d.Nilai, d.Mulai, processNullableStringValue(d.Selesai),
+		diskonAktif, processNullableStringValue(d.Note), requestUserID(user))
+	if err != nil {
+		return "", fmt.Errorf("error recording discount: %v", err)
+	}
+	return newID, syncDiskonBarang(q, barangID)
+}
+
+// hentikanDiskon ends the running discount of barang yesterday and cancels
+// the scheduled ones; the history is kept
+func hentikanDiskon(q sqlExecutor, barangID string, user *UserData) error {
+	today := time.Now().Format("2006-01-02")
+	_, err := q.Exec(`UPDATE barang_diskon_history SET diskon_status = ?, ended_by = ?, ended_at = NOW()
+		WHERE barang_id = ? AND diskon_status = ? AND diskon_mulai >= ?`,
+		diskonDibatalkan, requestUserID(user), barangID, diskonAktif, today)
+	if err != nil {
+		return fmt.Errorf("error cancelling discounts: %v", err)
+	}
+	_, err = q.Exec(`UPDATE barang_diskon_history
+		SET diskon_status = ?, diskon_selesai = DATE_SUB(?, INTERVAL 1 DAY), ended_by = ?, ended_at = NOW()
+		WHERE barang_id = ? AND diskon_status = ? AND diskon_mulai < ?
+		  AND (diskon_selesai IS NULL OR diskon_selesai >= ?)`,
+		diskonBerakhir, today, requestUserID(user), barangID, diskonAktif, today, today)
+	if err != nil {
+		return fmt.Errorf("error ending discounts: %v", err)
+	}
+	return syncDiskonBarang(q, barangID)
+}
+
+// syncDiskonBarang copies the discount valid today to the barang columns
+func syncDiskonBarang(q sqlExecutor, barangID string) error {
+	d, err := diskonPada(q, barangID, time.Now())
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		_, err = q.Exec(`UPDATE barang SET barang_diskon = NULL, barang_diskon_tipe = NULL, barang_diskon_nilai = NULL,
+			barang_diskon_mulai = NULL, barang_deadline_diskon = NULL WHERE barang_id = ?`, barangID)
+	} else {
+		_, err = q.Exec(`UPDATE barang SET barang_diskon = ?, barang_diskon_tipe = ?, barang_diskon_nilai = ?,
+			barang_diskon_mulai = ?, barang_deadline_diskon = ? WHERE barang_id = ?`,
+			teksDiskon(d.Tipe, d.Nilai), d.Tipe, d.Nilai, d.Mulai, processNullableStringValue(d.Selesai), barangID)
+	}
+	if err != nil {
+		return fmt.Errorf("error updating barang discount: %v", err)
+	}
+	return nil
+}
+
+// expireDiskon marks discounts past their end date as ended and refreshes
+// the barang whose discount expired or whose scheduled discount started
+func expireDiskon(db *sql.DB) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	today := time.Now().Format("2006-01-02")
+	rows, err := tx.Query(`SELECT DISTINCT h.barang_id FROM barang_diskon_history h
+		JOIN barang b ON h.barang_id = b.barang_id
+		WHERE h.diskon_status = ?
+		  AND (h.diskon_selesai < ? OR (h.diskon_mulai <= ? AND NOT (b.barang_diskon_mulai <=> h.diskon_mulai)))
+		FOR UPDATE`, diskonAktif, today, today)
+	if err != nil {
+		return 0, err
+	}
+	var barangIDs []string
+	for rows.Next() {
+		var barangID string
+		if err := rows.Scan(&barangID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		barangIDs = append(barangIDs, barangID)
+	}
+	rows.Close()
+	if len(barangIDs) == 0 {
+		return 0, nil
+	}
+
+	if _, err := tx.Exec("UPDATE barang_diskon_history SET diskon_status = ?, ended_at = NOW() WHERE diskon_status = ? AND diskon_selesai < ?",
+		diskonBerakhir, diskonAktif, today); err != nil {
+		return 0, err
+	}
+	for _, barangID := range barangIDs {
+		if err := syncDiskonBarang(tx, barangID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(barangIDs), nil
+}
+
+// StartDiskonScheduler expires and starts discounts once at startup and
+// then every interval in the background.
+func StartDiskonScheduler(interval time.Duration) {
+	run := func() {
+		db, err := database.GetDBConnection()
+		if err != nil {
+			log.Printf("diskon scheduler: %v", err)
+			return
+		}
+		defer db.Close()
+
+		n, err := expireDiskon(db)
+		if err != nil {
+			log.Printf("diskon scheduler: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("diskon scheduler: refreshed discounts of %d barang", n)
+		}
+	}
+
+	go func() {
+		run()
+		for range time.Tick(interval) {
+			run()
+		}
+	}()
+}
+
+const discountBarangSelect = `
+		SELECT
+			b.barang_id,
+			b.barang_nama,
+			b.brand_id,
+			br.brand_nama,
+			b.barang_harga_asli,
+			b.barang_harga_jual,
+			b.barang_diskon,
+			DATE_FORMAT(b.barang_deadline_diskon, '%Y-%m-%d'),
+			b.barang_diskon_tipe,
+			b.barang_diskon_nilai,
+			DATE_FORMAT(b.barang_diskon_mulai, '%Y-%m-%d'),
+			b.barang_status
+		FROM barang b
+		LEFT JOIN brand br ON b.brand_id = br.brand_id`
+
+// Helper function to scan one discountBarangSelect row. A discount past its
+// deadline shows as "-" even before the scheduler has cleared it.
+func scanDiscountBarang(row interface{ Scan(...interface{}) error }) (*DiscountBarang, error) {
+	var b DiscountBarang
+	var diskon, deadlineDiskon, tipe, mulai sql.NullString
+	var nilai sql.NullFloat64
+	if err := row.Scan(&b.BarangID, &b.BarangNama, &b.BrandID, &b.BrandNama, &b.HargaAsli, &b.HargaJual,
+		&diskon, &deadlineDiskon, &tipe, &nilai, &mulai, &b.Status); err != nil {
+		return nil, err
+	}
+
+	b.HargaDiskon = b.HargaJual
+	expired := deadlineDiskon.Valid && deadlineDiskon.String < time.Now().Format("2006-01-02")
+	if !diskon.Valid || expired {
+		// Handle nullable strings - return "-" if NULL
+		b.Diskon = "-"
+		b.DeadlineDiskon = "-"
+		return &b, nil
+	}
+	b.Diskon = diskon.String
+	b.DeadlineDiskon = nullStringToString(deadlineDiskon)
+	b.DiskonTipe = tipe.String
+	b.DiskonNilai = nilai.Float64
+	b.DiskonMulai = mulai.String
+	if tipe.Valid {
+		b.HargaDiskon = hargaDiskon(b.HargaJual, tipe.String, nilai.Float64)
+	}
+	return &b, nil
+}
+
 // Get all brands for brand selection dropdown
 func getBrandsForDiscount(w http.ResponseWriter, r *http.Request) {
 	db, err := database.GetDBConnection()
@@ -36,7 +420,7 @@ func getBrandsForDiscount(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	query := "SELECT brand_id, brand_nama FROM brand ORDER BY brand_nama"
+	query := "SELECT brand_id, brand_nama FROM brand WHERE deleted_at IS NULL ORDER BY brand_nama"
 	rows, err := db.Query(query)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
@@ -79,20 +463,8 @@ func getBarangByBrand(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	query := `
-		SELECT 
-			b.barang_id,
-			b.barang_nama,
-			b.brand_id,
-			br.brand_nama,
-			b.barang_harga_asli,
-			b.barang_harga_jual,
-			b.barang_diskon,
-			b.barang_deadline_diskon,
-			b.barang_status
-		FROM barang b
-		LEFT JOIN brand br ON b.brand_id = br.brand_id
-		WHERE br.brand_nama = ?
+	query := discountBarangSelect + `
+		WHERE br.brand_nama = ? AND b.deleted_at IS NULL
 		ORDER BY b.barang_nama`
 
 	rows, err := db.Query(query, brandNama)
@@ -104,28 +476,12 @@ func getBarangByBrand(w http.ResponseWriter, r *http.Request) {
 
 	var barangs []DiscountBarang
 	for rows.Next() {
-		var b DiscountBarang
-		var diskon, deadlineDiskon sql.NullString
-
-		if err := rows.Scan(&b.BarangID, &b.BarangNama, &b.BrandID, &b.BrandNama, &b.HargaAsli, &b.HargaJual, &diskon, &deadlineDiskon, &b.Status); err != nil {
+		b, err := scanDiscountBarang(rows)
+		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
 			return
 		}
-
-		// Handle nullable strings - return "-" if NULL
-		if diskon.Valid {
-			b.Diskon = diskon.String
-		} else {
-			b.Diskon = "-"
-		}
-
-		if deadlineDiskon.Valid {
-			b.DeadlineDiskon = deadlineDiskon.String
-		} else {
-			b.DeadlineDiskon = "-"
-		}
-
-		barangs = append(barangs, b)
+		barangs = append(barangs, *b)
 	}
 
 	respondWithJSON(w, barangs)
@@ -148,25 +504,7 @@ func getBarangDiscount(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	query := `
-		SELECT 
-			b.barang_id,
-			b.barang_nama,
-			b.brand_id,
-			br.brand_nama,
-			b.barang_harga_asli,
-			b.barang_harga_jual,
-			b.barang_diskon,
-			b.barang_deadline_diskon,
-			b.barang_status
-		FROM barang b
-		LEFT JOIN brand br ON b.brand_id = br.brand_id
-		WHERE b.barang_id = ?`
-
-	var b DiscountBarang
-	var diskon, deadlineDiskon sql.NullString
-
-	err = db.QueryRow(query, barangID).Scan(&b.BarangID, &b.BarangNama, &b.BrandID, &b.BrandNama, &b.HargaAsli, &b.HargaJual, &diskon, &deadlineDiskon, &b.Status)
+	b, err := scanDiscountBarang(db.QueryRow(discountBarangSelect+" WHERE b.barang_id = ?", barangID))
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Barang not found")
 		return
@@ -175,23 +513,11 @@ func getBarangDiscount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Handle nullable strings - return "-" if NULL
-	if diskon.Valid {
-		b.Diskon = diskon.String
-	} else {
-		b.Diskon = "-"
-	}
-
-	if deadlineDiskon.Valid {
-		b.DeadlineDiskon = deadlineDiskon.String
-	} else {
-		b.DeadlineDiskon = "-"
-	}
-
 	respondWithJSON(w, b)
 }
 
-// Update barang discount
+// Update barang discount. The new discount starts on diskon_mulai (default
+// today) and replaces the discounts it overlaps; they stay in the history.
 func updateBarangDiscount(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	barangID := params["barang_id"]
@@ -214,9 +540,15 @@ func updateBarangDiscount(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
 	// First check if barang exists
-	var existingBarangID string
-	err = db.QueryRow("SELECT barang_id FROM barang WHERE barang_id = ?", barangID).Scan(&existingBarangID)
+	var hargaAsli, hargaJual int
+	err = db.QueryRow("SELECT barang_harga_asli, barang_harga_jual FROM barang WHERE barang_id = ? AND deleted_at IS NULL", barangID).Scan(&hargaAsli, &hargaJual)
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Barang with ID "+barangID+" not found")
 		return
@@ -225,73 +557,60 @@ func updateBarangDiscount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Handle NULL values for diskon and deadline_diskon
-	var diskonValue interface{}
-	var deadlineDiskonValue interface{}
-
-	if discount.Diskon == "" || discount.Diskon == "-" {
-		diskonValue = nil
-	} else {
-		diskonValue = discount.Diskon
+	d, err := diskonDariRequest(discount)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
-
-	if discount.DeadlineDiskon == "" || discount.DeadlineDiskon == "-" {
-		deadlineDiskonValue = nil
-	} else {
-		deadlineDiskonValue = discount.DeadlineDiskon
+	if d == nil {
+		respondWithError(w, http.StatusBadRequest, "diskon_tipe and diskon_nilai are required, use deletebarangdiscount to remove a discount")
+		return
+	}
+	// A discount starting later is checked against the price valid then
+	if d.Mulai != "" {
+		if mulai, err := time.ParseInLocation("2006-01-02", d.Mulai, time.Local); err == nil {
+			h, err := hargaPada(db, barangID, mulai)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if h != nil {
+				hargaAsli, hargaJual = h.HargaAsli, h.HargaJual
+			}
+		}
+	}
+	if err := validasiDiskon(d, hargaAsli, hargaJual); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	stmt, err := db.Prepare("UPDATE barang SET barang_diskon = ?, barang_deadline_diskon = ? WHERE barang_id = ?")
+	audit := startAudit(db, r, "barang", barangID)
+
+	tx, err := db.Begin()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Prepare statement error")
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
 		return
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	_, err = stmt.Exec(diskonValue, deadlineDiskonValue, barangID)
+	diskonID, err := simpanDiskon(tx, barangID, d, user)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+	audit.record("update_discount")
 
 	// Get updated barang information to return
-	var updatedBarang DiscountBarang
-	var diskon, deadlineDiskon sql.NullString
-
-	query := `
-		SELECT 
-			b.barang_id,
-			b.barang_nama,
-			b.brand_id,
-			br.brand_nama,
-			b.barang_harga_asli,
-			b.barang_harga_jual,
-			b.barang_diskon,
-			b.barang_deadline_diskon,
-			b.barang_status
-		FROM barang b
-		LEFT JOIN brand br ON b.brand_id = br.brand_id
-		WHERE b.barang_id = ?`
-
-	err = db.QueryRow(query, barangID).Scan(&updatedBarang.BarangID, &updatedBarang.BarangNama, &updatedBarang.BrandID, &updatedBarang.BrandNama, &updatedBarang.HargaAsli, &updatedBarang.HargaJual, &diskon, &deadlineDiskon, &updatedBarang.Status)
+	updatedBarang, err := scanDiscountBarang(db.QueryRow(discountBarangSelect+" WHERE b.barang_id = ?", barangID))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error fetching updated barang: "+err.Error())
 		return
 	}
 
-	// Handle nullable strings - return "-" if NULL
-	if diskon.Valid {
-		updatedBarang.Diskon = diskon.String
-	} else {
-		updatedBarang.Diskon = "-"
-	}
-
-	if deadlineDiskon.Valid {
-		updatedBarang.DeadlineDiskon = deadlineDiskon.String
-	} else {
-		updatedBarang.DeadlineDiskon = "-"
-	}
-
 	respondWithJSON(w, map[string]interface{}{
 		"barang_id":              updatedBarang.BarangID,
 		"barang_nama":            updatedBarang.BarangNama,
@@ -301,12 +620,18 @@ func updateBarangDiscount(w http.ResponseWriter, r *http.Request) {
 		"barang_diskon":          updatedBarang.Diskon,
 		"barang_deadline_diskon": updatedBarang.DeadlineDiskon,
 		"barang_status":          updatedBarang.Status,
+		"diskon_id":              diskonID,
+		"diskon_tipe":            d.Tipe,
+		"diskon_nilai":           d.Nilai,
+		"diskon_mulai":           d.Mulai,
+		"diskon_selesai":         d.Selesai,
 		"status":                 "Updated",
 		"message":                "Discount updated successfully",
 	})
 }
 
-// Delete barang discount (set to NULL)
+// Delete barang discount: the running discount ends yesterday and scheduled
+// ones are cancelled, the history is kept
 func deleteBarangDiscount(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	barangID := params["barang_id"]
@@ -323,9 +648,15 @@ func deleteBarangDiscount(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
 	// First check if barang exists
 	var existingBarangID string
-	err = db.QueryRow("SELECT barang_id FROM barang WHERE barang_id = ?", barangID).Scan(&existingBarangID)
+	err = db.QueryRow("SELECT barang_id FROM barang WHERE barang_id = ? AND deleted_at IS NULL", barangID).Scan(&existingBarangID)
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Barang with ID "+barangID+" not found")
 		return
@@ -334,19 +665,24 @@ func deleteBarangDiscount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set both discount fields to NULL
-	stmt, err := db.Prepare("UPDATE barang SET barang_diskon = NULL, barang_deadline_diskon = NULL WHERE barang_id = ?")
+	audit := startAudit(db, r, "barang", barangID)
+
+	tx, err := db.Begin()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Prepare statement error")
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
 		return
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	_, err = stmt.Exec(barangID)
-	if err != nil {
+	if err := hentikanDiskon(tx, barangID, user); err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Delete error: "+err.Error())
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+	audit.record("delete_discount")
 
 	respondWithJSON(w, map[string]interface{}{
 		"barang_id":              barangID,
@@ -357,6 +693,37 @@ func deleteBarangDiscount(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// getBarangDiscountHistory lists every discount barang has had or has
+// scheduled, newest first
+func getBarangDiscountHistory(w http.ResponseWriter, r *http.Request) {
+	barangID := mux.Vars(r)["barang_id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	rows, err := db.Query(diskonBarangSelect+" WHERE barang_id = ? ORDER BY diskon_mulai DESC, diskon_id DESC", barangID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	history := []DiskonBarang{}
+	for rows.Next() {
+		d, err := scanDiskonBarang(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		history = append(history, *d)
+	}
+	respondWithJSON(w, history)
+}
+
 // Get all barangs with current discount information (for overview page)
 func getAllBarangDiscounts(w http.ResponseWriter, r *http.Request) {
 	db, err := database.GetDBConnection()
@@ -371,22 +738,10 @@ func getAllBarangDiscounts(w http.ResponseWriter, r *http.Request) {
 	statusFilter := r.URL.Query().Get("status")            // Filter by barang_status
 	hasDiscountFilter := r.URL.Query().Get("has_discount") // Filter by having discount (true/false)
 
-	query := `
-		SELECT 
-			b.barang_id,
-			b.barang_nama,
-			b.brand_id,
-			br.brand_nama,
-			b.barang_harga_asli,
-			b.barang_harga_jual,
-			b.barang_diskon,
-			b.barang_deadline_diskon,
-			b.barang_status
-		FROM barang b
-		LEFT JOIN brand br ON b.brand_id = br.brand_id`
+	query := discountBarangSelect
 
 	var args []interface{}
-	var conditions []string
+	conditions := []string{"b.deleted_at IS NULL"}
 
 	// Add filter conditions
 	if brandFilter != "" && brandFilter != "Semua Brand" {
@@ -399,11 +754,13 @@ func getAllBarangDiscounts(w http.ResponseWriter, r *http.Request) {
 		args = append(args, statusFilter)
 	}
 
+	// Discounts past their deadline count as no discount
+	const diskonBerjalan = "b.barang_diskon IS NOT NULL AND (b.barang_deadline_diskon IS NULL OR b.barang_deadline_diskon >= CURDATE())"
 	if hasDiscountFilter != "" {
 		if hasDiscountFilter == "true" {
-			conditions = append(conditions, "b.barang_diskon IS NOT NULL")
+			conditions = append(conditions, diskonBerjalan)
 		} else if hasDiscountFilter == "false" {
-			conditions = append(conditions, "b.barang_diskon IS NULL")
+			conditions = append(conditions, "NOT ("+diskonBerjalan+")")
 		}
 	}
 
@@ -426,28 +783,12 @@ func getAllBarangDiscounts(w http.ResponseWriter, r *http.Request) {
 
 	var barangs []DiscountBarang
 	for rows.Next() {
-		var b DiscountBarang
-		var diskon, deadlineDiskon sql.NullString
-
-		if err := rows.Scan(&b.BarangID, &b.BarangNama, &b.BrandID, &b.BrandNama, &b.HargaAsli, &b.HargaJual, &diskon, &deadlineDiskon, &b.Status); err != nil {
+		b, err := scanDiscountBarang(rows)
+		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
 			return
 		}
-
-		// Handle nullable strings - return "-" if NULL
-		if diskon.Valid {
-			b.Diskon = diskon.String
-		} else {
-			b.Diskon = "-"
-		}
-
-		if deadlineDiskon.Valid {
-			b.DeadlineDiskon = deadlineDiskon.String
-		} else {
-			b.DeadlineDiskon = "-"
-		}
-
-		barangs = append(barangs, b)
+		barangs = append(barangs, *b)
 	}
 
 	respondWithJSON(w, barangs)
@@ -470,6 +811,9 @@ func SetupDiscountRoutes(router *mux.Router) {
 	// Delete barang discount
 	router.HandleFunc("/deletebarangdiscount/{barang_id}", deleteBarangDiscount).Methods("DELETE")
 
+	// Discount history of one barang
+	router.HandleFunc("/getbarangdiscounthistory/{barang_id}", getBarangDiscountHistory).Methods("GET")
+
 	// Get all barangs with discount info (overview)
 	router.HandleFunc("/getallbarangdiscounts", getAllBarangDiscounts).Methods("GET")
 }
@@ -0,0 +1,807 @@
+package router
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"src/database"
+	"strconv"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// Dokumen is an archived, server-rendered PDF
+type Dokumen struct {
+	ID       string `json:"dokumen_id"`
+	No       string `json:"dokumen_no"`
+	Type     string `json:"dokumen_type"`
+	RefID    string `json:"ref_id"`
+	Variant  string `json:"dokumen_variant,omitempty"`
+	File     string `json:"dokumen_file"`
+	Size     int    `json:"dokumen_size"`
+	Created  string `json:"dokumen_created"`
+	Rendered string `json:"dokumen_rendered"`
+}
+
+// errDokumenStatus marks documents that cannot be printed in the current state
+var errDokumenStatus = errors.New("document cannot be printed")
+
+// dokumenType renders one kind of document for a reference id (sales_id,
+// sj_id or logs_id). Render returns sql.ErrNoRows for unknown references.
+// NomorQuery reads the legal document number; without one the number is
+// derived from Prefix and the reference id.
+type dokumenType struct {
+	Prefix     string
+	NomorQuery string
+	Render     func(q sqlExecutor, refID, variant, no string) ([]byte, error)
+}
+
+var dokumenTypes = map[string]dokumenType{
+	"invoice":        {Prefix: "INV", NomorQuery: "SELECT sales_invoice_no FROM sales WHERE sales_id = ?", Render: renderInvoicePDF},
+	"receipt":        {Prefix: "STR", Render: renderReceiptPDF},
+	"surat_jalan":    {Prefix: "SJ", NomorQuery: "SELECT sj_no FROM surat_jalan WHERE sj_id = ?", Render: renderSuratJalanPDF},
+	"purchase_order": {Prefix: "PO", NomorQuery: "SELECT logs_po_no FROM barang_logs WHERE logs_id = ?", Render: renderPurchaseOrderPDF},
+}
+
+// companyHeader is printed on every document, configured through
+// COMPANY_NAME, COMPANY_ADDRESS, COMPANY_PHONE and COMPANY_NPWP
+type companyHeader struct {
+	Nama   string
+	Alamat string
+	Telp   string
+	NPWP   string
+}
+
+func getCompanyHeader() companyHeader {
+	env := func(key, fallback string) string {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+		return fallback
+	}
+	return companyHeader{
+		Nama:   env("COMPANY_NAME", "Victoria Mebel"),
+		Alamat: env("COMPANY_ADDRESS", "Jl. Andalas No.38-40"),
+		Telp:   env("COMPANY_PHONE", "(0411) 3634028"),
+		NPWP:   os.Getenv("COMPANY_NPWP"),
+	}
+}
+
+// dokumenDir is where rendered PDFs are stored (DOCUMENT_DIR, default ./documents)
+func dokumenDir() string {
+	if dir := os.Getenv("DOCUMENT_DIR"); dir != "" {
+		return dir
+	}
+	return "documents"
+}
+
+// formatRupiah formats like the Flutter client: "Rp 1,250,000"
+func formatRupiah(value int) string {
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+	digits := strconv.Itoa(value)
+	var out strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out.WriteByte(',')
+		}
+		out.WriteRune(c)
+	}
+	return sign + "Rp " + out.String()
+}
+
+var paymentTypeNama = map[string]string{
+	"1": "Tunai",
+	"2": "Transfer",
+	"3": "Kredit",
+}
+
+// pdfColumn is one column of a document table
+type pdfColumn struct {
+	Title string
+	Width float64
+	Right bool
+}
+
+// pdfSheet lays out A4 documents top to bottom and breaks pages, repeating
+// the table header on each new page
+type pdfSheet struct {
+	doc    pdfDocument
+	margin float64
+	y      float64
+	cols   []pdfColumn
+}
+
+func newPDFSheet() *pdfSheet {
+	s := &pdfSheet{margin: 40}
+	s.doc.AddPage(pdfA4Width, pdfA4Height)
+	s.y = s.margin
+	return s
+}
+
+func (s *pdfSheet) contentWidth() float64 {
+	return pdfA4Width - 2*s.margin
+}
+
+// ensure starts a new page when h more points do not fit (footer kept free)
+func (s *pdfSheet) ensure(h float64) {
+	if s.y+h <= pdfA4Height-s.margin-20 {
+		return
+	}
+	s.doc.AddPage(pdfA4Width, pdfA4Height)
+	s.y = s.margin
+	if s.cols != nil {
+		s.tableHeader()
+	}
+}
+
+// header draws the document title on the left and the company on the right
+func (s *pdfSheet) header(title string) {
+	company := getCompanyHeader()
+	right := pdfA4Width - s.margin
+	s.doc.Text(s.margin, s.y+20, 20, true, title)
+	s.doc.TextRight(right, s.y+14, 14, true, company.Nama)
+	lines := []string{company.Alamat, "Telp: " + company.Telp}
+	if company.NPWP != "" {
+		lines = append(lines, "NPWP: "+company.NPWP)
+	}
+	y := s.y + 14
+	for _, line := range lines {
+		y += 12
+		s.doc.TextRight(right, y, 9, false, line)
+	}
+	s.y = y + 12
+	s.doc.Line(s.margin, s.y, right, s.y, 1)
+	s.y += 20
+}
+
+// infoBlocks prints label/value lines in two columns
+func (s *pdfSheet) infoBlocks(left, right [][2]string) {
+	top := s.y
+	y := top
+	for _, kv := range left {
+		s.doc.Text(s.margin, y+10, 10, true, kv[0])
+		for _, line := range pdfWrap(kv[1], 10, false, s.contentWidth()/2-90) {
+			s.doc.Text(s.margin+80, y+10, 10, false, line)
+			y += 14
+		}
+	}
+	yRight := top
+	x := s.margin + s.contentWidth()/2 + 20
+	for _, kv := range right {
+		s.doc.Text(x, yRight+10, 10, true, kv[0])
+		s.doc.TextRight(pdfA4Width-s.margin, yRight+10, 10, false, kv[1])
+		yRight += 14
+	}
+	if yRight > y {
+		y = yRight
+	}
+	s.y = y + 16
+}
+
+// table starts a table; the last column with Width 0 takes the remaining width
+func (s *pdfSheet) table(cols []pdfColumn) {
+	used := 0.0
+	flex := -1
+	for i, c := range cols {
+		if c.Width == 0 {
+			flex = i
+		}
+		used += c.Width
+	}
+	if flex >= 0 {
+		cols[flex].Width = s.contentWidth() - used
+	}
+	s.cols = cols
+	s.ensure(60)
+	s.tableHeader()
+}
+
+func (s *pdfSheet) tableHeader() {
+	const h = 20.0
+	s.doc.Rect(s.margin, s.y, s.contentWidth(), h, 0.85)
+	x := s.margin
+	for _, c := range s.cols {
+		if c.Right {
+			s.doc.TextRight(x+c.Width-4, s.y+13.5, 9, true, c.Title)
+		} else {
+			s.doc.Text(x+4, s.y+13.5, 9, true, c.Title)
+		}
+		x += c.Width
+	}
+	s.y += h
+}
+
+// row prints one table row, wrapping long cells
+func (s *pdfSheet) row(values ...string) {
+	const size, lineH = 9.0, 11.0
+	cells := make([][]string, len(s.cols))
+	lines := 1
+	for i, c := range s.cols {
+		cells[i] = pdfWrap(values[i], size, false, c.Width-8)
+		if len(cells[i]) > lines {
+			lines = len(cells[i])
+		}
+	}
+	h := float64(lines)*lineH + 8
+	s.ensure(h)
+	s.doc.Rect(s.margin, s.y, s.contentWidth(), h, -1)
+	x := s.margin
+	for i, c := range s.cols {
+		for j, line := range cells[i] {
+			y := s.y + 4 + lineH*float64(j+1) - 2
+			if c.Right {
+				s.doc.TextRight(x+c.Width-4, y, size, false, line)
+			} else {
+				s.doc.Text(x+4, y, size, false, line)
+			}
+		}
+		x += c.Width
+	}
+	s.y += h
+}
+
+func (s *pdfSheet) endTable() {
+	s.cols = nil
+	s.y += 12
+}
+
+// amountLine prints a label/value line aligned with the total box
+func (s *pdfSheet) amountLine(label, value string) {
+	const w = 220.0
+	s.ensure(16)
+	x := pdfA4Width - s.margin - w
+	s.doc.Text(x+10, s.y+10, 10, false, label)
+	s.doc.TextRight(x+w-10, s.y+10, 10, false, value)
+	s.y += 16
+}
+
+// totalBox prints a bold label/value box on the right
+func (s *pdfSheet) totalBox(label, value string) {
+	const w, h = 220.0, 26.0
+	s.ensure(h)
+	x := pdfA4Width - s.margin - w
+	s.doc.Rect(x, s.y, w, h, 0.93)
+	s.doc.Text(x+10, s.y+17, 12, true, label)
+	s.doc.TextRight(x+w-10, s.y+17, 12, true, value)
+	s.y += h + 12
+}
+
+// signatures prints named signature boxes spread over the width
+func (s *pdfSheet) signatures(names ...string) {
+	s.ensure(110)
+	s.y += 24
+	step := s.contentWidth() / float64(len(names))
+	for i, name := range names {
+		x := s.margin + step*(float64(i)+0.5)
+		s.doc.TextCenter(x, s.y, 10, false, name)
+		s.doc.TextCenter(x, s.y+70, 10, false, "(_______________)")
+	}
+	s.y += 80
+}
+
+// finish prints the document number and page numbers in the footer
+func (s *pdfSheet) finish(no string) []byte {
+	for i, page := range s.doc.pages {
+		s.doc.page = page
+		y := pdfA4Height - s.margin + 8
+		s.doc.Text(s.margin, y, 8, false, no)
+		s.doc.TextRight(pdfA4Width-s.margin, y, 8, false, fmt.Sprintf("Halaman %d dari %d", i+1, len(s.doc.pages)))
+	}
+	return s.doc.Bytes()
+}
+
+// Helper function to load a sale that may be printed
+func loadPrintableSales(q sqlExecutor, salesID string) (*SalesDetail, error) {
+	detail, err := loadSalesDetail(q, salesID)
+	if err != nil {
+		return nil, err
+	}
+	if detail.SalesStatus == salesStatusDraft || detail.SalesStatus == salesStatusBatal {
+		return nil, fmt.Errorf("%w: sales is %s", errDokumenStatus, salesStatusNama[detail.SalesStatus])
+	}
+	return detail, nil
+}
+
+func renderInvoicePDF(q sqlExecutor, salesID, _, no string) ([]byte, error) {
+	detail, err := loadPrintableSales(q, salesID)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newPDFSheet()
+	s.header("INVOICE PENJUALAN")
+
+	kepada := [][2]string{{"Kepada:", detail.CustomerName}}
+	if detail.CustomerKontak != "" {
+		kepada = append(kepada, [2]string{"Telp:", detail.CustomerKontak})
+	}
+	if detail.CustomerAlamat != "" {
+		kepada = append(kepada, [2]string{"Alamat:", detail.CustomerAlamat})
+	}
+	s.infoBlocks(kepada, [][2]string{
+		{"No. Invoice:", no},
+		{"Tanggal:", detail.SalesDate},
+		{"Pembayaran:", paymentTypeNama[detail.SalesPayment]},
+		{"Status:", salesStatusNama[detail.SalesStatus]},
+	})
+
+	s.table([]pdfColumn{
+		{Title: "No", Width: 28},
+		{Title: "Barang"},
+		{Title: "Gudang", Width: 95},
+		{Title: "Jumlah", Width: 50, Right: true},
+		{Title: "Harga", Width: 85, Right: true},
+		{Title: "Subtotal", Width: 95, Right: true},
+	})
+	for i, item := range detail.SaleItems {
+		s.row(strconv.Itoa(i+1), item.BarangNama, item.GudangNama, strconv.Itoa(item.SaleItemsAmount),
+			formatRupiah(item.SaleValue), formatRupiah(item.SaleItemsAmount*item.SaleValue))
+	}
+	s.endTable()
+
+	if detail.SalesPPN > 0 {
+		s.amountLine("DPP:", formatRupiah(detail.SalesDPP))
+		s.amountLine("PPN:", formatRupiah(detail.SalesPPN))
+	}
+	s.totalBox("TOTAL:", formatRupiah(detail.SalesTotal))
+	s.signatures("Penerima", "Hormat Kami")
+	return s.finish(no), nil
+}
+
+// renderReceiptPDF renders a thermal receipt; variant is the paper width in mm
+// (58 or 80). The page is as long as its content.
+func renderReceiptPDF(q sqlExecutor, salesID, variant, no string) ([]byte, error) {
+	detail, err := loadPrintableSales(q, salesID)
+	if err != nil {
+		return nil, err
+	}
+
+	widthMM, _ := strconv.Atoi(variant)
+	width := float64(widthMM) * pdfMM
+	margin := 3 * pdfMM
+	size, titleSize := 8.0, 11.0
+	if widthMM == 58 {
+		size, titleSize = 7.0, 9.0
+	}
+	lineH := size + 3
+	company := getCompanyHeader()
+
+	draw := func(doc *pdfDocument) float64 {
+		left, right, center := margin, width-margin, width/2
+		inner := right - left
+		y := margin
+		line := func() {
+			y += 4
+			doc.Line(left, y, right, y, 0.5)
+			y += 4
+		}
+		centered := func(text string, sz float64, bold bool) {
+			for _, l := range pdfWrap(text, sz, bold, inner) {
+				y += sz + 3
+				doc.TextCenter(center, y, sz, bold, l)
+			}
+		}
+		pair := func(label, value string, sz float64, bold bool) {
+			y += sz + 3
+			doc.Text(left, y, sz, bold, label)
+			doc.TextRight(right, y, sz, bold, value)
+		}
+
+		centered("STRUK PENJUALAN", titleSize, true)
+		centered(company.Nama, size+1, true)
+		centered(company.Alamat, size, false)
+		centered("Telp: "+company.Telp, size, false)
+		line()
+		pair("No:", no, size, false)
+		pair("Tanggal:", detail.SalesDate, size, false)
+		pair("Customer:", detail.CustomerName, size, false)
+		pair("Pembayaran:", paymentTypeNama[detail.SalesPayment], size, false)
+		line()
+		for _, item := range detail.SaleItems {
+			for _, l := range pdfWrap(item.BarangNama, size, true, inner) {
+				y += lineH
+				doc.Text(left, y, size, true, l)
+			}
+			pair(fmt.Sprintf("  %d x %s", item.SaleItemsAmount, formatRupiah(item.SaleValue)),
+				formatRupiah(item.SaleItemsAmount*item.SaleValue), size, false)
+			y += 2
+		}
+		line()
+		pair("TOTAL:", formatRupiah(detail.SalesTotal), size+2, true)
+		if len(detail.Pembayaran) > 0 {
+			line()
+			for _, p := range detail.Pembayaran {
+				label := p.MetodeNama
+				if p.Bank != "" {
+					label += " " + p.Bank
+				}
+				if p.Metode == bayarTunai && p.Kembalian > 0 {
+					pair(label+":", formatRupiah(p.Diterima), size, false)
+					pair("Kembalian:", formatRupiah(p.Kembalian), size, false)
+				} else {
+					pair(label+":", formatRupiah(p.Amount), size, false)
+				}
+			}
+		}
+		line()
+		centered("Terima Kasih", size, false)
+		return y + margin
+	}
+
+	// Measure first, then draw on a page of the right length
+	var measure pdfDocument
+	measure.AddPage(width, 10000)
+	height := draw(&measure)
+
+	var doc pdfDocument
+	doc.AddPage(width, height)
+	draw(&doc)
+	return doc.Bytes(), nil
+}
+
+func renderSuratJalanPDF(q sqlExecutor, sjID, _, no string) ([]byte, error) {
+	sj, err := scanSuratJalan(q.QueryRow(suratJalanSelect+" WHERE sj_id = ?", sjID).Scan)
+	if err != nil {
+		return nil, err
+	}
+	if sj.Status == sjDibatalkan {
+		return nil, fmt.Errorf("%w: surat jalan is %s", errDokumenStatus, sjStatusNama[sj.Status])
+	}
+	items, err := getSuratJalanItems(q, sjID)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newPDFSheet()
+	s.header("SURAT JALAN")
+
+	penerima := [][2]string{{"Kepada:", sj.Penerima}, {"Alamat:", sj.Alamat}}
+	if sj.Kontak != "" {
+		penerima = append(penerima, [2]string{"Telp:", sj.Kontak})
+	}
+	ref := sj.SalesID
+	if ref == "" {
+		ref = sj.LogsID
+	}
+	s.infoBlocks(penerima, [][2]string{
+		{"No. Surat Jalan:", no},
+		{"Tanggal:", sj.Date},
+		{"Referensi:", ref},
+		{"Kendaraan:", sj.Kendaraan},
+		{"Supir:", sj.Supir},
+	})
+
+	s.table([]pdfColumn{
+		{Title: "No", Width: 28},
+		{Title: "Barang"},
+		{Title: "Gudang / Lantai", Width: 140},
+		{Title: "Jumlah", Width: 60, Right: true},
+		{Title: "Diterima", Width: 60, Right: true},
+	})
+	for i, item := range items {
+		delivered := ""
+		if item.Delivered != nil {
+			delivered = strconv.Itoa(*item.Delivered)
+		}
+		s.row(strconv.Itoa(i+1), item.BarangNama, item.GudangNama+" / "+item.LantaiNama,
+			strconv.Itoa(item.Amount), delivered)
+	}
+	s.endTable()
+
+	if sj.Note != "" {
+		s.ensure(30)
+		s.doc.Text(s.margin, s.y+10, 10, true, "Catatan:")
+		for _, line := range pdfWrap(sj.Note, 10, false, s.contentWidth()-80) {
+			s.doc.Text(s.margin+80, s.y+10, 10, false, line)
+			s.y += 14
+		}
+	}
+	s.signatures("Pengirim", "Supir", "Penerima")
+	return s.finish(no), nil
+}
+
+// renderPurchaseOrderPDF prints an incoming (masuk) barang log as purchase order
+func renderPurchaseOrderPDF(q sqlExecutor, logsID, _, no string) ([]byte, error) {
+	var logsStatus int
+	var logsDate string
+	var logsDesc sql.NullString
+	err := q.QueryRow("SELECT logs_status, logs_date, logs_desc FROM barang_logs WHERE logs_id = ?", logsID).
+		Scan(&logsStatus, &logsDate, &logsDesc)
+	if err != nil {
+		return nil, err
+	}
+	if logsStatus != 1 {
+		return nil, fmt.Errorf("%w: logs_id is not an incoming (masuk) log", errDokumenStatus)
+	}
+
+	rows, err := q.Query(`
+		SELECT COALESCE(b.barang_nama, om.barang_id), COALESCE(br.brand_nama, ''), COALESCE(g.gudang_nama, om.gudang_id),
+		       om.orders_amount, om.orders_value
+		FROM orders_masuk om
+		LEFT JOIN barang b ON om.barang_id = b.barang_id
+		LEFT JOIN brand br ON b.brand_id = br.brand_id
+		LEFT JOIN list_gudang g ON om.gudang_id = g.gudang_id
+		WHERE om.logs_id = ?
+		ORDER BY om.orders_id`, logsID)
+	if err != nil {
+		return nil, err
+	}
+	type poLine struct {
+		barang, brand, gudang string
+		amount, value         int
+	}
+	var lines []poLine
+	for rows.Next() {
+		var l poLine
+		if err := rows.Scan(&l.barang, &l.brand, &l.gudang, &l.amount, &l.value); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	rows.Close()
+
+	s := newPDFSheet()
+	s.header("PURCHASE ORDER")
+	s.infoBlocks([][2]string{{"Keterangan:", nullStringToString(logsDesc)}}, [][2]string{
+		{"No. PO:", no},
+		{"Tanggal:", logsDate},
+	})
+
+	s.table([]pdfColumn{
+		{Title: "No", Width: 28},
+		{Title: "Barang"},
+		{Title: "Brand", Width: 80},
+		{Title: "Gudang", Width: 80},
+		{Title: "Jumlah", Width: 45, Right: true},
+		{Title: "Harga", Width: 80, Right: true},
+		{Title: "Subtotal", Width: 90, Right: true},
+	})
+	total := 0
+	for i, l := range lines {
+		subtotal := l.amount * l.value
+		total += subtotal
+		s.row(strconv.Itoa(i+1), l.barang, l.brand, l.gudang, strconv.Itoa(l.amount),
+			formatRupiah(l.value), formatRupiah(subtotal))
+	}
+	s.endTable()
+
+	s.totalBox("TOTAL:", formatRupiah(total))
+	s.signatures("Disetujui", "Hormat Kami")
+	return s.finish(no), nil
+}
+
+// Helper function to send a PDF to the client
+func serveDokumen(w http.ResponseWriter, d Dokumen, content []byte) {
+	filename := strings.NewReplacer("/", "-", "\\", "-", " ", "_").Replace(d.No)
+	if d.Variant != "" {
+		filename += "_" + d.Variant + "mm"
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.pdf\"", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set("X-Dokumen-No", d.No)
+	w.Write(content)
+}
+
+const dokumenSelect = `SELECT dokumen_id, dokumen_no, dokumen_type, ref_id, dokumen_variant, dokumen_file,
+	dokumen_size, dokumen_created, dokumen_rendered FROM dokumen`
+
+func scanDokumen(scan func(dest ...interface{}) error) (Dokumen, error) {
+	var d Dokumen
+	err := scan(&d.ID, &d.No, &d.Type, &d.RefID, &d.Variant, &d.File, &d.Size, &d.Created, &d.Rendered)
+	return d, err
+}
+
+// getDokumen returns the archived PDF of a document, rendering and storing it
+// on first request. ?regenerate=1 renders again under the same dokumen_no.
+func getDokumen(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	docType := params["type"]
+	refID := params["ref_id"]
+
+	t, ok := dokumenTypes[docType]
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Unknown document type '"+docType+"'")
+		return
+	}
+	variant := ""
+	if docType == "receipt" {
+		variant = r.URL.Query().Get("width")
+		if variant == "" {
+			variant = "80"
+		}
+		if variant != "58" && variant != "80" {
+			respondWithError(w, http.StatusBadRequest, "width must be 58 or 80")
+			return
+		}
+	}
+	regenerate := r.URL.Query().Get("regenerate")
+	forceRender := regenerate == "1" || regenerate == "true"
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	existing, err := scanDokumen(db.QueryRow(dokumenSelect+" WHERE dokumen_type = ? AND ref_id = ? AND dokumen_variant = ?",
+		docType, refID, variant).Scan)
+	archived := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if archived && !forceRender {
+		if content, err := os.ReadFile(existing.File); err == nil {
+			serveDokumen(w, existing, content)
+			return
+		}
+		// File went missing: render it again under the same number
+	}
+
+	d := existing
+	if !archived {
+		d = Dokumen{Type: docType, RefID: refID, Variant: variant, No: t.Prefix + "-" + refID}
+		if len(refID) > 3 && refID[2] == '_' {
+			d.No = t.Prefix + "-" + refID[3:] // "SL_0000001" -> "INV-0000001"
+		}
+	}
+	// The legal number wins once the document has one
+	if t.NomorQuery != "" {
+		var legalNo sql.NullString
+		err := db.QueryRow(t.NomorQuery, refID).Scan(&legalNo)
+		if err != nil && err != sql.ErrNoRows {
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		if legalNo.Valid && legalNo.String != "" {
+			d.No = legalNo.String
+		}
+	}
+
+	content, err := t.Render(db, refID, variant, d.No)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Reference '"+refID+"' not found")
+		return
+	} else if errors.Is(err, errDokumenStatus) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Render error: "+err.Error())
+		return
+	}
+
+	name := docType + "_" + refID
+	if variant != "" {
+		name += "_" + variant
+	}
+	d.File = filepath.Join(dokumenDir(), name+".pdf")
+	if err := os.MkdirAll(dokumenDir(), 0755); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Storage error: "+err.Error())
+		return
+	}
+	if err := os.WriteFile(d.File, content, 0644); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Storage error: "+err.Error())
+		return
+	}
+	d.Size = len(content)
+
+	if archived {
+		_, err = db.Exec("UPDATE dokumen SET dokumen_no = ?, dokumen_file = ?, dokumen_size = ?, dokumen_rendered = NOW(), users_id = ? WHERE dokumen_id = ?",
+			d.No, d.File, d.Size, requestUserID(user), d.ID)
+	} else {
+		var lastID string
+		err = db.QueryRow("SELECT dokumen_id FROM dokumen ORDER BY dokumen_id DESC LIMIT 1").Scan(&lastID)
+		if err != nil && err != sql.ErrNoRows {
+			respondWithError(w, http.StatusInternalServerError, "Error fetching last dokumen_id")
+			return
+		}
+		nextNum := 1
+		if lastID != "" {
+			n, _ := strconv.Atoi(lastID[3:]) // "DK_0000003" -> "0000003"
+			nextNum = n + 1
+		}
+		d.ID = fmt.Sprintf("DK_%07d", nextNum)
+		_, err = db.Exec(`INSERT INTO dokumen (dokumen_id, dokumen_no, dokumen_type, ref_id, dokumen_variant, dokumen_file,
+				dokumen_size, dokumen_created, dokumen_rendered, users_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, NOW(), NOW(), ?)`,
+			d.ID, d.No, d.Type, d.RefID, d.Variant, d.File, d.Size, requestUserID(user))
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error archiving document: "+err.Error())
+		return
+	}
+
+	serveDokumen(w, d, content)
+}
+
+func getDokumens(w http.ResponseWriter, r *http.Request) {
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	query := dokumenSelect + " WHERE 1 = 1"
+	var args []interface{}
+	if t := r.URL.Query().Get("type"); t != "" {
+		query += " AND dokumen_type = ?"
+		args = append(args, t)
+	}
+	if refID := r.URL.Query().Get("ref_id"); refID != "" {
+		query += " AND ref_id = ?"
+		args = append(args, refID)
+	}
+	query += " ORDER BY dokumen_created DESC, dokumen_id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	list := []Dokumen{}
+	for rows.Next() {
+		d, err := scanDokumen(rows.Scan)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		list = append(list, d)
+	}
+	respondWithJSON(w, list)
+}
+
+// downloadDokumen re-downloads an archived PDF by dokumen_id without rendering
+func downloadDokumen(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	d, err := scanDokumen(db.QueryRow(dokumenSelect+" WHERE dokumen_id = ?", id).Scan)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Dokumen not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	content, err := os.ReadFile(d.File)
+	if err != nil {
+		respondWithError(w, http.StatusGone, "Stored file is missing, request /dokumen/"+d.Type+"/"+d.RefID+" to render it again")
+		return
+	}
+	serveDokumen(w, d, content)
+}
+
+// SetupDokumenRoutes sets up all PDF document routes
+func SetupDokumenRoutes(router *mux.Router) {
+	router.HandleFunc("/getdokumens", getDokumens).Methods("GET")
+	router.HandleFunc("/getdokumenfile/{id}", downloadDokumen).Methods("GET")
+	router.HandleFunc("/dokumen/{type}/{ref_id}", getDokumen).Methods("GET")
+}
@@ -0,0 +1,370 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Spreadsheet export for list and report endpoints. A client asks for it with
+// ?format=csv|xlsx or an Accept header; rows are written as they come out of
+// the database. CSV goes to the client every exportFlushRows rows; the XLSX
+// stream writer spills to a temp file once its buffer is full, so neither
+// keeps the whole result in memory.
+
+const (
+	exportCSV  = "csv"
+	exportXLSX = "xlsx"
+
+	exportMimeCSV  = "text/csv"
+	exportMimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	exportFlushRows = 500
+)
+
+// Cell types of an export column
+const (
+	exportTeks    = iota
+	exportAngka   // whole number, 1.234
+	exportRupiah  // Rp1.234
+	exportTanggal // YYYY-MM-DD
+	exportWaktu   // YYYY-MM-DD HH:MM:SS
+
+	exportJudul = -1 // header row style
+)
+
+// exportKolom is one spreadsheet column: its localized header and cell type
+type exportKolom struct {
+	Judul string
+	Tipe  int
+}
+
+// exportFormat returns the spreadsheet format the request asks for, or "" for
+// the usual JSON. ?format= wins over the Accept header, where the first of
+// JSON, CSV and XLSX listed is taken.
+func exportFormat(r *http.Request) string {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case exportCSV:
+		return exportCSV
+	case exportXLSX:
+		return exportXLSX
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return ""
+		case exportMimeCSV:
+			return exportCSV
+		case exportMimeXLSX:
+			return exportXLSX
+		}
+	}
+	return ""
+}
+
+// exportWriter writes one sheet, row by row
+type exportWriter struct {
+	w       http.ResponseWriter
+	format  string
+	nama    string
+	kolom   []exportKolom
+	baris   int
+	dikirim bool // the response has started
+
+	csv    *csv.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	style  map[int]int
+}
+
+// newExportWriter prepares an export named nama (file name without date and
+// extension) and writes the header row. Nothing reaches the client yet.
+func newExportWriter(w http.ResponseWriter, format, nama string, kolom []exportKolom) (*exportWriter, error) {
+	e := &exportWriter{w: w, format: format, nama: nama, kolom: kolom}
+
+	judul := make([]string, len(kolom))
+	for i, k := range kolom {
+		judul[i] = k.Judul
+		// CSV cells are plain numbers, so the header carries the currency
+		if format == exportCSV && k.Tipe == exportRupiah {
+			judul[i] += " (Rp)"
+		}
+	}
+
+	if format == exportCSV {
+		e.csv = csv.NewWriter(w)
+		if err := e.tulisCSV(judul); err != nil {
+			return nil, e.Gagal(err)
+		}
+		return e, nil
+	}
+
+	e.file = excelize.NewFile()
+	sheet := e.file.GetSheetName(0)
+	stream, err := e.file.NewStreamWriter(sheet)
+	if err != nil {
+		e.file.Close()
+		return nil, err
+	}
+	e.stream = stream
+
+	if err := e.siapkanStyle(); err != nil {
+		e.file.Close()
+		return nil, err
+	}
+	for i, k := range kolom {
+		lebar := float64(len(k.Judul) + 4)
+		if lebar < 14 {
+			lebar = 14
+		}
+		if err := stream.SetColWidth(i+1, i+1, lebar); err != nil {
+			e.file.Close()
+			return nil, err
+		}
+	}
+	if err := stream.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		e.file.Close()
+		return nil, err
+	}
+
+	header := make([]interface{}, len(kolom))
+	for i, j := range judul {
+		header[i] = excelize.Cell{StyleID: e.style[exportJudul], Value: j}
+	}
+	e.baris = 1
+	if err := stream.SetRow("A1", header); err != nil {
+		e.file.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// siapkanStyle registers the header style and one number format per cell type
+func (e *exportWriter) siapkanStyle() error {
+	rupiah := `"Rp"#,##0;-"Rp"#,##0`
+	tanggal := "dd/mm/yyyy"
+	waktu := "dd/mm/yyyy hh:mm"
+	styles := map[int]*excelize.Style{
+		exportJudul:   {Font: &excelize.Font{Bold: true}, Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"D9D9D9"}}},
+		exportAngka:   {NumFmt: 3},
+		exportRupiah:  {CustomNumFmt: &rupiah},
+		exportTanggal: {CustomNumFmt: &tanggal},
+		exportWaktu:   {CustomNumFmt: &waktu},
+	}
+	e.style = make(map[int]int, len(styles))
+	for tipe, s := range styles {
+		id, err := e.file.NewStyle(s)
+		if err != nil {
+			return err
+		}
+		e.style[tipe] = id
+	}
+	return nil
+}
+
+// Tulis adds one row. Values follow e.kolom; numbers may be ints, floats or
+// numeric strings, dates strings as MySQL returns them, nil is an empty cell.
+func (e *exportWriter) Tulis(nilai ...interface{}) error {
+	if e.format == exportCSV {
+		record := make([]string, len(e.kolom))
+		for i, k := range e.kolom {
+			if i < len(nilai) {
+				record[i] = exportTeksCSV(k.Tipe, exportNilai(k.Tipe, nilai[i]))
+			}
+		}
+		if err := e.tulisCSV(record); err != nil {
+			return err
+		}
+		e.baris++
+		if e.baris%exportFlushRows == 0 {
+			e.csv.Flush()
+			if flusher, ok := e.w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		}
+		return e.csv.Error()
+	}
+
+	row := make([]interface{}, len(e.kolom))
+	for i, k := range e.kolom {
+		if i >= len(nilai) {
+			break
+		}
+		value := exportNilai(k.Tipe, nilai[i])
+		if value == nil {
+			continue
+		}
+		if k.Tipe == exportTeks {
+			row[i] = value
+			continue
+		}
+		row[i] = excelize.Cell{StyleID: e.style[k.Tipe], Value: value}
+	}
+	e.baris++
+	cell, err := excelize.CoordinatesToCellName(1, e.baris)
+	if err != nil {
+		return err
+	}
+	return e.stream.SetRow(cell, row)
+}
+
+// tulisCSV writes a record, sending the headers and a UTF-8 BOM (so Excel
+// reads non-ASCII names correctly) before the first one
+func (e *exportWriter) tulisCSV(record []string) error {
+	if !e.dikirim {
+		e.kirimHeader(exportMimeCSV + "; charset=utf-8")
+		if _, err := e.w.Write([]byte("\ufeff")); err != nil {
+			return err
+		}
+	}
+	return e.csv.Write(record)
+}
+
+func (e *exportWriter) kirimHeader(contentType string) {
+	filename := fmt.Sprintf("%s-%s.%s", e.nama, time.Now().Format("2006-01-02"), e.format)
+	e.w.Header().Set("Content-Type", contentType)
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	e.dikirim = true
+}
+
+// Selesai finishes the file and, for XLSX, sends it
+func (e *exportWriter) Selesai() error {
+	if e.format == exportCSV {
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+
+	defer e.file.Close()
+	if err := e.stream.Flush(); err != nil {
+		return err
+	}
+	e.kirimHeader(exportMimeXLSX)
+	_, err := e.file.WriteTo(e.w)
+	return err
+}
+
+// Batal drops an unfinished XLSX export
+func (e *exportWriter) Batal() {
+	if e.file != nil {
+		e.file.Close()
+	}
+}
+
+// Gagal reports an export error. Before anything was sent it returns err for
+// the caller's usual error response; once the file is streaming the status is
+// gone, so the connection is aborted instead of letting the client save a
+// truncated file.
+func (e *exportWriter) Gagal(err error) error {
+	e.Batal()
+	if e.dikirim {
+		log.Printf("export %s aborted after %d rows: %v", e.nama, e.baris, err)
+		panic(http.ErrAbortHandler)
+	}
+	return err
+}
+
+// exportRows streams every row of rows as a sheet. The query must select the
+// columns in kolom order.
+func exportRows(w http.ResponseWriter, format, nama string, kolom []exportKolom, rows *sql.Rows) error {
+	e, err := newExportWriter(w, format, nama, kolom)
+	if err != nil {
+		return err
+	}
+
+	values := make([]sql.NullString, len(kolom))
+	dest := make([]interface{}, len(kolom))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	nilai := make([]interface{}, len(kolom))
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return e.Gagal(err)
+		}
+		for i, v := range values {
+			if v.Valid {
+				nilai[i] = v.String
+			} else {
+				nilai[i] = nil
+			}
+		}
+		if err := e.Tulis(nilai...); err != nil {
+			return e.Gagal(err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return e.Gagal(err)
+	}
+	if err := e.Selesai(); err != nil {
+		return e.Gagal(err)
+	}
+	return nil
+}
+
+// exportNilai converts a value to what an XLSX cell of the given type holds:
+// float64/int64 for numbers, time.Time for dates, string otherwise. Values
+// that do not parse are kept as text.
+func exportNilai(tipe int, v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	switch tipe {
+	case exportAngka, exportRupiah:
+		if s == "" {
+			return nil
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case exportTanggal, exportWaktu:
+		if s == "" {
+			return nil
+		}
+		for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05Z07:00", "2006-01-02"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t
+			}
+		}
+	}
+	return s
+}
+
+// exportTeksCSV formats a converted value for CSV. Numbers stay plain (no
+// thousands separator, '.' decimal) so spreadsheets read them as numbers.
+func exportTeksCSV(tipe int, v interface{}) string {
+	switch n := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return n
+	case int:
+		return strconv.Itoa(n)
+	case int64:
+		return strconv.FormatInt(n, 10)
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	case time.Time:
+		if tipe == exportTanggal {
+			return n.Format("2006-01-02")
+		}
+		return n.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprint(v)
+}
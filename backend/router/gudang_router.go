@@ -13,9 +13,11 @@ import (
 )
 
 type Gudang struct {
-	ID     string `json:"gudang_id"`
-	Nama   string `json:"gudang_nama"`
-	Alamat string `json:"gudang_alamat"`
+	ID        string  `json:"gudang_id"`
+	Nama      string  `json:"gudang_nama"`
+	Alamat    string  `json:"gudang_alamat"`
+	Status    int     `json:"gudang_status"`
+	DeletedAt *string `json:"deleted_at,omitempty"`
 }
 
 func createGudang(w http.ResponseWriter, r *http.Request) {
@@ -65,6 +67,7 @@ func createGudang(w http.ResponseWriter, r *http.Request) {
 		nextNum = n + 1
 	}
 	newID := fmt.Sprintf("GU_%04d", nextNum) // "GU_0003"
+	audit := startAudit(db, r, "gudang", newID)
 
 	// Insert gudang
 	stmt, err := tx.Prepare("INSERT INTO list_gudang (gudang_id, gudang_nama, gudang_alamat) VALUES (?, ?, ?)")
@@ -124,6 +127,7 @@ func createGudang(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, "Commit error: "+err.Error())
 		return
 	}
+	audit.record("create")
 
 	respondWithJSON(w, map[string]interface{}{
 		"gudang_id":     newID,
@@ -141,7 +145,13 @@ func getGudangs(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	rows, err := db.Query("SELECT gudang_id, gudang_nama, gudang_alamat FROM list_gudang")
+	// Archived gudangs are hidden unless include_archived=true
+	query := "SELECT gudang_id, gudang_nama, gudang_alamat, gudang_status, deleted_at FROM list_gudang"
+	if r.URL.Query().Get("include_archived") != "true" {
+		query += " WHERE gudang_status = 1"
+	}
+
+	rows, err := db.Query(query)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
 		return
@@ -151,10 +161,14 @@ func getGudangs(w http.ResponseWriter, r *http.Request) {
 	var gudangs []Gudang
 	for rows.Next() {
 		var g Gudang
-		if err := rows.Scan(&g.ID, &g.Nama, &g.Alamat); err != nil {
+		var deletedAt sql.NullString
+		if err := rows.Scan(&g.ID, &g.Nama, &g.Alamat, &g.Status, &deletedAt); err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
 			return
 		}
+		if deletedAt.Valid {
+			g.DeletedAt = &deletedAt.String
+		}
 		gudangs = append(gudangs, g)
 	}
 	respondWithJSON(w, gudangs)
@@ -171,8 +185,10 @@ func getGudang(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	// Archived gudangs stay resolvable here for historical records
 	var g Gudang
-	err = db.QueryRow("SELECT gudang_id, gudang_nama, gudang_alamat FROM list_gudang WHERE gudang_id = ?", id).Scan(&g.ID, &g.Nama, &g.Alamat)
+	var deletedAt sql.NullString
+	err = db.QueryRow("SELECT gudang_id, gudang_nama, gudang_alamat, gudang_status, deleted_at FROM list_gudang WHERE gudang_id = ?", id).Scan(&g.ID, &g.Nama, &g.Alamat, &g.Status, &deletedAt)
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Gudang not found")
 		return
@@ -183,18 +199,23 @@ func getGudang(w http.ResponseWriter, r *http.Request) {
 
 	// Get the count of floors for this gudang
 	var jumlahLantai int
-	err = db.QueryRow("SELECT COUNT(*) FROM gudang_lantai WHERE gudang_id = ?", id).Scan(&jumlahLantai)
+	err = db.QueryRow("SELECT COUNT(*) FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1", id).Scan(&jumlahLantai)
 	if err != nil {
 		jumlahLantai = 0
 	}
 
 	// Return gudang with floor count
-	respondWithJSON(w, map[string]interface{}{
+	response := map[string]interface{}{
 		"gudang_id":     g.ID,
 		"gudang_nama":   g.Nama,
 		"gudang_alamat": g.Alamat,
+		"gudang_status": g.Status,
 		"jumlah_lantai": jumlahLantai,
-	})
+	}
+	if deletedAt.Valid {
+		response["deleted_at"] = deletedAt.String
+	}
+	respondWithJSON(w, response)
 }
 
 func updateGudang(w http.ResponseWriter, r *http.Request) {
@@ -225,6 +246,8 @@ func updateGudang(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	audit := startAudit(db, r, "gudang", id)
+
 	// Start transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -233,16 +256,15 @@ func updateGudang(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if gudang exists first
-	var exists int
-	err = tx.QueryRow("SELECT COUNT(*) FROM list_gudang WHERE gudang_id = ?", id).Scan(&exists)
-	if err != nil {
+	var oldNama string
+	err = tx.QueryRow("SELECT gudang_nama FROM list_gudang WHERE gudang_id = ?", id).Scan(&oldNama)
+	if err == sql.ErrNoRows {
 		tx.Rollback()
-		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		respondWithError(w, http.StatusNotFound, "Gudang not found")
 		return
-	}
-	if exists == 0 {
+	} else if err != nil {
 		tx.Rollback()
-		respondWithError(w, http.StatusNotFound, "Gudang not found")
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
 		return
 	}
 
@@ -262,9 +284,43 @@ func updateGudang(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get current number of floors
+	// Rename floors that still carry their default name, custom names are kept
+	if oldNama != gudang.Nama {
+		rows, err := tx.Query("SELECT lantai_id, lantai_no, lantai_nama FROM gudang_lantai WHERE gudang_id = ?", id)
+		if err != nil {
+			tx.Rollback()
+			respondWithError(w, http.StatusInternalServerError, "Error fetching floors")
+			return
+		}
+		renames := map[string]string{}
+		for rows.Next() {
+			var lantaiID, lantaiNama string
+			var lantaiNo int
+			if err := rows.Scan(&lantaiID, &lantaiNo, &lantaiNama); err != nil {
+				rows.Close()
+				tx.Rollback()
+				respondWithError(w, http.StatusInternalServerError, "Error scanning floors")
+				return
+			}
+			if lantaiNama == defaultLantaiNama(oldNama, lantaiNo) {
+				renames[lantaiID] = defaultLantaiNama(gudang.Nama, lantaiNo)
+			}
+		}
+		rows.Close()
+
+		for lantaiID, lantaiNama := range renames {
+			_, err = tx.Exec("UPDATE gudang_lantai SET lantai_nama = ? WHERE lantai_id = ?", lantaiNama, lantaiID)
+			if err != nil {
+				tx.Rollback()
+				respondWithError(w, http.StatusInternalServerError, "Update lantai name error: "+err.Error())
+				return
+			}
+		}
+	}
+
+	// Get current number of active floors
 	var currentFloorCount int
-	err = tx.QueryRow("SELECT COUNT(*) FROM gudang_lantai WHERE gudang_id = ?", id).Scan(&currentFloorCount)
+	err = tx.QueryRow("SELECT COUNT(*) FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1", id).Scan(&currentFloorCount)
 	if err != nil {
 		tx.Rollback()
 		respondWithError(w, http.StatusInternalServerError, "Error counting floors")
@@ -273,24 +329,15 @@ func updateGudang(w http.ResponseWriter, r *http.Request) {
 
 	// Handle floor changes
 	if gudang.JumlahLantai > currentFloorCount {
-		// Add new floors
-		// Get last lantai_id globally
-		var lastLantaiID string
-		err = tx.QueryRow("SELECT lantai_id FROM gudang_lantai ORDER BY lantai_id DESC LIMIT 1").Scan(&lastLantaiID)
-		if err != nil && err != sql.ErrNoRows {
+		// Add new floors after the last active floor, ahead of retired ones
+		nextLantaiNum, err := nextLantaiNum(tx)
+		if err != nil {
 			tx.Rollback()
 			respondWithError(w, http.StatusInternalServerError, "Error fetching last lantai_id")
 			return
 		}
-		nextLantaiNum := 1
-		if lastLantaiID != "" {
-			numPart := lastLantaiID[3:] // "GL_0006" -> "0006"
-			n, _ := strconv.Atoi(numPart)
-			nextLantaiNum = n + 1
-		}
 
-		// Insert new floors
-		lantaiStmt, err := tx.Prepare("INSERT INTO gudang_lantai (lantai_id, gudang_id, lantai_no, lantai_nama) VALUES (?, ?, ?, ?)")
+		lantaiStmt, err := tx.Prepare("INSERT INTO gudang_lantai (lantai_id, gudang_id, lantai_no, lantai_nama, lantai_status) VALUES (?, ?, ?, ?, 1)")
 		if err != nil {
 			tx.Rollback()
 			respondWithError(w, http.StatusInternalServerError, "Prepare lantai statement error")
@@ -300,9 +347,8 @@ func updateGudang(w http.ResponseWriter, r *http.Request) {
 
 		for i := currentFloorCount + 1; i <= gudang.JumlahLantai; i++ {
 			lantaiID := fmt.Sprintf("GL_%04d", nextLantaiNum)
-			lantaiNama := fmt.Sprintf("%s Lt.%d", gudang.Nama, i)
 
-			_, err = lantaiStmt.Exec(lantaiID, id, i, lantaiNama)
+			_, err = lantaiStmt.Exec(lantaiID, id, i, defaultLantaiNama(gudang.Nama, i))
 			if err != nil {
 				tx.Rollback()
 				respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Insert lantai %d error: %s", i, err.Error()))
@@ -310,40 +356,46 @@ func updateGudang(w http.ResponseWriter, r *http.Request) {
 			}
 			nextLantaiNum++
 		}
-	} else if gudang.JumlahLantai < currentFloorCount {
-		// Delete excess floors (from highest to lowest)
-		deleteStmt, err := tx.Prepare("DELETE FROM gudang_lantai WHERE gudang_id = ? AND lantai_no > ?")
-		if err != nil {
+		// Retired floors keep numbers after the active ones, so the new floors
+		// take their place and the retired ones move up
+		if err = renumberLantai(tx, id); err != nil {
 			tx.Rollback()
-			respondWithError(w, http.StatusInternalServerError, "Prepare delete statement error")
+			respondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
-		defer deleteStmt.Close()
-
-		_, err = deleteStmt.Exec(id, gudang.JumlahLantai)
+	} else if gudang.JumlahLantai < currentFloorCount {
+		// Retire the highest floors, refused while any of them still holds stock
+		rows, err := tx.Query("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1 AND lantai_no > ? ORDER BY lantai_no DESC", id, gudang.JumlahLantai)
 		if err != nil {
 			tx.Rollback()
-			respondWithError(w, http.StatusInternalServerError, "Delete excess floors error: "+err.Error())
+			respondWithError(w, http.StatusInternalServerError, "Error fetching floors")
 			return
 		}
-	}
-
-	// Update lantai names for all floors if gudang name changed
-	updateNamesStmt, err := tx.Prepare("UPDATE gudang_lantai SET lantai_nama = ? WHERE gudang_id = ? AND lantai_no = ?")
-	if err != nil {
-		tx.Rollback()
-		respondWithError(w, http.StatusInternalServerError, "Prepare update names statement error")
-		return
-	}
-	defer updateNamesStmt.Close()
+		var excessIDs []string
+		for rows.Next() {
+			var lantaiID string
+			if err := rows.Scan(&lantaiID); err != nil {
+				rows.Close()
+				tx.Rollback()
+				respondWithError(w, http.StatusInternalServerError, "Error scanning floors")
+				return
+			}
+			excessIDs = append(excessIDs, lantaiID)
+		}
+		rows.Close()
 
-	for i := 1; i <= gudang.JumlahLantai; i++ {
-		lantaiNama := fmt.Sprintf("%s Lt.%d", gudang.Nama, i)
-		_, err = updateNamesStmt.Exec(lantaiNama, id, i)
-		if err != nil {
-			tx.Rollback()
-			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Update lantai name %d error: %s", i, err.Error()))
-			return
+		for _, lantaiID := range excessIDs {
+			_, report, err := retireLantai(tx, lantaiID, "", nil)
+			if err != nil {
+				tx.Rollback()
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if report != nil {
+				tx.Rollback()
+				respondWithLantaiStock(w, lantaiID, report)
+				return
+			}
 		}
 	}
 
@@ -352,6 +404,7 @@ func updateGudang(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, "Commit error: "+err.Error())
 		return
 	}
+	audit.record("update")
 
 	respondWithJSON(w, map[string]interface{}{
 		"gudang_id":     id,
@@ -361,6 +414,8 @@ func updateGudang(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// deleteGudang archives a gudang. It is refused while any floor still holds
+// stock; the response then lists what is stored where.
 func deleteGudang(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id := params["id"]
@@ -372,28 +427,82 @@ func deleteGudang(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare("DELETE FROM list_gudang WHERE gudang_id = ?")
+	user, err := requestUser(db, r)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Prepare statement error")
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var status int
+	err = db.QueryRow("SELECT gudang_status FROM list_gudang WHERE gudang_id = ?", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Gudang not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if status == 0 {
+		respondWithError(w, http.StatusConflict, "Gudang is already archived")
 		return
 	}
-	defer stmt.Close()
 
-	res, err := stmt.Exec(id)
+	// Check remaining stock per floor
+	rows, err := db.Query(`
+		SELECT gl.lantai_id, gl.lantai_nama, sg.barang_id, COALESCE(b.barang_nama, ''), sg.stock_barang
+		FROM stock_gudang sg
+		JOIN gudang_lantai gl ON sg.lantai_id = gl.lantai_id
+		LEFT JOIN barang b ON sg.barang_id = b.barang_id
+		WHERE gl.gudang_id = ? AND sg.stock_barang <> 0
+		ORDER BY gl.lantai_no, sg.barang_id`, id)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Delete error: "+err.Error())
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
 		return
 	}
+	defer rows.Close()
 
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
-		respondWithError(w, http.StatusNotFound, "Gudang not found")
+	totalStock := 0
+	stockReport := []map[string]interface{}{}
+	for rows.Next() {
+		var lantaiID, lantaiNama, barangID, barangNama string
+		var stock int
+		if err := rows.Scan(&lantaiID, &lantaiNama, &barangID, &barangNama, &stock); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		totalStock += stock
+		stockReport = append(stockReport, map[string]interface{}{
+			"lantai_id":    lantaiID,
+			"lantai_nama":  lantaiNama,
+			"barang_id":    barangID,
+			"barang_nama":  barangNama,
+			"stock_barang": stock,
+		})
+	}
+
+	if len(stockReport) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Gudang still holds stock, move or remove it before deleting",
+			"gudang_id":   id,
+			"total_stock": totalStock,
+			"stock":       stockReport,
+		})
+		return
+	}
+
+	audit := startAudit(db, r, "gudang", id)
+	_, err = db.Exec("UPDATE list_gudang SET gudang_status = 0, deleted_at = NOW(), deleted_by = ? WHERE gudang_id = ?", requestUserID(user), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Archive error: "+err.Error())
 		return
 	}
+	audit.record("delete")
 
 	respondWithJSON(w, map[string]string{
 		"gudang_id": id,
-		"status":    "Deleted",
+		"status":    "Archived",
 	})
 }
 
@@ -415,6 +524,7 @@ func getGudangLantaiAll(w http.ResponseWriter, r *http.Request) {
 			lg.gudang_nama
 		FROM gudang_lantai gl
 		JOIN list_gudang lg ON gl.gudang_id = lg.gudang_id
+		WHERE gl.lantai_status = 1 AND lg.gudang_status = 1
 		ORDER BY lg.gudang_nama, gl.lantai_no
 	`
 
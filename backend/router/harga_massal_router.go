@@ -0,0 +1,334 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"src/database"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// HargaMassalRequest changes the price and/or discount of many barang at
+// once. Every selector given must match; at least one is required.
+type HargaMassalRequest struct {
+	BrandID      string   `json:"brand_id"`
+	BrandNama    string   `json:"brand_nama"`
+	BarangStatus *int     `json:"barang_status,omitempty"`
+	BarangIDs    []string `json:"barang_ids"`
+
+	// Price adjustment: nilai percent (tipe persen) or rupiah (tipe nominal)
+	// added to the price, negative lowers it
+	Target     string  `json:"target"` // asli, jual or keduanya
+	Tipe       string  `json:"tipe"`   // persen or nominal
+	Nilai      float64 `json:"nilai"`
+	Pembulatan int     `json:"pembulatan"` // round to a multiple, e.g. 500; 0 rounds to 1 rupiah
+	ArahBulat  string  `json:"arah_bulat"` // terdekat (default), atas or bawah
+	// Empty or today changes the price now, a later date schedules it
+	BerlakuMulai string `json:"berlaku_mulai"`
+	Note         string `json:"note"`
+
+	// Discount set on every selected barang
+	Diskon *DiscountRequest `json:"diskon,omitempty"`
+
+	// Only report what would change
+	DryRun bool `json:"dry_run"`
+}
+
+// HargaMassalItem is the change worked out for one barang
+type HargaMassalItem struct {
+	BarangID      string `json:"barang_id"`
+	BarangNama    string `json:"barang_nama"`
+	HargaAsliLama int    `json:"harga_asli_lama"`
+	HargaAsliBaru int    `json:"harga_asli_baru"`
+	HargaJualLama int    `json:"harga_jual_lama"`
+	HargaJualBaru int    `json:"harga_jual_baru"`
+	DiskonBaru    string `json:"diskon_baru,omitempty"`
+	HargaDiskon   int    `json:"harga_diskon,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// bulatkan rounds a price to a multiple of kelipatan
+func bulatkan(harga float64, kelipatan int, arah string) int {
+	if kelipatan <= 1 {
+		kelipatan = 1
+	}
+	n := harga / float64(kelipatan)
+	switch arah {
+	case "atas":
+		n = math.Ceil(n - 1e-9)
+	case "bawah":
+		n = math.Floor(n + 1e-9)
+	default:
+		n = math.Round(n)
+	}
+	return int(n) * kelipatan
+}
+
+// Helper function to apply the adjustment of a request to one price
+func sesuaikanHarga(harga int, req HargaMassalRequest) int {
+	baru := float64(harga) + req.Nilai
+	if req.Tipe == "persen" {
+		baru = float64(harga) * (100 + req.Nilai) / 100
+	}
+	return bulatkan(baru, req.Pembulatan, req.ArahBulat)
+}
+
+// Helper function to validate a bulk request before the barang are read
+func validateHargaMassalRequest(req *HargaMassalRequest) string {
+	if req.BrandID == "" && req.BrandNama == "" && req.BarangStatus == nil && len(req.BarangIDs) == 0 {
+		return "select barang by brand_id, brand_nama, barang_status or barang_ids"
+	}
+	if req.Tipe == "" && req.Diskon == nil {
+		return "nothing to change, give a price adjustment (tipe, nilai) or a diskon"
+	}
+	if req.Tipe != "" {
+		if req.Tipe != "persen" && req.Tipe != "nominal" {
+			return "tipe must be persen or nominal"
+		}
+		if req.Target == "" {
+			req.Target = "jual"
+		}
+		if req.Target != "asli" && req.Target != "jual" && req.Target != "keduanya" {
+			return "target must be asli, jual or keduanya"
+		}
+		if req.Nilai == 0 {
+			return "nilai cannot be 0"
+		}
+		if req.Tipe == "persen" && req.Nilai <= -100 {
+			return "nilai cannot lower a price by 100% or more"
+		}
+		if req.Pembulatan < 0 {
+			return "pembulatan cannot be negative"
+		}
+		if req.ArahBulat == "" {
+			req.ArahBulat = "terdekat"
+		}
+		if req.ArahBulat != "terdekat" && req.ArahBulat != "atas" && req.ArahBulat != "bawah" {
+			return "arah_bulat must be terdekat, atas or bawah"
+		}
+	}
+	today := time.Now().Format("2006-01-02")
+	if req.BerlakuMulai == "" {
+		req.BerlakuMulai = today
+	}
+	if _, err := time.ParseInLocation("2006-01-02", req.BerlakuMulai, time.Local); err != nil {
+		return "berlaku_mulai must be YYYY-MM-DD"
+	}
+	if req.BerlakuMulai < today {
+		return "berlaku_mulai cannot be in the past"
+	}
+	return ""
+}
+
+// hargaMassal previews (dry_run) or applies a bulk price/discount change.
+// Nothing is applied when any selected barang fails validation. Admin only.
+func hargaMassal(w http.ResponseWriter, r *http.Request) {
+	var req HargaMassalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if msg := validateHargaMassalRequest(&req); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if user == nil || user.UsersLevel != 1 {
+		respondWithError(w, http.StatusForbidden, "Only admin can change prices in bulk")
+		return
+	}
+
+	query := `SELECT b.barang_id, b.barang_nama, b.barang_harga_asli, b.barang_harga_jual
+		FROM barang b LEFT JOIN brand br ON b.brand_id = br.brand_id
+		WHERE b.deleted_at IS NULL`
+	var args []interface{}
+	if req.BrandID != "" {
+		query += " AND b.brand_id = ?"
+		args = append(args, req.BrandID)
+	}
+	if req.BrandNama != "" {
+		query += " AND br.brand_nama = ?"
+		args = append(args, req.BrandNama)
+	}
+	if req.BarangStatus != nil {
+		query += " AND b.barang_status = ?"
+		args = append(args, *req.BarangStatus)
+	}
+	if len(req.BarangIDs) > 0 {
+		query += " AND b.barang_id IN (?" + strings.Repeat(", ?", len(req.BarangIDs)-1) + ")"
+		for _, id := range req.BarangIDs {
+			args = append(args, id)
+		}
+	}
+	query += " ORDER BY b.barang_id"
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(query+" FOR UPDATE", args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	items := []HargaMassalItem{}
+	for rows.Next() {
+		var item HargaMassalItem
+		if err := rows.Scan(&item.BarangID, &item.BarangNama, &item.HargaAsliLama, &item.HargaJualLama); err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if len(items) == 0 {
+		respondWithError(w, http.StatusNotFound, "No barang match the selection")
+		return
+	}
+
+	mulai, _ := time.ParseInLocation("2006-01-02", req.BerlakuMulai, time.Local)
+	dijadwalkan := req.BerlakuMulai > time.Now().Format("2006-01-02")
+	diskon := make([]*DiskonBarang, len(items))
+	gagal := 0
+	for i := range items {
+		item := &items[i]
+		// A scheduled change builds on the price valid on that day
+		if dijadwalkan {
+			h, err := hargaPada(tx, item.BarangID, mulai)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if h != nil {
+				item.HargaAsliLama, item.HargaJualLama = h.HargaAsli, h.HargaJual
+			}
+		}
+		item.HargaAsliBaru, item.HargaJualBaru = item.HargaAsliLama, item.HargaJualLama
+		if req.Tipe != "" {
+			if req.Target != "jual" {
+				item.HargaAsliBaru = sesuaikanHarga(item.HargaAsliLama, req)
+			}
+			if req.Target != "asli" {
+				item.HargaJualBaru = sesuaikanHarga(item.HargaJualLama, req)
+			}
+		}
+
+		switch {
+		case item.HargaAsliBaru < 0:
+			item.Error = "barang_harga_asli would be negative"
+		case item.HargaJualBaru <= 0:
+			item.Error = "barang_harga_jual would not be greater than 0"
+		case item.HargaJualBaru < item.HargaAsliBaru:
+			item.Error = fmt.Sprintf("barang_harga_jual %d would be below barang_harga_asli %d", item.HargaJualBaru, item.HargaAsliBaru)
+		}
+		if item.Error == "" && req.Diskon != nil {
+			d, err := diskonDariRequest(*req.Diskon)
+			if err == nil && d == nil {
+				err = fmt.Errorf("diskon needs diskon_tipe and diskon_nilai")
+			}
+			if err == nil {
+				err = validasiDiskon(d, item.HargaAsliBaru, item.HargaJualBaru)
+			}
+			if err != nil {
+				item.Error = err.Error()
+			} else {
+				diskon[i] = d
+				item.DiskonBaru = teksDiskon(d.Tipe, d.Nilai)
+				item.HargaDiskon = hargaDiskon(item.HargaJualBaru, d.Tipe, d.Nilai)
+			}
+		}
+		if item.Error != "" {
+			gagal++
+		}
+	}
+
+	response := map[string]interface{}{
+		"dry_run":       req.DryRun,
+		"berlaku_mulai": req.BerlakuMulai,
+		"jumlah":        len(items),
+		"gagal":         gagal,
+		"items":         items,
+	}
+	if req.DryRun {
+		respondWithJSON(w, response)
+		return
+	}
+	if gagal > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		response["error"] = "Some barang failed validation, nothing was changed"
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Audit rows are written in the transaction, so they commit with the change
+	for i, item := range items {
+		audit := startAudit(tx, r, "barang", item.BarangID)
+		hargaBerubah := item.HargaAsliBaru != item.HargaAsliLama || item.HargaJualBaru != item.HargaJualLama
+		if hargaBerubah && dijadwalkan {
+			if _, err := catatHarga(tx, item.BarangID, item.HargaAsliBaru, item.HargaJualBaru, req.BerlakuMulai, hargaTerjadwal, req.Note, user); err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			// The barang row only changes on that day, record the schedule itself
+			err := writeAudit(tx, r, "barang", item.BarangID, "bulk_schedule_harga", map[string]auditChange{
+				"barang_harga_asli": {item.HargaAsliLama, item.HargaAsliBaru},
+				"barang_harga_jual": {item.HargaJualLama, item.HargaJualBaru},
+				"berlaku_mulai":     {nil, req.BerlakuMulai},
+			})
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		} else if hargaBerubah {
+			if _, err := tx.Exec("UPDATE barang SET barang_harga_asli = ?, barang_harga_jual = ? WHERE barang_id = ?",
+				item.HargaAsliBaru, item.HargaJualBaru, item.BarangID); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+				return
+			}
+			if _, err := catatHarga(tx, item.BarangID, item.HargaAsliBaru, item.HargaJualBaru, req.BerlakuMulai, hargaBerlaku, req.Note, user); err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		if diskon[i] != nil {
+			if _, err := simpanDiskon(tx, item.BarangID, diskon[i], user); err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		audit.record("bulk_update")
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+	respondWithJSON(w, response)
+}
+
+// SetupHargaMassalRoutes sets up the bulk price update route
+func SetupHargaMassalRoutes(router *mux.Router) {
+	router.HandleFunc("/hargamassal", hargaMassal).Methods("POST")
+}
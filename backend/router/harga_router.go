@@ -0,0 +1,515 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"src/database"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// HargaBarang is the selling price of one unit of barang after discounts
+type HargaBarang struct {
+	BarangID       string  `json:"barang_id"`
+	BarangNama     string  `json:"barang_nama"`
+	HargaJual      int     `json:"barang_harga_jual"`
+	Diskon         float64 `json:"diskon_persen"`
+	DiskonNominal  int     `json:"diskon_nominal,omitempty"`
+	DeadlineDiskon string  `json:"barang_deadline_diskon,omitempty"`
+	HargaAkhir     int     `json:"harga_akhir"`
+
+	// Set when the customer's price list gave the price
+	DaftarID   string `json:"daftar_id,omitempty"`
+	DaftarNama string `json:"daftar_nama,omitempty"`
+	MinQty     int    `json:"min_qty,omitempty"`
+}
+
+// parseDiskonPersen reads barang_diskon ("10", "10%", "12.5 %") as a percentage
+func parseDiskonPersen(diskon string) (float64, bool) {
+	value := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(diskon), "%"))
+	if value == "" || value == "-" {
+		return 0, false
+	}
+	pct, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+	if err != nil || pct <= 0 || pct > 100 {
+		return 0, false
+	}
+	return pct, true
+}
+
+// Helper function to apply a percentage discount to a unit price
+func hargaSetelahDiskon(harga int, pct float64) int {
+	return int(math.Round(float64(harga) * (100 - pct) / 100))
+}
+
+// Price history status values (barang_harga.harga_status)
+const (
+	hargaDibatalkan = 0
+	hargaTerjadwal  = 1
+	hargaBerlaku    = 2
+)
+
+var hargaStatusNama = map[int]string{
+	hargaDibatalkan: "Dibatalkan",
+	hargaTerjadwal:  "Terjadwal",
+	hargaBerlaku:    "Berlaku",
+}
+
+// hargaAsliSaatJual is the cost of a sale line on the day of the sale, from
+// the price history with barang_harga_asli as fallback. Expects the aliases
+// si (sale_items), s (sales) and b (barang).
+const hargaAsliSaatJual = `COALESCE((SELECT bh.harga_asli FROM barang_harga bh
+			WHERE bh.barang_id = si.barang_id AND bh.harga_status IN (1, 2) AND bh.berlaku_mulai <= DATE(s.sales_date)
+			ORDER BY bh.berlaku_mulai DESC, bh.harga_id DESC LIMIT 1), b.barang_harga_asli)`
+
+// BarangHarga is one entry of the price history of a barang
+type BarangHarga struct {
+	HargaID      string `json:"harga_id"`
+	BarangID     string `json:"barang_id"`
+	HargaAsli    int    `json:"harga_asli"`
+	HargaJual    int    `json:"harga_jual"`
+	BerlakuMulai string `json:"berlaku_mulai"`
+	Status       int    `json:"harga_status"`
+	StatusNama   string `json:"harga_status_nama"`
+	Note         string `json:"harga_note,omitempty"`
+	CreatedBy    string `json:"created_by,omitempty"`
+	CreatedAt    string `json:"created_at"`
+	AppliedAt    string `json:"applied_at,omitempty"`
+}
+
+type JadwalHargaRequest struct {
+	HargaAsli    int    `json:"barang_harga_asli"`
+	HargaJual    int    `json:"barang_harga_jual"`
+	BerlakuMulai string `json:"berlaku_mulai"` // YYYY-MM-DD, after today
+	Note         string `json:"harga_note"`
+}
+
+const barangHargaSelect = `SELECT harga_id, barang_id, harga_asli, harga_jual, DATE_FORMAT(berlaku_mulai, '%Y-%m-%d'),
+		harga_status, harga_note, created_by, created_at, applied_at
+	FROM barang_harga`
+
+// Helper function to scan one barang_harga row
+func scanBarangHarga(row interface{ Scan(...interface{}) error }) (*BarangHarga, error) {
+	var h BarangHarga
+	var note, createdBy, appliedAt sql.NullString
+	if err := row.Scan(&h.HargaID, &h.BarangID, &h.HargaAsli, &h.HargaJual, &h.BerlakuMulai,
+		&h.Status, &note, &createdBy, &h.CreatedAt, &appliedAt); err != nil {
+		return nil, err
+	}
+	h.StatusNama = hargaStatusNama[h.Status]
+	h.Note = note.String
+	h.CreatedBy = createdBy.String
+	h.AppliedAt = appliedAt.String
+	return &h, nil
+}
+
+// hargaPada returns the price of barang valid on day: the latest applied or
+// due scheduled entry starting on or before it. nil when barang has no history.
+func hargaPada(q sqlExecutor, barangID string, day time.Time) (*BarangHarga, error) {
+	h, err := scanBarangHarga(q.QueryRow(barangHargaSelect+`
+		WHERE barang_id = ? AND harga_status IN (1, 2) AND berlaku_mulai <= ?
+		ORDER BY berlaku_mulai DESC, harga_id DESC LIMIT 1`, barangID, day.Format("2006-01-02")))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error fetching price history: %v", err)
+	}
+	return h, nil
+}
+
+// catatHarga adds an entry to the price history of barang
+func catatHarga(q sqlExecutor, barangID string, hargaAsli, hargaJual int, berlakuMulai string, status int, note string, user *UserData) (string, error) {
+	var lastID string
+	err := q.QueryRow("SELECT harga_id FROM barang_harga ORDER BY harga_id DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("error fetching last harga_id: %v", err)
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:]) // "HH_0000002" -> "0000002"
+		nextNum = n + 1
+	}
+	newID := fmt.Sprintf("HH_%07d", nextNum)
+
+	var appliedAt interface{}
+	if status == hargaBerlaku {
+		appliedAt = time.Now().Format("2006-01-02 15:04:05")
+	}
+	_, err = q.Exec(`INSERT INTO barang_harga (harga_id, barang_id, harga_asli, harga_jual, berlaku_mulai, harga_status, harga_note, created_by, created_at, applied_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, NOW(), ?)`,
+		newID, barangID, hargaAsli, hargaJual, berlakuMulai, status, processNullableStringValue(note), requestUserID(user), appliedAt)
+	if err != nil {
+		return "", fmt.Errorf("error recording price history: %v", err)
+	}
+	return newID, nil
+}
+
+// applyHargaTerjadwal copies due scheduled prices to barang. Every due entry
+// is marked applied; barang gets whatever is valid today, so a manual change
+// made after an older schedule came due is not overwritten by it.
+func applyHargaTerjadwal(db *sql.DB) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	today := time.Now()
+	rows, err := tx.Query(`SELECT DISTINCT barang_id FROM barang_harga
+		WHERE harga_status = ? AND berlaku_mulai <= ? FOR UPDATE`, hargaTerjadwal, today.Format("2006-01-02"))
+	if err != nil {
+		return 0, err
+	}
+	var barangIDs []string
+	for rows.Next() {
+		var barangID string
+		if err := rows.Scan(&barangID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		barangIDs = append(barangIDs, barangID)
+	}
+	rows.Close()
+	if len(barangIDs) == 0 {
+		return 0, nil
+	}
+
+	if _, err := tx.Exec("UPDATE barang_harga SET harga_status = ?, applied_at = NOW() WHERE harga_status = ? AND berlaku_mulai <= ?",
+		hargaBerlaku, hargaTerjadwal, today.Format("2006-01-02")); err != nil {
+		return 0, err
+	}
+	for _, barangID := range barangIDs {
+		h, err := hargaPada(tx, barangID, today)
+		if err != nil {
+			return 0, err
+		}
+		if h == nil {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE barang SET barang_harga_asli = ?, barang_harga_jual = ? WHERE barang_id = ?",
+			h.HargaAsli, h.HargaJual, barangID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(barangIDs), nil
+}
+
+// StartHargaScheduler applies scheduled price changes once at startup and
+// then every interval in the background.
+func StartHargaScheduler(interval time.Duration) {
+	run := func() {
+		db, err := database.GetDBConnection()
+		if err != nil {
+			log.Printf("harga scheduler: %v", err)
+			return
+		}
+		defer db.Close()
+
+		n, err := applyHargaTerjadwal(db)
+		if err != nil {
+			log.Printf("harga scheduler: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("harga scheduler: applied scheduled prices for %d barang", n)
+		}
+	}
+
+	go func() {
+		run()
+		for range time.Tick(interval) {
+			run()
+		}
+	}()
+}
+
+// hitungHargaBarang prices one unit of barang on day from the price valid
+// then and the discount active on that day
+func hitungHargaBarang(q sqlExecutor, barangID string, day time.Time) (*HargaBarang, error) {
+	var h HargaBarang
+	err := q.QueryRow(`SELECT barang_id, barang_nama, barang_harga_jual
+		FROM barang WHERE barang_id = ? AND deleted_at IS NULL`, barangID).
+		Scan(&h.BarangID, &h.BarangNama, &h.HargaJual)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("barang '%s' does not exist", barangID)
+	} else if err != nil {
+		return nil, fmt.Errorf("error fetching barang: %v", err)
+	}
+
+	berlaku, err := hargaPada(q, barangID, day)
+	if err != nil {
+		return nil, err
+	}
+	if berlaku != nil {
+		h.HargaJual = berlaku.HargaJual
+	}
+
+	h.HargaAkhir = h.HargaJual
+	d, err := diskonPada(q, barangID, day)
+	if err != nil {
+		return nil, err
+	}
+	if d != nil {
+		if d.Tipe == diskonNominal {
+			h.DiskonNominal = int(math.Round(d.Nilai))
+		} else {
+			h.Diskon = d.Nilai
+		}
+		h.DeadlineDiskon = d.Selesai
+		h.HargaAkhir = hargaDiskon(h.HargaJual, d.Tipe, d.Nilai)
+	}
+	return &h, nil
+}
+
+// Helper function to read the ?date= parameter, default today
+func hargaTanggal(r *http.Request) (time.Time, error) {
+	value := r.URL.Query().Get("date")
+	if value == "" {
+		return time.Now(), nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date must be YYYY-MM-DD")
+	}
+	return day, nil
+}
+
+// getHargaBarang prices one unit of barang. Optional ?date=YYYY-MM-DD, and
+// ?customer_id= with ?qty= (default 1) to apply the customer's price list.
+func getHargaBarang(w http.ResponseWriter, r *http.Request) {
+	barangID := mux.Vars(r)["barang_id"]
+
+	day, err := hargaTanggal(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	qty := 1
+	if value := r.URL.Query().Get("qty"); value != "" {
+		qty, err = strconv.Atoi(value)
+		if err != nil || qty <= 0 {
+			respondWithError(w, http.StatusBadRequest, "qty must be a positive number")
+			return
+		}
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	harga, err := hitungHargaPelanggan(db, barangID, r.URL.Query().Get("customer_id"), qty, day)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithJSON(w, harga)
+}
+
+// getHargaBerlaku returns the cost and selling price of barang valid on
+// ?date=YYYY-MM-DD (default today)
+func getHargaBerlaku(w http.ResponseWriter, r *http.Request) {
+	barangID := mux.Vars(r)["barang_id"]
+
+	day, err := hargaTanggal(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	var hargaAsli, hargaJual int
+	err = db.QueryRow("SELECT barang_harga_asli, barang_harga_jual FROM barang WHERE barang_id = ?", barangID).Scan(&hargaAsli, &hargaJual)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Barang not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	h, err := hargaPada(db, barangID, day)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	result := map[string]interface{}{
+		"barang_id":         barangID,
+		"date":              day.Format("2006-01-02"),
+		"barang_harga_asli": hargaAsli,
+		"barang_harga_jual": hargaJual,
+	}
+	if h != nil {
+		result["barang_harga_asli"] = h.HargaAsli
+		result["barang_harga_jual"] = h.HargaJual
+		result["harga_id"] = h.HargaID
+		result["berlaku_mulai"] = h.BerlakuMulai
+	}
+	respondWithJSON(w, result)
+}
+
+// getHargaHistory lists the price history of barang, scheduled entries
+// included, newest first
+func getHargaHistory(w http.ResponseWriter, r *http.Request) {
+	barangID := mux.Vars(r)["barang_id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	rows, err := db.Query(barangHargaSelect+" WHERE barang_id = ? ORDER BY berlaku_mulai DESC, harga_id DESC", barangID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	history := []BarangHarga{}
+	for rows.Next() {
+		h, err := scanBarangHarga(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		history = append(history, *h)
+	}
+	respondWithJSON(w, history)
+}
+
+// jadwalHarga schedules a price change of barang for a later date. Changes
+// that take effect today go through updatebarang.
+func jadwalHarga(w http.ResponseWriter, r *http.Request) {
+	barangID := mux.Vars(r)["barang_id"]
+
+	var req JadwalHargaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.HargaAsli < 0 || req.HargaJual <= 0 {
+		respondWithError(w, http.StatusBadRequest, "barang_harga_jual must be greater than 0 and barang_harga_asli cannot be negative")
+		return
+	}
+	mulai, err := time.ParseInLocation("2006-01-02", req.BerlakuMulai, time.Local)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "berlaku_mulai must be YYYY-MM-DD")
+		return
+	}
+	if mulai.Format("2006-01-02") <= time.Now().Format("2006-01-02") {
+		respondWithError(w, http.StatusBadRequest, "berlaku_mulai must be after today, use updatebarang for an immediate change")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var exists bool
+	db.QueryRow("SELECT EXISTS(SELECT 1 FROM barang WHERE barang_id = ? AND deleted_at IS NULL)", barangID).Scan(&exists)
+	if !exists {
+		respondWithError(w, http.StatusNotFound, "Barang not found")
+		return
+	}
+
+	hargaID, err := catatHarga(db, barangID, req.HargaAsli, req.HargaJual, req.BerlakuMulai, hargaTerjadwal, req.Note, user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	auditCreated(db, r, "barang_harga", hargaID, "schedule")
+
+	h, err := scanBarangHarga(db.QueryRow(barangHargaSelect+" WHERE harga_id = ?", hargaID))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	respondWithJSON(w, h)
+}
+
+// batalHarga cancels a scheduled price change that has not been applied yet
+func batalHarga(w http.ResponseWriter, r *http.Request) {
+	hargaID := mux.Vars(r)["harga_id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	var status int
+	var berlakuMulai string
+	err = db.QueryRow("SELECT harga_status, DATE_FORMAT(berlaku_mulai, '%Y-%m-%d') FROM barang_harga WHERE harga_id = ?", hargaID).Scan(&status, &berlakuMulai)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Price entry not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	// A due schedule is already the valid price even before the job applied it
+	if status != hargaTerjadwal || berlakuMulai <= time.Now().Format("2006-01-02") {
+		respondWithError(w, http.StatusConflict, "Only scheduled price changes that have not taken effect can be cancelled")
+		return
+	}
+
+	audit := startAudit(db, r, "barang_harga", hargaID)
+	res, err := db.Exec("UPDATE barang_harga SET harga_status = ? WHERE harga_id = ? AND harga_status = ?", hargaDibatalkan, hargaID, hargaTerjadwal)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		respondWithError(w, http.StatusConflict, "Only scheduled price changes that have not taken effect can be cancelled")
+		return
+	}
+
+	audit.record("cancel")
+
+	respondWithJSON(w, map[string]string{
+		"harga_id": hargaID,
+		"status":   "Dibatalkan",
+	})
+}
+
+// SetupHargaRoutes sets up all pricing-related routes
+func SetupHargaRoutes(router *mux.Router) {
+	router.HandleFunc("/getharga/{barang_id}", getHargaBarang).Methods("GET")
+	router.HandleFunc("/gethargaberlaku/{barang_id}", getHargaBerlaku).Methods("GET")
+	router.HandleFunc("/gethargahistory/{barang_id}", getHargaHistory).Methods("GET")
+	router.HandleFunc("/jadwalharga/{barang_id}", jadwalHarga).Methods("POST")
+	router.HandleFunc("/batalharga/{harga_id}", batalHarga).Methods("PUT")
+}
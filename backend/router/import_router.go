@@ -0,0 +1,511 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"src/database"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+	"github.com/xuri/excelize/v2"
+)
+
+// Largest import file accepted
+const importMaxBytes = 10 << 20
+
+// importKolom lists the columns of each import type, required ones first
+var importKolom = map[string]struct {
+	Wajib    []string
+	Opsional []string
+}{
+	"brand":    {[]string{"brand_nama"}, []string{"brand_kontak", "brand_tlp"}},
+	"customer": {[]string{"customer_nama", "customer_kontak"}, []string{"customer_alamat"}},
+	"barang":   {[]string{"barang_nama", "brand_nama", "barang_harga_asli", "barang_harga_jual"}, []string{"barang_status"}},
+	// Opening stock: barang by barang_id or barang_nama, lantai by lantai_no
+	// (default the first active lantai of the gudang)
+	"stock": {[]string{"gudang_nama", "stock_barang"}, []string{"barang_id", "barang_nama", "lantai_no"}},
+}
+
+// ImportError is a problem with one cell or row of an import file
+type ImportError struct {
+	Baris int    `json:"baris"` // line in the file, the header is line 1
+	Kolom string `json:"kolom,omitempty"`
+	Pesan string `json:"pesan"`
+}
+
+// importBaris is one data row keyed by lower-case column name
+type importBaris struct {
+	no    int
+	kolom map[string]string
+}
+
+func (b importBaris) get(kolom string) string {
+	return strings.TrimSpace(b.kolom[kolom])
+}
+
+// Helper function to read an import file into header + rows. The format is
+// taken from ?format= or else the file extension.
+func bacaFileImport(r *http.Request) ([][]string, error) {
+	if err := r.ParseMultipartForm(importMaxBytes); err != nil {
+		return nil, fmt.Errorf("expected a multipart form with a 'file' field: %v", err)
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("file is required: %v", err)
+	}
+	defer file.Close()
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	}
+
+	switch format {
+	case "csv":
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file: %v", err)
+		}
+		text := strings.TrimPrefix(string(data), "\ufeff")
+		reader := csv.NewReader(strings.NewReader(text))
+		reader.FieldsPerRecord = -1
+		// Spreadsheets with a comma decimal separator save CSV with ';'
+		firstLine, _, _ := strings.Cut(text, "\n")
+		if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+			reader.Comma = ';'
+		}
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+		return rows, nil
+	case "xlsx":
+		f, err := excelize.OpenReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid XLSX: %v", err)
+		}
+		defer f.Close()
+		rows, err := f.GetRows(f.GetSheetName(0))
+		if err != nil {
+			return nil, fmt.Errorf("error reading XLSX sheet: %v", err)
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unsupported file format '%s', use csv or xlsx", format)
+}
+
+// Helper function to map the rows of a file onto the columns of an import
+// type. Blank rows are skipped.
+func parseImportRows(tipe string, rows [][]string) ([]importBaris, []ImportError) {
+	if len(rows) == 0 {
+		return nil, []ImportError{{Baris: 1, Pesan: "file is empty"}}
+	}
+
+	spec := importKolom[tipe]
+	dikenal := map[string]bool{}
+	for _, k := range append(append([]string{}, spec.Wajib...), spec.Opsional...) {
+		dikenal[k] = true
+	}
+	var errs []ImportError
+	header := make([]string, len(rows[0]))
+	ada := map[string]bool{}
+	for i, h := range rows[0] {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if h == "" {
+			continue
+		}
+		if !dikenal[h] {
+			errs = append(errs, ImportError{Baris: 1, Kolom: h, Pesan: "unknown column"})
+			continue
+		}
+		header[i] = h
+		ada[h] = true
+	}
+	for _, k := range spec.Wajib {
+		if !ada[k] {
+			errs = append(errs, ImportError{Baris: 1, Kolom: k, Pesan: "required column is missing"})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var data []importBaris
+	for i, row := range rows[1:] {
+		b := importBaris{no: i + 2, kolom: map[string]string{}}
+		kosong := true
+		for j, value := range row {
+			if j < len(header) && header[j] != "" {
+				b.kolom[header[j]] = value
+				if strings.TrimSpace(value) != "" {
+					kosong = false
+				}
+			}
+		}
+		if !kosong {
+			data = append(data, b)
+		}
+	}
+	if len(data) == 0 {
+		return nil, []ImportError{{Baris: 2, Pesan: "file has no data rows"}}
+	}
+	return data, nil
+}
+
+var importRibuan = regexp.MustCompile(`^-?[0-9]{1,3}(\.[0-9]{3})+$`)
+
+// Helper function to read a whole number cell ("15000", "15.000", "15000.00")
+func importAngka(value string) (int, bool) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), " ", "")
+	if n, err := strconv.Atoi(value); err == nil {
+		return n, true
+	}
+	// "." as thousands separator
+	if importRibuan.MatchString(value) {
+		n, err := strconv.Atoi(strings.ReplaceAll(value, ".", ""))
+		return n, err == nil
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && f == float64(int(f)) {
+		return int(f), true
+	}
+	return 0, false
+}
+
+// Helper function to generate the next ID of a table
+func nextImportID(q sqlExecutor, table, idCol, format string) (string, error) {
+	var lastID string
+	err := q.QueryRow("SELECT " + idCol + " FROM " + table + " ORDER BY " + idCol + " DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("error fetching last %s: %v", idCol, err)
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:])
+		nextNum = n + 1
+	}
+	return fmt.Sprintf(format, nextNum), nil
+}
+
+// importRows validates and writes the rows of one import type. Every row is
+// checked before the first error is returned so the caller can list them all.
+func importRows(q sqlExecutor, r *http.Request, tipe string, rows []importBaris, user *UserData) ([]ImportError, error) {
+	var errs []ImportError
+	tambahError := func(b importBaris, kolom, pesan string) {
+		errs = append(errs, ImportError{Baris: b.no, Kolom: kolom, Pesan: pesan})
+	}
+	wajib := func(b importBaris) bool {
+		ok := true
+		for _, k := range importKolom[tipe].Wajib {
+			if b.get(k) == "" {
+				tambahError(b, k, "is required")
+				ok = false
+			}
+		}
+		return ok
+	}
+
+	switch tipe {
+	case "brand":
+		seen := map[string]int{}
+		for _, b := range rows {
+			if !wajib(b) {
+				continue
+			}
+			nama := b.get("brand_nama")
+			key := strings.ToLower(nama)
+			if line, dup := seen[key]; dup {
+				tambahError(b, "brand_nama", fmt.Sprintf("duplicate of line %d", line))
+				continue
+			}
+			seen[key] = b.no
+			var exists bool
+			q.QueryRow("SELECT EXISTS(SELECT 1 FROM brand WHERE brand_nama = ? AND deleted_at IS NULL)", nama).Scan(&exists)
+			if exists {
+				tambahError(b, "brand_nama", fmt.Sprintf("brand '%s' already exists", nama))
+			}
+		}
+		if len(errs) > 0 {
+			return errs, nil
+		}
+		for _, b := range rows {
+			newID, err := nextImportID(q, "brand", "brand_id", "BR_%04d")
+			if err != nil {
+				return nil, err
+			}
+			audit := startAudit(q, r, "brand", newID)
+			if _, err := q.Exec("INSERT INTO brand (brand_id, brand_nama, brand_kontak, brand_tlp) VALUES (?, ?, ?, ?)",
+				newID, b.get("brand_nama"), b.get("brand_kontak"), b.get("brand_tlp")); err != nil {
+				return nil, fmt.Errorf("line %d: insert error: %v", b.no, err)
+			}
+			audit.record("import")
+		}
+
+	case "customer":
+		seen := map[string]int{}
+		for _, b := range rows {
+			if !wajib(b) {
+				continue
+			}
+			key := strings.ToLower(b.get("customer_nama") + "|" + b.get("customer_kontak"))
+			if line, dup := seen[key]; dup {
+				tambahError(b, "customer_nama", fmt.Sprintf("duplicate of line %d", line))
+				continue
+			}
+			seen[key] = b.no
+			var exists bool
+			q.QueryRow("SELECT EXISTS(SELECT 1 FROM customer WHERE customer_nama = ? AND customer_kontak = ? AND deleted_at IS NULL)",
+				b.get("customer_nama"), b.get("customer_kontak")).Scan(&exists)
+			if exists {
+				tambahError(b, "customer_nama", "customer with this name and kontak already exists")
+			}
+		}
+		if len(errs) > 0 {
+			return errs, nil
+		}
+		for _, b := range rows {
+			newID, err := nextImportID(q, "customer", "customer_id", "CU_%07d")
+			if err != nil {
+				return nil, err
+			}
+			audit := startAudit(q, r, "customer", newID)
+			if _, err := q.Exec("INSERT INTO customer (customer_id, customer_nama, customer_kontak, customer_alamat) VALUES (?, ?, ?, ?)",
+				newID, b.get("customer_nama"), b.get("customer_kontak"), b.get("customer_alamat")); err != nil {
+				return nil, fmt.Errorf("line %d: insert error: %v", b.no, err)
+			}
+			audit.record("import")
+		}
+
+	case "barang":
+		type barangBaru struct {
+			nama, brandID    string
+			asli, jual, stat int
+		}
+		baru := make([]barangBaru, len(rows))
+		seen := map[string]int{}
+		for i, b := range rows {
+			if !wajib(b) {
+				continue
+			}
+			item := barangBaru{nama: b.get("barang_nama"), stat: 1}
+			brandID, err := getBrandIDFromName(q, b.get("brand_nama"))
+			if err != nil {
+				tambahError(b, "brand_nama", err.Error())
+			}
+			item.brandID = brandID
+			var ok bool
+			if item.asli, ok = importAngka(b.get("barang_harga_asli")); !ok || item.asli < 0 {
+				tambahError(b, "barang_harga_asli", "must be a whole number, not negative")
+			}
+			if item.jual, ok = importAngka(b.get("barang_harga_jual")); !ok || item.jual <= 0 {
+				tambahError(b, "barang_harga_jual", "must be a whole number greater than 0")
+			}
+			if value := b.get("barang_status"); value != "" {
+				if item.stat, ok = importAngka(value); !ok || (item.stat != 0 && item.stat != 1) {
+					tambahError(b, "barang_status", "must be 0 or 1")
+				}
+			}
+			key := strings.ToLower(item.nama + "|" + item.brandID)
+			if line, dup := seen[key]; dup {
+				tambahError(b, "barang_nama", fmt.Sprintf("duplicate of line %d", line))
+			}
+			seen[key] = b.no
+			if brandID != "" {
+				var exists bool
+				q.QueryRow("SELECT EXISTS(SELECT 1 FROM barang WHERE barang_nama = ? AND brand_id = ? AND deleted_at IS NULL)",
+					item.nama, brandID).Scan(&exists)
+				if exists {
+					tambahError(b, "barang_nama", fmt.Sprintf("barang '%s' already exists for this brand", item.nama))
+				}
+			}
+			baru[i] = item
+		}
+		if len(errs) > 0 {
+			return errs, nil
+		}
+		today := time.Now().Format("2006-01-02")
+		for i, item := range baru {
+			newID, err := nextImportID(q, "barang", "barang_id", "BA_%05d")
+			if err != nil {
+				return nil, err
+			}
+			audit := startAudit(q, r, "barang", newID)
+			if _, err := q.Exec("INSERT INTO barang (barang_id, barang_nama, brand_id, barang_harga_asli, barang_harga_jual, barang_status) VALUES (?, ?, ?, ?, ?, ?)",
+				newID, item.nama, item.brandID, item.asli, item.jual, item.stat); err != nil {
+				return nil, fmt.Errorf("line %d: insert error: %v", rows[i].no, err)
+			}
+			if _, err := catatHarga(q, newID, item.asli, item.jual, today, hargaBerlaku, "Harga awal", user); err != nil {
+				return nil, fmt.Errorf("line %d: %v", rows[i].no, err)
+			}
+			audit.record("import")
+		}
+
+	case "stock":
+		type stockBaru struct {
+			barangID, lantaiID string
+			jumlah             int
+		}
+		baru := make([]stockBaru, len(rows))
+		seen := map[string]int{}
+		for i, b := range rows {
+			if !wajib(b) {
+				continue
+			}
+			var item stockBaru
+			switch {
+			case b.get("barang_id") != "":
+				var exists bool
+				q.QueryRow("SELECT EXISTS(SELECT 1 FROM barang WHERE barang_id = ? AND deleted_at IS NULL)", b.get("barang_id")).Scan(&exists)
+				if !exists {
+					tambahError(b, "barang_id", fmt.Sprintf("barang '%s' does not exist", b.get("barang_id")))
+				}
+				item.barangID = b.get("barang_id")
+			case b.get("barang_nama") != "":
+				var n int
+				q.QueryRow("SELECT COUNT(*), COALESCE(MIN(barang_id), '') FROM barang WHERE barang_nama = ? AND deleted_at IS NULL",
+					b.get("barang_nama")).Scan(&n, &item.barangID)
+				if n == 0 {
+					tambahError(b, "barang_nama", fmt.Sprintf("barang '%s' does not exist", b.get("barang_nama")))
+				} else if n > 1 {
+					tambahError(b, "barang_nama", fmt.Sprintf("%d barang are named '%s', use barang_id", n, b.get("barang_nama")))
+				}
+			default:
+				tambahError(b, "barang_id", "barang_id or barang_nama is required")
+			}
+
+			var gudangID string
+			err := q.QueryRow("SELECT gudang_id FROM list_gudang WHERE gudang_nama = ? AND gudang_status = 1 AND deleted_at IS NULL",
+				b.get("gudang_nama")).Scan(&gudangID)
+			if err != nil {
+				tambahError(b, "gudang_nama", fmt.Sprintf("gudang '%s' does not exist or is inactive", b.get("gudang_nama")))
+			} else if value := b.get("lantai_no"); value != "" {
+				err = q.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? AND lantai_no = ? AND lantai_status = 1",
+					gudangID, value).Scan(&item.lantaiID)
+				if err != nil {
+					tambahError(b, "lantai_no", fmt.Sprintf("gudang '%s' has no active lantai %s", b.get("gudang_nama"), value))
+				}
+			} else {
+				err = q.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1 ORDER BY lantai_no LIMIT 1",
+					gudangID).Scan(&item.lantaiID)
+				if err != nil {
+					tambahError(b, "gudang_nama", fmt.Sprintf("gudang '%s' has no active lantai", b.get("gudang_nama")))
+				}
+			}
+
+			var ok bool
+			if item.jumlah, ok = importAngka(b.get("stock_barang")); !ok || item.jumlah < 0 {
+				tambahError(b, "stock_barang", "must be a whole number, not negative")
+			}
+			key := item.barangID + "|" + item.lantaiID
+			if line, dup := seen[key]; dup && item.barangID != "" && item.lantaiID != "" {
+				tambahError(b, "", fmt.Sprintf("same barang and lantai as line %d", line))
+			}
+			seen[key] = b.no
+			baru[i] = item
+		}
+		if len(errs) > 0 {
+			return errs, nil
+		}
+		for i, item := range baru {
+			audit := startRowsAudit(q, r, "stock_gudang", "barang_id = ? AND lantai_id = ?", item.barangID, item.lantaiID)
+			err := updateStockInfoByLantai(q, item.barangID, []StockLantaiUpdateInfo{{LantaiID: item.lantaiID, StockBarang: item.jumlah}})
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", rows[i].no, err)
+			}
+			audit.record("import")
+		}
+	}
+	return nil, nil
+}
+
+// importData imports brand, customer, barang or opening stock rows from an
+// uploaded CSV or XLSX file (form field "file"). Every row is validated
+// first; the rows are written in one transaction, all or nothing. With
+// ?dry_run=true the import runs and is rolled back.
+func importData(w http.ResponseWriter, r *http.Request) {
+	tipe := mux.Vars(r)["type"]
+	if _, ok := importKolom[tipe]; !ok {
+		respondWithError(w, http.StatusBadRequest, "Unknown import type '"+tipe+"', use brand, customer, barang or stock")
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes)
+	file, err := bacaFileImport(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rows, errs := parseImportRows(tipe, file)
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	if len(errs) == 0 {
+		errs, err = importRows(tx, r, tipe, rows, user)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Import error: "+err.Error())
+			return
+		}
+	}
+
+	response := map[string]interface{}{
+		"type":    tipe,
+		"dry_run": dryRun,
+		"rows":    len(rows),
+		"errors":  errs,
+	}
+	if len(errs) > 0 {
+		response["status"] = "Invalid"
+		response["message"] = "Nothing was imported, fix the listed lines and upload the file again"
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	response["errors"] = []ImportError{}
+	if dryRun {
+		response["status"] = "Valid"
+		response["message"] = fmt.Sprintf("%d rows can be imported", len(rows))
+		respondWithJSON(w, response)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+	response["status"] = "Imported"
+	response["message"] = fmt.Sprintf("%d rows imported", len(rows))
+	respondWithJSON(w, response)
+}
+
+// SetupImportRoutes sets up the file import route
+func SetupImportRoutes(router *mux.Router) {
+	router.HandleFunc("/import/{type}", importData).Methods("POST")
+}
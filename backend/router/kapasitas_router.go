@@ -0,0 +1,420 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+
+	"src/database"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// LantaiUtilization is the fill level of a floor against its capacities.
+// Capacities and percentages are nil when the floor has no limit for that dimension.
+type LantaiUtilization struct {
+	LantaiID        string   `json:"lantai_id"`
+	LantaiNama      string   `json:"lantai_nama"`
+	LantaiNo        int      `json:"lantai_no"`
+	GudangID        string   `json:"gudang_id"`
+	GudangNama      string   `json:"gudang_nama"`
+	StockUnit       int      `json:"stock_unit"`
+	KapasitasUnit   *int     `json:"kapasitas_unit"`
+	PersenUnit      *float64 `json:"persen_unit"`
+	Volume          float64  `json:"volume"`
+	KapasitasVolume *float64 `json:"kapasitas_volume"`
+	PersenVolume    *float64 `json:"persen_volume"`
+	Berat           float64  `json:"berat"`
+	KapasitasBerat  *float64 `json:"kapasitas_berat"`
+	PersenBerat     *float64 `json:"persen_berat"`
+}
+
+type BarangDimensiRequest struct {
+	BarangVolume *float64 `json:"barang_volume"` // m3 per unit
+	BarangBerat  *float64 `json:"barang_berat"`  // kg per unit
+}
+
+// Helper function to convert a nullable INT column to *int
+func nullIntPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
+
+// Helper function to convert a nullable DECIMAL column to *float64
+func nullFloatPtr(n sql.NullFloat64) *float64 {
+	if !n.Valid {
+		return nil
+	}
+	v := n.Float64
+	return &v
+}
+
+// Helper function to compute a percentage rounded to 2 decimals
+func persenKapasitas(used, kapasitas float64) *float64 {
+	if kapasitas <= 0 {
+		return nil
+	}
+	p := math.Round(used/kapasitas*10000) / 100
+	return &p
+}
+
+// Helper function to fill in the percentages of a LantaiUtilization
+func (u *LantaiUtilization) hitungPersen() {
+	if u.KapasitasUnit != nil {
+		u.PersenUnit = persenKapasitas(float64(u.StockUnit), float64(*u.KapasitasUnit))
+	}
+	if u.KapasitasVolume != nil {
+		u.PersenVolume = persenKapasitas(u.Volume, *u.KapasitasVolume)
+	}
+	if u.KapasitasBerat != nil {
+		u.PersenBerat = persenKapasitas(u.Berat, *u.KapasitasBerat)
+	}
+}
+
+// sisaKapasitas returns how many more units of a barang fit on a floor, or -1
+// when none of the floor's limits apply to it
+func (u *LantaiUtilization) sisaKapasitas(volume, berat float64) int {
+	sisa := -1
+	limit := func(n int) {
+		if n < 0 {
+			n = 0
+		}
+		if sisa == -1 || n < sisa {
+			sisa = n
+		}
+	}
+	if u.KapasitasUnit != nil {
+		limit(*u.KapasitasUnit - u.StockUnit)
+	}
+	if u.KapasitasVolume != nil && volume > 0 {
+		limit(int(math.Floor((*u.KapasitasVolume - u.Volume) / volume)))
+	}
+	if u.KapasitasBerat != nil && berat > 0 {
+		limit(int(math.Floor((*u.KapasitasBerat - u.Berat) / berat)))
+	}
+	return sisa
+}
+
+// queryLantaiUtilization computes the utilization of active floors matching the
+// given condition on gudang_lantai gl / list_gudang lg
+func queryLantaiUtilization(q sqlExecutor, where string, args ...interface{}) ([]LantaiUtilization, error) {
+	rows, err := q.Query(`
+		SELECT gl.lantai_id, gl.lantai_nama, gl.lantai_no, gl.gudang_id, lg.gudang_nama,
+		       gl.lantai_kapasitas, gl.lantai_kapasitas_volume, gl.lantai_kapasitas_berat,
+		       COALESCE(SUM(sg.stock_barang), 0),
+		       COALESCE(SUM(sg.stock_barang * COALESCE(b.barang_volume, 0)), 0),
+		       COALESCE(SUM(sg.stock_barang * COALESCE(b.barang_berat, 0)), 0)
+		FROM gudang_lantai gl
+		JOIN list_gudang lg ON gl.gudang_id = lg.gudang_id
+		LEFT JOIN stock_gudang sg ON gl.lantai_id = sg.lantai_id
+		LEFT JOIN barang b ON sg.barang_id = b.barang_id
+		WHERE gl.lantai_status = 1 AND lg.gudang_status = 1 AND `+where+`
+		GROUP BY gl.lantai_id, gl.lantai_nama, gl.lantai_no, gl.gudang_id, lg.gudang_nama,
+		         gl.lantai_kapasitas, gl.lantai_kapasitas_volume, gl.lantai_kapasitas_berat
+		ORDER BY lg.gudang_nama, gl.lantai_no`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching utilization: %v", err)
+	}
+	defer rows.Close()
+
+	list := []LantaiUtilization{}
+	for rows.Next() {
+		var u LantaiUtilization
+		var kapasitas sql.NullInt64
+		var kapasitasVolume, kapasitasBerat sql.NullFloat64
+		err := rows.Scan(&u.LantaiID, &u.LantaiNama, &u.LantaiNo, &u.GudangID, &u.GudangNama,
+			&kapasitas, &kapasitasVolume, &kapasitasBerat, &u.StockUnit, &u.Volume, &u.Berat)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning utilization: %v", err)
+		}
+		u.KapasitasUnit = nullIntPtr(kapasitas)
+		u.KapasitasVolume = nullFloatPtr(kapasitasVolume)
+		u.KapasitasBerat = nullFloatPtr(kapasitasBerat)
+		u.hitungPersen()
+		list = append(list, u)
+	}
+	return list, nil
+}
+
+// Helper function to fetch the per-unit dimensions of a barang (0 when not set)
+func getBarangDimensi(q sqlExecutor, barangID string) (float64, float64, error) {
+	var volume, berat sql.NullFloat64
+	err := q.QueryRow("SELECT barang_volume, barang_berat FROM barang WHERE barang_id = ?", barangID).Scan(&volume, &berat)
+	if err != nil {
+		return 0, 0, err
+	}
+	return volume.Float64, berat.Float64, nil
+}
+
+// checkKapasitasOrders checks a receiving batch against floor capacities. It
+// never blocks the batch; it returns one warning per order that would overfill
+// its floor, with alternative floors that still have room. Orders in the same
+// batch are counted cumulatively.
+func checkKapasitasOrders(q sqlExecutor, orders []OrderMasukDetail) ([]map[string]interface{}, error) {
+	all, err := queryLantaiUtilization(q, "1 = 1")
+	if err != nil {
+		return nil, err
+	}
+	floors := map[string]*LantaiUtilization{}
+	for i := range all {
+		floors[all[i].LantaiID] = &all[i]
+	}
+
+	warnings := []map[string]interface{}{}
+	for i, order := range orders {
+		lantaiID := order.LantaiID
+		if lantaiID == "" {
+			err = q.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1 ORDER BY lantai_no LIMIT 1", order.GudangID).Scan(&lantaiID)
+			if err != nil {
+				continue
+			}
+		}
+		u, ok := floors[lantaiID]
+		if !ok {
+			continue
+		}
+
+		volume, berat, err := getBarangDimensi(q, order.BarangID)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("error fetching barang dimensions: %v", err)
+		}
+
+		sisa := u.sisaKapasitas(volume, berat)
+		if sisa >= 0 && order.OrdersAmount > sisa {
+			alternatif := []map[string]interface{}{}
+			for j := range all {
+				alt := &all[j]
+				if alt.LantaiID == lantaiID {
+					continue
+				}
+				altSisa := alt.sisaKapasitas(volume, berat)
+				if altSisa != -1 && altSisa < order.OrdersAmount {
+					continue
+				}
+				entry := map[string]interface{}{
+					"lantai_id":   alt.LantaiID,
+					"lantai_nama": alt.LantaiNama,
+					"gudang_id":   alt.GudangID,
+					"gudang_nama": alt.GudangNama,
+				}
+				if altSisa >= 0 {
+					entry["sisa_kapasitas"] = altSisa
+				}
+				// Floors of the same gudang are suggested first
+				if alt.GudangID == u.GudangID {
+					alternatif = append([]map[string]interface{}{entry}, alternatif...)
+				} else {
+					alternatif = append(alternatif, entry)
+				}
+			}
+			if len(alternatif) > 5 {
+				alternatif = alternatif[:5]
+			}
+
+			warnings = append(warnings, map[string]interface{}{
+				"order":          i + 1,
+				"barang_id":      order.BarangID,
+				"lantai_id":      lantaiID,
+				"lantai_nama":    u.LantaiNama,
+				"orders_amount":  order.OrdersAmount,
+				"sisa_kapasitas": sisa,
+				"message":        fmt.Sprintf("Order %d exceeds the capacity of %s by %d units", i+1, u.LantaiNama, order.OrdersAmount-sisa),
+				"alternatif":     alternatif,
+			})
+		}
+
+		// Count this order towards the floor for the next orders in the batch
+		u.StockUnit += order.OrdersAmount
+		u.Volume += volume * float64(order.OrdersAmount)
+		u.Berat += berat * float64(order.OrdersAmount)
+	}
+	return warnings, nil
+}
+
+// getGudangUtilization returns the utilization of every floor of a gudang plus the gudang total
+func getGudangUtilization(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	gudangID := params["gudang_id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	floors, err := queryLantaiUtilization(db, "gl.gudang_id = ?", gudangID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(floors) == 0 {
+		respondWithError(w, http.StatusNotFound, "Gudang not found or has no active floors")
+		return
+	}
+
+	// The gudang only has a total capacity for a dimension when every floor is limited in it
+	total := LantaiUtilization{GudangID: gudangID, GudangNama: floors[0].GudangNama}
+	kapasitasUnit, kapasitasVolume, kapasitasBerat := 0, 0.0, 0.0
+	allUnit, allVolume, allBerat := true, true, true
+	for _, f := range floors {
+		total.StockUnit += f.StockUnit
+		total.Volume += f.Volume
+		total.Berat += f.Berat
+		if f.KapasitasUnit != nil {
+			kapasitasUnit += *f.KapasitasUnit
+		} else {
+			allUnit = false
+		}
+		if f.KapasitasVolume != nil {
+			kapasitasVolume += *f.KapasitasVolume
+		} else {
+			allVolume = false
+		}
+		if f.KapasitasBerat != nil {
+			kapasitasBerat += *f.KapasitasBerat
+		} else {
+			allBerat = false
+		}
+	}
+	if allUnit {
+		total.KapasitasUnit = &kapasitasUnit
+	}
+	if allVolume {
+		total.KapasitasVolume = &kapasitasVolume
+	}
+	if allBerat {
+		total.KapasitasBerat = &kapasitasBerat
+	}
+	total.hitungPersen()
+
+	respondWithJSON(w, map[string]interface{}{
+		"gudang_id":        gudangID,
+		"gudang_nama":      total.GudangNama,
+		"stock_unit":       total.StockUnit,
+		"kapasitas_unit":   total.KapasitasUnit,
+		"persen_unit":      total.PersenUnit,
+		"volume":           total.Volume,
+		"kapasitas_volume": total.KapasitasVolume,
+		"persen_volume":    total.PersenVolume,
+		"berat":            total.Berat,
+		"kapasitas_berat":  total.KapasitasBerat,
+		"persen_berat":     total.PersenBerat,
+		"lantai":           floors,
+	})
+}
+
+// getLantaiUtilization returns the utilization of a single floor
+func getLantaiUtilization(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	lantaiID := params["lantai_id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	floors, err := queryLantaiUtilization(db, "gl.lantai_id = ?", lantaiID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(floors) == 0 {
+		respondWithError(w, http.StatusNotFound, "Lantai not found or inactive")
+		return
+	}
+
+	respondWithJSON(w, floors[0])
+}
+
+// checkKapasitas runs the capacity check of createBatchOrderMasuk without creating anything
+func checkKapasitas(w http.ResponseWriter, r *http.Request) {
+	var batch CombinedOrderMasukBatch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	warnings, err := checkKapasitasOrders(db, batch.Orders)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, map[string]interface{}{
+		"ok":       len(warnings) == 0,
+		"warnings": warnings,
+	})
+}
+
+// updateBarangDimensi sets the per-unit volume and weight of a barang
+func updateBarangDimensi(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["id"]
+
+	var req BarangDimensiRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if (req.BarangVolume != nil && *req.BarangVolume < 0) || (req.BarangBerat != nil && *req.BarangBerat < 0) {
+		respondWithError(w, http.StatusBadRequest, "barang_volume and barang_berat cannot be negative")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	audit := startAudit(db, r, "barang", id)
+	res, err := db.Exec("UPDATE barang SET barang_volume = ?, barang_berat = ? WHERE barang_id = ?", req.BarangVolume, req.BarangBerat, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+
+	var exists bool
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		db.QueryRow("SELECT EXISTS(SELECT 1 FROM barang WHERE barang_id = ? AND deleted_at IS NULL)", id).Scan(&exists)
+		if !exists {
+			respondWithError(w, http.StatusNotFound, "Barang not found")
+			return
+		}
+	}
+	audit.record("update_dimensi")
+
+	respondWithJSON(w, map[string]interface{}{
+		"barang_id":     id,
+		"barang_volume": req.BarangVolume,
+		"barang_berat":  req.BarangBerat,
+	})
+}
+
+// SetupKapasitasRoutes sets up capacity and utilization routes
+func SetupKapasitasRoutes(router *mux.Router) {
+	router.HandleFunc("/getutilization/{gudang_id}", getGudangUtilization).Methods("GET")
+	router.HandleFunc("/getlantaiutilization/{lantai_id}", getLantaiUtilization).Methods("GET")
+	router.HandleFunc("/checkkapasitas", checkKapasitas).Methods("POST")
+	router.HandleFunc("/updatebarangdimensi/{id}", updateBarangDimensi).Methods("PUT")
+}
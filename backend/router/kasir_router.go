@@ -0,0 +1,603 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"src/database"
+	"strconv"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// Cashier shift status values
+const (
+	shiftBuka  = 1
+	shiftTutup = 2
+)
+
+var shiftStatusNama = map[int]string{
+	shiftBuka:  "Buka",
+	shiftTutup: "Tutup",
+}
+
+// errKasirShift marks a cash payment that cannot be booked on a shift
+var errKasirShift = errors.New("cashier shift")
+
+// errKasirUser marks cash taken without a known cashier, so there is no shift
+// to book it on
+var errKasirUser = fmt.Errorf("%w: X-Users-ID header is required to take cash", errKasirShift)
+
+// KasirShift is a cashier session with its cash drawer reconciliation
+type KasirShift struct {
+	ShiftID    string `json:"shift_id"`
+	UsersID    string `json:"users_id"`
+	UsersNama  string `json:"users_nama"`
+	Status     int    `json:"shift_status"`
+	StatusNama string `json:"shift_status_nama"`
+	Open       string `json:"shift_open"`
+	Close      string `json:"shift_close,omitempty"`
+	ModalAwal  int    `json:"shift_modal_awal"`
+	Note       string `json:"shift_note,omitempty"`
+	ClosedBy   string `json:"closed_by,omitempty"`
+
+	// Cash drawer
+	PenjualanTunai  int  `json:"penjualan_tunai"`
+	JumlahTransaksi int  `json:"jumlah_transaksi"`
+	KasMasuk        int  `json:"kas_masuk"`
+	KasKeluar       int  `json:"kas_keluar"`
+	KasSeharusnya   int  `json:"shift_kas_seharusnya"`
+	KasDihitung     *int `json:"shift_kas_dihitung"`
+	Selisih         *int `json:"shift_selisih"`
+
+	Kas        []KasirKas        `json:"kas,omitempty"`
+	Pembayaran []SalesPembayaran `json:"pembayaran,omitempty"`
+}
+
+// KasirKas is petty cash put into or taken out of the drawer
+type KasirKas struct {
+	KasID   string `json:"kas_id"`
+	ShiftID string `json:"shift_id"`
+	Type    string `json:"kas_type"`
+	Amount  int    `json:"kas_amount"`
+	Note    string `json:"kas_note"`
+	Date    string `json:"kas_date"`
+	UsersID string `json:"users_id,omitempty"`
+}
+
+type BukaShiftRequest struct {
+	ModalAwal int    `json:"shift_modal_awal"`
+	Note      string `json:"shift_note"`
+}
+
+type TutupShiftRequest struct {
+	KasDihitung *int   `json:"shift_kas_dihitung"`
+	Note        string `json:"shift_note"`
+}
+
+type KasirKasRequest struct {
+	Type   string `json:"kas_type"` // masuk or keluar
+	Amount int    `json:"kas_amount"`
+	Note   string `json:"kas_note"`
+}
+
+// openShiftID returns the open shift of a user. The shift row is share locked
+// so it cannot be closed while a payment is being booked on it.
+func openShiftID(q sqlExecutor, usersID string) (string, error) {
+	var shiftID string
+	err := q.QueryRow("SELECT shift_id FROM kasir_shift WHERE users_id = ? AND shift_status = ? LOCK IN SHARE MODE",
+		usersID, shiftBuka).Scan(&shiftID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: user '%s' has no open shift, open one before taking cash", errKasirShift, usersID)
+	} else if err != nil {
+		return "", fmt.Errorf("error reading cashier shift: %v", err)
+	}
+	return shiftID, nil
+}
+
+// Helper function to compute the expected drawer content of a shift
+func hitungKasShift(q sqlExecutor, s *KasirShift) error {
+	err := q.QueryRow(`SELECT COALESCE(SUM(p.bayar_amount), 0), COUNT(DISTINCT p.sales_id)
+		FROM sales_pembayaran p
+		JOIN sales s ON p.sales_id = s.sales_id
+		WHERE p.shift_id = ? AND p.bayar_metode = ? AND s.sales_status <> ?`,
+		s.ShiftID, bayarTunai, salesStatusBatal).Scan(&s.PenjualanTunai, &s.JumlahTransaksi)
+	if err != nil {
+		return fmt.Errorf("error summing cash payments: %v", err)
+	}
+	err = q.QueryRow(`SELECT COALESCE(SUM(CASE WHEN kas_type = 'masuk' THEN kas_amount ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN kas_type = 'keluar' THEN kas_amount ELSE 0 END), 0)
+		FROM kasir_kas WHERE shift_id = ?`, s.ShiftID).Scan(&s.KasMasuk, &s.KasKeluar)
+	if err != nil {
+		return fmt.Errorf("error summing petty cash: %v", err)
+	}
+	s.KasSeharusnya = s.ModalAwal + s.PenjualanTunai + s.KasMasuk - s.KasKeluar
+	return nil
+}
+
+const kasirShiftSelect = `
+	SELECT k.shift_id, k.users_id, u.users_nama, k.shift_status, k.shift_open, k.shift_close,
+	       k.shift_modal_awal, k.shift_kas_seharusnya, k.shift_kas_dihitung, k.shift_selisih,
+	       k.shift_note, k.closed_by
+	FROM kasir_shift k
+	LEFT JOIN users u ON k.users_id = u.users_id`
+
+// scanKasirShift reads one row of kasirShiftSelect. A closed shift keeps the
+// expected cash stored at closing; an open one is computed by the caller.
+func scanKasirShift(scan func(dest ...interface{}) error) (KasirShift, error) {
+	var s KasirShift
+	var usersNama, closeDate, note, closedBy sql.NullString
+	var seharusnya, dihitung, selisih sql.NullInt64
+	err := scan(&s.ShiftID, &s.UsersID, &usersNama, &s.Status, &s.Open, &closeDate,
+		&s.ModalAwal, &seharusnya, &dihitung, &selisih, &note, &closedBy)
+	if err != nil {
+		return s, err
+	}
+	s.StatusNama = shiftStatusNama[s.Status]
+	s.UsersNama = usersNama.String
+	s.Close = closeDate.String
+	s.Note = note.String
+	s.ClosedBy = closedBy.String
+	s.KasSeharusnya = int(seharusnya.Int64)
+	if dihitung.Valid {
+		v := int(dihitung.Int64)
+		s.KasDihitung = &v
+	}
+	if selisih.Valid {
+		v := int(selisih.Int64)
+		s.Selisih = &v
+	}
+	return s, nil
+}
+
+// loadShiftReport reads a shift with its cash payments and petty cash entries
+func loadShiftReport(q sqlExecutor, shiftID string) (*KasirShift, error) {
+	s, err := scanKasirShift(q.QueryRow(kasirShiftSelect+" WHERE k.shift_id = ?", shiftID).Scan)
+	if err != nil {
+		return nil, err
+	}
+	stored := s.KasSeharusnya
+	if err := hitungKasShift(q, &s); err != nil {
+		return nil, err
+	}
+	if s.Status == shiftTutup {
+		s.KasSeharusnya = stored
+	}
+
+	rows, err := q.Query(`SELECT kas_id, shift_id, kas_type, kas_amount, kas_note, kas_date, users_id
+		FROM kasir_kas WHERE shift_id = ? ORDER BY kas_date, kas_id`, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	s.Kas = []KasirKas{}
+	for rows.Next() {
+		var k KasirKas
+		var usersID sql.NullString
+		if err := rows.Scan(&k.KasID, &k.ShiftID, &k.Type, &k.Amount, &k.Note, &k.Date, &usersID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		k.UsersID = usersID.String
+		s.Kas = append(s.Kas, k)
+	}
+	rows.Close()
+
+	s.Pembayaran, err = querySalesPembayaran(q, "shift_id = ?", shiftID)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Helper function to lock an open shift the requesting user may work on
+// (its own, or any shift for an admin)
+func lockOpenShift(q sqlExecutor, shiftID string, user *UserData) (KasirShift, int, error) {
+	s, err := scanKasirShift(q.QueryRow(kasirShiftSelect+" WHERE k.shift_id = ? FOR UPDATE", shiftID).Scan)
+	if err == sql.ErrNoRows {
+		return s, http.StatusNotFound, fmt.Errorf("Shift not found")
+	} else if err != nil {
+		return s, http.StatusInternalServerError, fmt.Errorf("Query error: %v", err)
+	}
+	if s.UsersID != user.UsersID && user.UsersLevel != 1 {
+		return s, http.StatusForbidden, fmt.Errorf("Shift %s belongs to another cashier", shiftID)
+	}
+	if s.Status != shiftBuka {
+		return s, http.StatusConflict, fmt.Errorf("Shift %s is already closed", shiftID)
+	}
+	return s, 0, nil
+}
+
+func bukaShift(w http.ResponseWriter, r *http.Request) {
+	var req BukaShiftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.ModalAwal < 0 {
+		respondWithError(w, http.StatusBadRequest, "shift_modal_awal cannot be negative")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if user == nil {
+		respondWithError(w, http.StatusUnauthorized, "X-Users-ID header is required to open a shift")
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error starting transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the user so two requests cannot open two shifts
+	var lockedID string
+	if err := tx.QueryRow("SELECT users_id FROM users WHERE users_id = ? FOR UPDATE", user.UsersID).Scan(&lockedID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	var openID string
+	err = tx.QueryRow("SELECT shift_id FROM kasir_shift WHERE users_id = ? AND shift_status = ?", user.UsersID, shiftBuka).Scan(&openID)
+	if err == nil {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("User already has open shift %s", openID))
+		return
+	} else if err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	var lastID string
+	err = tx.QueryRow("SELECT shift_id FROM kasir_shift ORDER BY shift_id DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching last shift_id")
+		return
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:]) // "KS_0000003" -> "0000003"
+		nextNum = n + 1
+	}
+	shiftID := fmt.Sprintf("KS_%07d", nextNum)
+	audit := startAudit(tx, r, "kasir_shift", shiftID)
+
+	_, err = tx.Exec(`INSERT INTO kasir_shift (shift_id, users_id, shift_status, shift_open, shift_modal_awal, shift_note)
+		VALUES (?, ?, ?, NOW(), ?, ?)`, shiftID, user.UsersID, shiftBuka, req.ModalAwal, processNullableStringValue(req.Note))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Insert error: "+err.Error())
+		return
+	}
+	audit.record("open")
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
+	s, err := loadShiftReport(db, shiftID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	respondWithJSON(w, s)
+}
+
+// createKasirKas books petty cash in or out of an open shift's drawer
+func createKasirKas(w http.ResponseWriter, r *http.Request) {
+	shiftID := mux.Vars(r)["id"]
+
+	var req KasirKasRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	if req.Type != "masuk" && req.Type != "keluar" {
+		respondWithError(w, http.StatusBadRequest, "kas_type must be masuk or keluar")
+		return
+	}
+	if req.Amount <= 0 {
+		respondWithError(w, http.StatusBadRequest, "kas_amount must be greater than 0")
+		return
+	}
+	if strings.TrimSpace(req.Note) == "" {
+		respondWithError(w, http.StatusBadRequest, "kas_note is required")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if user == nil {
+		respondWithError(w, http.StatusUnauthorized, "X-Users-ID header is required")
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error starting transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	s, code, err := lockOpenShift(tx, shiftID, user)
+	if err != nil {
+		respondWithError(w, code, err.Error())
+		return
+	}
+	if req.Type == "keluar" {
+		if err := hitungKasShift(tx, &s); err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if req.Amount > s.KasSeharusnya {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("The drawer should only hold %d", s.KasSeharusnya))
+			return
+		}
+	}
+
+	var lastID string
+	err = tx.QueryRow("SELECT kas_id FROM kasir_kas ORDER BY kas_id DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching last kas_id")
+		return
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:])
+		nextNum = n + 1
+	}
+	kas := KasirKas{
+		KasID:   fmt.Sprintf("KK_%07d", nextNum),
+		ShiftID: shiftID,
+		Type:    req.Type,
+		Amount:  req.Amount,
+		Note:    strings.TrimSpace(req.Note),
+		UsersID: user.UsersID,
+	}
+	audit := startAudit(tx, r, "kasir_kas", kas.KasID)
+	_, err = tx.Exec(`INSERT INTO kasir_kas (kas_id, shift_id, kas_type, kas_amount, kas_note, kas_date, users_id)
+		VALUES (?, ?, ?, ?, ?, NOW(), ?)`, kas.KasID, kas.ShiftID, kas.Type, kas.Amount, kas.Note, kas.UsersID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Insert error: "+err.Error())
+		return
+	}
+	if err := tx.QueryRow("SELECT kas_date FROM kasir_kas WHERE kas_id = ?", kas.KasID).Scan(&kas.Date); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	audit.record("create")
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respondWithJSON(w, kas)
+}
+
+// tutupShift closes a shift with the counted cash and stores the
+// reconciliation; the variance is counted minus expected cash
+func tutupShift(w http.ResponseWriter, r *http.Request) {
+	shiftID := mux.Vars(r)["id"]
+
+	var req TutupShiftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.KasDihitung == nil || *req.KasDihitung < 0 {
+		respondWithError(w, http.StatusBadRequest, "shift_kas_dihitung is required and cannot be negative")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if user == nil {
+		respondWithError(w, http.StatusUnauthorized, "X-Users-ID header is required")
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error starting transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	s, code, err := lockOpenShift(tx, shiftID, user)
+	if err != nil {
+		respondWithError(w, code, err.Error())
+		return
+	}
+	if err := hitungKasShift(tx, &s); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	audit := startAudit(tx, r, "kasir_shift", shiftID)
+	note := s.Note
+	if req.Note != "" {
+		note = req.Note
+	}
+	_, err = tx.Exec(`UPDATE kasir_shift SET shift_status = ?, shift_close = NOW(), shift_kas_seharusnya = ?,
+		shift_kas_dihitung = ?, shift_selisih = ?, shift_note = ?, closed_by = ? WHERE shift_id = ?`,
+		shiftTutup, s.KasSeharusnya, *req.KasDihitung, *req.KasDihitung-s.KasSeharusnya,
+		processNullableStringValue(note), user.UsersID, shiftID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	audit.record("close")
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
+	report, err := loadShiftReport(db, shiftID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	respondWithJSON(w, report)
+}
+
+// getShifts lists shifts, filtered by users_id, shift_status and date (of opening)
+func getShifts(w http.ResponseWriter, r *http.Request) {
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	query := kasirShiftSelect + " WHERE 1=1"
+	var args []interface{}
+	if usersID := r.URL.Query().Get("users_id"); usersID != "" {
+		query += " AND k.users_id = ?"
+		args = append(args, usersID)
+	}
+	if status := r.URL.Query().Get("shift_status"); status != "" {
+		query += " AND k.shift_status = ?"
+		args = append(args, status)
+	}
+	if date := r.URL.Query().Get("date"); date != "" {
+		query += " AND DATE(k.shift_open) = ?"
+		args = append(args, date)
+	}
+	query += " ORDER BY k.shift_open DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	shifts := []KasirShift{}
+	for rows.Next() {
+		s, err := scanKasirShift(rows.Scan)
+		if err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		shifts = append(shifts, s)
+	}
+	rows.Close()
+
+	// Totals of open shifts are live
+	for i := range shifts {
+		stored := shifts[i].KasSeharusnya
+		if err := hitungKasShift(db, &shifts[i]); err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if shifts[i].Status == shiftTutup {
+			shifts[i].KasSeharusnya = stored
+		}
+	}
+
+	respondWithJSON(w, shifts)
+}
+
+// getShift is the closing report of a shift (live while it is open)
+func getShift(w http.ResponseWriter, r *http.Request) {
+	shiftID := mux.Vars(r)["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	s, err := loadShiftReport(db, shiftID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Shift not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	respondWithJSON(w, s)
+}
+
+// getShiftAktif returns the open shift of the requesting user
+func getShiftAktif(w http.ResponseWriter, r *http.Request) {
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if user == nil {
+		respondWithError(w, http.StatusUnauthorized, "X-Users-ID header is required")
+		return
+	}
+
+	var shiftID string
+	err = db.QueryRow("SELECT shift_id FROM kasir_shift WHERE users_id = ? AND shift_status = ?", user.UsersID, shiftBuka).Scan(&shiftID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "No open shift")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	s, err := loadShiftReport(db, shiftID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	respondWithJSON(w, s)
+}
+
+// SetupKasirRoutes sets up the cashier shift routes
+func SetupKasirRoutes(router *mux.Router) {
+	router.HandleFunc("/bukashift", bukaShift).Methods("POST")
+	router.HandleFunc("/getshifts", getShifts).Methods("GET")
+	router.HandleFunc("/getshiftaktif", getShiftAktif).Methods("GET")
+	router.HandleFunc("/getshift/{id}", getShift).Methods("GET")
+	router.HandleFunc("/shift/{id}/kas", createKasirKas).Methods("POST")
+	router.HandleFunc("/shift/{id}/tutup", tutupShift).Methods("PUT")
+}
@@ -0,0 +1,1008 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"src/database"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// GudangLantai represents a floor of a gudang
+type GudangLantai struct {
+	LantaiID        string   `json:"lantai_id"`
+	GudangID        string   `json:"gudang_id"`
+	LantaiNo        int      `json:"lantai_no"`
+	LantaiNama      string   `json:"lantai_nama"`
+	LantaiKapasitas *int     `json:"lantai_kapasitas"`
+	KapasitasVolume *float64 `json:"lantai_kapasitas_volume"`
+	KapasitasBerat  *float64 `json:"lantai_kapasitas_berat"`
+	LantaiStatus    int      `json:"lantai_status"`
+	TotalStock      int      `json:"total_stock"`
+}
+
+type LantaiRequest struct {
+	GudangID        string   `json:"gudang_id"`
+	LantaiNama      string   `json:"lantai_nama"`
+	LantaiNo        int      `json:"lantai_no"` // Optional position, 0 = append after the last active floor
+	LantaiKapasitas *int     `json:"lantai_kapasitas"`
+	KapasitasVolume *float64 `json:"lantai_kapasitas_volume"` // m3
+	KapasitasBerat  *float64 `json:"lantai_kapasitas_berat"`  // kg
+}
+
+// Helper function to validate the capacity fields of a LantaiRequest
+func validateLantaiKapasitas(req LantaiRequest) string {
+	if req.LantaiKapasitas != nil && *req.LantaiKapasitas < 0 {
+		return "lantai_kapasitas cannot be negative"
+	}
+	if req.KapasitasVolume != nil && *req.KapasitasVolume < 0 {
+		return "lantai_kapasitas_volume cannot be negative"
+	}
+	if req.KapasitasBerat != nil && *req.KapasitasBerat < 0 {
+		return "lantai_kapasitas_berat cannot be negative"
+	}
+	return ""
+}
+
+type LantaiReorderRequest struct {
+	LantaiIDs []string `json:"lantai_ids"`
+}
+
+type LantaiRetireRequest struct {
+	TransferLantaiID string `json:"transfer_lantai_id"`
+}
+
+// Helper function to treat a capacity of 0 as "no limit"
+func clearZeroKapasitas(l *GudangLantai) {
+	if l.LantaiKapasitas != nil && *l.LantaiKapasitas == 0 {
+		l.LantaiKapasitas = nil
+	}
+	if l.KapasitasVolume != nil && *l.KapasitasVolume == 0 {
+		l.KapasitasVolume = nil
+	}
+	if l.KapasitasBerat != nil && *l.KapasitasBerat == 0 {
+		l.KapasitasBerat = nil
+	}
+}
+
+// Helper function to build the default floor name, e.g. "Gudang A Lt.2"
+func defaultLantaiNama(gudangNama string, lantaiNo int) string {
+	return fmt.Sprintf("%s Lt.%d", gudangNama, lantaiNo)
+}
+
+// Helper function to generate the next lantai_id number ("GL_0007" -> 7)
+func nextLantaiNum(q sqlExecutor) (int, error) {
+	var lastLantaiID string
+	err := q.QueryRow("SELECT lantai_id FROM gudang_lantai ORDER BY lantai_id DESC LIMIT 1").Scan(&lastLantaiID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	nextNum := 1
+	if lastLantaiID != "" {
+		numPart := lastLantaiID[3:] // "GL_0006" -> "0006"
+		n, _ := strconv.Atoi(numPart)
+		nextNum = n + 1
+	}
+	return nextNum, nil
+}
+
+// applyLantaiOrder sets lantai_no = position+1 for the given floors. Floors
+// still carrying their default name follow the new number.
+func applyLantaiOrder(q sqlExecutor, gudangID string, lantaiIDs []string) error {
+	var gudangNama string
+	err := q.QueryRow("SELECT gudang_nama FROM list_gudang WHERE gudang_id = ?", gudangID).Scan(&gudangNama)
+	if err != nil {
+		return fmt.Errorf("error fetching gudang: %v", err)
+	}
+
+	for i, lantaiID := range lantaiIDs {
+		newNo := i + 1
+		var currentNo int
+		var nama string
+		err = q.QueryRow("SELECT lantai_no, lantai_nama FROM gudang_lantai WHERE lantai_id = ?", lantaiID).Scan(&currentNo, &nama)
+		if err != nil {
+			return fmt.Errorf("error fetching floor '%s': %v", lantaiID, err)
+		}
+		if currentNo == newNo {
+			continue
+		}
+		if nama == defaultLantaiNama(gudangNama, currentNo) {
+			nama = defaultLantaiNama(gudangNama, newNo)
+		}
+		_, err = q.Exec("UPDATE gudang_lantai SET lantai_no = ?, lantai_nama = ? WHERE lantai_id = ?", newNo, nama, lantaiID)
+		if err != nil {
+			return fmt.Errorf("error renumbering floor '%s': %v", lantaiID, err)
+		}
+	}
+	return nil
+}
+
+// Helper function to list the floors of a gudang in lantai_no order, active floors first
+func orderedLantaiIDs(q sqlExecutor, gudangID string) ([]string, error) {
+	rows, err := q.Query("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? ORDER BY lantai_status DESC, lantai_no, lantai_id", gudangID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching floors: %v", err)
+	}
+	defer rows.Close()
+
+	var lantaiIDs []string
+	for rows.Next() {
+		var lantaiID string
+		if err := rows.Scan(&lantaiID); err != nil {
+			return nil, fmt.Errorf("error scanning floor: %v", err)
+		}
+		lantaiIDs = append(lantaiIDs, lantaiID)
+	}
+	return lantaiIDs, nil
+}
+
+// renumberLantai keeps lantai_no contiguous for a gudang: active floors first
+// in their current order, inactive floors after them
+func renumberLantai(q sqlExecutor, gudangID string) error {
+	lantaiIDs, err := orderedLantaiIDs(q, gudangID)
+	if err != nil {
+		return err
+	}
+	return applyLantaiOrder(q, gudangID, lantaiIDs)
+}
+
+// lantaiStockReport lists the non-zero stock_gudang rows of a floor
+func lantaiStockReport(q sqlExecutor, lantaiID string) ([]map[string]interface{}, int, error) {
+	rows, err := q.Query(`
+		SELECT sg.barang_id, COALESCE(b.barang_nama, ''), sg.stock_barang
+		FROM stock_gudang sg
+		LEFT JOIN barang b ON sg.barang_id = b.barang_id
+		WHERE sg.lantai_id = ? AND sg.stock_barang <> 0
+		ORDER BY sg.barang_id`, lantaiID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching floor stock: %v", err)
+	}
+	defer rows.Close()
+
+	total := 0
+	report := []map[string]interface{}{}
+	for rows.Next() {
+		var barangID, barangNama string
+		var stock int
+		if err := rows.Scan(&barangID, &barangNama, &stock); err != nil {
+			return nil, 0, fmt.Errorf("error scanning floor stock: %v", err)
+		}
+		total += stock
+		report = append(report, map[string]interface{}{
+			"barang_id":    barangID,
+			"barang_nama":  barangNama,
+			"stock_barang": stock,
+		})
+	}
+	return report, total, nil
+}
+
+// addStockGudang adds amount to the stock of a barang on a floor, creating the
+// stock_gudang row when needed
+func addStockGudang(q sqlExecutor, barangID, lantaiID string, amount int) error {
+	var currentStock int
+	err := q.QueryRow("SELECT stock_barang FROM stock_gudang WHERE barang_id = ? AND lantai_id = ?", barangID, lantaiID).Scan(&currentStock)
+	if err == sql.ErrNoRows {
+		var lastStockID string
+		err = q.QueryRow("SELECT stock_id FROM stock_gudang ORDER BY stock_id DESC LIMIT 1").Scan(&lastStockID)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("error generating stock_id: %v", err)
+		}
+		nextNum := 1
+		if lastStockID != "" {
+			n, _ := strconv.Atoi(lastStockID[3:]) // "ST_000010" -> "000010"
+			nextNum = n + 1
+		}
+		_, err = q.Exec("INSERT INTO stock_gudang (stock_id, barang_id, lantai_id, stock_barang) VALUES (?, ?, ?, ?)",
+			fmt.Sprintf("ST_%06d", nextNum), barangID, lantaiID, amount)
+		if err != nil {
+			return fmt.Errorf("error creating stock for lantai '%s': %v", lantaiID, err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error checking stock for lantai '%s': %v", lantaiID, err)
+	}
+
+	_, err = q.Exec("UPDATE stock_gudang SET stock_barang = ? WHERE barang_id = ? AND lantai_id = ?", currentStock+amount, barangID, lantaiID)
+	if err != nil {
+		return fmt.Errorf("error updating stock for lantai '%s': %v", lantaiID, err)
+	}
+	return nil
+}
+
+// transferLantaiStock moves all stock of a floor to another active floor of
+// the same gudang and books the move in barang_logs. Bin stock on the source
+// floor is cleared; it arrives unbinned on the target. Negative stock is
+// refused, it has to be corrected before the floor can be emptied.
+func transferLantaiStock(q sqlExecutor, lantaiID, targetLantaiID string, user *UserData) (int, error) {
+	var gudangID, lantaiNama string
+	err := q.QueryRow("SELECT gudang_id, lantai_nama FROM gudang_lantai WHERE lantai_id = ?", lantaiID).Scan(&gudangID, &lantaiNama)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching lantai: %v", err)
+	}
+
+	var targetGudangID, targetNama string
+	var targetStatus int
+	err = q.QueryRow(`SELECT gl.gudang_id, gl.lantai_nama, IF(gl.lantai_status = 1 AND lg.gudang_status = 1, 1, 0)
+		FROM gudang_lantai gl
+		JOIN list_gudang lg ON gl.gudang_id = lg.gudang_id
+		WHERE gl.lantai_id = ?`, targetLantaiID).Scan(&targetGudangID, &targetNama, &targetStatus)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("transfer lantai '%s' does not exist", targetLantaiID)
+	} else if err != nil {
+		return 0, fmt.Errorf("error fetching transfer lantai: %v", err)
+	}
+	if targetGudangID != gudangID {
+		return 0, fmt.Errorf("transfer lantai '%s' belongs to another gudang", targetLantaiID)
+	}
+	if targetStatus != 1 {
+		return 0, fmt.Errorf("transfer lantai '%s' is not active", targetLantaiID)
+	}
+
+	rows, err := q.Query("SELECT barang_id, stock_barang FROM stock_gudang WHERE lantai_id = ? AND stock_barang <> 0 ORDER BY barang_id", lantaiID)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching floor stock: %v", err)
+	}
+	stock := map[string]int{}
+	var barangIDs []string
+	for rows.Next() {
+		var barangID string
+		var amount int
+		if err := rows.Scan(&barangID, &amount); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning floor stock: %v", err)
+		}
+		stock[barangID] = amount
+		barangIDs = append(barangIDs, barangID)
+	}
+	rows.Close()
+
+	for _, barangID := range barangIDs {
+		if stock[barangID] < 0 {
+			return 0, fmt.Errorf("lantai '%s' has negative stock (%d) for barang '%s', correct it before moving the stock",
+				lantaiID, stock[barangID], barangID)
+		}
+	}
+
+	moved := 0
+	for _, barangID := range barangIDs {
+		if err := addStockGudang(q, barangID, targetLantaiID, stock[barangID]); err != nil {
+			return 0, err
+		}
+		moved += stock[barangID]
+	}
+
+	if _, err = q.Exec("UPDATE stock_gudang SET stock_barang = 0 WHERE lantai_id = ?", lantaiID); err != nil {
+		return 0, fmt.Errorf("error clearing floor stock: %v", err)
+	}
+	_, err = q.Exec("UPDATE stock_lokasi sl JOIN gudang_lokasi gk ON sl.lokasi_id = gk.lokasi_id SET sl.stock_barang = 0 WHERE gk.lantai_id = ?", lantaiID)
+	if err != nil {
+		return 0, fmt.Errorf("error clearing bin stock: %v", err)
+	}
+
+	if len(barangIDs) > 0 {
+		desc := fmt.Sprintf("Pindah stok %s ke %s", lantaiNama, targetNama)
+		if err := catatPindahLantai(q, gudangID, lantaiID, targetLantaiID, desc, barangIDs, stock, user); err != nil {
+			return 0, err
+		}
+	}
+	return moved, nil
+}
+
+// pindahStokBarang moves amount of one barang from lantaiID to targetLantaiID
+// of the same gudang and logs the move; note says what caused it
+func pindahStokBarang(q sqlExecutor, barangID, lantaiID, targetLantaiID string, amount int, note string, user *UserData) error {
+	var gudangID, lantaiNama, targetGudangID, targetNama string
+	err := q.QueryRow("SELECT gudang_id, lantai_nama FROM gudang_lantai WHERE lantai_id = ?", lantaiID).Scan(&gudangID, &lantaiNama)
+	if err != nil {
+		return fmt.Errorf("error fetching lantai '%s': %v", lantaiID, err)
+	}
+	err = q.QueryRow("SELECT gudang_id, lantai_nama FROM gudang_lantai WHERE lantai_id = ?", targetLantaiID).Scan(&targetGudangID, &targetNama)
+	if err != nil {
+		return fmt.Errorf("error fetching lantai '%s': %v", targetLantaiID, err)
+	}
+	if targetGudangID != gudangID {
+		return fmt.Errorf("lantai '%s' belongs to another gudang", targetLantaiID)
+	}
+
+	if _, err := takeSaleStock(q, barangID, gudangID, lantaiID, "", amount); err != nil {
+		return err
+	}
+	if err := addStockGudang(q, barangID, targetLantaiID, amount); err != nil {
+		return err
+	}
+
+	desc := fmt.Sprintf("Pindah stok %s ke %s (%s)", lantaiNama, targetNama, note)
+	return catatPindahLantai(q, gudangID, lantaiID, targetLantaiID, desc, []string{barangID}, map[string]int{barangID: amount}, user)
+}
+
+// catatPindahLantai books a stock move between floors as a completed Keluar
+// log on the source floor and a completed Masuk log (without value) on the
+// target, so the floor history stays complete
+func catatPindahLantai(q sqlExecutor, gudangID, lantaiID, targetLantaiID, desc string, barangIDs []string, stock map[string]int, user *UserData) error {
+	var lastLogsID string
+	err := q.QueryRow("SELECT logs_id FROM barang_logs ORDER BY logs_id DESC LIMIT 1").Scan(&lastLogsID)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error generating logs_id: %v", err)
+	}
+	nextLogsNum := 1
+	if lastLogsID != "" {
+		fmt.Sscanf(lastLogsID, "LO_%d", &nextLogsNum)
+		nextLogsNum++
+	}
+	keluarLogsID := fmt.Sprintf("LO_%07d", nextLogsNum)
+	masukLogsID := fmt.Sprintf("LO_%07d", nextLogsNum+1)
+	today := time.Now().Format("2006-01-02")
+
+	for _, logs := range []struct {
+		id     string
+		status int
+	}{{keluarLogsID, 2}, {masukLogsID, 1}} {
+		_, err = q.Exec("INSERT INTO barang_logs (logs_id, logs_status, logs_date, logs_desc, created_by) VALUES (?, ?, ?, ?, ?)",
+			logs.id, logs.status, today, desc, requestUserID(user))
+		if err != nil {
+			return fmt.Errorf("error inserting barang_logs: %v", err)
+		}
+	}
+
+	var lastKeluarID, lastMasukID string
+	err = q.QueryRow("SELECT orders_id FROM orders_keluar ORDER BY orders_id DESC LIMIT 1").Scan(&lastKeluarID)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error generating orders_id: %v", err)
+	}
+	err = q.QueryRow("SELECT orders_id FROM orders_masuk ORDER BY orders_id DESC LIMIT 1").Scan(&lastMasukID)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error generating orders_id: %v", err)
+	}
+	nextKeluarNum, nextMasukNum := 1, 1
+	if lastKeluarID != "" {
+		fmt.Sscanf(lastKeluarID, "OK_%d", &nextKeluarNum)
+		nextKeluarNum++
+	}
+	if lastMasukID != "" {
+		fmt.Sscanf(lastMasukID, "OM_%d", &nextMasukNum)
+		nextMasukNum++
+	}
+
+	for _, barangID := range barangIDs {
+		_, err = q.Exec("INSERT INTO orders_keluar (orders_id, logs_id, barang_id, gudang_id, lantai_id, orders_amount, orders_status, created_by) VALUES (?, ?, ?, ?, ?, ?, 1, ?)",
+			fmt.Sprintf("OK_%07d", nextKeluarNum), keluarLogsID, barangID, gudangID, lantaiID, stock[barangID], requestUserID(user))
+		if err != nil {
+			return fmt.Errorf("error inserting orders_keluar: %v", err)
+		}
+		_, err = q.Exec(`INSERT INTO orders_masuk (orders_id, logs_id, barang_id, gudang_id, lantai_id, orders_amount, orders_pay_type, orders_value, orders_deadline, orders_status, created_by)
+			VALUES (?, ?, ?, ?, ?, ?, 1, 0, ?, 1, ?)`,
+			fmt.Sprintf("OM_%07d", nextMasukNum), masukLogsID, barangID, gudangID, targetLantaiID, stock[barangID], today, requestUserID(user))
+		if err != nil {
+			return fmt.Errorf("error inserting orders_masuk: %v", err)
+		}
+		nextKeluarNum++
+		nextMasukNum++
+	}
+	return nil
+}
+
+// retireLantai empties a floor (into transferLantaiID when it holds stock) and
+// marks it and its bins inactive. It returns the stock report of the floor
+// when it still holds stock and no transfer target was given.
+func retireLantai(q sqlExecutor, lantaiID, transferLantaiID string, user *UserData) (int, []map[string]interface{}, error) {
+	var gudangID string
+	var status int
+	err := q.QueryRow("SELECT gudang_id, lantai_status FROM gudang_lantai WHERE lantai_id = ?", lantaiID).Scan(&gudangID, &status)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var activeFloors int
+	err = q.QueryRow("SELECT COUNT(*) FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1", gudangID).Scan(&activeFloors)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error counting floors: %v", err)
+	}
+	if status == 1 && activeFloors <= 1 {
+		return 0, nil, fmt.Errorf("cannot retire the last active floor of a gudang")
+	}
+
+	report, _, err := lantaiStockReport(q, lantaiID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Any non-zero row counts, stock that nets out to zero is still stock
+	moved := 0
+	if len(report) > 0 {
+		if transferLantaiID == "" {
+			return 0, report, nil
+		}
+		if transferLantaiID == lantaiID {
+			return 0, nil, fmt.Errorf("transfer_lantai_id must differ from the floor being retired")
+		}
+		moved, err = transferLantaiStock(q, lantaiID, transferLantaiID, user)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if _, err = q.Exec("UPDATE gudang_lantai SET lantai_status = 0 WHERE lantai_id = ?", lantaiID); err != nil {
+		return 0, nil, fmt.Errorf("error deactivating floor: %v", err)
+	}
+	if _, err = q.Exec("UPDATE gudang_lokasi SET lokasi_status = 0 WHERE lantai_id = ?", lantaiID); err != nil {
+		return 0, nil, fmt.Errorf("error deactivating bins: %v", err)
+	}
+	if err = renumberLantai(q, gudangID); err != nil {
+		return 0, nil, err
+	}
+	return moved, nil, nil
+}
+
+func createLantai(w http.ResponseWriter, r *http.Request) {
+	var req LantaiRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.GudangID == "" {
+		respondWithError(w, http.StatusBadRequest, "gudang_id is required")
+		return
+	}
+	if msg := validateLantaiKapasitas(req); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	var gudangNama string
+	var gudangStatus int
+	err = tx.QueryRow("SELECT gudang_nama, gudang_status FROM list_gudang WHERE gudang_id = ?", req.GudangID).Scan(&gudangNama, &gudangStatus)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Gudang not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if gudangStatus != 1 {
+		respondWithError(w, http.StatusBadRequest, "Gudang is archived")
+		return
+	}
+
+	lantaiIDs, err := orderedLantaiIDs(tx, req.GudangID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var activeFloors int
+	err = tx.QueryRow("SELECT COUNT(*) FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1", req.GudangID).Scan(&activeFloors)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error counting floors")
+		return
+	}
+
+	// Insert at the requested position, default is after the last active floor
+	lantaiNo := req.LantaiNo
+	if lantaiNo < 1 || lantaiNo > activeFloors+1 {
+		lantaiNo = activeFloors + 1
+	}
+
+	nextNum, err := nextLantaiNum(tx)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching last lantai_id")
+		return
+	}
+	newID := fmt.Sprintf("GL_%04d", nextNum)
+	audit := startAudit(db, r, "lantai", newID)
+
+	lantaiNama := req.LantaiNama
+	if lantaiNama == "" {
+		lantaiNama = defaultLantaiNama(gudangNama, lantaiNo)
+	}
+
+	l := GudangLantai{
+		LantaiID:        newID,
+		GudangID:        req.GudangID,
+		LantaiNo:        lantaiNo,
+		LantaiNama:      lantaiNama,
+		LantaiKapasitas: req.LantaiKapasitas,
+		KapasitasVolume: req.KapasitasVolume,
+		KapasitasBerat:  req.KapasitasBerat,
+		LantaiStatus:    1,
+	}
+	clearZeroKapasitas(&l)
+
+	_, err = tx.Exec("INSERT INTO gudang_lantai (lantai_id, gudang_id, lantai_no, lantai_nama, lantai_kapasitas, lantai_kapasitas_volume, lantai_kapasitas_berat, lantai_status) VALUES (?, ?, ?, ?, ?, ?, ?, 1)",
+		l.LantaiID, l.GudangID, l.LantaiNo, l.LantaiNama, l.LantaiKapasitas, l.KapasitasVolume, l.KapasitasBerat)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Insert lantai error: "+err.Error())
+		return
+	}
+
+	// Shift the floors from the insert position up by one
+	newOrder := append([]string{}, lantaiIDs[:lantaiNo-1]...)
+	newOrder = append(newOrder, newID)
+	newOrder = append(newOrder, lantaiIDs[lantaiNo-1:]...)
+	if err := applyLantaiOrder(tx, req.GudangID, newOrder); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error: "+err.Error())
+		return
+	}
+	audit.record("create")
+
+	respondWithJSON(w, l)
+}
+
+func getLantaiByGudang(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	gudangID := params["gudang_id"]
+	includeInactive := r.URL.Query().Get("include_inactive") == "true"
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	query := `
+		SELECT gl.lantai_id, gl.gudang_id, gl.lantai_no, gl.lantai_nama, gl.lantai_kapasitas,
+		       gl.lantai_kapasitas_volume, gl.lantai_kapasitas_berat, gl.lantai_status,
+		       COALESCE(SUM(sg.stock_barang), 0) as total_stock
+		FROM gudang_lantai gl
+		LEFT JOIN stock_gudang sg ON gl.lantai_id = sg.lantai_id
+		WHERE gl.gudang_id = ?`
+	if !includeInactive {
+		query += " AND gl.lantai_status = 1"
+	}
+	query += `
+		GROUP BY gl.lantai_id, gl.gudang_id, gl.lantai_no, gl.lantai_nama, gl.lantai_kapasitas,
+		         gl.lantai_kapasitas_volume, gl.lantai_kapasitas_berat, gl.lantai_status
+		ORDER BY gl.lantai_no`
+
+	rows, err := db.Query(query, gudangID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	lantaiList := []GudangLantai{}
+	for rows.Next() {
+		var l GudangLantai
+		var kapasitas sql.NullInt64
+		var volume, berat sql.NullFloat64
+		if err := rows.Scan(&l.LantaiID, &l.GudangID, &l.LantaiNo, &l.LantaiNama, &kapasitas, &volume, &berat, &l.LantaiStatus, &l.TotalStock); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		l.LantaiKapasitas = nullIntPtr(kapasitas)
+		l.KapasitasVolume = nullFloatPtr(volume)
+		l.KapasitasBerat = nullFloatPtr(berat)
+		lantaiList = append(lantaiList, l)
+	}
+
+	respondWithJSON(w, lantaiList)
+}
+
+// updateLantai renames a floor and/or sets its capacities
+func updateLantai(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["id"]
+
+	var req LantaiRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if msg := validateLantaiKapasitas(req); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	var l GudangLantai
+	var kapasitas sql.NullInt64
+	var volume, berat sql.NullFloat64
+	err = db.QueryRow("SELECT lantai_id, gudang_id, lantai_no, lantai_nama, lantai_kapasitas, lantai_kapasitas_volume, lantai_kapasitas_berat, lantai_status FROM gudang_lantai WHERE lantai_id = ?", id).
+		Scan(&l.LantaiID, &l.GudangID, &l.LantaiNo, &l.LantaiNama, &kapasitas, &volume, &berat, &l.LantaiStatus)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Lantai not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	l.LantaiKapasitas = nullIntPtr(kapasitas)
+	l.KapasitasVolume = nullFloatPtr(volume)
+	l.KapasitasBerat = nullFloatPtr(berat)
+
+	// Only the fields that are sent are changed
+	if req.LantaiNama != "" {
+		l.LantaiNama = req.LantaiNama
+	}
+	if req.LantaiKapasitas != nil {
+		l.LantaiKapasitas = req.LantaiKapasitas
+	}
+	if req.KapasitasVolume != nil {
+		l.KapasitasVolume = req.KapasitasVolume
+	}
+	if req.KapasitasBerat != nil {
+		l.KapasitasBerat = req.KapasitasBerat
+	}
+
+	clearZeroKapasitas(&l)
+
+	audit := startAudit(db, r, "lantai", id)
+	_, err = db.Exec("UPDATE gudang_lantai SET lantai_nama = ?, lantai_kapasitas = ?, lantai_kapasitas_volume = ?, lantai_kapasitas_berat = ? WHERE lantai_id = ?",
+		l.LantaiNama, l.LantaiKapasitas, l.KapasitasVolume, l.KapasitasBerat, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	audit.record("update")
+
+	respondWithJSON(w, l)
+}
+
+// reorderLantai sets the floor order of a gudang from the full list of its active floors
+func reorderLantai(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	gudangID := params["gudang_id"]
+
+	var req LantaiReorderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT lantai_id, lantai_status FROM gudang_lantai WHERE gudang_id = ? ORDER BY lantai_no", gudangID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	active := map[string]bool{}
+	var inactiveIDs []string
+	for rows.Next() {
+		var lantaiID string
+		var status int
+		if err := rows.Scan(&lantaiID, &status); err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		if status == 1 {
+			active[lantaiID] = true
+		} else {
+			inactiveIDs = append(inactiveIDs, lantaiID)
+		}
+	}
+	rows.Close()
+
+	if len(active) == 0 {
+		respondWithError(w, http.StatusNotFound, "Gudang not found or has no active floors")
+		return
+	}
+
+	// Validate the list is exactly the set of active floors
+	if len(req.LantaiIDs) != len(active) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("lantai_ids must list all %d active floors of the gudang", len(active)))
+		return
+	}
+	seen := map[string]bool{}
+	for _, lantaiID := range req.LantaiIDs {
+		if !active[lantaiID] {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Lantai '%s' is not an active floor of this gudang", lantaiID))
+			return
+		}
+		if seen[lantaiID] {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Lantai '%s' is listed more than once", lantaiID))
+			return
+		}
+		seen[lantaiID] = true
+	}
+
+	// One audit row per floor whose number changed
+	var audits []*auditTrail
+	for _, lantaiID := range req.LantaiIDs {
+		audits = append(audits, startAudit(db, r, "lantai", lantaiID))
+	}
+
+	if err := applyLantaiOrder(tx, gudangID, append(append([]string{}, req.LantaiIDs...), inactiveIDs...)); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error: "+err.Error())
+		return
+	}
+	for _, audit := range audits {
+		audit.record("reorder")
+	}
+
+	respondWithJSON(w, map[string]interface{}{
+		"gudang_id":  gudangID,
+		"lantai_ids": req.LantaiIDs,
+		"status":     "Reordered",
+	})
+}
+
+// deactivateLantai retires a floor. A floor holding stock is refused with a
+// stock report unless transfer_lantai_id is given.
+func deactivateLantai(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["id"]
+
+	var req LantaiRetireRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	audit := startAudit(db, r, "lantai", id)
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	moved, report, err := retireLantai(tx, id, req.TransferLantaiID, user)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Lantai not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if report != nil {
+		respondWithLantaiStock(w, id, report)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error: "+err.Error())
+		return
+	}
+	audit.record("deactivate")
+
+	respondWithJSON(w, map[string]interface{}{
+		"lantai_id":          id,
+		"transfer_lantai_id": req.TransferLantaiID,
+		"stock_moved":        moved,
+		"status":             "Deactivated",
+	})
+}
+
+func activateLantai(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	audit := startAudit(db, r, "lantai", id)
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	var gudangID string
+	var gudangStatus int
+	err = tx.QueryRow(`SELECT gl.gudang_id, lg.gudang_status FROM gudang_lantai gl
+		JOIN list_gudang lg ON gl.gudang_id = lg.gudang_id
+		WHERE gl.lantai_id = ? FOR UPDATE`, id).Scan(&gudangID, &gudangStatus)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Lantai not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	// Floors of an archived gudang stay out of the pickers until it is restored
+	if gudangStatus != 1 {
+		respondWithError(w, http.StatusConflict, "Gudang "+gudangID+" is archived, restore it before activating its floors")
+		return
+	}
+
+	// The floor comes back after the current active floors
+	if _, err = tx.Exec("UPDATE gudang_lantai SET lantai_status = 1 WHERE lantai_id = ?", id); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	if err := renumberLantai(tx, gudangID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error: "+err.Error())
+		return
+	}
+	audit.record("activate")
+
+	respondWithJSON(w, map[string]string{
+		"lantai_id": id,
+		"status":    "Activated",
+	})
+}
+
+// deleteLantai removes a floor. Stock has to be transferred first (same rule as
+// deactivateLantai); floors referenced by orders or sale items are only deactivated.
+func deleteLantai(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["id"]
+	transferLantaiID := r.URL.Query().Get("transfer_lantai_id")
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	audit := startAudit(db, r, "lantai", id)
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	var gudangID string
+	err = tx.QueryRow("SELECT gudang_id FROM gudang_lantai WHERE lantai_id = ?", id).Scan(&gudangID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Lantai not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	moved, report, err := retireLantai(tx, id, transferLantaiID, user)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if report != nil {
+		respondWithLantaiStock(w, id, report)
+		return
+	}
+
+	var referenced bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM orders_masuk WHERE lantai_id = ?)
+		OR EXISTS(SELECT 1 FROM orders_keluar WHERE lantai_id = ?)
+		OR EXISTS(SELECT 1 FROM sale_items WHERE lantai_id = ?)`, id, id, id).Scan(&referenced)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	status, action := "Deactivated", "deactivate"
+	if !referenced {
+		deletes := []string{
+			"DELETE sl FROM stock_lokasi sl JOIN gudang_lokasi gk ON sl.lokasi_id = gk.lokasi_id WHERE gk.lantai_id = ?",
+			"DELETE FROM gudang_lokasi WHERE lantai_id = ?",
+			"DELETE FROM stock_gudang WHERE lantai_id = ?",
+			"DELETE FROM gudang_lantai WHERE lantai_id = ?",
+		}
+		for _, query := range deletes {
+			if _, err = tx.Exec(query, id); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Delete error: "+err.Error())
+				return
+			}
+		}
+		if err := renumberLantai(tx, gudangID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		status, action = "Deleted", "delete"
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error: "+err.Error())
+		return
+	}
+	audit.record(action)
+
+	respondWithJSON(w, map[string]interface{}{
+		"lantai_id":          id,
+		"transfer_lantai_id": transferLantaiID,
+		"stock_moved":        moved,
+		"status":             status,
+	})
+}
+
+// Helper function to refuse retiring a floor that still holds stock
+func respondWithLantaiStock(w http.ResponseWriter, lantaiID string, report []map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":     "Lantai still holds stock, provide transfer_lantai_id to move it",
+		"lantai_id": lantaiID,
+		"stock":     report,
+	})
+}
+
+// SetupLantaiRoutes sets up all floor management routes
+func SetupLantaiRoutes(router *mux.Router) {
+	router.HandleFunc("/createlantai", createLantai).Methods("POST")
+	router.HandleFunc("/getlantai/{gudang_id}", getLantaiByGudang).Methods("GET")
+	router.HandleFunc("/updatelantai/{id}", updateLantai).Methods("PUT")
+	router.HandleFunc("/reorderlantai/{gudang_id}", reorderLantai).Methods("PUT")
+	router.HandleFunc("/deactivatelantai/{id}", deactivateLantai).Methods("PUT")
+	router.HandleFunc("/activatelantai/{id}", activateLantai).Methods("PUT")
+	router.HandleFunc("/deletelantai/{id}", deleteLantai).Methods("DELETE")
+}
@@ -0,0 +1,210 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Shared query contract of the list endpoints:
+//
+//	page, limit    1-based page and page size; limit alone is page 1, page
+//	               alone uses listDefaultLimit, at most listMaxLimit rows
+//	sort, order    one of the endpoint's sort fields, asc or desc
+//	q              free-text search over the endpoint's search columns
+//	               (search is accepted as an older name)
+//	field filters  date_from/date_to (YYYY-MM-DD) and the endpoint's own,
+//	               e.g. brand, gudang, customer, status
+//
+// Every response reports the number of matching rows in X-Total-Count. With
+// page or limit the body is a listPage envelope; without them it stays the
+// plain array older clients expect.
+
+const (
+	listDefaultLimit = 50
+	listMaxLimit     = 1000
+)
+
+// listFilter maps a query parameter to a WHERE condition; the value is bound
+// to every ? in the condition
+type listFilter struct {
+	param     string
+	condition string
+}
+
+// listSpec describes what one endpoint supports
+type listSpec struct {
+	filters      []listFilter
+	search       []string          // columns matched against q with LIKE
+	sorts        map[string]string // sort field -> ORDER BY expression
+	sortDefault  string
+	orderDefault string // "asc" or "desc"
+	key          string // unique column last in ORDER BY, so pages are stable
+}
+
+// listQuery is a parsed request: the WHERE conditions, ORDER BY and page
+type listQuery struct {
+	conditions []string
+	args       []interface{}
+	orderBy    string
+	sort       string
+	order      string
+	page       int
+	limit      int // 0: no limit
+	paged      bool
+}
+
+// listPage is the body of a paged response
+type listPage struct {
+	Data       interface{} `json:"data"`
+	Total      int         `json:"total"`
+	Page       int         `json:"page"`
+	Limit      int         `json:"limit"`
+	TotalPages int         `json:"total_pages"`
+	Sort       string      `json:"sort"`
+	Order      string      `json:"order"`
+}
+
+// parseListQuery reads the list parameters of r against spec. conditions are
+// added in front of the request's own, e.g. "b.deleted_at IS NULL".
+func parseListQuery(r *http.Request, spec listSpec, conditions ...string) (*listQuery, error) {
+	params := r.URL.Query()
+	l := &listQuery{conditions: append([]string{}, conditions...)}
+
+	for _, f := range spec.filters {
+		value := strings.TrimSpace(params.Get(f.param))
+		if value == "" {
+			continue
+		}
+		l.where(f.condition, value)
+	}
+
+	search := strings.TrimSpace(params.Get("q"))
+	if search == "" {
+		search = strings.TrimSpace(params.Get("search"))
+	}
+	if search != "" && len(spec.search) > 0 {
+		like := make([]string, len(spec.search))
+		for i, column := range spec.search {
+			like[i] = column + " LIKE ?"
+		}
+		l.where("("+strings.Join(like, " OR ")+")", "%"+search+"%")
+	}
+
+	field := params.Get("sort")
+	if field == "" {
+		field = spec.sortDefault
+	}
+	column, ok := spec.sorts[field]
+	if !ok {
+		return nil, fmt.Errorf("sort must be one of: %s", strings.Join(listSortFields(spec), ", "))
+	}
+	order := strings.ToLower(params.Get("order"))
+	if order == "" {
+		order = spec.orderDefault
+	}
+	if order != "asc" && order != "desc" {
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+	l.sort, l.order = field, order
+	l.orderBy = fmt.Sprintf("%s %s", column, strings.ToUpper(order))
+	if spec.key != "" && column != spec.key {
+		l.orderBy += fmt.Sprintf(", %s %s", spec.key, strings.ToUpper(order))
+	}
+
+	l.page = 1
+	if value := params.Get("page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("page must be a positive number")
+		}
+		l.page = n
+		l.paged = true
+		l.limit = listDefaultLimit
+	}
+	if value := params.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("limit must be a positive number")
+		}
+		if n > listMaxLimit {
+			return nil, fmt.Errorf("limit must be at most %d", listMaxLimit)
+		}
+		l.paged = true
+		l.limit = n
+	}
+	return l, nil
+}
+
+func listSortFields(spec listSpec) []string {
+	fields := make([]string, 0, len(spec.sorts))
+	for field := range spec.sorts {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// where adds a condition, binding value to each of its placeholders
+func (l *listQuery) where(condition string, value interface{}) {
+	l.conditions = append(l.conditions, condition)
+	for i := strings.Count(condition, "?"); i > 0; i-- {
+		l.args = append(l.args, value)
+	}
+}
+
+// whereSQL returns the WHERE clause, or "" when nothing is filtered
+func (l *listQuery) whereSQL() string {
+	if len(l.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(l.conditions, " AND ")
+}
+
+// limitSQL returns the LIMIT/OFFSET clause and its arguments
+func (l *listQuery) limitSQL() (string, []interface{}) {
+	if l.limit == 0 {
+		return "", nil
+	}
+	return " LIMIT ? OFFSET ?", []interface{}{l.limit, (l.page - 1) * l.limit}
+}
+
+// total returns how many rows match. n is the number of rows returned: when
+// nothing was cut off that is the total and no COUNT query is needed.
+func (l *listQuery) total(q sqlExecutor, expr, from string, n int) (int, error) {
+	if l.limit == 0 || (l.page == 1 && n < l.limit) {
+		return n, nil
+	}
+	var total int
+	err := q.QueryRow("SELECT "+expr+" "+from+l.whereSQL(), l.args...).Scan(&total)
+	return total, err
+}
+
+// respondWithList sends one page of a list with its total
+func respondWithList(w http.ResponseWriter, l *listQuery, total int, data interface{}) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if !l.paged {
+		respondWithJSON(w, data)
+		return
+	}
+	totalPages := 0
+	if l.limit > 0 {
+		totalPages = (total + l.limit - 1) / l.limit
+	}
+	respondWithJSON(w, listPage{
+		Data:       data,
+		Total:      total,
+		Page:       l.page,
+		Limit:      l.limit,
+		TotalPages: totalPages,
+		Sort:       l.sort,
+		Order:      l.order,
+	})
+}
+
+// sqlPlaceholders returns "?, ?, ?" for n values
+func sqlPlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -80,7 +80,7 @@ func loginUser(w http.ResponseWriter, r *http.Request) {
 	var user UserData
 	var hashedPassword string
 	query := `SELECT users_id, users_nama, users_tlp, users_pass, users_level, users_daftar, users_status 
-	          FROM users WHERE users_nama = ?`
+	          FROM users WHERE users_nama = ? AND deleted_at IS NULL`
 
 	err = db.QueryRow(query, loginReq.UsersNama).Scan(
 		&user.UsersID,
@@ -170,7 +170,26 @@ func loginUser(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// getLoginHistory gets all login history
+// loginHistoryListSpec is the list contract of getLoginHistory. The table
+// grows with every login, so clients that do not page get the newest 500.
+var loginHistoryListSpec = listSpec{
+	filters: []listFilter{
+		{"users_id", "ul.users_id = ?"},
+		{"date", "ul.login_date = ?"},
+		{"date_from", "ul.login_date >= ?"},
+		{"date_to", "ul.login_date <= ?"},
+	},
+	search: []string{"u.users_nama"},
+	sorts: map[string]string{
+		"date": "ul.login_date",
+		"user": "u.users_nama",
+	},
+	sortDefault:  "date",
+	orderDefault: "desc",
+	key:          "ul.login_id",
+}
+
+// getLoginHistory gets the login history, newest first
 func getLoginHistory(w http.ResponseWriter, r *http.Request) {
 	db, err := database.GetDBConnection()
 	if err != nil {
@@ -179,12 +198,19 @@ func getLoginHistory(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	list, err := parseListQuery(r, loginHistoryListSpec)
+	if err != nil {
+		respondWithErrorLogin(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	from := `FROM users_login ul 
+	          JOIN users u ON ul.users_id = u.users_id`
 	query := `SELECT ul.login_id, ul.users_id, u.users_nama, ul.login_date, ul.login_time 
-	          FROM users_login ul 
-	          JOIN users u ON ul.users_id = u.users_id 
-	          ORDER BY ul.login_date DESC, ul.login_time DESC`
+	          ` + from + list.whereSQL() + ` ORDER BY ` + list.orderBy
+	limit, limitArgs := list.limitSQL()
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query+limit, append(list.args, limitArgs...)...)
 	if err != nil {
 		respondWithErrorLogin(w, http.StatusInternalServerError, "Database query error")
 		return
@@ -199,7 +225,7 @@ func getLoginHistory(w http.ResponseWriter, r *http.Request) {
 		LoginTime string `json:"login_time"`
 	}
 
-	var history []LoginHistory
+	history := []LoginHistory{}
 	for rows.Next() {
 		var h LoginHistory
 		if err := rows.Scan(&h.LoginID, &h.UsersID, &h.UsersNama, &h.LoginDate, &h.LoginTime); err != nil {
@@ -209,7 +235,12 @@ func getLoginHistory(w http.ResponseWriter, r *http.Request) {
 		history = append(history, h)
 	}
 
-	respondWithJSONLogin(w, history)
+	total, err := list.total(db, "COUNT(*)", from, len(history))
+	if err != nil {
+		respondWithErrorLogin(w, http.StatusInternalServerError, "Database query error")
+		return
+	}
+	respondWithList(w, list, total, history)
 }
 
 // getUserLoginHistory gets login history for a specific user
@@ -256,6 +287,37 @@ func getUserLoginHistory(w http.ResponseWriter, r *http.Request) {
 	respondWithJSONLogin(w, history)
 }
 
+// requestUser resolves the user performing a request from the X-Users-ID header
+// the client sends after login. It returns nil when the header is missing.
+func requestUser(q sqlExecutor, r *http.Request) (*UserData, error) {
+	usersID := r.Header.Get("X-Users-ID")
+	if usersID == "" {
+		return nil, nil
+	}
+
+	var user UserData
+	err := q.QueryRow(`SELECT users_id, users_nama, users_level, users_status
+		FROM users WHERE users_id = ? AND deleted_at IS NULL`, usersID).
+		Scan(&user.UsersID, &user.UsersNama, &user.UsersLevel, &user.UsersStatus)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user '%s' does not exist", usersID)
+	} else if err != nil {
+		return nil, fmt.Errorf("error fetching user: %v", err)
+	}
+	if user.UsersStatus != 1 {
+		return nil, fmt.Errorf("user '%s' is not active", usersID)
+	}
+	return &user, nil
+}
+
+// Helper function to get the users_id for deleted_by/created_by style columns (NULL when unknown)
+func requestUserID(user *UserData) interface{} {
+	if user == nil {
+		return nil
+	}
+	return user.UsersID
+}
+
 // SetupLoginRoutes sets up all login-related routes
 func SetupLoginRoutes(router *mux.Router) {
 	router.HandleFunc("/login", loginUser).Methods("POST")
@@ -0,0 +1,805 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"src/database"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// GudangLokasi represents a bin location below a gudang_lantai (zona -> rak -> bin)
+type GudangLokasi struct {
+	LokasiID     string `json:"lokasi_id"`
+	LantaiID     string `json:"lantai_id"`
+	LokasiZona   string `json:"lokasi_zona"`
+	LokasiRak    string `json:"lokasi_rak"`
+	LokasiBin    string `json:"lokasi_bin"`
+	LokasiNama   string `json:"lokasi_nama"`
+	LokasiStatus int    `json:"lokasi_status"`
+	TotalStock   int    `json:"total_stock"`
+}
+
+type LokasiRequest struct {
+	LantaiID     string `json:"lantai_id"`
+	LokasiZona   string `json:"lokasi_zona"`
+	LokasiRak    string `json:"lokasi_rak"`
+	LokasiBin    string `json:"lokasi_bin"`
+	LokasiStatus *int   `json:"lokasi_status,omitempty"`
+}
+
+type LokasiBatchRequest struct {
+	LantaiID   string `json:"lantai_id"`
+	LokasiZona string `json:"lokasi_zona"`
+	JumlahRak  int    `json:"jumlah_rak"`
+	JumlahBin  int    `json:"jumlah_bin"`
+}
+
+type PutAwayRequest struct {
+	BarangID     string `json:"barang_id"`
+	LantaiID     string `json:"lantai_id"`
+	DariLokasiID string `json:"dari_lokasi_id,omitempty"` // Empty = take from unbinned stock
+	KeLokasiID   string `json:"ke_lokasi_id"`
+	Jumlah       int    `json:"jumlah"`
+}
+
+// sqlExecutor is satisfied by both *sql.DB and *sql.Tx so stock helpers
+// can run inside or outside a transaction
+type sqlExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Helper function to build the display name of a bin, e.g. "A-01-03"
+func lokasiNama(zona, rak, bin string) string {
+	return fmt.Sprintf("%s-%s-%s", zona, rak, bin)
+}
+
+// Helper function to generate the next lokasi_id ("LK_000001")
+func nextLokasiID(q sqlExecutor) (int, error) {
+	var lastID string
+	err := q.QueryRow("SELECT lokasi_id FROM gudang_lokasi ORDER BY lokasi_id DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:]) // "LK_000004" -> "000004"
+		nextNum = n + 1
+	}
+	return nextNum, nil
+}
+
+// Helper function to verify a lokasi exists, is active and belongs to the given lantai
+func validateLokasi(q sqlExecutor, lokasiID, lantaiID string) error {
+	var lokasiLantaiID string
+	var status int
+	err := q.QueryRow("SELECT lantai_id, lokasi_status FROM gudang_lokasi WHERE lokasi_id = ?", lokasiID).Scan(&lokasiLantaiID, &status)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("lokasi '%s' does not exist", lokasiID)
+	} else if err != nil {
+		return fmt.Errorf("error looking up lokasi '%s': %v", lokasiID, err)
+	}
+	if lokasiLantaiID != lantaiID {
+		return fmt.Errorf("lokasi '%s' does not belong to lantai '%s'", lokasiID, lantaiID)
+	}
+	if status != 1 {
+		return fmt.Errorf("lokasi '%s' is not active", lokasiID)
+	}
+	return nil
+}
+
+// addStockLokasi puts stock for a barang into a bin. The lantai total in
+// stock_gudang must already include the amount.
+func addStockLokasi(q sqlExecutor, barangID, lantaiID, lokasiID string, amount int) error {
+	if err := validateLokasi(q, lokasiID, lantaiID); err != nil {
+		return err
+	}
+
+	var stockLokasiID string
+	var current int
+	err := q.QueryRow("SELECT stock_lokasi_id, stock_barang FROM stock_lokasi WHERE barang_id = ? AND lokasi_id = ?", barangID, lokasiID).Scan(&stockLokasiID, &current)
+	if err == sql.ErrNoRows {
+		var lastID string
+		err = q.QueryRow("SELECT stock_lokasi_id FROM stock_lokasi ORDER BY stock_lokasi_id DESC LIMIT 1").Scan(&lastID)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("error generating stock_lokasi_id: %v", err)
+		}
+		nextNum := 1
+		if lastID != "" {
+			n, _ := strconv.Atoi(lastID[3:]) // "SK_0000010" -> "0000010"
+			nextNum = n + 1
+		}
+		_, err = q.Exec("INSERT INTO stock_lokasi (stock_lokasi_id, barang_id, lokasi_id, stock_barang) VALUES (?, ?, ?, ?)",
+			fmt.Sprintf("SK_%07d", nextNum), barangID, lokasiID, amount)
+		if err != nil {
+			return fmt.Errorf("error creating stock for lokasi '%s': %v", lokasiID, err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error checking stock for lokasi '%s': %v", lokasiID, err)
+	}
+
+	_, err = q.Exec("UPDATE stock_lokasi SET stock_barang = ? WHERE stock_lokasi_id = ?", current+amount, stockLokasiID)
+	if err != nil {
+		return fmt.Errorf("error updating stock for lokasi '%s': %v", lokasiID, err)
+	}
+	return nil
+}
+
+// takeStockLokasi picks stock for a barang from a specific bin
+func takeStockLokasi(q sqlExecutor, barangID, lantaiID, lokasiID string, amount int) error {
+	if err := validateLokasi(q, lokasiID, lantaiID); err != nil {
+		return err
+	}
+
+	var current int
+	err := q.QueryRow("SELECT stock_barang FROM stock_lokasi WHERE barang_id = ? AND lokasi_id = ?", barangID, lokasiID).Scan(&current)
+	if err == sql.ErrNoRows {
+		current = 0
+	} else if err != nil {
+		return fmt.Errorf("error checking stock for lokasi '%s': %v", lokasiID, err)
+	}
+	if current < amount {
+		return fmt.Errorf("insufficient stock in lokasi '%s' (available: %d, requested: %d)", lokasiID, current, amount)
+	}
+
+	_, err = q.Exec("UPDATE stock_lokasi SET stock_barang = ? WHERE barang_id = ? AND lokasi_id = ?", current-amount, barangID, lokasiID)
+	if err != nil {
+		return fmt.Errorf("error updating stock for lokasi '%s': %v", lokasiID, err)
+	}
+	return nil
+}
+
+// trimStockLokasi keeps the binned stock of a barang on a lantai from exceeding
+// the lantai total in stock_gudang. Call it after any decrement of stock_gudang
+// that was not picked from a specific bin; bins are drained in lokasi order.
+func trimStockLokasi(q sqlExecutor, barangID, lantaiID string) error {
+	var lantaiStock int
+	err := q.QueryRow("SELECT COALESCE(SUM(stock_barang), 0) FROM stock_gudang WHERE barang_id = ? AND lantai_id = ?", barangID, lantaiID).Scan(&lantaiStock)
+	if err != nil {
+		return fmt.Errorf("error fetching lantai stock: %v", err)
+	}
+
+	rows, err := q.Query(`
+		SELECT sl.lokasi_id, sl.stock_barang
+		FROM stock_lokasi sl
+		JOIN gudang_lokasi gk ON sl.lokasi_id = gk.lokasi_id
+		WHERE sl.barang_id = ? AND gk.lantai_id = ? AND sl.stock_barang > 0
+		ORDER BY gk.lokasi_zona, gk.lokasi_rak, gk.lokasi_bin`, barangID, lantaiID)
+	if err != nil {
+		return fmt.Errorf("error fetching lokasi stock: %v", err)
+	}
+
+	type binStock struct {
+		lokasiID string
+		stock    int
+	}
+	var bins []binStock
+	binned := 0
+	for rows.Next() {
+		var b binStock
+		if err := rows.Scan(&b.lokasiID, &b.stock); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning lokasi stock: %v", err)
+		}
+		bins = append(bins, b)
+		binned += b.stock
+	}
+	rows.Close()
+
+	excess := binned - lantaiStock
+	for _, b := range bins {
+		if excess <= 0 {
+			break
+		}
+		take := b.stock
+		if take > excess {
+			take = excess
+		}
+		_, err = q.Exec("UPDATE stock_lokasi SET stock_barang = ? WHERE barang_id = ? AND lokasi_id = ?", b.stock-take, barangID, b.lokasiID)
+		if err != nil {
+			return fmt.Errorf("error trimming stock for lokasi '%s': %v", b.lokasiID, err)
+		}
+		excess -= take
+	}
+	return nil
+}
+
+// syncStockLokasi follows a change of the lantai total in stock_gudang:
+// additions go into lokasiID when one is set, reductions are trimmed from the bins
+func syncStockLokasi(q sqlExecutor, barangID, lantaiID, lokasiID string, change int) error {
+	if change > 0 && lokasiID != "" {
+		return addStockLokasi(q, barangID, lantaiID, lokasiID, change)
+	}
+	if change < 0 {
+		return trimStockLokasi(q, barangID, lantaiID)
+	}
+	return nil
+}
+
+func createLokasi(w http.ResponseWriter, r *http.Request) {
+	var req LokasiRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Validate required fields
+	if req.LantaiID == "" || req.LokasiZona == "" || req.LokasiRak == "" || req.LokasiBin == "" {
+		respondWithError(w, http.StatusBadRequest, "lantai_id, lokasi_zona, lokasi_rak and lokasi_bin are required")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	var lantaiExists bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM gudang_lantai WHERE lantai_id = ? AND lantai_status = 1)", req.LantaiID).Scan(&lantaiExists)
+	if err != nil || !lantaiExists {
+		respondWithError(w, http.StatusBadRequest, "Invalid lantai_id: lantai does not exist or is inactive")
+		return
+	}
+
+	var duplicate bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM gudang_lokasi WHERE lantai_id = ? AND lokasi_zona = ? AND lokasi_rak = ? AND lokasi_bin = ?)",
+		req.LantaiID, req.LokasiZona, req.LokasiRak, req.LokasiBin).Scan(&duplicate)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if duplicate {
+		respondWithError(w, http.StatusConflict, "Lokasi "+lokasiNama(req.LokasiZona, req.LokasiRak, req.LokasiBin)+" already exists on this lantai")
+		return
+	}
+
+	nextNum, err := nextLokasiID(db)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching last lokasi_id")
+		return
+	}
+	newID := fmt.Sprintf("LK_%06d", nextNum)
+	nama := lokasiNama(req.LokasiZona, req.LokasiRak, req.LokasiBin)
+
+	audit := startAudit(db, r, "lokasi", newID)
+	_, err = db.Exec("INSERT INTO gudang_lokasi (lokasi_id, lantai_id, lokasi_zona, lokasi_rak, lokasi_bin, lokasi_nama, lokasi_status) VALUES (?, ?, ?, ?, ?, ?, 1)",
+		newID, req.LantaiID, req.LokasiZona, req.LokasiRak, req.LokasiBin, nama)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Insert error: "+err.Error())
+		return
+	}
+	audit.record("create")
+
+	respondWithJSON(w, GudangLokasi{
+		LokasiID:     newID,
+		LantaiID:     req.LantaiID,
+		LokasiZona:   req.LokasiZona,
+		LokasiRak:    req.LokasiRak,
+		LokasiBin:    req.LokasiBin,
+		LokasiNama:   nama,
+		LokasiStatus: 1,
+	})
+}
+
+// createLokasiBatch generates a whole zona at once: rak 01..jumlah_rak x bin 01..jumlah_bin
+func createLokasiBatch(w http.ResponseWriter, r *http.Request) {
+	var req LokasiBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.LantaiID == "" || req.LokasiZona == "" {
+		respondWithError(w, http.StatusBadRequest, "lantai_id and lokasi_zona are required")
+		return
+	}
+	if req.JumlahRak < 1 || req.JumlahBin < 1 {
+		respondWithError(w, http.StatusBadRequest, "jumlah_rak and jumlah_bin must be at least 1")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	var lantaiExists bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM gudang_lantai WHERE lantai_id = ? AND lantai_status = 1)", req.LantaiID).Scan(&lantaiExists)
+	if err != nil || !lantaiExists {
+		respondWithError(w, http.StatusBadRequest, "Invalid lantai_id: lantai does not exist or is inactive")
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	nextNum, err := nextLokasiID(tx)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching last lokasi_id")
+		return
+	}
+
+	created := 0
+	skipped := 0
+	var audits []*auditTrail
+	for rak := 1; rak <= req.JumlahRak; rak++ {
+		for bin := 1; bin <= req.JumlahBin; bin++ {
+			rakKode := fmt.Sprintf("%02d", rak)
+			binKode := fmt.Sprintf("%02d", bin)
+
+			// Existing bins are kept as they are so the batch can be re-run to extend a zona
+			var exists bool
+			err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM gudang_lokasi WHERE lantai_id = ? AND lokasi_zona = ? AND lokasi_rak = ? AND lokasi_bin = ?)",
+				req.LantaiID, req.LokasiZona, rakKode, binKode).Scan(&exists)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+				return
+			}
+			if exists {
+				skipped++
+				continue
+			}
+
+			lokasiID := fmt.Sprintf("LK_%06d", nextNum)
+			audits = append(audits, startAudit(db, r, "lokasi", lokasiID))
+			_, err = tx.Exec("INSERT INTO gudang_lokasi (lokasi_id, lantai_id, lokasi_zona, lokasi_rak, lokasi_bin, lokasi_nama, lokasi_status) VALUES (?, ?, ?, ?, ?, ?, 1)",
+				lokasiID, req.LantaiID, req.LokasiZona, rakKode, binKode, lokasiNama(req.LokasiZona, rakKode, binKode))
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Insert error: "+err.Error())
+				return
+			}
+			nextNum++
+			created++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error: "+err.Error())
+		return
+	}
+	for _, audit := range audits {
+		audit.record("create")
+	}
+
+	respondWithJSON(w, map[string]interface{}{
+		"lantai_id":   req.LantaiID,
+		"lokasi_zona": req.LokasiZona,
+		"created":     created,
+		"skipped":     skipped,
+		"status":      "Created",
+		"message":     fmt.Sprintf("%d lokasi created in zona %s", created, req.LokasiZona),
+	})
+}
+
+func getLokasiByLantai(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	lantaiID := params["lantai_id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT gk.lokasi_id, gk.lantai_id, gk.lokasi_zona, gk.lokasi_rak, gk.lokasi_bin,
+		       gk.lokasi_nama, gk.lokasi_status, COALESCE(SUM(sl.stock_barang), 0) as total_stock
+		FROM gudang_lokasi gk
+		LEFT JOIN stock_lokasi sl ON gk.lokasi_id = sl.lokasi_id
+		WHERE gk.lantai_id = ?
+		GROUP BY gk.lokasi_id, gk.lantai_id, gk.lokasi_zona, gk.lokasi_rak, gk.lokasi_bin, gk.lokasi_nama, gk.lokasi_status
+		ORDER BY gk.lokasi_zona, gk.lokasi_rak, gk.lokasi_bin`, lantaiID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	lokasiList := []GudangLokasi{}
+	for rows.Next() {
+		var l GudangLokasi
+		if err := rows.Scan(&l.LokasiID, &l.LantaiID, &l.LokasiZona, &l.LokasiRak, &l.LokasiBin, &l.LokasiNama, &l.LokasiStatus, &l.TotalStock); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		lokasiList = append(lokasiList, l)
+	}
+
+	respondWithJSON(w, lokasiList)
+}
+
+func updateLokasi(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["id"]
+
+	var req LokasiRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.LokasiZona == "" || req.LokasiRak == "" || req.LokasiBin == "" {
+		respondWithError(w, http.StatusBadRequest, "lokasi_zona, lokasi_rak and lokasi_bin are required")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	var lantaiID string
+	var status int
+	err = db.QueryRow("SELECT lantai_id, lokasi_status FROM gudang_lokasi WHERE lokasi_id = ?", id).Scan(&lantaiID, &status)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Lokasi not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	if req.LokasiStatus != nil {
+		if *req.LokasiStatus != 0 && *req.LokasiStatus != 1 {
+			respondWithError(w, http.StatusBadRequest, "lokasi_status must be 0 or 1")
+			return
+		}
+		status = *req.LokasiStatus
+	}
+
+	// A bin that still holds stock cannot be deactivated
+	if status == 0 {
+		var binStock int
+		err = db.QueryRow("SELECT COALESCE(SUM(stock_barang), 0) FROM stock_lokasi WHERE lokasi_id = ?", id).Scan(&binStock)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		if binStock > 0 {
+			respondWithError(w, http.StatusConflict, fmt.Sprintf("Lokasi still holds %d items, move them before deactivating", binStock))
+			return
+		}
+	}
+
+	var duplicate bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM gudang_lokasi WHERE lantai_id = ? AND lokasi_zona = ? AND lokasi_rak = ? AND lokasi_bin = ? AND lokasi_id <> ?)",
+		lantaiID, req.LokasiZona, req.LokasiRak, req.LokasiBin, id).Scan(&duplicate)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if duplicate {
+		respondWithError(w, http.StatusConflict, "Lokasi "+lokasiNama(req.LokasiZona, req.LokasiRak, req.LokasiBin)+" already exists on this lantai")
+		return
+	}
+
+	nama := lokasiNama(req.LokasiZona, req.LokasiRak, req.LokasiBin)
+	audit := startAudit(db, r, "lokasi", id)
+	_, err = db.Exec("UPDATE gudang_lokasi SET lokasi_zona = ?, lokasi_rak = ?, lokasi_bin = ?, lokasi_nama = ?, lokasi_status = ? WHERE lokasi_id = ?",
+		req.LokasiZona, req.LokasiRak, req.LokasiBin, nama, status, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	audit.record("update")
+
+	respondWithJSON(w, GudangLokasi{
+		LokasiID:     id,
+		LantaiID:     lantaiID,
+		LokasiZona:   req.LokasiZona,
+		LokasiRak:    req.LokasiRak,
+		LokasiBin:    req.LokasiBin,
+		LokasiNama:   nama,
+		LokasiStatus: status,
+	})
+}
+
+func deleteLokasi(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	var binStock int
+	err = db.QueryRow("SELECT COALESCE(SUM(stock_barang), 0) FROM stock_lokasi WHERE lokasi_id = ?", id).Scan(&binStock)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if binStock > 0 {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("Lokasi still holds %d items, move them before deleting", binStock))
+		return
+	}
+
+	audit := startAudit(db, r, "lokasi", id)
+
+	// Sale lines and receipts keep pointing at the bin, so referenced bins are only deactivated
+	var referenced bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM sale_items WHERE lokasi_id = ?) OR EXISTS(SELECT 1 FROM orders_masuk WHERE lokasi_id = ?)", id, id).Scan(&referenced)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if referenced {
+		res, err := db.Exec("UPDATE gudang_lokasi SET lokasi_status = 0 WHERE lokasi_id = ?", id)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+			return
+		}
+		if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+			respondWithError(w, http.StatusNotFound, "Lokasi not found")
+			return
+		}
+		audit.record("deactivate")
+		respondWithJSON(w, map[string]string{
+			"lokasi_id": id,
+			"status":    "Deactivated",
+			"message":   "Lokasi is referenced by transactions and was deactivated instead of deleted",
+		})
+		return
+	}
+
+	if _, err = db.Exec("DELETE FROM stock_lokasi WHERE lokasi_id = ?", id); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Delete error: "+err.Error())
+		return
+	}
+
+	res, err := db.Exec("DELETE FROM gudang_lokasi WHERE lokasi_id = ?", id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Delete error: "+err.Error())
+		return
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Lokasi not found")
+		return
+	}
+	audit.record("delete")
+
+	respondWithJSON(w, map[string]string{
+		"lokasi_id": id,
+		"status":    "Deleted",
+	})
+}
+
+// getStockLokasi returns the per-bin breakdown of a barang on one lantai.
+// The lantai total is the same figure /getfloorstock returns.
+func getStockLokasi(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	barangID := params["barang_id"]
+	lantaiID := params["lantai_id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	var lantaiStock int
+	err = db.QueryRow("SELECT COALESCE(SUM(stock_barang), 0) FROM stock_gudang WHERE barang_id = ? AND lantai_id = ?", barangID, lantaiID).Scan(&lantaiStock)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT gk.lokasi_id, gk.lokasi_nama, sl.stock_barang
+		FROM stock_lokasi sl
+		JOIN gudang_lokasi gk ON sl.lokasi_id = gk.lokasi_id
+		WHERE sl.barang_id = ? AND gk.lantai_id = ? AND sl.stock_barang > 0
+		ORDER BY gk.lokasi_zona, gk.lokasi_rak, gk.lokasi_bin`, barangID, lantaiID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	binned := 0
+	stockLokasi := []map[string]interface{}{}
+	for rows.Next() {
+		var lokasiID, nama string
+		var stock int
+		if err := rows.Scan(&lokasiID, &nama, &stock); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		binned += stock
+		stockLokasi = append(stockLokasi, map[string]interface{}{
+			"lokasi_id":    lokasiID,
+			"lokasi_nama":  nama,
+			"stock_barang": stock,
+		})
+	}
+
+	respondWithJSON(w, map[string]interface{}{
+		"barang_id":      barangID,
+		"lantai_id":      lantaiID,
+		"stock_lantai":   lantaiStock,
+		"stock_binned":   binned,
+		"stock_unbinned": lantaiStock - binned,
+		"stock_lokasi":   stockLokasi,
+	})
+}
+
+// putAwayStock moves stock into a bin, either from the unbinned remainder of
+// the lantai or from another bin on the same lantai
+func putAwayStock(w http.ResponseWriter, r *http.Request) {
+	var req PutAwayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.BarangID == "" || req.LantaiID == "" || req.KeLokasiID == "" {
+		respondWithError(w, http.StatusBadRequest, "barang_id, lantai_id and ke_lokasi_id are required")
+		return
+	}
+	if req.Jumlah <= 0 {
+		respondWithError(w, http.StatusBadRequest, "jumlah must be greater than 0")
+		return
+	}
+	if req.DariLokasiID == req.KeLokasiID {
+		respondWithError(w, http.StatusBadRequest, "dari_lokasi_id and ke_lokasi_id must differ")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	audit := startRowsAudit(tx, r, "stock_lokasi", "barang_id = ? AND lokasi_id IN (?, ?)", req.BarangID, req.DariLokasiID, req.KeLokasiID)
+
+	if req.DariLokasiID != "" {
+		if err := takeStockLokasi(tx, req.BarangID, req.LantaiID, req.DariLokasiID, req.Jumlah); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	} else {
+		var lantaiStock, binned int
+		err = tx.QueryRow("SELECT COALESCE(SUM(stock_barang), 0) FROM stock_gudang WHERE barang_id = ? AND lantai_id = ?", req.BarangID, req.LantaiID).Scan(&lantaiStock)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		err = tx.QueryRow(`
+			SELECT COALESCE(SUM(sl.stock_barang), 0)
+			FROM stock_lokasi sl
+			JOIN gudang_lokasi gk ON sl.lokasi_id = gk.lokasi_id
+			WHERE sl.barang_id = ? AND gk.lantai_id = ?`, req.BarangID, req.LantaiID).Scan(&binned)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		if lantaiStock-binned < req.Jumlah {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Insufficient unbinned stock (available: %d, requested: %d)", lantaiStock-binned, req.Jumlah))
+			return
+		}
+	}
+
+	if err := addStockLokasi(tx, req.BarangID, req.LantaiID, req.KeLokasiID, req.Jumlah); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	audit.record("put_away")
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, map[string]interface{}{
+		"barang_id":      req.BarangID,
+		"lantai_id":      req.LantaiID,
+		"dari_lokasi_id": req.DariLokasiID,
+		"ke_lokasi_id":   req.KeLokasiID,
+		"jumlah":         req.Jumlah,
+		"status":         "Moved",
+		"message":        "Stock put away successfully",
+	})
+}
+
+// getPutAwaySuggestion lists candidate bins for receiving a barang on a lantai:
+// bins that already hold the barang first, then empty bins
+func getPutAwaySuggestion(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	barangID := params["barang_id"]
+	lantaiID := params["lantai_id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT gk.lokasi_id, gk.lokasi_nama,
+		       COALESCE(SUM(CASE WHEN sl.barang_id = ? THEN sl.stock_barang ELSE 0 END), 0) as stock_barang_ini,
+		       COALESCE(SUM(sl.stock_barang), 0) as stock_total
+		FROM gudang_lokasi gk
+		LEFT JOIN stock_lokasi sl ON gk.lokasi_id = sl.lokasi_id
+		WHERE gk.lantai_id = ? AND gk.lokasi_status = 1
+		GROUP BY gk.lokasi_id, gk.lokasi_nama, gk.lokasi_zona, gk.lokasi_rak, gk.lokasi_bin
+		HAVING stock_barang_ini > 0 OR stock_total = 0
+		ORDER BY stock_barang_ini DESC, gk.lokasi_zona, gk.lokasi_rak, gk.lokasi_bin`, barangID, lantaiID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	suggestions := []map[string]interface{}{}
+	for rows.Next() {
+		var lokasiID, nama string
+		var stockBarang, stockTotal int
+		if err := rows.Scan(&lokasiID, &nama, &stockBarang, &stockTotal); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		alasan := "empty"
+		if stockBarang > 0 {
+			alasan = "same_barang"
+		}
+		suggestions = append(suggestions, map[string]interface{}{
+			"lokasi_id":    lokasiID,
+			"lokasi_nama":  nama,
+			"stock_barang": stockBarang,
+			"alasan":       alasan,
+		})
+	}
+
+	respondWithJSON(w, map[string]interface{}{
+		"barang_id":   barangID,
+		"lantai_id":   lantaiID,
+		"suggestions": suggestions,
+	})
+}
+
+// SetupLokasiRoutes sets up all bin location routes
+func SetupLokasiRoutes(router *mux.Router) {
+	router.HandleFunc("/createlokasi", createLokasi).Methods("POST")
+	router.HandleFunc("/createlokasibatch", createLokasiBatch).Methods("POST")
+	router.HandleFunc("/getlokasi/{lantai_id}", getLokasiByLantai).Methods("GET")
+	router.HandleFunc("/updatelokasi/{id}", updateLokasi).Methods("PUT")
+	router.HandleFunc("/deletelokasi/{id}", deleteLokasi).Methods("DELETE")
+
+	// Stock per bin
+	router.HandleFunc("/getlokasistock/{barang_id}/{lantai_id}", getStockLokasi).Methods("GET")
+	router.HandleFunc("/getputawaysuggestion/{barang_id}/{lantai_id}", getPutAwaySuggestion).Methods("GET")
+	router.HandleFunc("/putawaystock", putAwayStock).Methods("PUT")
+}
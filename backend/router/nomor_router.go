@@ -0,0 +1,292 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"src/database"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// NomorSeri configures the legal numbering of one document type
+type NomorSeri struct {
+	Type    string `json:"seri_type"`
+	Pattern string `json:"seri_pattern"`
+	Reset   string `json:"seri_reset"`
+	Updated string `json:"seri_updated,omitempty"`
+	// Number the next document of today would get (not reserved)
+	NextNo string `json:"next_no"`
+}
+
+type NomorSeriRequest struct {
+	Pattern string `json:"seri_pattern"`
+	Reset   string `json:"seri_reset"`
+}
+
+var nomorSeqPattern = regexp.MustCompile(`\{SEQ(?::(\d+))?\}`)
+
+// nomorBranch is the branch code of this server (BRANCH_CODE), used by the
+// {BRANCH} token and to keep a separate counter per branch
+func nomorBranch() string {
+	return os.Getenv("BRANCH_CODE")
+}
+
+// nomorPeriod returns the counter period of day for a reset setting
+func nomorPeriod(reset string, day time.Time) string {
+	switch reset {
+	case "yearly":
+		return day.Format("2006")
+	case "monthly":
+		return day.Format("2006-01")
+	}
+	return ""
+}
+
+// formatNomor fills a numbering pattern
+func formatNomor(pattern string, day time.Time, branch string, seq int) string {
+	out := strings.NewReplacer(
+		"{BRANCH}", branch,
+		"{YYYY}", day.Format("2006"),
+		"{YY}", day.Format("06"),
+		"{MM}", day.Format("01"),
+	).Replace(pattern)
+	return nomorSeqPattern.ReplaceAllStringFunc(out, func(token string) string {
+		width := 1
+		if m := nomorSeqPattern.FindStringSubmatch(token); m[1] != "" {
+			width, _ = strconv.Atoi(m[1])
+		}
+		return fmt.Sprintf("%0*d", width, seq)
+	})
+}
+
+// validateNomorSeri makes sure numbers of different periods cannot collide
+func validateNomorSeri(pattern, reset string) error {
+	if !nomorSeqPattern.MatchString(pattern) {
+		return fmt.Errorf("seri_pattern must contain {SEQ} or {SEQ:n}")
+	}
+	hasYear := strings.Contains(pattern, "{YYYY}") || strings.Contains(pattern, "{YY}")
+	switch reset {
+	case "none":
+	case "yearly":
+		if !hasYear {
+			return fmt.Errorf("a yearly series needs {YYYY} or {YY} in seri_pattern")
+		}
+	case "monthly":
+		if !hasYear || !strings.Contains(pattern, "{MM}") {
+			return fmt.Errorf("a monthly series needs {YYYY} or {YY} and {MM} in seri_pattern")
+		}
+	default:
+		return fmt.Errorf("seri_reset must be none, yearly or monthly")
+	}
+	return nil
+}
+
+// parseNomorDate reads a document date ("2026-10-19" or a DATETIME/RFC3339
+// value); anything unreadable counts as today
+func parseNomorDate(value string) time.Time {
+	if len(value) >= 10 {
+		if day, err := time.ParseInLocation("2006-01-02", value[:10], time.Local); err == nil {
+			return day
+		}
+	}
+	return time.Now()
+}
+
+// assignNomor takes the next number of a series for a document dated day.
+// q must be the transaction that confirms the document: the counter row stays
+// locked until it commits and is rolled back with it, so numbers are gapless.
+func assignNomor(q sqlExecutor, seriType string, day time.Time) (string, error) {
+	var pattern, reset string
+	err := q.QueryRow("SELECT seri_pattern, seri_reset FROM nomor_seri WHERE seri_type = ?", seriType).Scan(&pattern, &reset)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("numbering series '%s' is not configured", seriType)
+	} else if err != nil {
+		return "", fmt.Errorf("error reading numbering series: %v", err)
+	}
+
+	period := nomorPeriod(reset, day)
+	branch := nomorBranch()
+
+	// Create the counter of a new period, then lock it
+	_, err = q.Exec(`INSERT INTO nomor_counter (seri_type, counter_period, counter_branch, counter_last)
+		VALUES (?, ?, ?, 0) ON DUPLICATE KEY UPDATE counter_last = counter_last`, seriType, period, branch)
+	if err != nil {
+		return "", fmt.Errorf("error creating number counter: %v", err)
+	}
+	var last int
+	err = q.QueryRow(`SELECT counter_last FROM nomor_counter
+		WHERE seri_type = ? AND counter_period = ? AND counter_branch = ? FOR UPDATE`, seriType, period, branch).Scan(&last)
+	if err != nil {
+		return "", fmt.Errorf("error locking number counter: %v", err)
+	}
+
+	_, err = q.Exec(`UPDATE nomor_counter SET counter_last = ?
+		WHERE seri_type = ? AND counter_period = ? AND counter_branch = ?`, last+1, seriType, period, branch)
+	if err != nil {
+		return "", fmt.Errorf("error updating number counter: %v", err)
+	}
+	return formatNomor(pattern, day, branch, last+1), nil
+}
+
+// assignSalesInvoiceNo numbers a sale once it leaves Draft; a sale keeps its
+// number for good, also when it is cancelled later
+func assignSalesInvoiceNo(q sqlExecutor, salesID string) (string, error) {
+	var invoiceNo sql.NullString
+	var salesDate string
+	err := q.QueryRow("SELECT sales_invoice_no, sales_date FROM sales WHERE sales_id = ?", salesID).Scan(&invoiceNo, &salesDate)
+	if err != nil {
+		return "", fmt.Errorf("error reading sales: %v", err)
+	}
+	if invoiceNo.Valid {
+		return invoiceNo.String, nil
+	}
+
+	no, err := assignNomor(q, "invoice", parseNomorDate(salesDate))
+	if err != nil {
+		return "", err
+	}
+	if _, err := q.Exec("UPDATE sales SET sales_invoice_no = ? WHERE sales_id = ?", no, salesID); err != nil {
+		return "", fmt.Errorf("error saving invoice number: %v", err)
+	}
+	return no, nil
+}
+
+// assignSalesCreditNote numbers the credit note that voids the invoice of a
+// cancelled sale. Sales without an invoice number need none and get "".
+func assignSalesCreditNote(q sqlExecutor, salesID string) (string, error) {
+	var invoiceNo, creditNoteNo sql.NullString
+	err := q.QueryRow("SELECT sales_invoice_no, sales_credit_note_no FROM sales WHERE sales_id = ?", salesID).Scan(&invoiceNo, &creditNoteNo)
+	if err != nil {
+		return "", fmt.Errorf("error reading sales: %v", err)
+	}
+	if !invoiceNo.Valid || creditNoteNo.Valid {
+		return creditNoteNo.String, nil
+	}
+
+	no, err := assignNomor(q, "credit_note", time.Now())
+	if err != nil {
+		return "", err
+	}
+	if _, err := q.Exec("UPDATE sales SET sales_credit_note_no = ? WHERE sales_id = ?", no, salesID); err != nil {
+		return "", fmt.Errorf("error saving credit note number: %v", err)
+	}
+	return no, nil
+}
+
+func getNomorSeri(w http.ResponseWriter, r *http.Request) {
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT seri_type, seri_pattern, seri_reset, seri_updated FROM nomor_seri ORDER BY seri_type")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	var list []NomorSeri
+	for rows.Next() {
+		var s NomorSeri
+		var updated sql.NullString
+		if err := rows.Scan(&s.Type, &s.Pattern, &s.Reset, &updated); err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		s.Updated = updated.String
+		list = append(list, s)
+	}
+	rows.Close()
+
+	today := time.Now()
+	branch := nomorBranch()
+	for i := range list {
+		var last int
+		err := db.QueryRow(`SELECT counter_last FROM nomor_counter
+			WHERE seri_type = ? AND counter_period = ? AND counter_branch = ?`,
+			list[i].Type, nomorPeriod(list[i].Reset, today), branch).Scan(&last)
+		if err != nil && err != sql.ErrNoRows {
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		list[i].NextNo = formatNomor(list[i].Pattern, today, branch, last+1)
+	}
+	if list == nil {
+		list = []NomorSeri{}
+	}
+
+	respondWithJSON(w, list)
+}
+
+// updateNomorSeri changes pattern or reset period. Counters are kept, so a
+// new pattern continues the running sequence of the current period.
+func updateNomorSeri(w http.ResponseWriter, r *http.Request) {
+	seriType := mux.Vars(r)["type"]
+
+	var req NomorSeriRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateNomorSeri(req.Pattern, req.Reset); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if user == nil || user.UsersLevel != 1 {
+		respondWithError(w, http.StatusForbidden, "Only admin can change document numbering")
+		return
+	}
+
+	audit := startAudit(db, r, "nomor_seri", seriType)
+	res, err := db.Exec("UPDATE nomor_seri SET seri_pattern = ?, seri_reset = ?, seri_updated = NOW() WHERE seri_type = ?",
+		req.Pattern, req.Reset, seriType)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		var exists bool
+		db.QueryRow("SELECT EXISTS(SELECT 1 FROM nomor_seri WHERE seri_type = ?)", seriType).Scan(&exists)
+		if !exists {
+			respondWithError(w, http.StatusNotFound, "Unknown numbering series '"+seriType+"'")
+			return
+		}
+	}
+	audit.record("update")
+
+	respondWithJSON(w, map[string]string{
+		"seri_type":    seriType,
+		"seri_pattern": req.Pattern,
+		"seri_reset":   req.Reset,
+		"status":       "Updated",
+	})
+}
+
+// SetupNomorRoutes sets up the document numbering routes
+func SetupNomorRoutes(router *mux.Router) {
+	router.HandleFunc("/getnomorseri", getNomorSeri).Methods("GET")
+	router.HandleFunc("/updatenomorseri/{type}", updateNomorSeri).Methods("PUT")
+}
@@ -0,0 +1,77 @@
+package router
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatNomor(t *testing.T) {
+	day := time.Date(2026, time.March, 7, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name    string
+		pattern string
+		branch  string
+		seq     int
+		want    string
+	}{
+		{"yearly invoice", "INV/{YYYY}/{SEQ:5}", "", 42, "INV/2026/00042"},
+		{"monthly with branch", "{BRANCH}-SJ/{YY}{MM}/{SEQ:4}", "JKT", 7, "JKT-SJ/2603/0007"},
+		{"plain seq", "PO-{SEQ}", "", 123, "PO-123"},
+		{"seq wider than padding", "INV{SEQ:2}", "", 1234, "INV1234"},
+		{"empty branch", "{BRANCH}INV/{SEQ:3}", "", 1, "INV/001"},
+		{"seq used twice", "{SEQ:3}-{SEQ}", "", 5, "005-5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatNomor(tt.pattern, day, tt.branch, tt.seq); got != tt.want {
+				t.Errorf("formatNomor(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateNomorSeri(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		reset   string
+		wantErr string
+	}{
+		{"never resets", "PO-{SEQ:6}", "none", ""},
+		{"yearly with YYYY", "INV/{YYYY}/{SEQ:5}", "yearly", ""},
+		{"yearly with YY", "INV{YY}{SEQ:5}", "yearly", ""},
+		{"monthly with year and month", "SJ/{YY}{MM}/{SEQ:4}", "monthly", ""},
+		{"missing seq", "INV/{YYYY}", "yearly", "must contain {SEQ}"},
+		{"yearly forgets the year", "INV/{SEQ:5}", "yearly", "yearly series needs"},
+		{"monthly forgets the month", "INV/{YYYY}/{SEQ:5}", "monthly", "monthly series needs"},
+		{"monthly forgets the year", "INV/{MM}/{SEQ:5}", "monthly", "monthly series needs"},
+		{"unknown reset", "INV/{YYYY}/{SEQ:5}", "daily", "seri_reset must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNomorSeri(tt.pattern, tt.reset)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNomorPeriod(t *testing.T) {
+	day := time.Date(2026, time.March, 7, 0, 0, 0, 0, time.Local)
+	for reset, want := range map[string]string{"none": "", "yearly": "2026", "monthly": "2026-03"} {
+		if got := nomorPeriod(reset, day); got != want {
+			t.Errorf("nomorPeriod(%q) = %q, want %q", reset, got, want)
+		}
+	}
+}
@@ -32,6 +32,12 @@ type OrdersMasuk struct {
 	LogsStatus int    `json:"logs_status,omitempty"`
 	LogsDate   string `json:"logs_date,omitempty"`
 	LogsDesc   string `json:"logs_desc,omitempty"`
+	// Who recorded and last changed the order
+	CreatedBy     string `json:"created_by,omitempty"`
+	CreatedByNama string `json:"created_by_nama,omitempty"`
+	UpdatedBy     string `json:"updated_by,omitempty"`
+	UpdatedByNama string `json:"updated_by_nama,omitempty"`
+	UpdatedAt     string `json:"updated_at,omitempty"`
 }
 
 type CombinedOrderMasukBatch struct {
@@ -45,6 +51,7 @@ type CombinedOrderMasukBatch struct {
 type OrderMasukDetail struct {
 	GudangID     string `json:"gudang_id,omitempty"` // Deprecated: kept for backward compatibility
 	LantaiID     string `json:"lantai_id"`           // New: floor-level tracking
+	LokasiID     string `json:"lokasi_id,omitempty"` // Optional: put-away bin on the lantai
 	BarangID     string `json:"barang_id"`
 	OrdersAmount int    `json:"orders_amount"`
 	OrdersValue  int    `json:"orders_value"`
@@ -91,6 +98,10 @@ func createBatchOrderMasuk(w http.ResponseWriter, r *http.Request) {
 			respondWithErrorOrdersMasuk(w, http.StatusBadRequest, fmt.Sprintf("lantai_id is required for order %d", i+1))
 			return
 		}
+		if order.LokasiID != "" && order.LantaiID == "" {
+			respondWithErrorOrdersMasuk(w, http.StatusBadRequest, fmt.Sprintf("lantai_id is required when lokasi_id is set for order %d", i+1))
+			return
+		}
 		if order.BarangID == "" {
 			respondWithErrorOrdersMasuk(w, http.StatusBadRequest, fmt.Sprintf("barang_id is required for order %d", i+1))
 			return
@@ -118,6 +129,12 @@ func createBatchOrderMasuk(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithErrorOrdersMasuk(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
 	// Begin transaction for data consistency
 	tx, err := db.Begin()
 	if err != nil {
@@ -128,7 +145,7 @@ func createBatchOrderMasuk(w http.ResponseWriter, r *http.Request) {
 	// Validate all barang_id and lantai_id exist
 	for i, order := range batch.Orders {
 		var existingBarangID string
-		err = tx.QueryRow("SELECT barang_id FROM barang WHERE barang_id = ?", order.BarangID).Scan(&existingBarangID)
+		err = tx.QueryRow("SELECT barang_id FROM barang WHERE barang_id = ? AND deleted_at IS NULL", order.BarangID).Scan(&existingBarangID)
 		if err == sql.ErrNoRows {
 			tx.Rollback()
 			respondWithErrorOrdersMasuk(w, http.StatusNotFound, fmt.Sprintf("Barang with ID %s not found for order %d", order.BarangID, i+1))
@@ -142,20 +159,31 @@ func createBatchOrderMasuk(w http.ResponseWriter, r *http.Request) {
 		// Validate lantai_id if provided (new format)
 		if order.LantaiID != "" {
 			var existingLantaiID string
-			err = tx.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE lantai_id = ?", order.LantaiID).Scan(&existingLantaiID)
+			err = tx.QueryRow(`SELECT gl.lantai_id FROM gudang_lantai gl
+				JOIN list_gudang lg ON gl.gudang_id = lg.gudang_id
+				WHERE gl.lantai_id = ? AND gl.lantai_status = 1 AND lg.gudang_status = 1`, order.LantaiID).Scan(&existingLantaiID)
 			if err == sql.ErrNoRows {
 				tx.Rollback()
-				respondWithErrorOrdersMasuk(w, http.StatusNotFound, fmt.Sprintf("Lantai with ID %s not found for order %d", order.LantaiID, i+1))
+				respondWithErrorOrdersMasuk(w, http.StatusNotFound, fmt.Sprintf("Lantai with ID %s not found or inactive for order %d", order.LantaiID, i+1))
 				return
 			} else if err != nil {
 				tx.Rollback()
 				respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error validating lantai_id")
 				return
 			}
+
+			// Validate lokasi_id if provided, it must be a bin on the same lantai
+			if order.LokasiID != "" {
+				if err := validateLokasi(tx, order.LokasiID, order.LantaiID); err != nil {
+					tx.Rollback()
+					respondWithErrorOrdersMasuk(w, http.StatusBadRequest, fmt.Sprintf("%v for order %d", err, i+1))
+					return
+				}
+			}
 		} else if order.GudangID != "" {
 			// Legacy support: validate gudang_id
 			var existingGudangID string
-			err = tx.QueryRow("SELECT gudang_id FROM list_gudang WHERE gudang_id = ?", order.GudangID).Scan(&existingGudangID)
+			err = tx.QueryRow("SELECT gudang_id FROM list_gudang WHERE gudang_id = ? AND gudang_status = 1", order.GudangID).Scan(&existingGudangID)
 			if err == sql.ErrNoRows {
 				tx.Rollback()
 				respondWithErrorOrdersMasuk(w, http.StatusNotFound, fmt.Sprintf("Gudang with ID %s not found for order %d", order.GudangID, i+1))
@@ -191,8 +219,16 @@ func createBatchOrderMasuk(w http.ResponseWriter, r *http.Request) {
 		logsDate = batch.LogsDate
 	}
 
+	// Purchase order number, taken inside this transaction
+	poNo, err := assignNomor(tx, "purchase_order", parseNomorDate(logsDate))
+	if err != nil {
+		tx.Rollback()
+		respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	// Insert barang_logs with logs_status = 1 (Masuk)
-	logsStmt, err := tx.Prepare("INSERT INTO barang_logs (logs_id, logs_status, logs_date, logs_desc) VALUES (?, ?, ?, ?)")
+	logsStmt, err := tx.Prepare("INSERT INTO barang_logs (logs_id, logs_status, logs_date, logs_desc, logs_po_no, created_by) VALUES (?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		tx.Rollback()
 		respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error preparing logs insert")
@@ -200,7 +236,7 @@ func createBatchOrderMasuk(w http.ResponseWriter, r *http.Request) {
 	}
 	defer logsStmt.Close()
 
-	_, err = logsStmt.Exec(newLogsID, 1, logsDate, batch.LogsDesc)
+	_, err = logsStmt.Exec(newLogsID, 1, logsDate, batch.LogsDesc, poNo, requestUserID(user))
 	if err != nil {
 		tx.Rollback()
 		respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error inserting barang_logs")
@@ -234,8 +270,16 @@ func createBatchOrderMasuk(w http.ResponseWriter, r *http.Request) {
 		ordersDeadline = logsDate
 	}
 
+	// Capacity of the target floors only produces warnings, the batch is still created
+	kapasitasWarnings, err := checkKapasitasOrders(tx, batch.Orders)
+	if err != nil {
+		tx.Rollback()
+		respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error checking floor capacity")
+		return
+	}
+
 	// Prepare orders_masuk insert statement
-	ordersStmt, err := tx.Prepare("INSERT INTO orders_masuk (orders_id, logs_id, barang_id, gudang_id, lantai_id, orders_amount, orders_pay_type, orders_value, orders_deadline, orders_status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	ordersStmt, err := tx.Prepare("INSERT INTO orders_masuk (orders_id, logs_id, barang_id, gudang_id, lantai_id, lokasi_id, orders_amount, orders_pay_type, orders_value, orders_deadline, orders_status, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		tx.Rollback()
 		respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error preparing orders insert")
@@ -265,7 +309,7 @@ func createBatchOrderMasuk(w http.ResponseWriter, r *http.Request) {
 		} else {
 			// Legacy format: use gudang_id directly, get first lantai_id
 			gudangID = order.GudangID
-			err = tx.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? ORDER BY lantai_no LIMIT 1", gudangID).Scan(&lantaiID)
+			err = tx.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1 ORDER BY lantai_no LIMIT 1", gudangID).Scan(&lantaiID)
 			if err != nil {
 				tx.Rollback()
 				respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error fetching lantai_id from gudang_id")
@@ -273,14 +317,22 @@ func createBatchOrderMasuk(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		audit := startAudit(tx, r, "orders_masuk", newOrdersID)
+
 		// Insert into orders_masuk with both gudang_id and lantai_id
-		_, err = ordersStmt.Exec(newOrdersID, newLogsID, order.BarangID, gudangID, lantaiID, order.OrdersAmount, batch.OrdersPayType, order.OrdersValue, ordersDeadline, ordersStatus)
+		_, err = ordersStmt.Exec(newOrdersID, newLogsID, order.BarangID, gudangID, lantaiID, processNullableStringValue(order.LokasiID), order.OrdersAmount, batch.OrdersPayType, order.OrdersValue, ordersDeadline, ordersStatus, requestUserID(user))
 		if err != nil {
 			tx.Rollback()
 			respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error inserting order")
 			return
 		}
 
+		if err := hitungPajakOrdersMasuk(tx, newOrdersID); err != nil {
+			tx.Rollback()
+			respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
 		// Update stock if orders_status is 1 (Lunas/done)
 		if ordersStatus == 1 {
 			// Check if stock record exists using lantai_id
@@ -326,13 +378,25 @@ func createBatchOrderMasuk(w http.ResponseWriter, r *http.Request) {
 					return
 				}
 			}
+
+			// Put the received stock straight into its bin
+			if order.LokasiID != "" {
+				if err := addStockLokasi(tx, order.BarangID, lantaiID, order.LokasiID, order.OrdersAmount); err != nil {
+					tx.Rollback()
+					respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, err.Error())
+					return
+				}
+			}
 		}
 
+		audit.record("create")
+
 		createdOrders = append(createdOrders, map[string]interface{}{
 			"orders_id":       newOrdersID,
 			"barang_id":       order.BarangID,
 			"gudang_id":       gudangID,
 			"lantai_id":       lantaiID,
+			"lokasi_id":       order.LokasiID,
 			"orders_amount":   order.OrdersAmount,
 			"orders_value":    order.OrdersValue,
 			"orders_pay_type": batch.OrdersPayType,
@@ -349,13 +413,16 @@ func createBatchOrderMasuk(w http.ResponseWriter, r *http.Request) {
 
 	respondWithJSONOrdersMasuk(w, map[string]interface{}{
 		"logs_id":         newLogsID,
+		"logs_po_no":      poNo,
 		"logs_status":     1,
 		"logs_date":       logsDate,
 		"logs_desc":       batch.LogsDesc,
 		"orders_pay_type": batch.OrdersPayType,
 		"orders_deadline": ordersDeadline,
 		"orders_status":   ordersStatus,
+		"created_by":      requestUserID(user),
 		"orders":          createdOrders,
+		"warnings":        kapasitasWarnings,
 		"status":          "Created",
 		"message":         fmt.Sprintf("Successfully created pesan barang with %d items", len(createdOrders)),
 	})
@@ -370,47 +437,97 @@ func getOrdersMasuk(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	query := `
-		SELECT 
-			om.orders_id, om.logs_id, om.barang_id, om.gudang_id, 
-			om.orders_amount, om.orders_pay_type, om.orders_value,
-			om.orders_deadline, om.orders_status,
-			b.barang_nama, br.brand_nama, g.gudang_nama,
-			bl.logs_status, bl.logs_date, bl.logs_desc
+	list, err := parseListQuery(r, ordersMasukListSpec)
+	if err != nil {
+		respondWithErrorOrdersMasuk(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	from := `
 		FROM orders_masuk om
 		JOIN barang_logs bl ON om.logs_id = bl.logs_id
 		JOIN barang b ON om.barang_id = b.barang_id
 		JOIN brand br ON b.brand_id = br.brand_id
 		JOIN gudang_lantai gl ON om.lantai_id = gl.lantai_id
 		JOIN list_gudang g ON gl.gudang_id = g.gudang_id
-		ORDER BY om.orders_id DESC
-	`
+		LEFT JOIN users uc ON om.created_by = uc.users_id
+		LEFT JOIN users uu ON om.updated_by = uu.users_id`
+	query := `
+		SELECT 
+			om.orders_id, om.logs_id, om.barang_id, om.gudang_id, 
+			om.orders_amount, om.orders_pay_type, om.orders_value,
+			om.orders_deadline, om.orders_status,
+			b.barang_nama, br.brand_nama, g.gudang_nama,
+			bl.logs_status, bl.logs_date, bl.logs_desc,
+			om.created_by, uc.users_nama, om.updated_by, uu.users_nama, om.updated_at` +
+		from + list.whereSQL() + " ORDER BY " + list.orderBy
+	limit, limitArgs := list.limitSQL()
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query+limit, append(list.args, limitArgs...)...)
 	if err != nil {
 		respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error querying orders")
 		return
 	}
 	defer rows.Close()
 
-	var orders []OrdersMasuk
+	orders := []OrdersMasuk{}
 	for rows.Next() {
 		var order OrdersMasuk
+		var createdBy, createdByNama, updatedBy, updatedByNama, updatedAt sql.NullString
 		err := rows.Scan(
 			&order.OrdersID, &order.LogsID, &order.BarangID, &order.GudangID,
 			&order.OrdersAmount, &order.OrdersPayType, &order.OrdersValue,
 			&order.OrdersDeadline, &order.OrdersStatus,
 			&order.BarangNama, &order.BrandNama, &order.GudangNama,
 			&order.LogsStatus, &order.LogsDate, &order.LogsDesc,
+			&createdBy, &createdByNama, &updatedBy, &updatedByNama, &updatedAt,
 		)
 		if err != nil {
 			respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error scanning order")
 			return
 		}
+		order.CreatedBy = createdBy.String
+		order.CreatedByNama = createdByNama.String
+		order.UpdatedBy = updatedBy.String
+		order.UpdatedByNama = updatedByNama.String
+		order.UpdatedAt = updatedAt.String
 		orders = append(orders, order)
 	}
 
-	respondWithJSONOrdersMasuk(w, orders)
+	total, err := list.total(db, "COUNT(*)", from, len(orders))
+	if err != nil {
+		respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error counting orders")
+		return
+	}
+	respondWithList(w, list, total, orders)
+}
+
+// ordersMasukListSpec is the list contract of getOrdersMasuk
+var ordersMasukListSpec = listSpec{
+	filters: []listFilter{
+		{"created_by", "om.created_by = ?"},
+		{"date", "bl.logs_date = ?"},
+		{"date_from", "bl.logs_date >= ?"},
+		{"date_to", "bl.logs_date <= ?"},
+		{"brand", "br.brand_nama = ?"},
+		{"gudang", "g.gudang_id = ?"},
+		{"barang", "om.barang_id = ?"},
+		{"status", "om.orders_status = ?"},
+		{"pay_type", "om.orders_pay_type = ?"},
+	},
+	search: []string{"om.orders_id", "om.logs_id", "b.barang_nama", "bl.logs_desc"},
+	sorts: map[string]string{
+		"id":       "om.orders_id",
+		"date":     "bl.logs_date",
+		"barang":   "b.barang_nama",
+		"amount":   "om.orders_amount",
+		"value":    "om.orders_value",
+		"deadline": "om.orders_deadline",
+		"status":   "om.orders_status",
+	},
+	sortDefault:  "id",
+	orderDefault: "desc",
+	key:          "om.orders_id",
 }
 
 // Update orders_masuk status (for Kredit to Lunas)
@@ -439,6 +556,12 @@ func updateOrdersMasukStatus(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithErrorOrdersMasuk(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error starting transaction")
@@ -448,8 +571,8 @@ func updateOrdersMasukStatus(w http.ResponseWriter, r *http.Request) {
 	// Get current order info including lantai_id
 	var currentStatus, ordersAmount int
 	var barangID, gudangID string
-	var lantaiIDNull sql.NullString
-	err = tx.QueryRow("SELECT orders_status, barang_id, gudang_id, lantai_id, orders_amount FROM orders_masuk WHERE orders_id = ?", ordersID).Scan(&currentStatus, &barangID, &gudangID, &lantaiIDNull, &ordersAmount)
+	var lantaiIDNull, lokasiIDNull sql.NullString
+	err = tx.QueryRow("SELECT orders_status, barang_id, gudang_id, lantai_id, lokasi_id, orders_amount FROM orders_masuk WHERE orders_id = ?", ordersID).Scan(&currentStatus, &barangID, &gudangID, &lantaiIDNull, &lokasiIDNull, &ordersAmount)
 	if err == sql.ErrNoRows {
 		tx.Rollback()
 		respondWithErrorOrdersMasuk(w, http.StatusNotFound, "Order not found")
@@ -466,7 +589,7 @@ func updateOrdersMasukStatus(w http.ResponseWriter, r *http.Request) {
 		lantaiID = lantaiIDNull.String
 	} else {
 		// Fallback: get first floor of the gudang
-		err = tx.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? ORDER BY lantai_no LIMIT 1", gudangID).Scan(&lantaiID)
+		err = tx.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1 ORDER BY lantai_no LIMIT 1", gudangID).Scan(&lantaiID)
 		if err != nil {
 			tx.Rollback()
 			respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error fetching lantai_id for gudang")
@@ -484,8 +607,11 @@ func updateOrdersMasukStatus(w http.ResponseWriter, r *http.Request) {
 		stockChange = -ordersAmount
 	}
 
+	audit := startAudit(tx, r, "orders_masuk", ordersID)
+
 	// Update order status
-	_, err = tx.Exec("UPDATE orders_masuk SET orders_status = ? WHERE orders_id = ?", req.OrdersStatus, ordersID)
+	_, err = tx.Exec("UPDATE orders_masuk SET orders_status = ?, updated_by = ?, updated_at = NOW() WHERE orders_id = ?",
+		req.OrdersStatus, requestUserID(user), ordersID)
 	if err != nil {
 		tx.Rollback()
 		respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error updating order status")
@@ -540,8 +666,17 @@ func updateOrdersMasukStatus(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		}
+
+		// Keep the put-away bin in step with the lantai total
+		if err := syncStockLokasi(tx, barangID, lantaiID, lokasiIDNull.String, stockChange); err != nil {
+			tx.Rollback()
+			respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 	}
 
+	audit.record("update_status")
+
 	if err := tx.Commit(); err != nil {
 		respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error committing transaction")
 		return
@@ -592,6 +727,12 @@ func updateOrdersMasuk(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithErrorOrdersMasuk(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
 	// Begin transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -602,8 +743,8 @@ func updateOrdersMasuk(w http.ResponseWriter, r *http.Request) {
 	// Get current order data including lantai_id
 	var oldAmount, oldStatus int
 	var barangID, gudangID string
-	var lantaiIDNull sql.NullString
-	err = tx.QueryRow("SELECT orders_amount, orders_status, barang_id, gudang_id, lantai_id FROM orders_masuk WHERE orders_id = ?", ordersID).Scan(&oldAmount, &oldStatus, &barangID, &gudangID, &lantaiIDNull)
+	var lantaiIDNull, lokasiIDNull sql.NullString
+	err = tx.QueryRow("SELECT orders_amount, orders_status, barang_id, gudang_id, lantai_id, lokasi_id FROM orders_masuk WHERE orders_id = ?", ordersID).Scan(&oldAmount, &oldStatus, &barangID, &gudangID, &lantaiIDNull, &lokasiIDNull)
 	if err == sql.ErrNoRows {
 		tx.Rollback()
 		respondWithErrorOrdersMasuk(w, http.StatusNotFound, "Order not found")
@@ -620,7 +761,7 @@ func updateOrdersMasuk(w http.ResponseWriter, r *http.Request) {
 		lantaiID = lantaiIDNull.String
 	} else {
 		// Fallback: get first floor of the gudang
-		err = tx.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? ORDER BY lantai_no LIMIT 1", gudangID).Scan(&lantaiID)
+		err = tx.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1 ORDER BY lantai_no LIMIT 1", gudangID).Scan(&lantaiID)
 		if err != nil {
 			tx.Rollback()
 			respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error fetching lantai_id for gudang")
@@ -628,17 +769,26 @@ func updateOrdersMasuk(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	audit := startAudit(tx, r, "orders_masuk", ordersID)
+
 	// Update order
 	_, err = tx.Exec(`UPDATE orders_masuk 
-		SET orders_amount = ?, orders_value = ?, orders_deadline = ?, orders_pay_type = ?, orders_status = ? 
+		SET orders_amount = ?, orders_value = ?, orders_deadline = ?, orders_pay_type = ?, orders_status = ?,
+		    updated_by = ?, updated_at = NOW()
 		WHERE orders_id = ?`,
-		req.OrdersAmount, req.OrdersValue, req.OrdersDeadline, req.OrdersPayType, req.OrdersStatus, ordersID)
+		req.OrdersAmount, req.OrdersValue, req.OrdersDeadline, req.OrdersPayType, req.OrdersStatus, requestUserID(user), ordersID)
 	if err != nil {
 		tx.Rollback()
 		respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error updating order")
 		return
 	}
 
+	if err := hitungPajakOrdersMasuk(tx, ordersID); err != nil {
+		tx.Rollback()
+		respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	// Handle stock changes
 	// Calculate stock impact from status change
 	var stockChange int
@@ -702,8 +852,17 @@ func updateOrdersMasuk(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		}
+
+		// Keep the put-away bin in step with the lantai total
+		if err := syncStockLokasi(tx, barangID, lantaiID, lokasiIDNull.String, stockChange); err != nil {
+			tx.Rollback()
+			respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 	}
 
+	audit.record("update")
+
 	if err := tx.Commit(); err != nil {
 		respondWithErrorOrdersMasuk(w, http.StatusInternalServerError, "Error committing transaction")
 		return
@@ -27,6 +27,12 @@ type OrdersKeluar struct {
 	LogsStatus int    `json:"logs_status,omitempty"`
 	LogsDate   string `json:"logs_date,omitempty"`
 	LogsDesc   string `json:"logs_desc,omitempty"`
+	// Who recorded and last changed the order
+	CreatedBy     string `json:"created_by,omitempty"`
+	CreatedByNama string `json:"created_by_nama,omitempty"`
+	UpdatedBy     string `json:"updated_by,omitempty"`
+	UpdatedByNama string `json:"updated_by_nama,omitempty"`
+	UpdatedAt     string `json:"updated_at,omitempty"`
 }
 
 type CombinedOrderKeluarBatch struct {
@@ -97,6 +103,12 @@ func createBatchOrderKeluar(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithErrorOrdersOut(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
 	// Begin transaction for data consistency
 	tx, err := db.Begin()
 	if err != nil {
@@ -107,7 +119,7 @@ func createBatchOrderKeluar(w http.ResponseWriter, r *http.Request) {
 	// Validate all barang_id and lantai_id exist
 	for i, order := range batch.Orders {
 		var existingBarangID string
-		err = tx.QueryRow("SELECT barang_id FROM barang WHERE barang_id = ?", order.BarangID).Scan(&existingBarangID)
+		err = tx.QueryRow("SELECT barang_id FROM barang WHERE barang_id = ? AND deleted_at IS NULL", order.BarangID).Scan(&existingBarangID)
 		if err == sql.ErrNoRows {
 			tx.Rollback()
 			respondWithErrorOrdersOut(w, http.StatusNotFound, fmt.Sprintf("Barang with ID %s not found for order %d", order.BarangID, i+1))
@@ -171,7 +183,7 @@ func createBatchOrderKeluar(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Insert barang_logs with logs_status = 2 (Keluar)
-	logsStmt, err := tx.Prepare("INSERT INTO barang_logs (logs_id, logs_status, logs_date, logs_desc) VALUES (?, ?, ?, ?)")
+	logsStmt, err := tx.Prepare("INSERT INTO barang_logs (logs_id, logs_status, logs_date, logs_desc, created_by) VALUES (?, ?, ?, ?, ?)")
 	if err != nil {
 		tx.Rollback()
 		respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error preparing logs insert")
@@ -179,7 +191,7 @@ func createBatchOrderKeluar(w http.ResponseWriter, r *http.Request) {
 	}
 	defer logsStmt.Close()
 
-	_, err = logsStmt.Exec(newLogsID, 2, logsDate, batch.LogsDesc)
+	_, err = logsStmt.Exec(newLogsID, 2, logsDate, batch.LogsDesc, requestUserID(user))
 	if err != nil {
 		tx.Rollback()
 		respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error inserting barang_logs")
@@ -201,7 +213,7 @@ func createBatchOrderKeluar(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Prepare orders_keluar insert statement - now includes lantai_id
-	ordersStmt, err := tx.Prepare("INSERT INTO orders_keluar (orders_id, logs_id, barang_id, gudang_id, lantai_id, orders_amount, orders_status) VALUES (?, ?, ?, ?, ?, ?, ?)")
+	ordersStmt, err := tx.Prepare("INSERT INTO orders_keluar (orders_id, logs_id, barang_id, gudang_id, lantai_id, orders_amount, orders_status, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		tx.Rollback()
 		respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error preparing orders insert")
@@ -231,7 +243,7 @@ func createBatchOrderKeluar(w http.ResponseWriter, r *http.Request) {
 		} else {
 			// Legacy format: use gudang_id directly, get first lantai_id
 			gudangID = order.GudangID
-			err = tx.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? ORDER BY lantai_no LIMIT 1", gudangID).Scan(&lantaiID)
+			err = tx.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1 ORDER BY lantai_no LIMIT 1", gudangID).Scan(&lantaiID)
 			if err != nil {
 				tx.Rollback()
 				respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error fetching lantai_id from gudang_id")
@@ -239,8 +251,10 @@ func createBatchOrderKeluar(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		audit := startAudit(tx, r, "orders_keluar", newOrdersID)
+
 		// Insert into orders_keluar with both gudang_id (compatibility) and lantai_id (new)
-		_, err = ordersStmt.Exec(newOrdersID, newLogsID, order.BarangID, gudangID, lantaiID, order.OrdersAmount, batch.OrdersStatus)
+		_, err = ordersStmt.Exec(newOrdersID, newLogsID, order.BarangID, gudangID, lantaiID, order.OrdersAmount, batch.OrdersStatus, requestUserID(user))
 		if err != nil {
 			tx.Rollback()
 			respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error inserting order")
@@ -277,8 +291,17 @@ func createBatchOrderKeluar(w http.ResponseWriter, r *http.Request) {
 				respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error updating stock")
 				return
 			}
+
+			// Keep bin stock within the reduced lantai total
+			if err := trimStockLokasi(tx, order.BarangID, lantaiID); err != nil {
+				tx.Rollback()
+				respondWithErrorOrdersOut(w, http.StatusInternalServerError, err.Error())
+				return
+			}
 		}
 
+		audit.record("create")
+
 		createdOrders = append(createdOrders, map[string]interface{}{
 			"orders_id":     newOrdersID,
 			"barang_id":     order.BarangID,
@@ -301,6 +324,7 @@ func createBatchOrderKeluar(w http.ResponseWriter, r *http.Request) {
 		"logs_date":     logsDate,
 		"logs_desc":     batch.LogsDesc,
 		"orders_status": batch.OrdersStatus,
+		"created_by":    requestUserID(user),
 		"orders":        createdOrders,
 		"status":        "Created",
 		"message":       fmt.Sprintf("Successfully created barang keluar with %d items", len(createdOrders)),
@@ -316,44 +340,91 @@ func getOrdersKeluar(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	query := `
-		SELECT 
-			ok.orders_id, ok.logs_id, ok.barang_id, ok.gudang_id, 
-			ok.orders_amount, ok.orders_status,
-			b.barang_nama, br.brand_nama, g.gudang_nama,
-			bl.logs_status, bl.logs_date, bl.logs_desc
+	list, err := parseListQuery(r, ordersKeluarListSpec)
+	if err != nil {
+		respondWithErrorOrdersOut(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	from := `
 		FROM orders_keluar ok
 		JOIN barang_logs bl ON ok.logs_id = bl.logs_id
 		JOIN barang b ON ok.barang_id = b.barang_id
 		JOIN brand br ON b.brand_id = br.brand_id
 		JOIN list_gudang g ON ok.gudang_id = g.gudang_id
-		ORDER BY ok.orders_id DESC
-	`
+		LEFT JOIN users uc ON ok.created_by = uc.users_id
+		LEFT JOIN users uu ON ok.updated_by = uu.users_id`
+	query := `
+		SELECT 
+			ok.orders_id, ok.logs_id, ok.barang_id, ok.gudang_id, 
+			ok.orders_amount, ok.orders_status,
+			b.barang_nama, br.brand_nama, g.gudang_nama,
+			bl.logs_status, bl.logs_date, bl.logs_desc,
+			ok.created_by, uc.users_nama, ok.updated_by, uu.users_nama, ok.updated_at` +
+		from + list.whereSQL() + " ORDER BY " + list.orderBy
+	limit, limitArgs := list.limitSQL()
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query+limit, append(list.args, limitArgs...)...)
 	if err != nil {
 		respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error querying orders")
 		return
 	}
 	defer rows.Close()
 
-	var orders []OrdersKeluar
+	orders := []OrdersKeluar{}
 	for rows.Next() {
 		var order OrdersKeluar
+		var createdBy, createdByNama, updatedBy, updatedByNama, updatedAt sql.NullString
 		err := rows.Scan(
 			&order.OrdersID, &order.LogsID, &order.BarangID, &order.GudangID,
 			&order.OrdersAmount, &order.OrdersStatus,
 			&order.BarangNama, &order.BrandNama, &order.GudangNama,
 			&order.LogsStatus, &order.LogsDate, &order.LogsDesc,
+			&createdBy, &createdByNama, &updatedBy, &updatedByNama, &updatedAt,
 		)
 		if err != nil {
 			respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error scanning order")
 			return
 		}
+		order.CreatedBy = createdBy.String
+		order.CreatedByNama = createdByNama.String
+		order.UpdatedBy = updatedBy.String
+		order.UpdatedByNama = updatedByNama.String
+		order.UpdatedAt = updatedAt.String
 		orders = append(orders, order)
 	}
 
-	respondWithJSONOrdersOut(w, orders)
+	total, err := list.total(db, "COUNT(*)", from, len(orders))
+	if err != nil {
+		respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error counting orders")
+		return
+	}
+	respondWithList(w, list, total, orders)
+}
+
+// ordersKeluarListSpec is the list contract of getOrdersKeluar
+var ordersKeluarListSpec = listSpec{
+	filters: []listFilter{
+		{"created_by", "ok.created_by = ?"},
+		{"date", "bl.logs_date = ?"},
+		{"date_from", "bl.logs_date >= ?"},
+		{"date_to", "bl.logs_date <= ?"},
+		{"brand", "br.brand_nama = ?"},
+		{"gudang", "g.gudang_id = ?"},
+		{"barang", "ok.barang_id = ?"},
+		{"status", "ok.orders_status = ?"},
+	},
+	search: []string{"ok.orders_id", "ok.logs_id", "b.barang_nama", "bl.logs_desc"},
+	sorts: map[string]string{
+		"id":     "ok.orders_id",
+		"date":   "bl.logs_date",
+		"barang": "b.barang_nama",
+		"amount": "ok.orders_amount",
+		"status": "ok.orders_status",
+	},
+	sortDefault:  "id",
+	orderDefault: "desc",
+	key:          "ok.orders_id",
 }
 
 // Update orders_keluar status
@@ -382,6 +453,12 @@ func updateOrdersKeluarStatus(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithErrorOrdersOut(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error starting transaction")
@@ -408,7 +485,7 @@ func updateOrdersKeluarStatus(w http.ResponseWriter, r *http.Request) {
 	if lantaiID.Valid && lantaiID.String != "" {
 		finalLantaiID = lantaiID.String
 	} else {
-		err = tx.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? ORDER BY lantai_no LIMIT 1", gudangID).Scan(&finalLantaiID)
+		err = tx.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1 ORDER BY lantai_no LIMIT 1", gudangID).Scan(&finalLantaiID)
 		if err != nil {
 			tx.Rollback()
 			respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error fetching lantai_id")
@@ -426,8 +503,11 @@ func updateOrdersKeluarStatus(w http.ResponseWriter, r *http.Request) {
 		stockChange = ordersAmount
 	}
 
+	audit := startAudit(tx, r, "orders_keluar", ordersID)
+
 	// Update order status
-	_, err = tx.Exec("UPDATE orders_keluar SET orders_status = ? WHERE orders_id = ?", req.OrdersStatus, ordersID)
+	_, err = tx.Exec("UPDATE orders_keluar SET orders_status = ?, updated_by = ?, updated_at = NOW() WHERE orders_id = ?",
+		req.OrdersStatus, requestUserID(user), ordersID)
 	if err != nil {
 		tx.Rollback()
 		respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error updating order status")
@@ -457,8 +537,17 @@ func updateOrdersKeluarStatus(w http.ResponseWriter, r *http.Request) {
 			respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error updating stock")
 			return
 		}
+
+		// Keep bin stock within the reduced lantai total
+		if err := syncStockLokasi(tx, barangID, finalLantaiID, "", stockChange); err != nil {
+			tx.Rollback()
+			respondWithErrorOrdersOut(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 	}
 
+	audit.record("update_status")
+
 	if err := tx.Commit(); err != nil {
 		respondWithErrorOrdersOut(w, http.StatusInternalServerError, "Error committing transaction")
 		return
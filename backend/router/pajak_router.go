@@ -0,0 +1,434 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"src/database"
+	"strconv"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// PajakSetting is the PPN configuration (single row in pajak_setting)
+type PajakSetting struct {
+	Aktif                bool    `json:"pajak_aktif"`
+	PPNPersen            float64 `json:"ppn_persen"`
+	HargaJualTermasukPPN bool    `json:"harga_jual_termasuk_ppn"`
+	HargaBeliTermasukPPN bool    `json:"harga_beli_termasuk_ppn"`
+	Updated              string  `json:"setting_updated,omitempty"`
+}
+
+type PajakBarangRequest struct {
+	PPNPersen  *float64 `json:"barang_ppn_persen"` // null: use the default rate
+	BebasPajak bool     `json:"barang_bebas_pajak"`
+}
+
+type PajakCustomerRequest struct {
+	BebasPajak bool `json:"customer_bebas_pajak"`
+}
+
+// PPNDokumen is one sale or purchase in the monthly PPN summary
+type PPNDokumen struct {
+	ID      string `json:"id"`
+	No      string `json:"no"`
+	Date    string `json:"date"`
+	Partner string `json:"partner"`
+	DPP     int    `json:"dpp"`
+	PPN     int    `json:"ppn"`
+}
+
+// Helper function to read the PPN configuration; without a row PPN is off
+func getPajakSetting(q sqlExecutor) (PajakSetting, error) {
+	var s PajakSetting
+	var updated sql.NullString
+	err := q.QueryRow(`SELECT pajak_aktif, ppn_persen, harga_jual_termasuk_ppn, harga_beli_termasuk_ppn, setting_updated
+		FROM pajak_setting WHERE setting_id = 1`).
+		Scan(&s.Aktif, &s.PPNPersen, &s.HargaJualTermasukPPN, &s.HargaBeliTermasukPPN, &updated)
+	if err == sql.ErrNoRows {
+		return PajakSetting{}, nil
+	} else if err != nil {
+		return s, fmt.Errorf("error reading pajak setting: %v", err)
+	}
+	s.Updated = updated.String
+	return s, nil
+}
+
+// hitungPPN splits a line value into DPP and PPN. Inclusive values already
+// contain the tax; exclusive values get it added. Returns dpp, ppn, total.
+func hitungPPN(nilai int, pct float64, inclusive bool) (int, int, int) {
+	if pct <= 0 {
+		return nilai, 0, nilai
+	}
+	if inclusive {
+		dpp := int(math.Round(float64(nilai) * 100 / (100 + pct)))
+		return dpp, nilai - dpp, nilai
+	}
+	ppn := int(math.Round(float64(nilai) * pct / 100))
+	return nilai, ppn, nilai + ppn
+}
+
+// Helper function to pick the rate of one line: exempt barang or customer pay
+// nothing, otherwise the barang rate or the default
+func tarifPPN(s PajakSetting, barangPersen sql.NullFloat64, barangBebas, customerBebas bool) float64 {
+	if !s.Aktif || barangBebas || customerBebas {
+		return 0
+	}
+	if barangPersen.Valid {
+		return barangPersen.Float64
+	}
+	return s.PPNPersen
+}
+
+// pajakLine is one sale line: its value after promotions, the barang tax
+// fields and, once calculated, its rate, DPP and PPN
+type pajakLine struct {
+	id           string
+	nilai        int
+	barangPersen sql.NullFloat64
+	barangBebas  bool
+	pct          float64
+	dpp, ppn     int
+}
+
+// hitungPajakLines fills in the tax of every line and returns the sale's
+// DPP, PPN and total. Each line is rounded on its own, as on the invoice.
+func hitungPajakLines(s PajakSetting, customerBebas bool, lines []pajakLine) (int, int, int) {
+	totalDPP, totalPPN, total := 0, 0, 0
+	for i := range lines {
+		l := &lines[i]
+		l.pct = tarifPPN(s, l.barangPersen, l.barangBebas, customerBebas)
+		var lineTotal int
+		l.dpp, l.ppn, lineTotal = hitungPPN(l.nilai, l.pct, s.HargaJualTermasukPPN)
+		totalDPP += l.dpp
+		totalPPN += l.ppn
+		total += lineTotal
+	}
+	return totalDPP, totalPPN, total
+}
+
+// hitungPajakSales recalculates PPN of every line of a sale and the sale
+// totals (sales_dpp, sales_ppn, sales_total = DPP + PPN)
+func hitungPajakSales(q sqlExecutor, salesID string) error {
+	setting, err := getPajakSetting(q)
+	if err != nil {
+		return err
+	}
+
+	var customerBebas bool
+	err = q.QueryRow(`SELECT COALESCE(c.customer_bebas_pajak, 0) FROM sales s
+		LEFT JOIN customer c ON s.customer_id = c.customer_id WHERE s.sales_id = ?`, salesID).Scan(&customerBebas)
+	if err != nil {
+		return fmt.Errorf("error reading sales customer: %v", err)
+	}
+
+	rows, err := q.Query(`SELECT si.sale_items_id, si.sale_items_amount * si.sale_value - si.sale_promo_potongan,
+			b.barang_ppn_persen, COALESCE(b.barang_bebas_pajak, 0)
+		FROM sale_items si LEFT JOIN barang b ON si.barang_id = b.barang_id
+		WHERE si.sales_id = ?`, salesID)
+	if err != nil {
+		return fmt.Errorf("error fetching sale items: %v", err)
+	}
+	var lines []pajakLine
+	for rows.Next() {
+		var l pajakLine
+		if err := rows.Scan(&l.id, &l.nilai, &l.barangPersen, &l.barangBebas); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning sale item: %v", err)
+		}
+		lines = append(lines, l)
+	}
+	rows.Close()
+	totalDPP, totalPPN, total := hitungPajakLines(setting, customerBebas, lines)
+
+	for _, l := range lines {
+		_, err := q.Exec("UPDATE sale_items SET sale_tax_persen = ?, sale_tax_dpp = ?, sale_tax_amount = ? WHERE sale_items_id = ?",
+			l.pct, l.dpp, l.ppn, l.id)
+		if err != nil {
+			return fmt.Errorf("error updating sale item tax: %v", err)
+		}
+	}
+	_, err = q.Exec("UPDATE sales SET sales_dpp = ?, sales_ppn = ?, sales_total = ? WHERE sales_id = ?", totalDPP, totalPPN, total, salesID)
+	if err != nil {
+		return fmt.Errorf("error updating sales total: %v", err)
+	}
+	return nil
+}
+
+// hitungPajakOrdersMasuk calculates PPN of one purchase line
+func hitungPajakOrdersMasuk(q sqlExecutor, ordersID string) error {
+	setting, err := getPajakSetting(q)
+	if err != nil {
+		return err
+	}
+
+	var amount, value int
+	var barangPersen sql.NullFloat64
+	var barangBebas bool
+	err = q.QueryRow(`SELECT om.orders_amount, om.orders_value, b.barang_ppn_persen, COALESCE(b.barang_bebas_pajak, 0)
+		FROM orders_masuk om LEFT JOIN barang b ON om.barang_id = b.barang_id
+		WHERE om.orders_id = ?`, ordersID).Scan(&amount, &value, &barangPersen, &barangBebas)
+	if err != nil {
+		return fmt.Errorf("error reading orders_masuk: %v", err)
+	}
+
+	pct := tarifPPN(setting, barangPersen, barangBebas, false)
+	dpp, ppn, _ := hitungPPN(amount*value, pct, setting.HargaBeliTermasukPPN)
+	_, err = q.Exec("UPDATE orders_masuk SET orders_tax_persen = ?, orders_tax_dpp = ?, orders_tax_amount = ? WHERE orders_id = ?",
+		pct, dpp, ppn, ordersID)
+	if err != nil {
+		return fmt.Errorf("error updating orders_masuk tax: %v", err)
+	}
+	return nil
+}
+
+func getPajakSettingHandler(w http.ResponseWriter, r *http.Request) {
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	setting, err := getPajakSetting(db)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, setting)
+}
+
+// updatePajakSetting changes the PPN configuration. Existing lines keep their
+// tax until their document is edited.
+func updatePajakSetting(w http.ResponseWriter, r *http.Request) {
+	var req PajakSetting
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.PPNPersen < 0 || req.PPNPersen > 100 {
+		respondWithError(w, http.StatusBadRequest, "ppn_persen must be between 0 and 100")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if user == nil || user.UsersLevel != 1 {
+		respondWithError(w, http.StatusForbidden, "Only admin can change tax settings")
+		return
+	}
+
+	audit := startAudit(db, r, "pajak_setting", "1")
+	_, err = db.Exec(`INSERT INTO pajak_setting (setting_id, pajak_aktif, ppn_persen, harga_jual_termasuk_ppn, harga_beli_termasuk_ppn, setting_updated)
+		VALUES (1, ?, ?, ?, ?, NOW())
+		ON DUPLICATE KEY UPDATE pajak_aktif = VALUES(pajak_aktif), ppn_persen = VALUES(ppn_persen),
+			harga_jual_termasuk_ppn = VALUES(harga_jual_termasuk_ppn), harga_beli_termasuk_ppn = VALUES(harga_beli_termasuk_ppn),
+			setting_updated = NOW()`,
+		req.Aktif, req.PPNPersen, req.HargaJualTermasukPPN, req.HargaBeliTermasukPPN)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	audit.record("update")
+
+	setting, err := getPajakSetting(db)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, setting)
+}
+
+func updatePajakBarang(w http.ResponseWriter, r *http.Request) {
+	barangID := mux.Vars(r)["barang_id"]
+
+	var req PajakBarangRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.PPNPersen != nil && (*req.PPNPersen < 0 || *req.PPNPersen > 100) {
+		respondWithError(w, http.StatusBadRequest, "barang_ppn_persen must be between 0 and 100")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	var ppnPersen interface{}
+	if req.PPNPersen != nil {
+		ppnPersen = *req.PPNPersen
+	}
+	audit := startAudit(db, r, "barang", barangID)
+	res, err := db.Exec("UPDATE barang SET barang_ppn_persen = ?, barang_bebas_pajak = ? WHERE barang_id = ? AND deleted_at IS NULL",
+		ppnPersen, req.BebasPajak, barangID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		var exists bool
+		db.QueryRow("SELECT EXISTS(SELECT 1 FROM barang WHERE barang_id = ? AND deleted_at IS NULL)", barangID).Scan(&exists)
+		if !exists {
+			respondWithError(w, http.StatusNotFound, "Barang not found")
+			return
+		}
+	}
+	audit.record("update_pajak")
+
+	respondWithJSON(w, map[string]interface{}{
+		"barang_id":          barangID,
+		"barang_ppn_persen":  req.PPNPersen,
+		"barang_bebas_pajak": req.BebasPajak,
+	})
+}
+
+func updatePajakCustomer(w http.ResponseWriter, r *http.Request) {
+	customerID := mux.Vars(r)["customer_id"]
+
+	var req PajakCustomerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	audit := startAudit(db, r, "customer", customerID)
+	res, err := db.Exec("UPDATE customer SET customer_bebas_pajak = ? WHERE customer_id = ? AND deleted_at IS NULL", req.BebasPajak, customerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		var exists bool
+		db.QueryRow("SELECT EXISTS(SELECT 1 FROM customer WHERE customer_id = ? AND deleted_at IS NULL)", customerID).Scan(&exists)
+		if !exists {
+			respondWithError(w, http.StatusNotFound, "Customer not found")
+			return
+		}
+	}
+	audit.record("update_pajak")
+
+	respondWithJSON(w, map[string]interface{}{
+		"customer_id":          customerID,
+		"customer_bebas_pajak": req.BebasPajak,
+	})
+}
+
+// getLaporanPPN summarises output tax (PPN keluaran, sales) and input tax (PPN
+// masukan, incoming orders) of one month for the monthly filing
+func getLaporanPPN(w http.ResponseWriter, r *http.Request) {
+	year, errYear := strconv.Atoi(r.URL.Query().Get("year"))
+	month, errMonth := strconv.Atoi(r.URL.Query().Get("month"))
+	if errYear != nil || errMonth != nil || month < 1 || month > 12 {
+		respondWithError(w, http.StatusBadRequest, "year and month (1-12) are required")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	// Drafts and cancelled sales are not taxable deliveries
+	keluaran, err := queryPPNDokumen(db, `
+		SELECT s.sales_id, COALESCE(s.sales_invoice_no, ''), DATE_FORMAT(s.sales_date, '%Y-%m-%d'), COALESCE(c.customer_nama, ''),
+		       s.sales_dpp, s.sales_ppn
+		FROM sales s
+		LEFT JOIN customer c ON s.customer_id = c.customer_id
+		WHERE s.sales_status NOT IN (0, 5) AND YEAR(s.sales_date) = ? AND MONTH(s.sales_date) = ?
+		ORDER BY s.sales_date, s.sales_id`, year, month)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	masukan, err := queryPPNDokumen(db, `
+		SELECT bl.logs_id, COALESCE(bl.logs_po_no, ''), DATE_FORMAT(bl.logs_date, '%Y-%m-%d'), COALESCE(bl.logs_desc, ''),
+		       COALESCE(SUM(om.orders_tax_dpp), 0), COALESCE(SUM(om.orders_tax_amount), 0)
+		FROM barang_logs bl
+		JOIN orders_masuk om ON om.logs_id = bl.logs_id
+		WHERE bl.logs_status = 1 AND YEAR(bl.logs_date) = ? AND MONTH(bl.logs_date) = ?
+		GROUP BY bl.logs_id, bl.logs_po_no, bl.logs_date, bl.logs_desc
+		ORDER BY bl.logs_date, bl.logs_id`, year, month)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	sum := func(docs []PPNDokumen) (int, int) {
+		dpp, ppn := 0, 0
+		for _, d := range docs {
+			dpp += d.DPP
+			ppn += d.PPN
+		}
+		return dpp, ppn
+	}
+	dppKeluaran, ppnKeluaran := sum(keluaran)
+	dppMasukan, ppnMasukan := sum(masukan)
+
+	respondWithJSON(w, map[string]interface{}{
+		"year":             year,
+		"month":            month,
+		"dpp_keluaran":     dppKeluaran,
+		"ppn_keluaran":     ppnKeluaran,
+		"dpp_masukan":      dppMasukan,
+		"ppn_masukan":      ppnMasukan,
+		"ppn_kurang_bayar": ppnKeluaran - ppnMasukan, // negative: lebih bayar
+		"dokumen_keluaran": keluaran,
+		"dokumen_masukan":  masukan,
+	})
+}
+
+// Helper function to run one side of the PPN summary
+func queryPPNDokumen(q sqlExecutor, query string, args ...interface{}) ([]PPNDokumen, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []PPNDokumen{}
+	for rows.Next() {
+		var d PPNDokumen
+		if err := rows.Scan(&d.ID, &d.No, &d.Date, &d.Partner, &d.DPP, &d.PPN); err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// SetupPajakRoutes sets up all tax-related routes
+func SetupPajakRoutes(router *mux.Router) {
+	router.HandleFunc("/getpajaksetting", getPajakSettingHandler).Methods("GET")
+	router.HandleFunc("/updatepajaksetting", updatePajakSetting).Methods("PUT")
+	router.HandleFunc("/updatepajakbarang/{barang_id}", updatePajakBarang).Methods("PUT")
+	router.HandleFunc("/updatepajakcustomer/{customer_id}", updatePajakCustomer).Methods("PUT")
+	router.HandleFunc("/getlaporanppn", getLaporanPPN).Methods("GET")
+}
@@ -0,0 +1,106 @@
+package router
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestHitungPajakLines(t *testing.T) {
+	ppn11 := PajakSetting{Aktif: true, PPNPersen: 11}
+	ppn11Inklusif := PajakSetting{Aktif: true, PPNPersen: 11, HargaJualTermasukPPN: true}
+	tarif := func(pct float64) sql.NullFloat64 { return sql.NullFloat64{Float64: pct, Valid: true} }
+
+	type want struct {
+		pct      float64
+		dpp, ppn int
+	}
+	tests := []struct {
+		name          string
+		setting       PajakSetting
+		customerBebas bool
+		lines         []pajakLine
+		want          []want
+		dpp, ppn      int
+		total         int
+	}{
+		{
+			name:    "exclusive adds PPN",
+			setting: ppn11,
+			lines:   []pajakLine{{nilai: 10000}},
+			want:    []want{{11, 10000, 1100}},
+			dpp:     10000, ppn: 1100, total: 11100,
+		},
+		{
+			name:    "exclusive rounds each line",
+			setting: ppn11,
+			lines:   []pajakLine{{nilai: 999}, {nilai: 999}},
+			want:    []want{{11, 999, 110}, {11, 999, 110}},
+			dpp:     1998, ppn: 220, total: 2218,
+		},
+		{
+			name:    "exclusive rounds half up",
+			setting: ppn11,
+			lines:   []pajakLine{{nilai: 50}},
+			want:    []want{{11, 50, 6}},
+			dpp:     50, ppn: 6, total: 56,
+		},
+		{
+			name:    "inclusive splits the price",
+			setting: ppn11Inklusif,
+			lines:   []pajakLine{{nilai: 11100}},
+			want:    []want{{11, 10000, 1100}},
+			dpp:     10000, ppn: 1100, total: 11100,
+		},
+		{
+			name:    "inclusive rounds DPP and keeps the total",
+			setting: ppn11Inklusif,
+			lines:   []pajakLine{{nilai: 1000}},
+			want:    []want{{11, 901, 99}},
+			dpp:     901, ppn: 99, total: 1000,
+		},
+		{
+			name:    "exempt barang pays nothing",
+			setting: ppn11,
+			lines:   []pajakLine{{nilai: 10000, barangBebas: true}, {nilai: 10000}},
+			want:    []want{{0, 10000, 0}, {11, 10000, 1100}},
+			dpp:     20000, ppn: 1100, total: 21100,
+		},
+		{
+			name:          "exempt customer pays nothing",
+			setting:       ppn11,
+			customerBebas: true,
+			lines:         []pajakLine{{nilai: 10000}, {nilai: 5000, barangPersen: tarif(12)}},
+			want:          []want{{0, 10000, 0}, {0, 5000, 0}},
+			dpp:           15000, ppn: 0, total: 15000,
+		},
+		{
+			name:    "barang rate overrides the default",
+			setting: ppn11,
+			lines:   []pajakLine{{nilai: 10000, barangPersen: tarif(12)}, {nilai: 10000, barangPersen: tarif(0)}},
+			want:    []want{{12, 10000, 1200}, {0, 10000, 0}},
+			dpp:     20000, ppn: 1200, total: 21200,
+		},
+		{
+			name:    "PPN switched off",
+			setting: PajakSetting{PPNPersen: 11},
+			lines:   []pajakLine{{nilai: 10000}},
+			want:    []want{{0, 10000, 0}},
+			dpp:     10000, ppn: 0, total: 10000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dpp, ppn, total := hitungPajakLines(tt.setting, tt.customerBebas, tt.lines)
+			if dpp != tt.dpp || ppn != tt.ppn || total != tt.total {
+				t.Errorf("totals = %d, %d, %d; want %d, %d, %d", dpp, ppn, total, tt.dpp, tt.ppn, tt.total)
+			}
+			for i, w := range tt.want {
+				l := tt.lines[i]
+				if l.pct != w.pct || l.dpp != w.dpp || l.ppn != w.ppn {
+					t.Errorf("line %d = %v%%, %d, %d; want %v%%, %d, %d", i+1, l.pct, l.dpp, l.ppn, w.pct, w.dpp, w.ppn)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Minimal PDF writer for server-rendered documents. It only knows the two
+// standard Helvetica fonts (no embedding), text, lines and grey boxes, which
+// is all invoices, receipts and delivery notes need.
+
+// Page sizes in points (1 mm = 72/25.4 pt)
+const (
+	pdfMM       = 72.0 / 25.4
+	pdfA4Width  = 595.28
+	pdfA4Height = 841.89
+)
+
+// Glyph widths (per 1000 em) of Helvetica and Helvetica-Bold for ASCII 32..126
+var pdfHelveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var pdfHelveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+type pdfPage struct {
+	width, height float64
+	content       bytes.Buffer
+}
+
+type pdfDocument struct {
+	pages []*pdfPage
+	page  *pdfPage
+}
+
+// AddPage starts a new page of the given size in points
+func (d *pdfDocument) AddPage(width, height float64) {
+	d.page = &pdfPage{width: width, height: height}
+	d.pages = append(d.pages, d.page)
+}
+
+// pdfEncode converts text to WinAnsi bytes; runes outside Latin-1 become '?'
+func pdfEncode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			out = append(out, ' ')
+		case r < 32:
+		case r < 127 || (r >= 160 && r <= 255):
+			out = append(out, byte(r))
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// pdfTextWidth measures text in points
+func pdfTextWidth(s string, size float64, bold bool) float64 {
+	widths := &pdfHelveticaWidths
+	if bold {
+		widths = &pdfHelveticaBoldWidths
+	}
+	total := 0
+	for _, c := range pdfEncode(s) {
+		if c >= 32 && c <= 126 {
+			total += widths[c-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// pdfWrap breaks text into lines no wider than maxWidth
+func pdfWrap(s string, size float64, bold bool, maxWidth float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && pdfTextWidth(candidate, size, bold) > maxWidth {
+			lines = append(lines, line)
+			candidate = word
+		}
+		// Hard-break words that do not fit on a line of their own
+		for pdfTextWidth(candidate, size, bold) > maxWidth && len(candidate) > 1 {
+			cut := len(candidate) - 1
+			for cut > 1 && pdfTextWidth(candidate[:cut], size, bold) > maxWidth {
+				cut--
+			}
+			lines = append(lines, candidate[:cut])
+			candidate = candidate[cut:]
+		}
+		line = candidate
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Text draws text with its baseline at y, measured from the top of the page
+func (d *pdfDocument) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	var escaped bytes.Buffer
+	for _, c := range pdfEncode(s) {
+		if c == '(' || c == ')' || c == '\\' {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteByte(c)
+	}
+	fmt.Fprintf(&d.page.content, "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, d.page.height-y, escaped.Bytes())
+}
+
+// TextRight draws text that ends at x
+func (d *pdfDocument) TextRight(x, y, size float64, bold bool, s string) {
+	d.Text(x-pdfTextWidth(s, size, bold), y, size, bold, s)
+}
+
+// TextCenter draws text centred on x
+func (d *pdfDocument) TextCenter(x, y, size float64, bold bool, s string) {
+	d.Text(x-pdfTextWidth(s, size, bold)/2, y, size, bold, s)
+}
+
+// Line draws a line between two points measured from the top of the page
+func (d *pdfDocument) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&d.page.content, "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, d.page.height-y1, x2, d.page.height-y2)
+}
+
+// Rect draws a box whose top-left corner is at x, y. gray is the fill level
+// (0 black .. 1 white); a negative gray only strokes the border.
+func (d *pdfDocument) Rect(x, y, w, h, gray float64) {
+	if gray >= 0 {
+		fmt.Fprintf(&d.page.content, "%.2f g %.2f %.2f %.2f %.2f re f 0 g\n", gray, x, d.page.height-y-h, w, h)
+	}
+	fmt.Fprintf(&d.page.content, "0.5 w %.2f %.2f %.2f %.2f re S\n", x, d.page.height-y-h, w, h)
+}
+
+// Bytes serialises the document
+func (d *pdfDocument) Bytes() []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1 catalog, 2 page tree, 3-4 fonts, then a page and content object per page
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, p := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			p.width, p.height, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
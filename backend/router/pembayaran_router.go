@@ -0,0 +1,427 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"src/database"
+	"strconv"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// Payment methods of a payment line
+const (
+	bayarTunai    = "tunai"
+	bayarTransfer = "transfer"
+	bayarQRIS     = "qris"
+	bayarEwallet  = "ewallet"
+	bayarKredit   = "kredit"
+)
+
+var bayarMetodeNama = map[string]string{
+	bayarTunai:    "Tunai",
+	bayarTransfer: "Transfer",
+	bayarQRIS:     "QRIS",
+	bayarEwallet:  "E-Wallet",
+	bayarKredit:   "Kredit",
+}
+
+// SalesPembayaran is one payment line of a sale
+type SalesPembayaran struct {
+	BayarID    string `json:"bayar_id"`
+	SalesID    string `json:"sales_id"`
+	ShiftID    string `json:"shift_id,omitempty"` // kasir shift of a tunai line
+	Metode     string `json:"bayar_metode"`
+	MetodeNama string `json:"bayar_metode_nama"`
+	Amount     int    `json:"bayar_amount"`
+	Diterima   int    `json:"bayar_diterima"`
+	Kembalian  int    `json:"bayar_kembalian"`
+	Bank       string `json:"bayar_bank,omitempty"`
+	Rekening   string `json:"bayar_rekening,omitempty"`
+	Referensi  string `json:"bayar_referensi,omitempty"`
+	Date       string `json:"bayar_date"`
+	UsersID    string `json:"users_id,omitempty"`
+}
+
+type PembayaranRequest struct {
+	Metode    string `json:"bayar_metode"`
+	Amount    int    `json:"bayar_amount"`             // part of sales_total settled by this line
+	Diterima  int    `json:"bayar_diterima,omitempty"` // tunai: cash handed over, defaults to bayar_amount
+	Bank      string `json:"bayar_bank,omitempty"`
+	Rekening  string `json:"bayar_rekening,omitempty"`
+	Referensi string `json:"bayar_referensi,omitempty"`
+}
+
+type SalesPembayaranRequest struct {
+	Pembayaran []PembayaranRequest `json:"pembayaran"`
+	KreditSisa bool                `json:"kredit_sisa"` // book what is not paid as kredit
+}
+
+// buildPembayaran checks payment lines against the sale total. The lines must
+// settle the total exactly; a shortfall is only accepted as a kredit line,
+// either given explicitly or added with kreditSisa. Only cash can be overpaid,
+// through bayar_diterima, and the difference is given back as change.
+func buildPembayaran(total int, lines []PembayaranRequest, kreditSisa bool) ([]PembayaranRequest, error) {
+	var out []PembayaranRequest
+	sum := 0
+	kredit := -1
+	for i, line := range lines {
+		line.Metode = strings.ToLower(strings.TrimSpace(line.Metode))
+		line.Bank = strings.TrimSpace(line.Bank)
+		line.Rekening = strings.TrimSpace(line.Rekening)
+		line.Referensi = strings.TrimSpace(line.Referensi)
+		if _, ok := bayarMetodeNama[line.Metode]; !ok {
+			return nil, fmt.Errorf("pembayaran %d: bayar_metode must be tunai, transfer, qris, ewallet or kredit", i+1)
+		}
+		if line.Amount <= 0 {
+			return nil, fmt.Errorf("pembayaran %d: bayar_amount must be greater than 0", i+1)
+		}
+		switch line.Metode {
+		case bayarTunai:
+			if line.Diterima == 0 {
+				line.Diterima = line.Amount
+			}
+			if line.Diterima < line.Amount {
+				return nil, fmt.Errorf("pembayaran %d: bayar_diterima is less than bayar_amount", i+1)
+			}
+		case bayarTransfer:
+			if line.Bank == "" || line.Rekening == "" {
+				return nil, fmt.Errorf("pembayaran %d: a transfer needs bayar_bank and bayar_rekening", i+1)
+			}
+		case bayarQRIS, bayarEwallet:
+			if line.Referensi == "" {
+				return nil, fmt.Errorf("pembayaran %d: %s needs bayar_referensi", i+1, bayarMetodeNama[line.Metode])
+			}
+		case bayarKredit:
+			if kredit >= 0 {
+				return nil, fmt.Errorf("pembayaran %d: only one kredit line is allowed", i+1)
+			}
+			kredit = len(out)
+		}
+		if line.Metode != bayarTunai && line.Diterima != 0 {
+			return nil, fmt.Errorf("pembayaran %d: bayar_diterima is only used for tunai", i+1)
+		}
+		sum += line.Amount
+		out = append(out, line)
+	}
+
+	if sum > total {
+		return nil, fmt.Errorf("payments of %d exceed sales_total %d; give change on cash through bayar_diterima", sum, total)
+	}
+	if sum < total {
+		if !kreditSisa {
+			return nil, fmt.Errorf("payments of %d do not cover sales_total %d; book the remaining %d as kredit", sum, total, total-sum)
+		}
+		if kredit >= 0 {
+			out[kredit].Amount += total - sum
+		} else {
+			out = append(out, PembayaranRequest{Metode: bayarKredit, Amount: total - sum})
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("at least one payment line is required")
+	}
+	return out, nil
+}
+
+// pembayaranSummaryCode is the sales_payment code that sums up payment lines
+func pembayaranSummaryCode(lines []PembayaranRequest) string {
+	allTunai := true
+	for _, line := range lines {
+		if line.Metode == bayarKredit {
+			return "3"
+		}
+		if line.Metode != bayarTunai {
+			allTunai = false
+		}
+	}
+	if allTunai {
+		return "1"
+	}
+	return "2"
+}
+
+// savePembayaran replaces the payment lines of a sale and updates its
+// sales_payment summary code
+func savePembayaran(q sqlExecutor, salesID string, lines []PembayaranRequest, user *UserData) error {
+	// Cash already counted at the close of a shift cannot be rebooked
+	var closed int
+	err := q.QueryRow(`SELECT COUNT(*) FROM sales_pembayaran p JOIN kasir_shift k ON p.shift_id = k.shift_id
+		WHERE p.sales_id = ? AND k.shift_status = ?`, salesID, shiftTutup).Scan(&closed)
+	if err != nil {
+		return fmt.Errorf("error reading payments: %v", err)
+	}
+	if closed > 0 {
+		return fmt.Errorf("%w: the cash of this sale was counted in a closed shift", errKasirShift)
+	}
+
+	// Cash goes into the drawer of the cashier's open shift
+	var shiftID interface{}
+	for _, line := range lines {
+		if line.Metode == bayarTunai {
+			if user == nil {
+				return errKasirUser
+			}
+			id, err := openShiftID(q, user.UsersID)
+			if err != nil {
+				return err
+			}
+			shiftID = id
+			break
+		}
+	}
+
+	if _, err := q.Exec("DELETE FROM sales_pembayaran WHERE sales_id = ?", salesID); err != nil {
+		return fmt.Errorf("error removing payments: %v", err)
+	}
+
+	var lastID string
+	err = q.QueryRow("SELECT bayar_id FROM sales_pembayaran ORDER BY bayar_id DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error fetching last bayar_id: %v", err)
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:]) // "SP_0000012" -> "0000012"
+		nextNum = n + 1
+	}
+
+	for _, line := range lines {
+		kembalian := 0
+		var lineShift interface{}
+		if line.Metode == bayarTunai {
+			kembalian = line.Diterima - line.Amount
+			lineShift = shiftID
+		}
+		_, err := q.Exec(`INSERT INTO sales_pembayaran (bayar_id, sales_id, shift_id, bayar_metode, bayar_amount, bayar_diterima,
+			bayar_kembalian, bayar_bank, bayar_rekening, bayar_referensi, bayar_date, users_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), ?)`,
+			fmt.Sprintf("SP_%07d", nextNum), salesID, lineShift, line.Metode, line.Amount, line.Diterima, kembalian,
+			processNullableStringValue(line.Bank), processNullableStringValue(line.Rekening),
+			processNullableStringValue(line.Referensi), requestUserID(user))
+		if err != nil {
+			return fmt.Errorf("error saving payment: %v", err)
+		}
+		nextNum++
+	}
+
+	_, err = q.Exec("UPDATE sales SET sales_payment = ?, updated_by = ?, updated_at = NOW() WHERE sales_id = ?",
+		pembayaranSummaryCode(lines), requestUserID(user), salesID)
+	if err != nil {
+		return fmt.Errorf("error updating sales_payment: %v", err)
+	}
+	return nil
+}
+
+// Helper function to get the payment lines of a sale
+func getSalesPembayaran(q sqlExecutor, salesID string) ([]SalesPembayaran, error) {
+	return querySalesPembayaran(q, "sales_id = ?", salesID)
+}
+
+// querySalesPembayaran reads payment lines matching a condition
+func querySalesPembayaran(q sqlExecutor, where string, args ...interface{}) ([]SalesPembayaran, error) {
+	rows, err := q.Query(`SELECT bayar_id, sales_id, shift_id, bayar_metode, bayar_amount, bayar_diterima, bayar_kembalian,
+		       bayar_bank, bayar_rekening, bayar_referensi, bayar_date, users_id
+		FROM sales_pembayaran WHERE `+where+` ORDER BY bayar_date, bayar_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []SalesPembayaran{}
+	for rows.Next() {
+		var p SalesPembayaran
+		var shiftID, bank, rekening, referensi, usersID sql.NullString
+		if err := rows.Scan(&p.BayarID, &p.SalesID, &shiftID, &p.Metode, &p.Amount, &p.Diterima, &p.Kembalian,
+			&bank, &rekening, &referensi, &p.Date, &usersID); err != nil {
+			return nil, err
+		}
+		p.ShiftID = shiftID.String
+		p.MetodeNama = bayarMetodeNama[p.Metode]
+		p.Bank = bank.String
+		p.Rekening = rekening.String
+		p.Referensi = referensi.String
+		p.UsersID = usersID.String
+		lines = append(lines, p)
+	}
+	return lines, rows.Err()
+}
+
+// Helper function to ensure the recorded payments of a sale still settle its
+// total, which may have changed when lines were edited after paying
+func checkPembayaranCover(q sqlExecutor, salesID string, total int) error {
+	var count, sum int
+	err := q.QueryRow("SELECT COUNT(*), COALESCE(SUM(bayar_amount), 0) FROM sales_pembayaran WHERE sales_id = ?", salesID).
+		Scan(&count, &sum)
+	if err != nil {
+		return fmt.Errorf("error reading payments: %v", err)
+	}
+	if count > 0 && sum != total {
+		return fmt.Errorf("recorded payments of %d do not match sales_total %d; update the payments first", sum, total)
+	}
+	return nil
+}
+
+// auditPembayaran records the payment lines of a sale replaced by
+// savePembayaran on the sale itself; the lines get new ids on every save
+func auditPembayaran(q sqlExecutor, r *http.Request, salesID string, before []SalesPembayaran) {
+	after, err := getSalesPembayaran(q, salesID)
+	if err != nil {
+		log.Printf("audit: reading payments of %s: %v", salesID, err)
+		return
+	}
+	var old interface{}
+	if len(before) > 0 {
+		old = before
+	}
+	err = writeAudit(q, r, "sales", salesID, "update_pembayaran", map[string]auditChange{
+		"pembayaran": {Before: old, After: after},
+	})
+	if err != nil {
+		log.Printf("audit: %v", err)
+	}
+}
+
+// pembayaranSummary builds the response of a sale's payments
+func pembayaranSummary(salesID string, total int, lines []SalesPembayaran) map[string]interface{} {
+	dibayar, kredit, kembalian := 0, 0, 0
+	for _, p := range lines {
+		if p.Metode == bayarKredit {
+			kredit += p.Amount
+		} else {
+			dibayar += p.Amount
+		}
+		kembalian += p.Kembalian
+	}
+	return map[string]interface{}{
+		"sales_id":        salesID,
+		"sales_total":     total,
+		"total_dibayar":   dibayar,
+		"total_kredit":    kredit,
+		"total_kembalian": kembalian,
+		"sisa":            total - dibayar - kredit,
+		"pembayaran":      lines,
+	}
+}
+
+func getPembayaranSales(w http.ResponseWriter, r *http.Request) {
+	salesID := mux.Vars(r)["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	var total int
+	err = db.QueryRow("SELECT sales_total FROM sales WHERE sales_id = ?", salesID).Scan(&total)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Sales not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	lines, err := getSalesPembayaran(db, salesID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, pembayaranSummary(salesID, total, lines))
+}
+
+// updatePembayaranSales records the full payment breakdown of a sale,
+// replacing earlier lines. A paid (Lunas) or cancelled sale is final.
+func updatePembayaranSales(w http.ResponseWriter, r *http.Request) {
+	salesID := mux.Vars(r)["id"]
+
+	var req SalesPembayaranRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error starting transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	var total, status int
+	err = tx.QueryRow("SELECT sales_total, sales_status FROM sales WHERE sales_id = ? FOR UPDATE", salesID).Scan(&total, &status)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Sales not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if status == salesStatusLunas || status == salesStatusBatal {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("Payments of a sale in status %s can no longer be changed", salesStatusNama[status]))
+		return
+	}
+
+	lines, err := buildPembayaran(total, req.Pembayaran, req.KreditSisa)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	before, err := getSalesPembayaran(tx, salesID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if err := savePembayaran(tx, salesID, lines, user); errors.Is(err, errKasirUser) {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	} else if errors.Is(err, errKasirShift) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	auditPembayaran(tx, r, salesID, before)
+
+	saved, err := getSalesPembayaran(tx, salesID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
+	respondWithJSON(w, pembayaranSummary(salesID, total, saved))
+}
+
+// SetupPembayaranRoutes sets up the sales payment routes
+func SetupPembayaranRoutes(router *mux.Router) {
+	router.HandleFunc("/getsalespembayaran/{id}", getPembayaranSales).Methods("GET")
+	router.HandleFunc("/updatesalespembayaran/{id}", updatePembayaranSales).Methods("PUT")
+}
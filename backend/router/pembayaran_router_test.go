@@ -0,0 +1,173 @@
+package router
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildPembayaran(t *testing.T) {
+	tests := []struct {
+		name       string
+		total      int
+		lines      []PembayaranRequest
+		kreditSisa bool
+		want       []PembayaranRequest
+		wantErr    string
+	}{
+		{
+			name:  "exact cash",
+			total: 100000,
+			lines: []PembayaranRequest{{Metode: "tunai", Amount: 100000}},
+			want:  []PembayaranRequest{{Metode: bayarTunai, Amount: 100000, Diterima: 100000}},
+		},
+		{
+			name:  "split over methods",
+			total: 100000,
+			lines: []PembayaranRequest{
+				{Metode: " Tunai ", Amount: 40000},
+				{Metode: "transfer", Amount: 60000, Bank: " BCA ", Rekening: "123"},
+			},
+			want: []PembayaranRequest{
+				{Metode: bayarTunai, Amount: 40000, Diterima: 40000},
+				{Metode: bayarTransfer, Amount: 60000, Bank: "BCA", Rekening: "123"},
+			},
+		},
+		{
+			name:  "cash overpaid gives change",
+			total: 85000,
+			lines: []PembayaranRequest{{Metode: "tunai", Amount: 85000, Diterima: 100000}},
+			want:  []PembayaranRequest{{Metode: bayarTunai, Amount: 85000, Diterima: 100000}},
+		},
+		{
+			name:    "cash handed over below its amount",
+			total:   85000,
+			lines:   []PembayaranRequest{{Metode: "tunai", Amount: 85000, Diterima: 80000}},
+			wantErr: "bayar_diterima is less than bayar_amount",
+		},
+		{
+			name:    "amounts over the total",
+			total:   85000,
+			lines:   []PembayaranRequest{{Metode: "tunai", Amount: 100000}},
+			wantErr: "exceed sales_total",
+		},
+		{
+			name:    "change on a non-cash line",
+			total:   50000,
+			lines:   []PembayaranRequest{{Metode: "qris", Amount: 50000, Diterima: 60000, Referensi: "Q1"}},
+			wantErr: "only used for tunai",
+		},
+		{
+			name:    "shortfall without kredit",
+			total:   100000,
+			lines:   []PembayaranRequest{{Metode: "tunai", Amount: 60000}},
+			wantErr: "book the remaining 40000 as kredit",
+		},
+		{
+			name:       "remainder booked as kredit",
+			total:      100000,
+			lines:      []PembayaranRequest{{Metode: "tunai", Amount: 60000}},
+			kreditSisa: true,
+			want: []PembayaranRequest{
+				{Metode: bayarTunai, Amount: 60000, Diterima: 60000},
+				{Metode: bayarKredit, Amount: 40000},
+			},
+		},
+		{
+			name:  "remainder added to the kredit line",
+			total: 100000,
+			lines: []PembayaranRequest{
+				{Metode: "kredit", Amount: 10000},
+				{Metode: "tunai", Amount: 60000},
+			},
+			kreditSisa: true,
+			want: []PembayaranRequest{
+				{Metode: bayarKredit, Amount: 40000},
+				{Metode: bayarTunai, Amount: 60000, Diterima: 60000},
+			},
+		},
+		{
+			name:       "whole total on kredit",
+			total:      50000,
+			kreditSisa: true,
+			want:       []PembayaranRequest{{Metode: bayarKredit, Amount: 50000}},
+		},
+		{
+			name:    "no lines",
+			total:   0,
+			wantErr: "at least one payment line",
+		},
+		{
+			name:    "two kredit lines",
+			total:   100000,
+			lines:   []PembayaranRequest{{Metode: "kredit", Amount: 50000}, {Metode: "kredit", Amount: 50000}},
+			wantErr: "only one kredit line",
+		},
+		{
+			name:    "transfer without account",
+			total:   100000,
+			lines:   []PembayaranRequest{{Metode: "transfer", Amount: 100000, Bank: "BCA"}},
+			wantErr: "needs bayar_bank and bayar_rekening",
+		},
+		{
+			name:    "e-wallet without reference",
+			total:   100000,
+			lines:   []PembayaranRequest{{Metode: "ewallet", Amount: 100000}},
+			wantErr: "E-Wallet needs bayar_referensi",
+		},
+		{
+			name:    "unknown method",
+			total:   100000,
+			lines:   []PembayaranRequest{{Metode: "cek", Amount: 100000}},
+			wantErr: "bayar_metode must be",
+		},
+		{
+			name:    "zero amount",
+			total:   100000,
+			lines:   []PembayaranRequest{{Metode: "tunai", Amount: 0}},
+			wantErr: "bayar_amount must be greater than 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildPembayaran(tt.total, tt.lines, tt.kreditSisa)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %+v\nwant    %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPembayaranSummaryCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		metodes []string
+		want    string
+	}{
+		{"cash only", []string{bayarTunai, bayarTunai}, "1"},
+		{"cash and transfer", []string{bayarTunai, bayarTransfer}, "2"},
+		{"qris", []string{bayarQRIS}, "2"},
+		{"any kredit", []string{bayarTunai, bayarKredit}, "3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []PembayaranRequest
+			for _, m := range tt.metodes {
+				lines = append(lines, PembayaranRequest{Metode: m})
+			}
+			if got := pembayaranSummaryCode(lines); got != tt.want {
+				t.Errorf("code = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
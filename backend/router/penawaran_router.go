@@ -0,0 +1,687 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"src/database"
+	"strconv"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// Penawaran status values
+const (
+	penawaranTerkirim    = 1
+	penawaranDiterima    = 2
+	penawaranKedaluwarsa = 3
+	penawaranDitolak     = 4
+)
+
+var penawaranStatusNama = map[int]string{
+	penawaranTerkirim:    "Terkirim",
+	penawaranDiterima:    "Diterima",
+	penawaranKedaluwarsa: "Kedaluwarsa",
+	penawaranDitolak:     "Ditolak",
+}
+
+type PenawaranItem struct {
+	ID          string  `json:"penawaran_items_id"`
+	PenawaranID string  `json:"penawaran_id"`
+	BarangID    string  `json:"barang_id"`
+	BarangNama  string  `json:"barang_nama"`
+	GudangID    string  `json:"gudang_id"`
+	LantaiID    string  `json:"lantai_id,omitempty"`
+	Amount      int     `json:"items_amount"`
+	HargaJual   int     `json:"harga_jual"`
+	Diskon      float64 `json:"diskon"`
+	HargaAkhir  int     `json:"harga_akhir"`
+	Subtotal    int     `json:"subtotal"`
+}
+
+type Penawaran struct {
+	ID           string          `json:"penawaran_id"`
+	CustomerID   string          `json:"customer_id"`
+	CustomerNama string          `json:"customer_nama"`
+	Date         string          `json:"penawaran_date"`
+	ValidUntil   string          `json:"penawaran_valid_until"`
+	Status       int             `json:"penawaran_status"`
+	StatusNama   string          `json:"penawaran_status_nama"`
+	Total        int             `json:"penawaran_total"`
+	Note         string          `json:"penawaran_note,omitempty"`
+	SalesID      string          `json:"sales_id,omitempty"`
+	UsersID      string          `json:"users_id,omitempty"`
+	Items        []PenawaranItem `json:"items,omitempty"`
+}
+
+type PenawaranItemRequest struct {
+	BarangID string `json:"barang_id"`
+	GudangID string `json:"gudang_id"`
+	LantaiID string `json:"lantai_id,omitempty"`
+	Amount   int    `json:"items_amount"`
+}
+
+type PenawaranRequest struct {
+	CustomerID string                 `json:"customer_id"`
+	ValidUntil string                 `json:"penawaran_valid_until"` // default: 14 days from today
+	Note       string                 `json:"penawaran_note"`
+	Items      []PenawaranItemRequest `json:"items"`
+}
+
+type PenawaranStatusRequest struct {
+	Status int `json:"penawaran_status"`
+}
+
+type PenawaranConvertRequest struct {
+	SalesPayment string `json:"sales_payment"`
+	SalesDate    string `json:"sales_date"`
+}
+
+// Default validity of a penawaran in days
+const penawaranBerlakuHari = 14
+
+// expirePenawaran marks sent quotations past their validity date as expired
+func expirePenawaran(q sqlExecutor) error {
+	_, err := q.Exec("UPDATE penawaran SET penawaran_status = ? WHERE penawaran_status = ? AND penawaran_valid_until < CURDATE()",
+		penawaranKedaluwarsa, penawaranTerkirim)
+	if err != nil {
+		return fmt.Errorf("error expiring penawaran: %v", err)
+	}
+	return nil
+}
+
+// Helper function to load the lines of a penawaran (rows are closed before returning)
+func getPenawaranItems(q sqlExecutor, penawaranID string) ([]PenawaranItem, error) {
+	rows, err := q.Query(`
+		SELECT pi.penawaran_items_id, pi.penawaran_id, pi.barang_id, COALESCE(b.barang_nama, ''),
+		       pi.gudang_id, pi.lantai_id, pi.items_amount, pi.harga_jual, pi.diskon, pi.harga_akhir
+		FROM penawaran_items pi
+		LEFT JOIN barang b ON pi.barang_id = b.barang_id
+		WHERE pi.penawaran_id = ?
+		ORDER BY pi.penawaran_items_id`, penawaranID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching penawaran items: %v", err)
+	}
+	defer rows.Close()
+
+	items := []PenawaranItem{}
+	for rows.Next() {
+		var item PenawaranItem
+		var lantaiID sql.NullString
+		if err := rows.Scan(&item.ID, &item.PenawaranID, &item.BarangID, &item.BarangNama,
+			&item.GudangID, &lantaiID, &item.Amount, &item.HargaJual, &item.Diskon, &item.HargaAkhir); err != nil {
+			return nil, fmt.Errorf("error scanning penawaran item: %v", err)
+		}
+		item.LantaiID = lantaiID.String
+		item.Subtotal = item.HargaAkhir * item.Amount
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// Helper function to load a penawaran header
+func getPenawaranHeader(q sqlExecutor, penawaranID string) (*Penawaran, error) {
+	var p Penawaran
+	var customerNama, note, salesID, usersID sql.NullString
+	err := q.QueryRow(`
+		SELECT p.penawaran_id, p.customer_id, c.customer_nama, p.penawaran_date, p.penawaran_valid_until,
+		       p.penawaran_status, p.penawaran_total, p.penawaran_note, p.sales_id, p.users_id
+		FROM penawaran p
+		LEFT JOIN customer c ON p.customer_id = c.customer_id
+		WHERE p.penawaran_id = ?`, penawaranID).
+		Scan(&p.ID, &p.CustomerID, &customerNama, &p.Date, &p.ValidUntil,
+			&p.Status, &p.Total, &note, &salesID, &usersID)
+	if err != nil {
+		return nil, err
+	}
+	p.CustomerNama = customerNama.String
+	p.Note = note.String
+	p.SalesID = salesID.String
+	p.UsersID = usersID.String
+	p.StatusNama = penawaranStatusNama[p.Status]
+	return &p, nil
+}
+
+func createPenawaran(w http.ResponseWriter, r *http.Request) {
+	var req PenawaranRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.CustomerID == "" {
+		respondWithError(w, http.StatusBadRequest, "customer_id is required")
+		return
+	}
+	if len(req.Items) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one item is required")
+		return
+	}
+
+	today := time.Now().Format("2006-01-02")
+	if req.ValidUntil == "" {
+		req.ValidUntil = time.Now().AddDate(0, 0, penawaranBerlakuHari).Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", req.ValidUntil); err != nil {
+		respondWithError(w, http.StatusBadRequest, "penawaran_valid_until must be in YYYY-MM-DD format")
+		return
+	} else if req.ValidUntil < today {
+		respondWithError(w, http.StatusBadRequest, "penawaran_valid_until cannot be in the past")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var customerExists bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM customer WHERE customer_id = ? AND deleted_at IS NULL)", req.CustomerID).Scan(&customerExists)
+	if err != nil || !customerExists {
+		respondWithError(w, http.StatusBadRequest, "Invalid customer_id: customer does not exist")
+		return
+	}
+
+	// Validate lines and price them for the customer (price list, selling price and active discounts)
+	var items []PenawaranItem
+	total := 0
+	for i, itemReq := range req.Items {
+		if itemReq.BarangID == "" || itemReq.GudangID == "" {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("barang_id and gudang_id are required for item #%d", i+1))
+			return
+		}
+		if itemReq.Amount <= 0 {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("items_amount must be greater than 0 for item #%d", i+1))
+			return
+		}
+		if err := validateSaleLine(db, itemReq.BarangID, itemReq.GudangID, itemReq.LantaiID, ""); err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Item #%d: %v", i+1, err))
+			return
+		}
+
+		harga, err := hitungHargaPelanggan(db, itemReq.BarangID, req.CustomerID, itemReq.Amount, time.Now())
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Item #%d: %v", i+1, err))
+			return
+		}
+		item := PenawaranItem{
+			BarangID:   itemReq.BarangID,
+			BarangNama: harga.BarangNama,
+			GudangID:   itemReq.GudangID,
+			LantaiID:   itemReq.LantaiID,
+			Amount:     itemReq.Amount,
+			HargaJual:  harga.HargaJual,
+			Diskon:     harga.Diskon,
+			HargaAkhir: harga.HargaAkhir,
+			Subtotal:   harga.HargaAkhir * itemReq.Amount,
+		}
+		total += item.Subtotal
+		items = append(items, item)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Get last penawaran_id
+	var lastID string
+	err = tx.QueryRow("SELECT penawaran_id FROM penawaran ORDER BY penawaran_id DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching last penawaran_id")
+		return
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:]) // "PN_0000002" -> "0000002"
+		nextNum = n + 1
+	}
+	newID := fmt.Sprintf("PN_%07d", nextNum)
+	audit := startAudit(tx, r, "penawaran", newID)
+
+	_, err = tx.Exec(`INSERT INTO penawaran (penawaran_id, customer_id, penawaran_date, penawaran_valid_until, penawaran_status, penawaran_total, penawaran_note, users_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		newID, req.CustomerID, today, req.ValidUntil, penawaranTerkirim, total, processNullableStringValue(req.Note), requestUserID(user))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Insert error: "+err.Error())
+		return
+	}
+
+	var lastItemID string
+	err = tx.QueryRow("SELECT penawaran_items_id FROM penawaran_items ORDER BY penawaran_items_id DESC LIMIT 1").Scan(&lastItemID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching last penawaran_items_id")
+		return
+	}
+	itemNum := 1
+	if lastItemID != "" {
+		n, _ := strconv.Atoi(lastItemID[3:])
+		itemNum = n + 1
+	}
+
+	for i := range items {
+		items[i].ID = fmt.Sprintf("PI_%07d", itemNum)
+		items[i].PenawaranID = newID
+		_, err = tx.Exec(`INSERT INTO penawaran_items (penawaran_items_id, penawaran_id, barang_id, gudang_id, lantai_id, items_amount, harga_jual, diskon, harga_akhir)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			items[i].ID, newID, items[i].BarangID, items[i].GudangID, processNullableStringValue(items[i].LantaiID),
+			items[i].Amount, items[i].HargaJual, items[i].Diskon, items[i].HargaAkhir)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Insert item error: "+err.Error())
+			return
+		}
+		itemNum++
+	}
+
+	audit.record("create")
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respondWithJSON(w, Penawaran{
+		ID:         newID,
+		CustomerID: req.CustomerID,
+		Date:       today,
+		ValidUntil: req.ValidUntil,
+		Status:     penawaranTerkirim,
+		StatusNama: penawaranStatusNama[penawaranTerkirim],
+		Total:      total,
+		Note:       req.Note,
+		Items:      items,
+	})
+}
+
+func getPenawarans(w http.ResponseWriter, r *http.Request) {
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	if err := expirePenawaran(db); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	query := `
+		SELECT p.penawaran_id, p.customer_id, c.customer_nama, p.penawaran_date, p.penawaran_valid_until,
+		       p.penawaran_status, p.penawaran_total, p.penawaran_note, p.sales_id, p.users_id
+		FROM penawaran p
+		LEFT JOIN customer c ON p.customer_id = c.customer_id
+		WHERE 1 = 1`
+	var args []interface{}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += " AND p.penawaran_status = ?"
+		args = append(args, status)
+	}
+	if customerID := r.URL.Query().Get("customer_id"); customerID != "" {
+		query += " AND p.customer_id = ?"
+		args = append(args, customerID)
+	}
+	query += " ORDER BY p.penawaran_date DESC, p.penawaran_id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	penawarans := []Penawaran{}
+	for rows.Next() {
+		var p Penawaran
+		var customerNama, note, salesID, usersID sql.NullString
+		if err := rows.Scan(&p.ID, &p.CustomerID, &customerNama, &p.Date, &p.ValidUntil,
+			&p.Status, &p.Total, &note, &salesID, &usersID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		p.CustomerNama = customerNama.String
+		p.Note = note.String
+		p.SalesID = salesID.String
+		p.UsersID = usersID.String
+		p.StatusNama = penawaranStatusNama[p.Status]
+		penawarans = append(penawarans, p)
+	}
+
+	respondWithJSON(w, penawarans)
+}
+
+func getPenawaranDetail(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	if err := expirePenawaran(db); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	p, err := getPenawaranHeader(db, id)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Penawaran not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	p.Items, err = getPenawaranItems(db, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, p)
+}
+
+// updatePenawaranStatus records the customer's answer: accepted or rejected
+func updatePenawaranStatus(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req PenawaranStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Status != penawaranDiterima && req.Status != penawaranDitolak {
+		respondWithError(w, http.StatusBadRequest, "penawaran_status must be 2 (Diterima) or 4 (Ditolak)")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	if err := expirePenawaran(db); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var current int
+	err = db.QueryRow("SELECT penawaran_status FROM penawaran WHERE penawaran_id = ?", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Penawaran not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if current != penawaranTerkirim {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("Penawaran is %s, only sent penawaran can be answered", penawaranStatusNama[current]))
+		return
+	}
+
+	audit := startAudit(db, r, "penawaran", id)
+	_, err = db.Exec("UPDATE penawaran SET penawaran_status = ? WHERE penawaran_id = ?", req.Status, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	audit.record("update_status")
+
+	respondWithJSON(w, map[string]interface{}{
+		"penawaran_id":          id,
+		"penawaran_status":      req.Status,
+		"penawaran_status_nama": penawaranStatusNama[req.Status],
+		"status":                "Updated",
+	})
+}
+
+// convertPenawaran turns an accepted penawaran into a confirmed sale at the
+// offered prices, after re-checking stock per lantai
+func convertPenawaran(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req PenawaranConvertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.SalesPayment != "1" && req.SalesPayment != "2" && req.SalesPayment != "3" {
+		respondWithError(w, http.StatusBadRequest, "sales_payment must be 1 (Tunai), 2 (Transfer), or 3 (Kredit)")
+		return
+	}
+	if req.SalesDate == "" {
+		req.SalesDate = time.Now().Format("2006-01-02")
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	var status int
+	var salesID sql.NullString
+	var customerID string
+	err = tx.QueryRow("SELECT penawaran_status, sales_id, customer_id FROM penawaran WHERE penawaran_id = ? FOR UPDATE", id).
+		Scan(&status, &salesID, &customerID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Penawaran not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if salesID.Valid {
+		respondWithError(w, http.StatusConflict, "Penawaran was already converted into sales "+salesID.String)
+		return
+	}
+	if status != penawaranDiterima {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("Penawaran is %s, only accepted penawaran can be converted", penawaranStatusNama[status]))
+		return
+	}
+
+	var customerExists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM customer WHERE customer_id = ? AND deleted_at IS NULL)", customerID).Scan(&customerExists)
+	if err != nil || !customerExists {
+		respondWithError(w, http.StatusConflict, "Customer of this penawaran no longer exists")
+		return
+	}
+
+	items, err := getPenawaranItems(tx, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Re-check stock per lantai, summing lines that draw from the same floor
+	type stockKey struct{ barangID, lantaiID string }
+	needed := map[stockKey]int{}
+	var keys []stockKey
+	salesReq := CombinedSalesRequest{
+		CustomerID:   customerID,
+		SalesPayment: req.SalesPayment,
+		SalesDate:    req.SalesDate,
+		SalesStatus:  salesStatusDiproses,
+	}
+	for i, item := range items {
+		if err := validateSaleLine(tx, item.BarangID, item.GudangID, item.LantaiID, ""); err != nil {
+			respondWithError(w, http.StatusConflict, fmt.Sprintf("Item #%d: %v", i+1, err))
+			return
+		}
+		lantaiID, err := resolveSaleLantai(tx, item.GudangID, item.LantaiID)
+		if err != nil {
+			respondWithError(w, http.StatusConflict, fmt.Sprintf("Item #%d: %v", i+1, err))
+			return
+		}
+		key := stockKey{item.BarangID, lantaiID}
+		if _, ok := needed[key]; !ok {
+			keys = append(keys, key)
+		}
+		needed[key] += item.Amount
+
+		salesReq.SaleItems = append(salesReq.SaleItems, SaleItemsRequest{
+			BarangID:        item.BarangID,
+			GudangID:        item.GudangID,
+			LantaiID:        lantaiID,
+			SaleItemsAmount: item.Amount,
+			SaleValue:       item.HargaAkhir,
+			hargaTetap:      true,
+		})
+	}
+
+	var shortages []map[string]interface{}
+	for _, key := range keys {
+		var available int
+		err := tx.QueryRow("SELECT COALESCE(SUM(stock_barang), 0) FROM stock_gudang WHERE barang_id = ? AND lantai_id = ?",
+			key.barangID, key.lantaiID).Scan(&available)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error checking stock: "+err.Error())
+			return
+		}
+		if available < needed[key] {
+			shortages = append(shortages, map[string]interface{}{
+				"barang_id": key.barangID,
+				"lantai_id": key.lantaiID,
+				"required":  needed[key],
+				"available": available,
+			})
+		}
+	}
+	if len(shortages) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":        "Insufficient stock to convert penawaran",
+			"penawaran_id": id,
+			"shortages":    shortages,
+		})
+		return
+	}
+
+	newSalesID, salesTotal, createdItems, err := insertCombinedSales(tx, salesReq, user)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	auditCreated(tx, r, "sales", newSalesID, "create")
+
+	audit := startAudit(tx, r, "penawaran", id)
+	_, err = tx.Exec("UPDATE penawaran SET sales_id = ? WHERE penawaran_id = ?", newSalesID, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+
+	audit.record("convert")
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respondWithJSON(w, map[string]interface{}{
+		"penawaran_id":  id,
+		"sales_id":      newSalesID,
+		"customer_id":   customerID,
+		"sales_total":   salesTotal,
+		"sales_payment": req.SalesPayment,
+		"sales_date":    req.SalesDate,
+		"sales_status":  salesStatusDiproses,
+		"sale_items":    createdItems,
+		"status":        "Converted",
+	})
+}
+
+func deletePenawaran(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	var salesID sql.NullString
+	err = db.QueryRow("SELECT sales_id FROM penawaran WHERE penawaran_id = ?", id).Scan(&salesID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Penawaran not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if salesID.Valid {
+		respondWithError(w, http.StatusConflict, "Penawaran was converted into sales "+salesID.String+" and cannot be deleted")
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	audit := startAudit(tx, r, "penawaran", id)
+	if _, err := tx.Exec("DELETE FROM penawaran_items WHERE penawaran_id = ?", id); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Delete error: "+err.Error())
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM penawaran WHERE penawaran_id = ?", id); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Delete error: "+err.Error())
+		return
+	}
+	audit.record("delete")
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
+	respondWithJSON(w, map[string]string{
+		"penawaran_id": id,
+		"status":       "Deleted",
+	})
+}
+
+// SetupPenawaranRoutes sets up all penawaran-related routes
+func SetupPenawaranRoutes(router *mux.Router) {
+	router.HandleFunc("/createpenawaran", createPenawaran).Methods("POST")
+	router.HandleFunc("/getpenawarans", getPenawarans).Methods("GET")
+	router.HandleFunc("/getpenawaran/{id}", getPenawaranDetail).Methods("GET")
+	router.HandleFunc("/updatepenawaranstatus/{id}", updatePenawaranStatus).Methods("PUT")
+	router.HandleFunc("/convertpenawaran/{id}", convertPenawaran).Methods("POST")
+	router.HandleFunc("/deletepenawaran/{id}", deletePenawaran).Methods("DELETE")
+}
@@ -0,0 +1,726 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"src/database"
+	"strconv"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// Pick list status values
+const (
+	pickDibuat     = 1
+	pickSelesai    = 2
+	pickDibatalkan = 3
+)
+
+var pickStatusNama = map[int]string{
+	pickDibuat:     "Dibuat",
+	pickSelesai:    "Selesai",
+	pickDibatalkan: "Dibatalkan",
+}
+
+type PickBin struct {
+	LokasiID   string `json:"lokasi_id"`
+	LokasiNama string `json:"lokasi_nama"`
+	Stock      int    `json:"stock_barang"`
+}
+
+type PickListItem struct {
+	ID         string `json:"pli_id"`
+	BarangID   string `json:"barang_id"`
+	BarangNama string `json:"barang_nama"`
+	LokasiID   string `json:"lokasi_id,omitempty"`
+	LokasiNama string `json:"lokasi_nama,omitempty"`
+	Required   int    `json:"pli_required"`
+	Picked     *int   `json:"pli_picked"`
+	Short      *int   `json:"pli_short"`
+	// Bins holding the barang when the line has no bin of its own
+	Bins []PickBin `json:"bins,omitempty"`
+}
+
+type PickLantai struct {
+	LantaiID   string         `json:"lantai_id"`
+	LantaiNama string         `json:"lantai_nama"`
+	LantaiNo   int            `json:"lantai_no"`
+	Items      []PickListItem `json:"items"`
+}
+
+type PickGudang struct {
+	GudangID   string       `json:"gudang_id"`
+	GudangNama string       `json:"gudang_nama"`
+	Lantais    []PickLantai `json:"lantai"`
+}
+
+type PickDocument struct {
+	Type string `json:"doc_type"`
+	ID   string `json:"doc_id"`
+}
+
+type PickList struct {
+	ID         string         `json:"pick_id"`
+	Date       string         `json:"pick_date"`
+	Status     int            `json:"pick_status"`
+	StatusNama string         `json:"pick_status_nama"`
+	Note       string         `json:"pick_note,omitempty"`
+	PickedBy   string         `json:"picked_by,omitempty"`
+	PickedAt   string         `json:"picked_at,omitempty"`
+	Documents  []PickDocument `json:"documents"`
+	Gudangs    []PickGudang   `json:"gudang,omitempty"`
+}
+
+type PickListRequest struct {
+	SalesIDs []string `json:"sales_ids"`
+	LogsIDs  []string `json:"logs_ids"`
+	Note     string   `json:"pick_note"`
+}
+
+type PickConfirmRequest struct {
+	Items []struct {
+		ID     string `json:"pli_id"`
+		Picked int    `json:"pli_picked"`
+	} `json:"items"` // lines not listed are picked in full
+}
+
+// pickSourceLine is one document line that has to be picked
+type pickSourceLine struct {
+	BarangID string
+	GudangID string
+	LantaiID string
+	LokasiID string
+	Amount   int
+}
+
+// Helper function to load the lines of a pick list document (rows are closed before returning)
+func getPickSourceLines(q sqlExecutor, docType, docID string) ([]pickSourceLine, error) {
+	query := "SELECT barang_id, gudang_id, lantai_id, lokasi_id, sale_items_amount FROM sale_items WHERE sales_id = ?"
+	if docType == "logs" {
+		query = "SELECT barang_id, gudang_id, lantai_id, NULL, orders_amount FROM orders_keluar WHERE logs_id = ?"
+	}
+	rows, err := q.Query(query, docID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching lines of %s: %v", docID, err)
+	}
+	defer rows.Close()
+
+	var lines []pickSourceLine
+	for rows.Next() {
+		var line pickSourceLine
+		var lantaiID, lokasiID sql.NullString
+		if err := rows.Scan(&line.BarangID, &line.GudangID, &lantaiID, &lokasiID, &line.Amount); err != nil {
+			return nil, fmt.Errorf("error scanning lines of %s: %v", docID, err)
+		}
+		line.LantaiID = lantaiID.String
+		line.LokasiID = lokasiID.String
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// Helper function to check that a document can be picked and is not on another open pick list
+func validatePickDocument(q sqlExecutor, docType, docID string) error {
+	if docType == "sales" {
+		var status int
+		err := q.QueryRow("SELECT sales_status FROM sales WHERE sales_id = ?", docID).Scan(&status)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("sales '%s' does not exist", docID)
+		} else if err != nil {
+			return fmt.Errorf("error checking sales: %v", err)
+		}
+		if status != salesStatusDiproses {
+			return fmt.Errorf("sales '%s' is %s, only %s sales can be picked", docID, salesStatusNama[status], salesStatusNama[salesStatusDiproses])
+		}
+	} else {
+		var logsStatus int
+		err := q.QueryRow("SELECT logs_status FROM barang_logs WHERE logs_id = ?", docID).Scan(&logsStatus)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("barang logs '%s' does not exist", docID)
+		} else if err != nil {
+			return fmt.Errorf("error checking barang logs: %v", err)
+		}
+		if logsStatus != 2 {
+			return fmt.Errorf("barang logs '%s' is not an outbound (keluar) log", docID)
+		}
+	}
+
+	var openPickID string
+	err := q.QueryRow(`SELECT pl.pick_id FROM pick_list pl
+		JOIN pick_list_documents pd ON pd.pick_id = pl.pick_id
+		WHERE pd.doc_type = ? AND pd.doc_id = ? AND pl.pick_status = ? LIMIT 1`, docType, docID, pickDibuat).Scan(&openPickID)
+	if err == nil {
+		return fmt.Errorf("%s is already on open pick list %s", docID, openPickID)
+	} else if err != sql.ErrNoRows {
+		return fmt.Errorf("error checking open pick lists: %v", err)
+	}
+	return nil
+}
+
+// Helper function to list the bins on a lantai that hold a barang, fullest first
+func getPickBins(q sqlExecutor, barangID, lantaiID string) ([]PickBin, error) {
+	rows, err := q.Query(`SELECT gk.lokasi_id, gk.lokasi_nama, sk.stock_barang
+		FROM stock_lokasi sk
+		JOIN gudang_lokasi gk ON sk.lokasi_id = gk.lokasi_id
+		WHERE sk.barang_id = ? AND gk.lantai_id = ? AND sk.stock_barang > 0
+		ORDER BY sk.stock_barang DESC, gk.lokasi_zona, gk.lokasi_rak, gk.lokasi_bin`, barangID, lantaiID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching bins: %v", err)
+	}
+	defer rows.Close()
+
+	var bins []PickBin
+	for rows.Next() {
+		var bin PickBin
+		if err := rows.Scan(&bin.LokasiID, &bin.LokasiNama, &bin.Stock); err != nil {
+			return nil, fmt.Errorf("error scanning bins: %v", err)
+		}
+		bins = append(bins, bin)
+	}
+	return bins, rows.Err()
+}
+
+// getPickList loads a pick list with its lines grouped by gudang and lantai in
+// walking order (lantai_no, then zona/rak/bin)
+func getPickList(q sqlExecutor, pickID string) (*PickList, error) {
+	var pl PickList
+	var note, pickedBy, pickedAt sql.NullString
+	err := q.QueryRow("SELECT pick_id, pick_date, pick_status, pick_note, picked_by, picked_at FROM pick_list WHERE pick_id = ?", pickID).
+		Scan(&pl.ID, &pl.Date, &pl.Status, &note, &pickedBy, &pickedAt)
+	if err != nil {
+		return nil, err
+	}
+	pl.StatusNama = pickStatusNama[pl.Status]
+	pl.Note = note.String
+	pl.PickedBy = pickedBy.String
+	pl.PickedAt = pickedAt.String
+
+	docRows, err := q.Query("SELECT doc_type, doc_id FROM pick_list_documents WHERE pick_id = ? ORDER BY doc_type DESC, doc_id", pickID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching pick list documents: %v", err)
+	}
+	pl.Documents = []PickDocument{}
+	for docRows.Next() {
+		var doc PickDocument
+		if err := docRows.Scan(&doc.Type, &doc.ID); err != nil {
+			docRows.Close()
+			return nil, fmt.Errorf("error scanning pick list documents: %v", err)
+		}
+		pl.Documents = append(pl.Documents, doc)
+	}
+	docRows.Close()
+
+	rows, err := q.Query(`
+		SELECT pli.pli_id, pli.gudang_id, COALESCE(g.gudang_nama, ''), pli.lantai_id, COALESCE(gl.lantai_nama, ''), COALESCE(gl.lantai_no, 0),
+		       pli.barang_id, COALESCE(b.barang_nama, ''), pli.lokasi_id, gk.lokasi_nama,
+		       pli.pli_required, pli.pli_picked, pli.pli_short
+		FROM pick_list_items pli
+		LEFT JOIN list_gudang g ON pli.gudang_id = g.gudang_id
+		LEFT JOIN gudang_lantai gl ON pli.lantai_id = gl.lantai_id
+		LEFT JOIN gudang_lokasi gk ON pli.lokasi_id = gk.lokasi_id
+		LEFT JOIN barang b ON pli.barang_id = b.barang_id
+		WHERE pli.pick_id = ?
+		ORDER BY g.gudang_nama, pli.gudang_id, gl.lantai_no, pli.lantai_id,
+		         gk.lokasi_zona IS NULL, gk.lokasi_zona, gk.lokasi_rak, gk.lokasi_bin, b.barang_nama`, pickID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching pick list items: %v", err)
+	}
+
+	type lantaiKey struct{ gudang, lantai string }
+	var lantaiKeys []lantaiKey
+	for rows.Next() {
+		var item PickListItem
+		var gudangID, gudangNama, lantaiID, lantaiNama string
+		var lantaiNo int
+		var lokasiID, lokasiNama sql.NullString
+		var picked, short sql.NullInt64
+		if err := rows.Scan(&item.ID, &gudangID, &gudangNama, &lantaiID, &lantaiNama, &lantaiNo,
+			&item.BarangID, &item.BarangNama, &lokasiID, &lokasiNama,
+			&item.Required, &picked, &short); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning pick list items: %v", err)
+		}
+		item.LokasiID = lokasiID.String
+		item.LokasiNama = lokasiNama.String
+		if picked.Valid {
+			p, s := int(picked.Int64), int(short.Int64)
+			item.Picked, item.Short = &p, &s
+		}
+
+		if n := len(pl.Gudangs); n == 0 || pl.Gudangs[n-1].GudangID != gudangID {
+			pl.Gudangs = append(pl.Gudangs, PickGudang{GudangID: gudangID, GudangNama: gudangNama})
+		}
+		g := &pl.Gudangs[len(pl.Gudangs)-1]
+		if n := len(g.Lantais); n == 0 || g.Lantais[n-1].LantaiID != lantaiID {
+			g.Lantais = append(g.Lantais, PickLantai{LantaiID: lantaiID, LantaiNama: lantaiNama, LantaiNo: lantaiNo})
+			lantaiKeys = append(lantaiKeys, lantaiKey{gudangID, lantaiID})
+		}
+		l := &g.Lantais[len(g.Lantais)-1]
+		l.Items = append(l.Items, item)
+	}
+	rows.Close()
+
+	// Suggest bins for open lines without a bin of their own
+	if pl.Status == pickDibuat {
+		for gi := range pl.Gudangs {
+			for li := range pl.Gudangs[gi].Lantais {
+				l := &pl.Gudangs[gi].Lantais[li]
+				for ii := range l.Items {
+					if l.Items[ii].LokasiID != "" {
+						continue
+					}
+					bins, err := getPickBins(q, l.Items[ii].BarangID, l.LantaiID)
+					if err != nil {
+						return nil, err
+					}
+					l.Items[ii].Bins = bins
+				}
+			}
+		}
+	}
+	return &pl, nil
+}
+
+// createPickList builds one pick list for several sales and/or outbound logs,
+// summing the same barang picked from the same lantai/bin
+func createPickList(w http.ResponseWriter, r *http.Request) {
+	var req PickListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var docs []PickDocument
+	seen := map[PickDocument]bool{}
+	for _, id := range req.SalesIDs {
+		doc := PickDocument{Type: "sales", ID: id}
+		if id != "" && !seen[doc] {
+			seen[doc] = true
+			docs = append(docs, doc)
+		}
+	}
+	for _, id := range req.LogsIDs {
+		doc := PickDocument{Type: "logs", ID: id}
+		if id != "" && !seen[doc] {
+			seen[doc] = true
+			docs = append(docs, doc)
+		}
+	}
+	if len(docs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one of sales_ids or logs_ids is required")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Aggregate per barang and pick spot across all documents
+	type pickKey struct{ barang, gudang, lantai, lokasi string }
+	var keys []pickKey
+	required := map[pickKey]int{}
+	for _, doc := range docs {
+		if err := validatePickDocument(tx, doc.Type, doc.ID); err != nil {
+			respondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+		lines, err := getPickSourceLines(tx, doc.Type, doc.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, line := range lines {
+			lantaiID, err := resolveSaleLantai(tx, line.GudangID, line.LantaiID)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s: %v", doc.ID, err))
+				return
+			}
+			key := pickKey{line.BarangID, line.GudangID, lantaiID, line.LokasiID}
+			if _, ok := required[key]; !ok {
+				keys = append(keys, key)
+			}
+			required[key] += line.Amount
+		}
+	}
+	if len(keys) == 0 {
+		respondWithError(w, http.StatusBadRequest, "The selected documents have no lines to pick")
+		return
+	}
+
+	// Get last pick_id
+	var lastID string
+	err = tx.QueryRow("SELECT pick_id FROM pick_list ORDER BY pick_id DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching last pick_id")
+		return
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:]) // "PK_0000002" -> "0000002"
+		nextNum = n + 1
+	}
+	pickID := fmt.Sprintf("PK_%07d", nextNum)
+	audit := startAudit(tx, r, "pick_list", pickID)
+
+	_, err = tx.Exec("INSERT INTO pick_list (pick_id, pick_date, pick_status, pick_note, users_id) VALUES (?, NOW(), ?, ?, ?)",
+		pickID, pickDibuat, processNullableStringValue(req.Note), requestUserID(user))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Insert error: "+err.Error())
+		return
+	}
+	for _, doc := range docs {
+		if _, err := tx.Exec("INSERT INTO pick_list_documents (pick_id, doc_type, doc_id) VALUES (?, ?, ?)", pickID, doc.Type, doc.ID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Insert document error: "+err.Error())
+			return
+		}
+	}
+
+	var lastItemID string
+	err = tx.QueryRow("SELECT pli_id FROM pick_list_items ORDER BY pli_id DESC LIMIT 1").Scan(&lastItemID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching last pli_id")
+		return
+	}
+	itemNum := 1
+	if lastItemID != "" {
+		n, _ := strconv.Atoi(lastItemID[3:])
+		itemNum = n + 1
+	}
+	for _, key := range keys {
+		_, err = tx.Exec(`INSERT INTO pick_list_items (pli_id, pick_id, barang_id, gudang_id, lantai_id, lokasi_id, pli_required)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			fmt.Sprintf("PL_%07d", itemNum), pickID, key.barang, key.gudang, key.lantai,
+			processNullableStringValue(key.lokasi), required[key])
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Insert item error: "+err.Error())
+			return
+		}
+		itemNum++
+	}
+
+	audit.record("create")
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
+	pl, err := getPickList(db, pickID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading pick list: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	respondWithJSON(w, pl)
+}
+
+func getPickLists(w http.ResponseWriter, r *http.Request) {
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	query := `SELECT pl.pick_id, pl.pick_date, pl.pick_status, pl.pick_note, pl.picked_by, pl.picked_at
+		FROM pick_list pl WHERE 1 = 1`
+	var args []interface{}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += " AND pl.pick_status = ?"
+		args = append(args, status)
+	}
+	if docID := r.URL.Query().Get("doc_id"); docID != "" {
+		query += " AND EXISTS (SELECT 1 FROM pick_list_documents pd WHERE pd.pick_id = pl.pick_id AND pd.doc_id = ?)"
+		args = append(args, docID)
+	}
+	query += " ORDER BY pl.pick_date DESC, pl.pick_id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	lists := []PickList{}
+	for rows.Next() {
+		var pl PickList
+		var note, pickedBy, pickedAt sql.NullString
+		if err := rows.Scan(&pl.ID, &pl.Date, &pl.Status, &note, &pickedBy, &pickedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		pl.StatusNama = pickStatusNama[pl.Status]
+		pl.Note = note.String
+		pl.PickedBy = pickedBy.String
+		pl.PickedAt = pickedAt.String
+		lists = append(lists, pl)
+	}
+	respondWithJSON(w, lists)
+}
+
+func getPickListDetail(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	pl, err := getPickList(db, id)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Pick list not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, pl)
+}
+
+// confirmPickList records the picked quantities. Without shortages the sales on
+// the pick list move from Diproses to Dipick.
+func confirmPickList(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req PickConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	var status int
+	err = tx.QueryRow("SELECT pick_status FROM pick_list WHERE pick_id = ? FOR UPDATE", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Pick list not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if status != pickDibuat {
+		respondWithError(w, http.StatusConflict, "Pick list is already "+pickStatusNama[status])
+		return
+	}
+
+	audit := startAudit(tx, r, "pick_list", id)
+
+	rows, err := tx.Query("SELECT pli_id, pli_required FROM pick_list_items WHERE pick_id = ?", id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	var itemIDs []string
+	required := map[string]int{}
+	for rows.Next() {
+		var itemID string
+		var amount int
+		if err := rows.Scan(&itemID, &amount); err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		itemIDs = append(itemIDs, itemID)
+		required[itemID] = amount
+	}
+	rows.Close()
+
+	picked := map[string]int{}
+	for _, itemID := range itemIDs {
+		picked[itemID] = required[itemID]
+	}
+	for _, p := range req.Items {
+		amount, ok := required[p.ID]
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Line '%s' does not belong to this pick list", p.ID))
+			return
+		}
+		if p.Picked < 0 || p.Picked > amount {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("pli_picked for '%s' must be between 0 and %d", p.ID, amount))
+			return
+		}
+		picked[p.ID] = p.Picked
+	}
+
+	totalShort := 0
+	for _, itemID := range itemIDs {
+		short := required[itemID] - picked[itemID]
+		totalShort += short
+		if _, err := tx.Exec("UPDATE pick_list_items SET pli_picked = ?, pli_short = ? WHERE pli_id = ?", picked[itemID], short, itemID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+			return
+		}
+	}
+
+	_, err = tx.Exec("UPDATE pick_list SET pick_status = ?, picked_by = ?, picked_at = NOW() WHERE pick_id = ?",
+		pickSelesai, requestUserID(user), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+
+	// Shortages keep the sales in Diproses so they can be picked again
+	var pickedSales []string
+	if totalShort == 0 {
+		rows, err := tx.Query("SELECT doc_id FROM pick_list_documents WHERE pick_id = ? AND doc_type = 'sales'", id)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		var salesIDs []string
+		for rows.Next() {
+			var salesID string
+			if err := rows.Scan(&salesID); err != nil {
+				rows.Close()
+				respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+				return
+			}
+			salesIDs = append(salesIDs, salesID)
+		}
+		rows.Close()
+
+		for _, salesID := range salesIDs {
+			var salesStatus int
+			if err := tx.QueryRow("SELECT sales_status FROM sales WHERE sales_id = ? FOR UPDATE", salesID).Scan(&salesStatus); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+				return
+			}
+			if salesStatus != salesStatusDiproses {
+				continue
+			}
+			salesAudit := startAudit(tx, r, "sales", salesID)
+			if _, err := tx.Exec("UPDATE sales SET sales_status = ?, updated_by = ?, updated_at = NOW() WHERE sales_id = ?",
+				salesStatusDipick, requestUserID(user), salesID); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+				return
+			}
+			if err := logSalesStatus(tx, salesID, &salesStatus, salesStatusDipick, "pick", user, "Picked with pick list "+id); err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			salesAudit.record("pick")
+			pickedSales = append(pickedSales, salesID)
+		}
+	}
+
+	audit.record("confirm")
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
+	pl, err := getPickList(db, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading pick list: "+err.Error())
+		return
+	}
+
+	// Report only the short lines
+	shortages := []PickListItem{}
+	for _, g := range pl.Gudangs {
+		for _, l := range g.Lantais {
+			for _, item := range l.Items {
+				if item.Short != nil && *item.Short > 0 {
+					shortages = append(shortages, item)
+				}
+			}
+		}
+	}
+	if pickedSales == nil {
+		pickedSales = []string{}
+	}
+
+	respondWithJSON(w, map[string]interface{}{
+		"pick_list":    pl,
+		"shortages":    shortages,
+		"sales_picked": pickedSales,
+	})
+}
+
+func cancelPickList(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	audit := startAudit(db, r, "pick_list", id)
+	res, err := db.Exec("UPDATE pick_list SET pick_status = ? WHERE pick_id = ? AND pick_status = ?", pickDibatalkan, id, pickDibuat)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		var status int
+		err = db.QueryRow("SELECT pick_status FROM pick_list WHERE pick_id = ?", id).Scan(&status)
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Pick list not found")
+		} else {
+			respondWithError(w, http.StatusConflict, "Pick list is already "+pickStatusNama[status])
+		}
+		return
+	}
+
+	audit.record("cancel")
+
+	respondWithJSON(w, map[string]interface{}{
+		"pick_id":          id,
+		"pick_status":      pickDibatalkan,
+		"pick_status_nama": pickStatusNama[pickDibatalkan],
+	})
+}
+
+// SetupPickListRoutes sets up all pick list routes
+func SetupPickListRoutes(router *mux.Router) {
+	router.HandleFunc("/createpicklist", createPickList).Methods("POST")
+	router.HandleFunc("/getpicklists", getPickLists).Methods("GET")
+	router.HandleFunc("/getpicklist/{id}", getPickListDetail).Methods("GET")
+	router.HandleFunc("/picklist/{id}/confirm", confirmPickList).Methods("PUT")
+	router.HandleFunc("/picklist/{id}/cancel", cancelPickList).Methods("PUT")
+}
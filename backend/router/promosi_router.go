@@ -0,0 +1,777 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"src/database"
+	"strconv"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// Promotion rule types (promosi.promo_tipe)
+const (
+	promoPersen          = "persen"
+	promoPotongan        = "potongan"
+	promoBeliGratis      = "beli_gratis"
+	promoBundle          = "bundle"
+	promoBelanjaPersen   = "belanja_persen"
+	promoBelanjaPotongan = "belanja_potongan"
+)
+
+var promoTipeValid = map[string]bool{
+	promoPersen:          true,
+	promoPotongan:        true,
+	promoBeliGratis:      true,
+	promoBundle:          true,
+	promoBelanjaPersen:   true,
+	promoBelanjaPotongan: true,
+}
+
+// Promotion scopes (promosi.promo_scope)
+var promoScopeValid = map[string]bool{
+	"semua":  true,
+	"barang": true,
+	"brand":  true,
+}
+
+// Promosi is a date-ranged promotion rule
+type Promosi struct {
+	PromoID         string  `json:"promo_id"`
+	PromoNama       string  `json:"promo_nama"`
+	PromoTipe       string  `json:"promo_tipe"`
+	PromoScope      string  `json:"promo_scope"`
+	PromoBarangID   string  `json:"promo_barang_id,omitempty"`
+	PromoBrandID    string  `json:"promo_brand_id,omitempty"`
+	PromoDaftarID   string  `json:"promo_daftar_id,omitempty"`
+	PromoNilai      float64 `json:"promo_nilai"`
+	PromoMinQty     int     `json:"promo_min_qty"`
+	PromoGratisQty  int     `json:"promo_gratis_qty"`
+	PromoMinBelanja int     `json:"promo_min_belanja"`
+	PromoMaks       int     `json:"promo_maks"`
+	PromoMulai      string  `json:"promo_mulai"`
+	PromoSelesai    string  `json:"promo_selesai"`
+	PromoPrioritas  int     `json:"promo_prioritas"`
+	PromoStackable  bool    `json:"promo_stackable"`
+	PromoStatus     int     `json:"promo_status"`
+	PromoNote       string  `json:"promo_note,omitempty"`
+	CreatedBy       string  `json:"created_by,omitempty"`
+	CreatedAt       string  `json:"created_at"`
+}
+
+type PromosiRequest struct {
+	PromoNama       string  `json:"promo_nama"`
+	PromoTipe       string  `json:"promo_tipe"`
+	PromoScope      string  `json:"promo_scope"` // default semua
+	PromoBarangID   string  `json:"promo_barang_id"`
+	PromoBrandID    string  `json:"promo_brand_id"`
+	PromoDaftarID   string  `json:"promo_daftar_id"`
+	PromoNilai      float64 `json:"promo_nilai"`
+	PromoMinQty     int     `json:"promo_min_qty"` // default 1
+	PromoGratisQty  int     `json:"promo_gratis_qty"`
+	PromoMinBelanja int     `json:"promo_min_belanja"`
+	PromoMaks       int     `json:"promo_maks"`
+	PromoMulai      string  `json:"promo_mulai"`
+	PromoSelesai    string  `json:"promo_selesai"`
+	PromoPrioritas  *int    `json:"promo_prioritas,omitempty"` // default 100
+	PromoStackable  bool    `json:"promo_stackable"`
+	PromoStatus     *int    `json:"promo_status,omitempty"`
+	PromoNote       string  `json:"promo_note"`
+}
+
+// SalesPromosi is a promotion applied to one sale line
+type SalesPromosi struct {
+	SaleItemsID string `json:"sale_items_id"`
+	BarangID    string `json:"barang_id"`
+	BarangNama  string `json:"barang_nama"`
+	PromoID     string `json:"promo_id"`
+	PromoNama   string `json:"promo_nama"`
+	PromoTipe   string `json:"promo_tipe"`
+	Potongan    int    `json:"potongan"`
+}
+
+// LaporanPromosi is the performance of one promotion over a period
+type LaporanPromosi struct {
+	PromoID         string `json:"promo_id"`
+	PromoNama       string `json:"promo_nama"`
+	PromoTipe       string `json:"promo_tipe"`
+	JumlahTransaksi int    `json:"jumlah_transaksi"`
+	JumlahBaris     int    `json:"jumlah_baris"`
+	JumlahQty       int    `json:"jumlah_qty"`
+	TotalPotongan   int    `json:"total_potongan"`
+	Omzet           int    `json:"omzet"` // value of the discounted lines after all promotions
+}
+
+const promosiSelect = `SELECT promo_id, promo_nama, promo_tipe, promo_scope, promo_barang_id, promo_brand_id, promo_daftar_id,
+		promo_nilai, promo_min_qty, promo_gratis_qty, promo_min_belanja, promo_maks,
+		DATE_FORMAT(promo_mulai, '%Y-%m-%d'), DATE_FORMAT(promo_selesai, '%Y-%m-%d'),
+		promo_prioritas, promo_stackable, promo_status, promo_note, created_by, created_at
+	FROM promosi`
+
+// Helper function to scan one promosi row
+func scanPromosi(row interface{ Scan(...interface{}) error }) (*Promosi, error) {
+	var p Promosi
+	var barangID, brandID, daftarID, note, createdBy sql.NullString
+	if err := row.Scan(&p.PromoID, &p.PromoNama, &p.PromoTipe, &p.PromoScope, &barangID, &brandID, &daftarID,
+		&p.PromoNilai, &p.PromoMinQty, &p.PromoGratisQty, &p.PromoMinBelanja, &p.PromoMaks,
+		&p.PromoMulai, &p.PromoSelesai, &p.PromoPrioritas, &p.PromoStackable, &p.PromoStatus,
+		&note, &createdBy, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	p.PromoBarangID = barangID.String
+	p.PromoBrandID = brandID.String
+	p.PromoDaftarID = daftarID.String
+	p.PromoNote = note.String
+	p.CreatedBy = createdBy.String
+	return &p, nil
+}
+
+// promoLine is a sale line while promotions are evaluated
+type promoLine struct {
+	id       string
+	barangID string
+	brandID  string
+	amount   int
+	value    int
+	potongan int
+	// a non-stackable promotion discounted the line
+	locked bool
+	// the line keeps the price quoted in a penawaran
+	tetap bool
+}
+
+func (l *promoLine) sisa() int {
+	return l.amount*l.value - l.potongan
+}
+
+// berlakuUntuk reports whether the promotion's scope covers the line
+func (p *Promosi) berlakuUntuk(l *promoLine) bool {
+	switch p.PromoScope {
+	case "barang":
+		return l.barangID == p.PromoBarangID
+	case "brand":
+		return l.brandID == p.PromoBrandID
+	}
+	return true
+}
+
+// bagiProporsional splits total over the weights, rounding so the parts add
+// up to total exactly
+func bagiProporsional(total int, weights []int) []int {
+	parts := make([]int, len(weights))
+	sum := 0
+	for _, w := range weights {
+		sum += w
+	}
+	if sum <= 0 || total <= 0 {
+		return parts
+	}
+	if total > sum {
+		total = sum
+	}
+	given, last := 0, -1
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		parts[i] = int(int64(total) * int64(w) / int64(sum))
+		given += parts[i]
+		last = i
+	}
+	parts[last] += total - given
+	return parts
+}
+
+// potonganPromosi works out the discount the promotion gives each line,
+// lines are the in-scope lines it may still discount
+func (p *Promosi) potonganPromosi(lines []*promoLine) []int {
+	cuts := make([]int, len(lines))
+	minQty := p.PromoMinQty
+	if minQty < 1 {
+		minQty = 1
+	}
+
+	switch p.PromoTipe {
+	case promoPersen:
+		for i, l := range lines {
+			if l.amount >= minQty {
+				cuts[i] = int(math.Round(float64(l.sisa()) * p.PromoNilai / 100))
+			}
+		}
+	case promoPotongan:
+		for i, l := range lines {
+			if l.amount >= minQty {
+				cuts[i] = int(math.Round(p.PromoNilai)) * l.amount
+			}
+		}
+	case promoBeliGratis:
+		for i, l := range lines {
+			free := l.amount / (minQty + p.PromoGratisQty) * p.PromoGratisQty
+			cuts[i] = free * l.value
+		}
+	case promoBundle:
+		for i, l := range lines {
+			sets := l.amount / minQty
+			if cut := sets * (minQty*l.value - int(math.Round(p.PromoNilai))); cut > 0 {
+				cuts[i] = cut
+			}
+		}
+	case promoBelanjaPersen, promoBelanjaPotongan:
+		weights := make([]int, len(lines))
+		subtotal := 0
+		for i, l := range lines {
+			weights[i] = l.sisa()
+			subtotal += weights[i]
+		}
+		if subtotal <= 0 || subtotal < p.PromoMinBelanja {
+			return cuts
+		}
+		total := int(math.Round(p.PromoNilai))
+		if p.PromoTipe == promoBelanjaPersen {
+			total = int(math.Round(float64(subtotal) * p.PromoNilai / 100))
+		}
+		cuts = bagiProporsional(total, weights)
+	}
+
+	// A line is never discounted below zero
+	sum := 0
+	for i, l := range lines {
+		if cuts[i] > l.sisa() {
+			cuts[i] = l.sisa()
+		}
+		if cuts[i] < 0 {
+			cuts[i] = 0
+		}
+		sum += cuts[i]
+	}
+	if p.PromoMaks > 0 && sum > p.PromoMaks {
+		cuts = bagiProporsional(p.PromoMaks, cuts)
+	}
+	return cuts
+}
+
+// terapkanPromosi evaluates the promotions active on the sales date against
+// the lines of a sale, stores sale_promo_potongan per line and rewrites the
+// sales_promosi records. Lines priced by an accepted penawaran are left alone.
+// Called by updateSalesTotal before PPN is computed.
+func terapkanPromosi(q sqlExecutor, salesID string) error {
+	var salesDate string
+	var daftarID sql.NullString
+	err := q.QueryRow(`SELECT DATE_FORMAT(s.sales_date, '%Y-%m-%d'), c.daftar_id FROM sales s
+		LEFT JOIN customer c ON s.customer_id = c.customer_id WHERE s.sales_id = ?`, salesID).Scan(&salesDate, &daftarID)
+	if err != nil {
+		return fmt.Errorf("error reading sales customer: %v", err)
+	}
+
+	rows, err := q.Query(`SELECT si.sale_items_id, si.barang_id, COALESCE(b.brand_id, ''), si.sale_items_amount, si.sale_value, si.sale_harga_tetap
+		FROM sale_items si LEFT JOIN barang b ON si.barang_id = b.barang_id
+		WHERE si.sales_id = ? ORDER BY si.sale_items_id`, salesID)
+	if err != nil {
+		return fmt.Errorf("error fetching sale items: %v", err)
+	}
+	var lines []*promoLine
+	for rows.Next() {
+		var l promoLine
+		if err := rows.Scan(&l.id, &l.barangID, &l.brandID, &l.amount, &l.value, &l.tetap); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning sale item: %v", err)
+		}
+		lines = append(lines, &l)
+	}
+	rows.Close()
+
+	rows, err = q.Query(promosiSelect+`
+		WHERE promo_status = 1 AND promo_mulai <= ? AND promo_selesai >= ?
+		ORDER BY promo_prioritas, promo_id`, salesDate, salesDate)
+	if err != nil {
+		return fmt.Errorf("error fetching promotions: %v", err)
+	}
+	var promos []*Promosi
+	for rows.Next() {
+		p, err := scanPromosi(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning promotion: %v", err)
+		}
+		promos = append(promos, p)
+	}
+	rows.Close()
+
+	type applied struct {
+		lineID, promoID string
+		potongan        int
+	}
+	var records []applied
+	for _, p := range promos {
+		if p.PromoDaftarID != "" && p.PromoDaftarID != daftarID.String {
+			continue
+		}
+		var eligible []*promoLine
+		for _, l := range lines {
+			if l.tetap || l.locked || (!p.PromoStackable && l.potongan > 0) || !p.berlakuUntuk(l) {
+				continue
+			}
+			eligible = append(eligible, l)
+		}
+		for i, cut := range p.potonganPromosi(eligible) {
+			if cut <= 0 {
+				continue
+			}
+			l := eligible[i]
+			l.potongan += cut
+			l.locked = !p.PromoStackable
+			records = append(records, applied{l.id, p.PromoID, cut})
+		}
+	}
+
+	if _, err := q.Exec("DELETE FROM sales_promosi WHERE sales_id = ?", salesID); err != nil {
+		return fmt.Errorf("error clearing sales promotions: %v", err)
+	}
+	for _, a := range records {
+		_, err := q.Exec("INSERT INTO sales_promosi (sale_items_id, promo_id, sales_id, potongan) VALUES (?, ?, ?, ?)",
+			a.lineID, a.promoID, salesID, a.potongan)
+		if err != nil {
+			return fmt.Errorf("error recording sales promotion: %v", err)
+		}
+	}
+	for _, l := range lines {
+		if _, err := q.Exec("UPDATE sale_items SET sale_promo_potongan = ? WHERE sale_items_id = ?", l.potongan, l.id); err != nil {
+			return fmt.Errorf("error updating sale item promotion: %v", err)
+		}
+	}
+	return nil
+}
+
+// Helper function to validate a promotion request and fill its defaults
+func validatePromosiRequest(q sqlExecutor, req *PromosiRequest) string {
+	if req.PromoNama == "" {
+		return "promo_nama is required"
+	}
+	if !promoTipeValid[req.PromoTipe] {
+		return "promo_tipe must be persen, potongan, beli_gratis, bundle, belanja_persen or belanja_potongan"
+	}
+	if req.PromoScope == "" {
+		req.PromoScope = "semua"
+	}
+	if !promoScopeValid[req.PromoScope] {
+		return "promo_scope must be semua, barang or brand"
+	}
+	if req.PromoMinQty == 0 {
+		req.PromoMinQty = 1
+	}
+	if req.PromoMinQty < 1 {
+		return "promo_min_qty must be at least 1"
+	}
+	if req.PromoNilai < 0 || req.PromoMinBelanja < 0 || req.PromoMaks < 0 || req.PromoGratisQty < 0 {
+		return "promo_nilai, promo_min_belanja, promo_maks and promo_gratis_qty cannot be negative"
+	}
+
+	switch req.PromoTipe {
+	case promoPersen, promoBelanjaPersen:
+		if req.PromoNilai <= 0 || req.PromoNilai > 100 {
+			return "promo_nilai must be a percentage between 0 and 100"
+		}
+	case promoBeliGratis:
+		if req.PromoGratisQty < 1 {
+			return "promo_gratis_qty must be at least 1 for beli_gratis"
+		}
+	case promoBundle:
+		if req.PromoMinQty < 2 {
+			return "promo_min_qty must be at least 2 for bundle"
+		}
+		if req.PromoNilai <= 0 {
+			return "promo_nilai (bundle price) must be greater than 0"
+		}
+	default:
+		if req.PromoNilai <= 0 {
+			return "promo_nilai must be greater than 0"
+		}
+	}
+
+	mulai, err := time.ParseInLocation("2006-01-02", req.PromoMulai, time.Local)
+	if err != nil {
+		return "promo_mulai must be YYYY-MM-DD"
+	}
+	selesai, err := time.ParseInLocation("2006-01-02", req.PromoSelesai, time.Local)
+	if err != nil {
+		return "promo_selesai must be YYYY-MM-DD"
+	}
+	if selesai.Before(mulai) {
+		return "promo_selesai cannot be before promo_mulai"
+	}
+	if req.PromoStatus != nil && *req.PromoStatus != 0 && *req.PromoStatus != 1 {
+		return "promo_status must be 0 or 1"
+	}
+
+	var exists bool
+	switch req.PromoScope {
+	case "barang":
+		req.PromoBrandID = ""
+		q.QueryRow("SELECT EXISTS(SELECT 1 FROM barang WHERE barang_id = ? AND deleted_at IS NULL)", req.PromoBarangID).Scan(&exists)
+		if !exists {
+			return "Invalid promo_barang_id: barang does not exist"
+		}
+	case "brand":
+		req.PromoBarangID = ""
+		q.QueryRow("SELECT EXISTS(SELECT 1 FROM brand WHERE brand_id = ? AND deleted_at IS NULL)", req.PromoBrandID).Scan(&exists)
+		if !exists {
+			return "Invalid promo_brand_id: brand does not exist"
+		}
+	default:
+		req.PromoBarangID = ""
+		req.PromoBrandID = ""
+	}
+	if req.PromoDaftarID != "" {
+		exists = false
+		q.QueryRow("SELECT EXISTS(SELECT 1 FROM daftar_harga WHERE daftar_id = ?)", req.PromoDaftarID).Scan(&exists)
+		if !exists {
+			return "Invalid promo_daftar_id: price list does not exist"
+		}
+	}
+	return ""
+}
+
+// getPromosiList lists promotions. Optional ?status= and ?date= (active on
+// that day).
+func getPromosiList(w http.ResponseWriter, r *http.Request) {
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	query := promosiSelect + " WHERE 1=1"
+	var args []interface{}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += " AND promo_status = ?"
+		args = append(args, status)
+	}
+	if r.URL.Query().Get("date") != "" {
+		day, err := hargaTanggal(r)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		query += " AND promo_status = 1 AND promo_mulai <= ? AND promo_selesai >= ?"
+		args = append(args, day.Format("2006-01-02"), day.Format("2006-01-02"))
+	}
+	query += " ORDER BY promo_prioritas, promo_id"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	promos := []Promosi{}
+	for rows.Next() {
+		p, err := scanPromosi(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		promos = append(promos, *p)
+	}
+	respondWithJSON(w, promos)
+}
+
+func getPromosi(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	p, err := scanPromosi(db.QueryRow(promosiSelect+" WHERE promo_id = ?", id))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Promotion not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	respondWithJSON(w, p)
+}
+
+func createPromosi(w http.ResponseWriter, r *http.Request) {
+	var req PromosiRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	if msg := validatePromosiRequest(db, &req); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	prioritas := 100
+	if req.PromoPrioritas != nil {
+		prioritas = *req.PromoPrioritas
+	}
+	status := 1
+	if req.PromoStatus != nil {
+		status = *req.PromoStatus
+	}
+
+	// Get last promo_id
+	var lastID string
+	err = db.QueryRow("SELECT promo_id FROM promosi ORDER BY promo_id DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching last promo_id")
+		return
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:]) // "PM_00002" -> "00002"
+		nextNum = n + 1
+	}
+	newID := fmt.Sprintf("PM_%05d", nextNum)
+
+	audit := startAudit(db, r, "promosi", newID)
+	_, err = db.Exec(`INSERT INTO promosi (promo_id, promo_nama, promo_tipe, promo_scope, promo_barang_id, promo_brand_id, promo_daftar_id,
+			promo_nilai, promo_min_qty, promo_gratis_qty, promo_min_belanja, promo_maks, promo_mulai, promo_selesai,
+			promo_prioritas, promo_stackable, promo_status, promo_note, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())`,
+		newID, req.PromoNama, req.PromoTipe, req.PromoScope, processNullableStringValue(req.PromoBarangID),
+		processNullableStringValue(req.PromoBrandID), processNullableStringValue(req.PromoDaftarID),
+		req.PromoNilai, req.PromoMinQty, req.PromoGratisQty, req.PromoMinBelanja, req.PromoMaks, req.PromoMulai, req.PromoSelesai,
+		prioritas, req.PromoStackable, status, processNullableStringValue(req.PromoNote), requestUserID(user))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Insert error: "+err.Error())
+		return
+	}
+	audit.record("create")
+
+	p, err := scanPromosi(db.QueryRow(promosiSelect+" WHERE promo_id = ?", newID))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	respondWithJSON(w, p)
+}
+
+// updatePromosi changes a promotion. Sales already priced keep their
+// discount until their lines are edited again.
+func updatePromosi(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req PromosiRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	current, err := scanPromosi(db.QueryRow(promosiSelect+" WHERE promo_id = ?", id))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Promotion not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if msg := validatePromosiRequest(db, &req); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	prioritas := current.PromoPrioritas
+	if req.PromoPrioritas != nil {
+		prioritas = *req.PromoPrioritas
+	}
+	status := current.PromoStatus
+	if req.PromoStatus != nil {
+		status = *req.PromoStatus
+	}
+
+	audit := startAudit(db, r, "promosi", id)
+	_, err = db.Exec(`UPDATE promosi SET promo_nama = ?, promo_tipe = ?, promo_scope = ?, promo_barang_id = ?, promo_brand_id = ?,
+			promo_daftar_id = ?, promo_nilai = ?, promo_min_qty = ?, promo_gratis_qty = ?, promo_min_belanja = ?, promo_maks = ?,
+			promo_mulai = ?, promo_selesai = ?, promo_prioritas = ?, promo_stackable = ?, promo_status = ?, promo_note = ?
+		WHERE promo_id = ?`,
+		req.PromoNama, req.PromoTipe, req.PromoScope, processNullableStringValue(req.PromoBarangID),
+		processNullableStringValue(req.PromoBrandID), processNullableStringValue(req.PromoDaftarID),
+		req.PromoNilai, req.PromoMinQty, req.PromoGratisQty, req.PromoMinBelanja, req.PromoMaks, req.PromoMulai, req.PromoSelesai,
+		prioritas, req.PromoStackable, status, processNullableStringValue(req.PromoNote), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	audit.record("update")
+
+	p, err := scanPromosi(db.QueryRow(promosiSelect+" WHERE promo_id = ?", id))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	respondWithJSON(w, p)
+}
+
+// deletePromosi removes a promotion that was never applied; used promotions
+// are kept for the report and can only be switched off (promo_status 0)
+func deletePromosi(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	var used bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM sales_promosi WHERE promo_id = ?)", id).Scan(&used); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if used {
+		respondWithError(w, http.StatusConflict, "Promotion has been applied to sales, set promo_status to 0 instead")
+		return
+	}
+
+	audit := startAudit(db, r, "promosi", id)
+	res, err := db.Exec("DELETE FROM promosi WHERE promo_id = ?", id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Delete error: "+err.Error())
+		return
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Promotion not found")
+		return
+	}
+	audit.record("delete")
+
+	respondWithJSON(w, map[string]string{
+		"promo_id": id,
+		"status":   "Deleted",
+	})
+}
+
+// getSalesPromosi lists the promotions applied to the lines of a sale
+func getSalesPromosi(w http.ResponseWriter, r *http.Request) {
+	salesID := mux.Vars(r)["sales_id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT sp.sale_items_id, si.barang_id, COALESCE(b.barang_nama, ''), sp.promo_id, p.promo_nama, p.promo_tipe, sp.potongan
+		FROM sales_promosi sp
+		JOIN sale_items si ON sp.sale_items_id = si.sale_items_id
+		JOIN promosi p ON sp.promo_id = p.promo_id
+		LEFT JOIN barang b ON si.barang_id = b.barang_id
+		WHERE sp.sales_id = ?
+		ORDER BY sp.sale_items_id, p.promo_prioritas, p.promo_id`, salesID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	list := []SalesPromosi{}
+	for rows.Next() {
+		var sp SalesPromosi
+		if err := rows.Scan(&sp.SaleItemsID, &sp.BarangID, &sp.BarangNama, &sp.PromoID, &sp.PromoNama, &sp.PromoTipe, &sp.Potongan); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		list = append(list, sp)
+	}
+	respondWithJSON(w, list)
+}
+
+// getLaporanPromosi reports per promotion how often it was applied and what
+// it cost. Optional ?date_from=, ?date_to= (YYYY-MM-DD, on sales_date) and
+// ?promo_id=. Cancelled and draft sales are left out.
+func getLaporanPromosi(w http.ResponseWriter, r *http.Request) {
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	query := `SELECT p.promo_id, p.promo_nama, p.promo_tipe,
+			COUNT(DISTINCT sp.sales_id), COUNT(*), SUM(si.sale_items_amount), SUM(sp.potongan),
+			SUM(si.sale_items_amount * si.sale_value - si.sale_promo_potongan)
+		FROM sales_promosi sp
+		JOIN promosi p ON sp.promo_id = p.promo_id
+		JOIN sale_items si ON sp.sale_items_id = si.sale_items_id
+		JOIN sales s ON sp.sales_id = s.sales_id
+		WHERE s.sales_status NOT IN (0, 5)`
+	var args []interface{}
+	if from := r.URL.Query().Get("date_from"); from != "" {
+		query += " AND DATE(s.sales_date) >= ?"
+		args = append(args, from)
+	}
+	if to := r.URL.Query().Get("date_to"); to != "" {
+		query += " AND DATE(s.sales_date) <= ?"
+		args = append(args, to)
+	}
+	if promoID := r.URL.Query().Get("promo_id"); promoID != "" {
+		query += " AND sp.promo_id = ?"
+		args = append(args, promoID)
+	}
+	query += " GROUP BY p.promo_id, p.promo_nama, p.promo_tipe ORDER BY SUM(sp.potongan) DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	report := []LaporanPromosi{}
+	for rows.Next() {
+		var l LaporanPromosi
+		if err := rows.Scan(&l.PromoID, &l.PromoNama, &l.PromoTipe, &l.JumlahTransaksi, &l.JumlahBaris,
+			&l.JumlahQty, &l.TotalPotongan, &l.Omzet); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		report = append(report, l)
+	}
+	respondWithJSON(w, report)
+}
+
+// SetupPromosiRoutes sets up the promotion routes
+func SetupPromosiRoutes(router *mux.Router) {
+	router.HandleFunc("/getpromosi", getPromosiList).Methods("GET")
+	router.HandleFunc("/getpromosi/{id}", getPromosi).Methods("GET")
+	router.HandleFunc("/createpromosi", createPromosi).Methods("POST")
+	router.HandleFunc("/updatepromosi/{id}", updatePromosi).Methods("PUT")
+	router.HandleFunc("/deletepromosi/{id}", deletePromosi).Methods("DELETE")
+	router.HandleFunc("/getsalespromosi/{sales_id}", getSalesPromosi).Methods("GET")
+	router.HandleFunc("/laporanpromosi", getLaporanPromosi).Methods("GET")
+}
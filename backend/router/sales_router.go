@@ -3,6 +3,7 @@ package router
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"src/database"
@@ -28,16 +29,19 @@ type Sales struct {
 
 // SaleItems represents individual items in a sale
 type SaleItems struct {
-	SaleItemsID     string `json:"sale_items_id"`
-	SalesID         string `json:"sales_id"`
-	BarangID        string `json:"barang_id"`
-	BarangNama      string `json:"barang_nama,omitempty"`
-	GudangID        string `json:"gudang_id"`
-	GudangNama      string `json:"gudang_nama,omitempty"`
-	LantaiID        string `json:"lantai_id"`
-	LantaiNama      string `json:"lantai_nama,omitempty"`
-	SaleItemsAmount int    `json:"sale_items_amount"`
-	SaleValue       int    `json:"sale_value"`
+	SaleItemsID       string `json:"sale_items_id"`
+	SalesID           string `json:"sales_id"`
+	BarangID          string `json:"barang_id"`
+	BarangNama        string `json:"barang_nama,omitempty"`
+	GudangID          string `json:"gudang_id"`
+	GudangNama        string `json:"gudang_nama,omitempty"`
+	LantaiID          string `json:"lantai_id"`
+	LantaiNama        string `json:"lantai_nama,omitempty"`
+	LokasiID          string `json:"lokasi_id,omitempty"`
+	LokasiNama        string `json:"lokasi_nama,omitempty"`
+	SaleItemsAmount   int    `json:"sale_items_amount"`
+	SaleValue         int    `json:"sale_value"`
+	SalePromoPotongan int    `json:"sale_promo_potongan"` // discount of the promotions applied to the line
 }
 
 // SalesRequest for creating sales
@@ -53,8 +57,10 @@ type SaleItemsRequest struct {
 	BarangID        string `json:"barang_id"`
 	GudangID        string `json:"gudang_id"`
 	LantaiID        string `json:"lantai_id"`
+	LokasiID        string `json:"lokasi_id,omitempty"` // Optional: pick bin on the lantai
 	SaleItemsAmount int    `json:"sale_items_amount"`
-	SaleValue       int    `json:"sale_value"`
+	SaleValue       int    `json:"sale_value"` // 0: priced for the customer
+	hargaTetap      bool   // quoted price of an accepted penawaran, no promotions on top
 }
 
 // CombinedSalesRequest for creating sales with items in one request
@@ -77,16 +83,26 @@ type BatchSalesRequest struct {
 
 // SalesDetail for detailed response with items
 type SalesDetail struct {
-	SalesID        string      `json:"sales_id"`
-	CustomerID     string      `json:"customer_id"`
-	CustomerName   string      `json:"customer_name"`
-	CustomerKontak string      `json:"customer_kontak"`
-	CustomerAlamat string      `json:"customer_alamat"`
-	SalesTotal     int         `json:"sales_total"`
-	SalesPayment   string      `json:"sales_payment"`
-	SalesDate      string      `json:"sales_date"`
-	SalesStatus    int         `json:"sales_status"`
-	SaleItems      []SaleItems `json:"sale_items"`
+	SalesID           string            `json:"sales_id"`
+	CustomerID        string            `json:"customer_id"`
+	CustomerName      string            `json:"customer_name"`
+	CustomerKontak    string            `json:"customer_kontak"`
+	CustomerAlamat    string            `json:"customer_alamat"`
+	SalesTotal        int               `json:"sales_total"`
+	SalesPayment      string            `json:"sales_payment"`
+	SalesDate         string            `json:"sales_date"`
+	SalesStatus       int               `json:"sales_status"`
+	SalesDPP          int               `json:"sales_dpp"`
+	SalesPPN          int               `json:"sales_ppn"`
+	SalesInvoiceNo    string            `json:"sales_invoice_no,omitempty"`
+	SalesCreditNoteNo string            `json:"sales_credit_note_no,omitempty"`
+	CreatedBy         string            `json:"created_by,omitempty"`
+	CreatedByNama     string            `json:"created_by_nama,omitempty"`
+	UpdatedBy         string            `json:"updated_by,omitempty"`
+	UpdatedByNama     string            `json:"updated_by_nama,omitempty"`
+	UpdatedAt         string            `json:"updated_at,omitempty"`
+	SaleItems         []SaleItems       `json:"sale_items"`
+	Pembayaran        []SalesPembayaran `json:"pembayaran"`
 }
 
 // SetupSalesRoutes registers all sales-related routes
@@ -129,16 +145,30 @@ func getSales(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	// Get all sales first
+	list, err := parseListQuery(r, salesListSpec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get the sales first, then the items of just those sales
+	from := `
+		FROM sales s
+		LEFT JOIN customer c ON s.customer_id = c.customer_id`
 	salesQuery := `
 		SELECT s.sales_id, s.customer_id, c.customer_nama, c.customer_kontak, c.customer_alamat,
-		       s.sales_total, s.sales_payment, s.sales_date, s.sales_status
-		FROM sales s
-		LEFT JOIN customer c ON s.customer_id = c.customer_id
-		ORDER BY s.sales_date DESC, s.sales_id DESC
-	`
+		       s.sales_total, s.sales_payment, s.sales_date, s.sales_status,
+		       s.created_by, uc.users_nama, s.updated_by, uu.users_nama, s.updated_at` + from + `
+		LEFT JOIN users uc ON s.created_by = uc.users_id
+		LEFT JOIN users uu ON s.updated_by = uu.users_id` + list.whereSQL() + " ORDER BY " + list.orderBy
+
+	if format := exportFormat(r); format != "" {
+		exportSales(w, db, format, salesQuery, list.args)
+		return
+	}
 
-	rows, err := db.Query(salesQuery)
+	limit, limitArgs := list.limitSQL()
+	rows, err := db.Query(salesQuery+limit, append(list.args, limitArgs...)...)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -152,35 +182,60 @@ func getSales(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var s SalesDetail
 		s.SaleItems = []SaleItems{} // Initialize empty slice
+		var createdBy, createdByNama, updatedBy, updatedByNama, updatedAt sql.NullString
 		err := rows.Scan(&s.SalesID, &s.CustomerID, &s.CustomerName, &s.CustomerKontak, &s.CustomerAlamat,
-			&s.SalesTotal, &s.SalesPayment, &s.SalesDate, &s.SalesStatus)
+			&s.SalesTotal, &s.SalesPayment, &s.SalesDate, &s.SalesStatus,
+			&createdBy, &createdByNama, &updatedBy, &updatedByNama, &updatedAt)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		s.CreatedBy = createdBy.String
+		s.CreatedByNama = createdByNama.String
+		s.UpdatedBy = updatedBy.String
+		s.UpdatedByNama = updatedByNama.String
+		s.UpdatedAt = updatedAt.String
 		salesMap[s.SalesID] = &s
 		salesOrder = append(salesOrder, s.SalesID)
 	}
 
+	total, err := list.total(db, "COUNT(*)", from, len(salesOrder))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// If no sales found, return empty array
 	if len(salesMap) == 0 {
-		respondWithJSON(w, []SalesDetail{})
+		respondWithList(w, list, total, []SalesDetail{})
 		return
 	}
 
-	// Get all sale items for all sales in one query (more efficient)
+	// Get the items of the listed sales in one query: the same filters, or
+	// the sales of this page
+	itemsWhere, itemsArgs := list.whereSQL(), list.args
+	if list.limit > 0 {
+		itemsWhere = " WHERE si.sales_id IN (" + sqlPlaceholders(len(salesOrder)) + ")"
+		itemsArgs = make([]interface{}, len(salesOrder))
+		for i, id := range salesOrder {
+			itemsArgs[i] = id
+		}
+	}
 	itemsQuery := `
 		SELECT si.sale_items_id, si.sales_id, si.barang_id, b.barang_nama,
 		       si.gudang_id, g.gudang_nama, si.lantai_id, gl.lantai_nama,
-		       si.sale_items_amount, si.sale_value
+		       si.lokasi_id, gk.lokasi_nama, si.sale_items_amount, si.sale_value, si.sale_promo_potongan
 		FROM sale_items si
+		JOIN sales s ON si.sales_id = s.sales_id
+		LEFT JOIN customer c ON s.customer_id = c.customer_id
 		LEFT JOIN barang b ON si.barang_id = b.barang_id
 		LEFT JOIN list_gudang g ON si.gudang_id = g.gudang_id
 		LEFT JOIN gudang_lantai gl ON si.lantai_id = gl.lantai_id
+		LEFT JOIN gudang_lokasi gk ON si.lokasi_id = gk.lokasi_id` + itemsWhere + `
 		ORDER BY si.sales_id, si.sale_items_id
 	`
 
-	itemRows, err := db.Query(itemsQuery)
+	itemRows, err := db.Query(itemsQuery, itemsArgs...)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -190,10 +245,10 @@ func getSales(w http.ResponseWriter, r *http.Request) {
 	// Group items by sales_id
 	for itemRows.Next() {
 		var item SaleItems
-		var lantaiID, lantaiNama sql.NullString
+		var lantaiID, lantaiNama, lokasiID, lokasiNama sql.NullString
 		err := itemRows.Scan(&item.SaleItemsID, &item.SalesID, &item.BarangID, &item.BarangNama,
 			&item.GudangID, &item.GudangNama, &lantaiID, &lantaiNama,
-			&item.SaleItemsAmount, &item.SaleValue)
+			&lokasiID, &lokasiNama, &item.SaleItemsAmount, &item.SaleValue, &item.SalePromoPotongan)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -206,6 +261,8 @@ func getSales(w http.ResponseWriter, r *http.Request) {
 		if lantaiNama.Valid {
 			item.LantaiNama = lantaiNama.String
 		}
+		item.LokasiID = lokasiID.String
+		item.LokasiNama = lokasiNama.String
 
 		// Add item to corresponding sale
 		if sale, exists := salesMap[item.SalesID]; exists {
@@ -219,7 +276,88 @@ func getSales(w http.ResponseWriter, r *http.Request) {
 		salesList = append(salesList, *salesMap[salesID])
 	}
 
-	respondWithJSON(w, salesList)
+	respondWithList(w, list, total, salesList)
+}
+
+// salesListSpec is the list contract of getSales
+var salesListSpec = listSpec{
+	filters: []listFilter{
+		{"created_by", "s.created_by = ?"},
+		{"date", "DATE(s.sales_date) = ?"},
+		{"date_from", "s.sales_date >= ?"},
+		{"date_to", "s.sales_date < DATE_ADD(?, INTERVAL 1 DAY)"},
+		{"customer", "s.customer_id = ?"},
+		{"status", "s.sales_status = ?"},
+	},
+	search: []string{"s.sales_id", "c.customer_nama", "c.customer_kontak"},
+	sorts: map[string]string{
+		"date":     "s.sales_date",
+		"id":       "s.sales_id",
+		"total":    "s.sales_total",
+		"customer": "c.customer_nama",
+		"status":   "s.sales_status",
+	},
+	sortDefault:  "date",
+	orderDefault: "desc",
+	key:          "s.sales_id",
+}
+
+var salesExportKolom = []exportKolom{
+	{"No. Transaksi", exportTeks},
+	{"Tanggal", exportWaktu},
+	{"Kode Customer", exportTeks},
+	{"Customer", exportTeks},
+	{"Kontak", exportTeks},
+	{"Alamat", exportTeks},
+	{"Total", exportRupiah},
+	{"Pembayaran", exportTeks},
+	{"Status", exportTeks},
+	{"Dibuat Oleh", exportTeks},
+	{"Diubah Oleh", exportTeks},
+	{"Diubah Pada", exportWaktu},
+}
+
+// exportSales streams the getSales list as a spreadsheet, one row per sale.
+// salesQuery is getSales' own query, so the columns and filters match.
+func exportSales(w http.ResponseWriter, db *sql.DB, format, salesQuery string, args []interface{}) {
+	rows, err := db.Query(salesQuery, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	export, err := newExportWriter(w, format, "penjualan", salesExportKolom)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for rows.Next() {
+		var salesID, salesDate, salesPayment string
+		var salesTotal, salesStatus int
+		var customerID, customerNama, customerKontak, customerAlamat sql.NullString
+		var createdBy, createdByNama, updatedBy, updatedByNama, updatedAt sql.NullString
+		if err := rows.Scan(&salesID, &customerID, &customerNama, &customerKontak, &customerAlamat,
+			&salesTotal, &salesPayment, &salesDate, &salesStatus,
+			&createdBy, &createdByNama, &updatedBy, &updatedByNama, &updatedAt); err != nil {
+			http.Error(w, export.Gagal(err).Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := export.Tulis(salesID, salesDate, customerID.String, customerNama.String, customerKontak.String,
+			customerAlamat.String, salesTotal, salesPayment, salesStatusNama[salesStatus],
+			createdByNama.String, updatedByNama.String, updatedAt.String); err != nil {
+			http.Error(w, export.Gagal(err).Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, export.Gagal(err).Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := export.Selesai(); err != nil {
+		http.Error(w, export.Gagal(err).Error(), http.StatusInternalServerError)
+	}
 }
 
 // getSalesDetail retrieves a single sale with all its items
@@ -234,59 +372,81 @@ func getSalesDetail(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	salesID := vars["id"]
 
+	detail, err := loadSalesDetail(db, salesID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Sales not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, detail)
+}
+
+// loadSalesDetail reads a sale with its items, as served by /getsale/{id}
+func loadSalesDetail(q sqlExecutor, salesID string) (*SalesDetail, error) {
 	// Get sales information
 	salesQuery := `
 		SELECT s.sales_id, s.customer_id, c.customer_nama, c.customer_kontak, c.customer_alamat,
-		       s.sales_total, s.sales_payment, s.sales_date, s.sales_status
+		       s.sales_total, s.sales_payment, s.sales_date, s.sales_status, s.sales_invoice_no, s.sales_credit_note_no,
+		       s.sales_dpp, s.sales_ppn,
+		       s.created_by, uc.users_nama, s.updated_by, uu.users_nama, s.updated_at
 		FROM sales s
 		LEFT JOIN customer c ON s.customer_id = c.customer_id
+		LEFT JOIN users uc ON s.created_by = uc.users_id
+		LEFT JOIN users uu ON s.updated_by = uu.users_id
 		WHERE s.sales_id = ?
 	`
 
 	var detail SalesDetail
-	err = db.QueryRow(salesQuery, salesID).Scan(
+	var invoiceNo, creditNoteNo, createdBy, createdByNama, updatedBy, updatedByNama, updatedAt sql.NullString
+	err := q.QueryRow(salesQuery, salesID).Scan(
 		&detail.SalesID, &detail.CustomerID, &detail.CustomerName, &detail.CustomerKontak, &detail.CustomerAlamat,
-		&detail.SalesTotal, &detail.SalesPayment, &detail.SalesDate, &detail.SalesStatus,
+		&detail.SalesTotal, &detail.SalesPayment, &detail.SalesDate, &detail.SalesStatus, &invoiceNo, &creditNoteNo,
+		&detail.SalesDPP, &detail.SalesPPN,
+		&createdBy, &createdByNama, &updatedBy, &updatedByNama, &updatedAt,
 	)
-
-	if err == sql.ErrNoRows {
-		http.Error(w, "Sales not found", http.StatusNotFound)
-		return
-	} else if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if err != nil {
+		return nil, err
 	}
+	detail.SalesInvoiceNo = invoiceNo.String
+	detail.SalesCreditNoteNo = creditNoteNo.String
+	detail.CreatedBy = createdBy.String
+	detail.CreatedByNama = createdByNama.String
+	detail.UpdatedBy = updatedBy.String
+	detail.UpdatedByNama = updatedByNama.String
+	detail.UpdatedAt = updatedAt.String
 
 	// Get sale items
 	itemsQuery := `
 		SELECT si.sale_items_id, si.sales_id, si.barang_id, b.barang_nama,
 		       si.gudang_id, g.gudang_nama, si.lantai_id, gl.lantai_nama,
-		       si.sale_items_amount, si.sale_value
+		       si.lokasi_id, gk.lokasi_nama, si.sale_items_amount, si.sale_value, si.sale_promo_potongan
 		FROM sale_items si
 		LEFT JOIN barang b ON si.barang_id = b.barang_id
 		LEFT JOIN list_gudang g ON si.gudang_id = g.gudang_id
 		LEFT JOIN gudang_lantai gl ON si.lantai_id = gl.lantai_id
+		LEFT JOIN gudang_lokasi gk ON si.lokasi_id = gk.lokasi_id
 		WHERE si.sales_id = ?
 		ORDER BY si.sale_items_id
 	`
 
-	rows, err := db.Query(itemsQuery, salesID)
+	rows, err := q.Query(itemsQuery, salesID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
 	var items []SaleItems
 	for rows.Next() {
 		var item SaleItems
-		var lantaiID, lantaiNama sql.NullString
+		var lantaiID, lantaiNama, lokasiID, lokasiNama sql.NullString
 		err := rows.Scan(&item.SaleItemsID, &item.SalesID, &item.BarangID, &item.BarangNama,
 			&item.GudangID, &item.GudangNama, &lantaiID, &lantaiNama,
-			&item.SaleItemsAmount, &item.SaleValue)
+			&lokasiID, &lokasiNama, &item.SaleItemsAmount, &item.SaleValue, &item.SalePromoPotongan)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
 
 		// Handle nullable lantai fields
@@ -296,12 +456,23 @@ func getSalesDetail(w http.ResponseWriter, r *http.Request) {
 		if lantaiNama.Valid {
 			item.LantaiNama = lantaiNama.String
 		}
+		item.LokasiID = lokasiID.String
+		item.LokasiNama = lokasiNama.String
 
 		items = append(items, item)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
 	detail.SaleItems = items
-	respondWithJSON(w, detail)
+
+	detail.Pembayaran, err = getSalesPembayaran(q, salesID)
+	if err != nil {
+		return nil, err
+	}
+	return &detail, nil
 }
 
 // createSales creates a new sales record only
@@ -335,27 +506,40 @@ func createSales(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.SalesStatus != 1 && req.SalesStatus != 2 {
-		http.Error(w, "sales_status must be 1 (Selesai) or 2 (Diproses)", http.StatusBadRequest)
+	if !validSalesCreateStatus(req.SalesStatus) {
+		http.Error(w, "sales_status must be 0 (Draft), 1 (Selesai) or 2 (Diproses)", http.StatusBadRequest)
 		return
 	}
 
 	// Validate customer exists
 	var customerExists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM customer WHERE customer_id = ?)", req.CustomerID).Scan(&customerExists)
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM customer WHERE customer_id = ? AND deleted_at IS NULL)", req.CustomerID).Scan(&customerExists)
 	if err != nil || !customerExists {
 		http.Error(w, "Invalid customer_id: customer does not exist", http.StatusBadRequest)
 		return
 	}
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
 	// Set default date if not provided
 	if req.SalesDate == "" {
 		req.SalesDate = time.Now().Format("2006-01-02")
 	}
 
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
 	// Generate new sales ID
 	var lastID string
-	err = db.QueryRow("SELECT sales_id FROM sales ORDER BY sales_id DESC LIMIT 1").Scan(&lastID)
+	err = tx.QueryRow("SELECT sales_id FROM sales ORDER BY sales_id DESC LIMIT 1").Scan(&lastID)
 
 	var newID string
 	if err == sql.ErrNoRows {
@@ -368,25 +552,50 @@ func createSales(w http.ResponseWriter, r *http.Request) {
 		newID = fmt.Sprintf("SL_%07d", lastNum+1)
 	}
 
+	audit := startAudit(tx, r, "sales", newID)
+
 	// Insert sales with initial total of 0
-	query := `INSERT INTO sales (sales_id, customer_id, sales_total, sales_payment, sales_date, sales_status) 
-	          VALUES (?, ?, 0, ?, ?, ?)`
+	query := `INSERT INTO sales (sales_id, customer_id, sales_total, sales_payment, sales_date, sales_status, created_by) 
+	          VALUES (?, ?, 0, ?, ?, ?, ?)`
 
-	_, err = db.Exec(query, newID, req.CustomerID, req.SalesPayment, req.SalesDate, req.SalesStatus)
+	_, err = tx.Exec(query, newID, req.CustomerID, req.SalesPayment, req.SalesDate, req.SalesStatus, requestUserID(user))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if err := logSalesStatus(tx, newID, nil, req.SalesStatus, "create", user, ""); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Drafts get their invoice number on confirmation
+	invoiceNo := ""
+	if req.SalesStatus != salesStatusDraft {
+		invoiceNo, err = assignSalesInvoiceNo(tx, newID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	audit.record("create")
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	response := map[string]interface{}{
-		"sales_id":      newID,
-		"customer_id":   req.CustomerID,
-		"sales_total":   0,
-		"sales_payment": req.SalesPayment,
-		"sales_date":    req.SalesDate,
-		"sales_status":  req.SalesStatus,
-		"status":        "Created",
-		"message":       "Sales record created successfully",
+		"sales_id":         newID,
+		"sales_invoice_no": invoiceNo,
+		"customer_id":      req.CustomerID,
+		"sales_total":      0,
+		"sales_payment":    req.SalesPayment,
+		"sales_date":       req.SalesDate,
+		"sales_status":     req.SalesStatus,
+		"status":           "Created",
+		"message":          "Sales record created successfully",
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -424,8 +633,8 @@ func createCombinedSales(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.SalesStatus != 1 && req.SalesStatus != 2 {
-		http.Error(w, "sales_status must be 1 (Selesai) or 2 (Diproses)", http.StatusBadRequest)
+	if !validSalesCreateStatus(req.SalesStatus) {
+		http.Error(w, "sales_status must be 0 (Draft), 1 (Selesai) or 2 (Diproses)", http.StatusBadRequest)
 		return
 	}
 
@@ -436,12 +645,18 @@ func createCombinedSales(w http.ResponseWriter, r *http.Request) {
 
 	// Validate customer exists
 	var customerExists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM customer WHERE customer_id = ?)", req.CustomerID).Scan(&customerExists)
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM customer WHERE customer_id = ? AND deleted_at IS NULL)", req.CustomerID).Scan(&customerExists)
 	if err != nil || !customerExists {
 		http.Error(w, "Invalid customer_id: customer does not exist", http.StatusBadRequest)
 		return
 	}
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
 	// Set default date if not provided
 	if req.SalesDate == "" {
 		req.SalesDate = time.Now().Format("2006-01-02")
@@ -461,14 +676,14 @@ func createCombinedSales(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, fmt.Sprintf("sale_items_amount must be greater than 0 for item #%d", i+1), http.StatusBadRequest)
 			return
 		}
-		if item.SaleValue <= 0 {
-			http.Error(w, fmt.Sprintf("sale_value must be greater than 0 for item #%d", i+1), http.StatusBadRequest)
+		if item.SaleValue < 0 {
+			http.Error(w, fmt.Sprintf("sale_value cannot be negative for item #%d", i+1), http.StatusBadRequest)
 			return
 		}
 
 		// Validate barang exists
 		var barangExists bool
-		err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM barang WHERE barang_id = ?)", item.BarangID).Scan(&barangExists)
+		err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM barang WHERE barang_id = ? AND deleted_at IS NULL)", item.BarangID).Scan(&barangExists)
 		if err != nil || !barangExists {
 			http.Error(w, fmt.Sprintf("Invalid barang_id for item #%d: barang does not exist", i+1), http.StatusBadRequest)
 			return
@@ -476,21 +691,43 @@ func createCombinedSales(w http.ResponseWriter, r *http.Request) {
 
 		// Validate gudang exists
 		var gudangExists bool
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM list_gudang WHERE gudang_id = ?)", item.GudangID).Scan(&gudangExists)
+		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM list_gudang WHERE gudang_id = ? AND gudang_status = 1)", item.GudangID).Scan(&gudangExists)
 		if err != nil || !gudangExists {
-			http.Error(w, fmt.Sprintf("Invalid gudang_id for item #%d: gudang does not exist", i+1), http.StatusBadRequest)
+			http.Error(w, fmt.Sprintf("Invalid gudang_id for item #%d: gudang does not exist or is archived", i+1), http.StatusBadRequest)
 			return
 		}
 
 		// Validate lantai exists and belongs to gudang
 		if item.LantaiID != "" {
 			var lantaiExists bool
-			err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM gudang_lantai WHERE lantai_id = ? AND gudang_id = ?)", item.LantaiID, item.GudangID).Scan(&lantaiExists)
+			err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM gudang_lantai WHERE lantai_id = ? AND gudang_id = ? AND lantai_status = 1)", item.LantaiID, item.GudangID).Scan(&lantaiExists)
 			if err != nil || !lantaiExists {
-				http.Error(w, fmt.Sprintf("Invalid lantai_id for item #%d: lantai does not exist or does not belong to gudang", i+1), http.StatusBadRequest)
+				http.Error(w, fmt.Sprintf("Invalid lantai_id for item #%d: lantai does not exist, is inactive or does not belong to gudang", i+1), http.StatusBadRequest)
 				return
 			}
 		}
+
+		// Validate lokasi belongs to the lantai (a pick bin needs an explicit lantai)
+		if item.LokasiID != "" {
+			if item.LantaiID == "" {
+				http.Error(w, fmt.Sprintf("lantai_id is required when lokasi_id is set for item #%d", i+1), http.StatusBadRequest)
+				return
+			}
+			if err := validateLokasi(db, item.LokasiID, item.LantaiID); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid lokasi_id for item #%d: %v", i+1, err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Lines without sale_value get the customer's price (price list tiers included)
+		if item.SaleValue == 0 {
+			harga, err := hargaSaleLine(db, req.CustomerID, req.SalesDate, item.BarangID, item.SaleItemsAmount)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Item #%d: %v", i+1, err), http.StatusBadRequest)
+				return
+			}
+			req.SaleItems[i].SaleValue = harga
+		}
 	}
 
 	// Start transaction
@@ -501,16 +738,63 @@ func createCombinedSales(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tx.Rollback()
 
+	newSalesID, salesTotal, createdItems, err := insertCombinedSales(tx, req, user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	auditCreated(tx, r, "sales", newSalesID, "create")
+
+	// A cash sale at the counter is paid on the spot, into the cashier's shift
+	if req.SalesPayment == "1" && req.SalesStatus != salesStatusDraft {
+		payment := []PembayaranRequest{{Metode: bayarTunai, Amount: salesTotal, Diterima: salesTotal}}
+		if err := savePembayaran(tx, newSalesID, payment, user); errors.Is(err, errKasirUser) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		} else if errors.Is(err, errKasirShift) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		auditPembayaran(tx, r, newSalesID, nil)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"sales_id":      newSalesID,
+		"customer_id":   req.CustomerID,
+		"sales_total":   salesTotal,
+		"sales_payment": req.SalesPayment,
+		"sales_date":    req.SalesDate,
+		"sales_status":  req.SalesStatus,
+		"sale_items":    createdItems,
+		"status":        "Created",
+		"message":       fmt.Sprintf("Sales with %d items created successfully", len(createdItems)),
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respondWithJSON(w, response)
+}
+
+// insertCombinedSales writes a sales header with its lines inside tx and takes
+// the stock unless the sale is a draft. The request must already be validated.
+func insertCombinedSales(tx sqlExecutor, req CombinedSalesRequest, user *UserData) (string, int, []SaleItems, error) {
 	// Generate new sales ID
 	var lastSalesID string
-	err = tx.QueryRow("SELECT sales_id FROM sales ORDER BY sales_id DESC LIMIT 1").Scan(&lastSalesID)
+	err := tx.QueryRow("SELECT sales_id FROM sales ORDER BY sales_id DESC LIMIT 1").Scan(&lastSalesID)
 
 	var newSalesID string
 	if err == sql.ErrNoRows {
 		newSalesID = "SL_0000001"
 	} else if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return "", 0, nil, err
 	} else {
 		lastNum, _ := strconv.Atoi(lastSalesID[3:])
 		newSalesID = fmt.Sprintf("SL_%07d", lastNum+1)
@@ -523,13 +807,12 @@ func createCombinedSales(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Insert sales
-	salesQuery := `INSERT INTO sales (sales_id, customer_id, sales_total, sales_payment, sales_date, sales_status) 
-	               VALUES (?, ?, ?, ?, ?, ?)`
+	salesQuery := `INSERT INTO sales (sales_id, customer_id, sales_total, sales_payment, sales_date, sales_status, created_by) 
+	               VALUES (?, ?, ?, ?, ?, ?, ?)`
 
-	_, err = tx.Exec(salesQuery, newSalesID, req.CustomerID, salesTotal, req.SalesPayment, req.SalesDate, req.SalesStatus)
+	_, err = tx.Exec(salesQuery, newSalesID, req.CustomerID, salesTotal, req.SalesPayment, req.SalesDate, req.SalesStatus, requestUserID(user))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return "", 0, nil, err
 	}
 
 	// Get last sale item ID
@@ -540,62 +823,32 @@ func createCombinedSales(w http.ResponseWriter, r *http.Request) {
 	if err == sql.ErrNoRows {
 		itemIDNum = 1
 	} else if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return "", 0, nil, err
 	} else {
 		itemIDNum, _ = strconv.Atoi(lastItemID[3:])
 		itemIDNum++
 	}
 
 	// Insert sale items and reduce stock
-	itemQuery := `INSERT INTO sale_items (sale_items_id, sales_id, barang_id, gudang_id, lantai_id, sale_items_amount, sale_value) 
-	              VALUES (?, ?, ?, ?, ?, ?, ?)`
+	itemQuery := `INSERT INTO sale_items (sale_items_id, sales_id, barang_id, gudang_id, lantai_id, lokasi_id, sale_items_amount, sale_value, sale_harga_tetap, created_by) 
+	              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	var createdItems []SaleItems
 	for _, item := range req.SaleItems {
 		newItemID := fmt.Sprintf("SI_%07d", itemIDNum)
 
-		_, err = tx.Exec(itemQuery, newItemID, newSalesID, item.BarangID, item.GudangID, item.LantaiID, item.SaleItemsAmount, item.SaleValue)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// Reduce stock in stock_gudang (using lantai_id)
-		var currentStock int
-		var stockID string
+		// Drafts take no stock until they are confirmed
 		lantaiID := item.LantaiID
-		if lantaiID == "" {
-			// If no lantai specified, get first available lantai for this gudang
-			err = tx.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? ORDER BY lantai_no LIMIT 1", item.GudangID).Scan(&lantaiID)
+		if salesHoldsStock(req.SalesStatus) {
+			lantaiID, err = takeSaleStock(tx, item.BarangID, item.GudangID, item.LantaiID, item.LokasiID, item.SaleItemsAmount)
 			if err != nil {
-				http.Error(w, fmt.Sprintf("Error finding lantai for gudang: %v", err), http.StatusInternalServerError)
-				return
+				return "", 0, nil, fmt.Errorf("Item #%d: %v", len(createdItems)+1, err)
 			}
 		}
-		err = tx.QueryRow("SELECT stock_id, stock_barang FROM stock_gudang WHERE barang_id = ? AND lantai_id = ?",
-			item.BarangID, lantaiID).Scan(&stockID, &currentStock)
 
-		if err == sql.ErrNoRows {
-			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Stock not found for barang_id %s in gudang_id %s", item.BarangID, item.GudangID), http.StatusBadRequest)
-			return
-		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		newStock := currentStock - item.SaleItemsAmount
-		if newStock < 0 {
-			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Insufficient stock for item #%d. Available: %d, Required: %d", itemIDNum-int(itemIDNum), currentStock, item.SaleItemsAmount), http.StatusBadRequest)
-			return
-		}
-
-		_, err = tx.Exec("UPDATE stock_gudang SET stock_barang = ? WHERE stock_id = ?", newStock, stockID)
+		_, err = tx.Exec(itemQuery, newItemID, newSalesID, item.BarangID, item.GudangID, lantaiID, processNullableStringValue(item.LokasiID), item.SaleItemsAmount, item.SaleValue, item.hargaTetap, requestUserID(user))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return "", 0, nil, err
 		}
 
 		createdItems = append(createdItems, SaleItems{
@@ -603,6 +856,8 @@ func createCombinedSales(w http.ResponseWriter, r *http.Request) {
 			SalesID:         newSalesID,
 			BarangID:        item.BarangID,
 			GudangID:        item.GudangID,
+			LantaiID:        lantaiID,
+			LokasiID:        item.LokasiID,
 			SaleItemsAmount: item.SaleItemsAmount,
 			SaleValue:       item.SaleValue,
 		})
@@ -610,26 +865,23 @@ func createCombinedSales(w http.ResponseWriter, r *http.Request) {
 		itemIDNum++
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	// PPN per line and the final total
+	if err := updateSalesTotal(tx, newSalesID); err != nil {
+		return "", 0, nil, err
 	}
-
-	response := map[string]interface{}{
-		"sales_id":      newSalesID,
-		"customer_id":   req.CustomerID,
-		"sales_total":   salesTotal,
-		"sales_payment": req.SalesPayment,
-		"sales_date":    req.SalesDate,
-		"sales_status":  req.SalesStatus,
-		"sale_items":    createdItems,
-		"status":        "Created",
-		"message":       fmt.Sprintf("Sales with %d items created successfully", len(createdItems)),
+	if err := tx.QueryRow("SELECT sales_total FROM sales WHERE sales_id = ?", newSalesID).Scan(&salesTotal); err != nil {
+		return "", 0, nil, err
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	respondWithJSON(w, response)
+	if err := logSalesStatus(tx, newSalesID, nil, req.SalesStatus, "create", user, ""); err != nil {
+		return "", 0, nil, err
+	}
+	if req.SalesStatus != salesStatusDraft {
+		if _, err := assignSalesInvoiceNo(tx, newSalesID); err != nil {
+			return "", 0, nil, err
+		}
+	}
+	return newSalesID, salesTotal, createdItems, nil
 }
 
 // createBatchSales creates a sales record with multiple items (alias for createCombinedSales)
@@ -661,24 +913,65 @@ func updateSales(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.SalesPayment == "" {
-		http.Error(w, "sales_payment is required", http.StatusBadRequest)
+	// sales_payment is optional: left out, the current code is kept
+	if req.SalesPayment != "" && req.SalesPayment != "1" && req.SalesPayment != "2" && req.SalesPayment != "3" {
+		http.Error(w, "sales_payment must be 1 (Tunai), 2 (Transfer), or 3 (Kredit)", http.StatusBadRequest)
 		return
 	}
 
-	if req.SalesPayment != "1" && req.SalesPayment != "2" && req.SalesPayment != "3" {
-		http.Error(w, "sales_payment must be 1 (Tunai), 2 (Transfer), or 3 (Kredit)", http.StatusBadRequest)
+	user, err := requestUser(db, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Status only moves through PUT /sales/{id}/{action}; the row lock keeps a
+	// concurrent transition or payment from interleaving with the update
+	var currentStatus int
+	var currentPayment string
+	err = tx.QueryRow("SELECT sales_status, sales_payment FROM sales WHERE sales_id = ? FOR UPDATE", salesID).Scan(&currentStatus, &currentPayment)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Sales not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if req.SalesStatus != 0 && req.SalesStatus != currentStatus {
+		http.Error(w, "sales_status cannot be changed here, use PUT /sales/{id}/{action}", http.StatusConflict)
+		return
+	}
+	if currentStatus == salesStatusLunas || currentStatus == salesStatusBatal {
+		http.Error(w, fmt.Sprintf("Sales in status %s can no longer be edited", salesStatusNama[currentStatus]), http.StatusConflict)
 		return
 	}
 
-	if req.SalesStatus != 1 && req.SalesStatus != 2 {
-		http.Error(w, "sales_status must be 1 (Selesai) or 2 (Diproses)", http.StatusBadRequest)
+	// Once payment lines are recorded sales_payment is their summary code and
+	// only changes with them
+	var bayarCount int
+	err = tx.QueryRow("SELECT COUNT(*) FROM sales_pembayaran WHERE sales_id = ?", salesID).Scan(&bayarCount)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if bayarCount > 0 && req.SalesPayment != "" && req.SalesPayment != currentPayment {
+		http.Error(w, "sales_payment follows the recorded payments, change them with PUT /updatesalespembayaran/{id}", http.StatusConflict)
 		return
 	}
+	if req.SalesPayment == "" {
+		req.SalesPayment = currentPayment
+	}
 
 	// Validate customer exists
 	var customerExists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM customer WHERE customer_id = ?)", req.CustomerID).Scan(&customerExists)
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM customer WHERE customer_id = ? AND deleted_at IS NULL)", req.CustomerID).Scan(&customerExists)
 	if err != nil || !customerExists {
 		http.Error(w, "Invalid customer_id: customer does not exist", http.StatusBadRequest)
 		return
@@ -688,33 +981,41 @@ func updateSales(w http.ResponseWriter, r *http.Request) {
 		req.SalesDate = time.Now().Format("2006-01-02")
 	}
 
-	// Recalculate total from sale items
-	var salesTotal int
-	err = db.QueryRow(`
-		SELECT COALESCE(SUM(sale_items_amount * sale_value), 0) 
-		FROM sale_items 
-		WHERE sales_id = ?
-	`, salesID).Scan(&salesTotal)
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	audit := startAudit(tx, r, "sales", salesID)
 
 	// Update sales
 	query := `UPDATE sales 
-	          SET customer_id = ?, sales_total = ?, sales_payment = ?, sales_date = ?, sales_status = ?
+	          SET customer_id = ?, sales_payment = ?, sales_date = ?, updated_by = ?, updated_at = NOW()
 	          WHERE sales_id = ?`
 
-	result, err := db.Exec(query, req.CustomerID, salesTotal, req.SalesPayment, req.SalesDate, req.SalesStatus, salesID)
+	_, err = tx.Exec(query, req.CustomerID, req.SalesPayment, req.SalesDate, requestUserID(user), salesID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Sales not found", http.StatusNotFound)
+	// Recalculate total from sale items while they are still editable; the
+	// customer may be tax exempt. Later the total is fixed with the stock.
+	if salesLinesEditable(currentStatus) {
+		if err := updateSalesTotal(tx, salesID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	var salesTotal int
+	if err := tx.QueryRow("SELECT sales_total FROM sales WHERE sales_id = ?", salesID).Scan(&salesTotal); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := checkPembayaranCover(tx, salesID, salesTotal); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	audit.record("update")
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -724,7 +1025,7 @@ func updateSales(w http.ResponseWriter, r *http.Request) {
 		"sales_total":   salesTotal,
 		"sales_payment": req.SalesPayment,
 		"sales_date":    req.SalesDate,
-		"sales_status":  req.SalesStatus,
+		"sales_status":  currentStatus,
 		"status":        "Updated",
 		"message":       "Sales updated successfully",
 	}
@@ -732,7 +1033,8 @@ func updateSales(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, response)
 }
 
-// deleteSales deletes a sales record and all its items
+// deleteSales deletes a draft or cancelled sales record and all its items.
+// Sales holding stock must be cancelled first so the stock is returned.
 func deleteSales(w http.ResponseWriter, r *http.Request) {
 	db, err := database.GetDBConnection()
 	if err != nil {
@@ -752,44 +1054,36 @@ func deleteSales(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tx.Rollback()
 
-	// Get all sale items to restore stock before deletion
-	itemsQuery := `SELECT barang_id, gudang_id, sale_items_amount FROM sale_items WHERE sales_id = ?`
-	rows, err := tx.Query(itemsQuery, salesID)
-	if err != nil {
+	var currentStatus int
+	var invoiceNo sql.NullString
+	err = tx.QueryRow("SELECT sales_status, sales_invoice_no FROM sales WHERE sales_id = ? FOR UPDATE", salesID).Scan(&currentStatus, &invoiceNo)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Sales not found", http.StatusNotFound)
+		return
+	} else if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	// Restore stock for each item
-	for rows.Next() {
-		var barangID, gudangID string
-		var amount int
-		if err := rows.Scan(&barangID, &gudangID, &amount); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	if currentStatus != salesStatusDraft && currentStatus != salesStatusBatal {
+		http.Error(w, fmt.Sprintf("Sales in status %s cannot be deleted, cancel it first", salesStatusNama[currentStatus]), http.StatusConflict)
+		return
+	}
+	// An issued invoice number is part of the numbering series and must stay
+	// accounted for, so invoiced sales are only ever cancelled
+	if invoiceNo.Valid {
+		http.Error(w, fmt.Sprintf("Sales with invoice %s cannot be deleted, it stays as %s", invoiceNo.String, salesStatusNama[salesStatusBatal]), http.StatusConflict)
+		return
+	}
 
-		var stockID string
-		var currentStock int
-		err = tx.QueryRow("SELECT stock_id, stock_barang FROM stock_gudang WHERE barang_id = ? AND gudang_id = ?",
-			barangID, gudangID).Scan(&stockID, &currentStock)
+	audit := startAudit(tx, r, "sales", salesID)
 
-		if err != nil && err != sql.ErrNoRows {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		if err != sql.ErrNoRows {
-			_, err = tx.Exec("UPDATE stock_gudang SET stock_barang = ? WHERE stock_id = ?", currentStock+amount, stockID)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-		}
+	// sales_status_log and sales_pembayaran are kept as history of the deleted sale
+	// Delete applied promotions and sale items first (due to foreign key constraint)
+	_, err = tx.Exec("DELETE FROM sales_promosi WHERE sales_id = ?", salesID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-
-	// Delete sale items first (due to foreign key constraint)
 	_, err = tx.Exec("DELETE FROM sale_items WHERE sales_id = ?", salesID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -809,6 +1103,8 @@ func deleteSales(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	audit.record("delete")
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -817,7 +1113,7 @@ func deleteSales(w http.ResponseWriter, r *http.Request) {
 
 	response := map[string]interface{}{
 		"status":  "Deleted",
-		"message": "Sales and all related items deleted successfully",
+		"message": "Sales and its items deleted successfully",
 	}
 
 	respondWithJSON(w, response)
@@ -835,11 +1131,12 @@ func getSaleItems(w http.ResponseWriter, r *http.Request) {
 	query := `
 		SELECT si.sale_items_id, si.sales_id, si.barang_id, b.barang_nama,
 		       si.gudang_id, g.gudang_nama, si.lantai_id, gl.lantai_nama,
-		       si.sale_items_amount, si.sale_value
+		       si.lokasi_id, gk.lokasi_nama, si.sale_items_amount, si.sale_value, si.sale_promo_potongan
 		FROM sale_items si
 		LEFT JOIN barang b ON si.barang_id = b.barang_id
 		LEFT JOIN list_gudang g ON si.gudang_id = g.gudang_id
 		LEFT JOIN gudang_lantai gl ON si.lantai_id = gl.lantai_id
+		LEFT JOIN gudang_lokasi gk ON si.lokasi_id = gk.lokasi_id
 		ORDER BY si.sale_items_id DESC
 	`
 
@@ -853,10 +1150,10 @@ func getSaleItems(w http.ResponseWriter, r *http.Request) {
 	var items []SaleItems
 	for rows.Next() {
 		var item SaleItems
-		var lantaiID, lantaiNama sql.NullString
+		var lantaiID, lantaiNama, lokasiID, lokasiNama sql.NullString
 		err := rows.Scan(&item.SaleItemsID, &item.SalesID, &item.BarangID, &item.BarangNama,
 			&item.GudangID, &item.GudangNama, &lantaiID, &lantaiNama,
-			&item.SaleItemsAmount, &item.SaleValue)
+			&lokasiID, &lokasiNama, &item.SaleItemsAmount, &item.SaleValue, &item.SalePromoPotongan)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -869,6 +1166,8 @@ func getSaleItems(w http.ResponseWriter, r *http.Request) {
 		if lantaiNama.Valid {
 			item.LantaiNama = lantaiNama.String
 		}
+		item.LokasiID = lokasiID.String
+		item.LokasiNama = lokasiNama.String
 
 		items = append(items, item)
 	}
@@ -891,20 +1190,21 @@ func getSaleItem(w http.ResponseWriter, r *http.Request) {
 	query := `
 		SELECT si.sale_items_id, si.sales_id, si.barang_id, b.barang_nama,
 		       si.gudang_id, g.gudang_nama, si.lantai_id, gl.lantai_nama,
-		       si.sale_items_amount, si.sale_value
+		       si.lokasi_id, gk.lokasi_nama, si.sale_items_amount, si.sale_value, si.sale_promo_potongan
 		FROM sale_items si
 		LEFT JOIN barang b ON si.barang_id = b.barang_id
 		LEFT JOIN list_gudang g ON si.gudang_id = g.gudang_id
 		LEFT JOIN gudang_lantai gl ON si.lantai_id = gl.lantai_id
+		LEFT JOIN gudang_lokasi gk ON si.lokasi_id = gk.lokasi_id
 		WHERE si.sale_items_id = ?
 	`
 
 	var item SaleItems
-	var lantaiID, lantaiNama sql.NullString
+	var lantaiID, lantaiNama, lokasiID, lokasiNama sql.NullString
 	err = db.QueryRow(query, itemID).Scan(
 		&item.SaleItemsID, &item.SalesID, &item.BarangID, &item.BarangNama,
 		&item.GudangID, &item.GudangNama, &lantaiID, &lantaiNama,
-		&item.SaleItemsAmount, &item.SaleValue,
+		&lokasiID, &lokasiNama, &item.SaleItemsAmount, &item.SaleValue, &item.SalePromoPotongan,
 	)
 
 	if err == sql.ErrNoRows {
@@ -922,11 +1222,46 @@ func getSaleItem(w http.ResponseWriter, r *http.Request) {
 	if lantaiNama.Valid {
 		item.LantaiNama = lantaiNama.String
 	}
+	item.LokasiID = lokasiID.String
+	item.LokasiNama = lokasiNama.String
 
 	respondWithJSON(w, item)
 }
 
-// createSaleItem creates a new sale item for an existing sales record
+// validateSaleLine checks barang, gudang, lantai and lokasi of a single sale line
+func validateSaleLine(q sqlExecutor, barangID, gudangID, lantaiID, lokasiID string) error {
+	var barangExists bool
+	err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM barang WHERE barang_id = ? AND deleted_at IS NULL)", barangID).Scan(&barangExists)
+	if err != nil || !barangExists {
+		return fmt.Errorf("Invalid barang_id: barang does not exist")
+	}
+
+	var gudangExists bool
+	err = q.QueryRow("SELECT EXISTS(SELECT 1 FROM list_gudang WHERE gudang_id = ? AND gudang_status = 1)", gudangID).Scan(&gudangExists)
+	if err != nil || !gudangExists {
+		return fmt.Errorf("Invalid gudang_id: gudang does not exist or is archived")
+	}
+
+	if lantaiID != "" {
+		var lantaiExists bool
+		err = q.QueryRow("SELECT EXISTS(SELECT 1 FROM gudang_lantai WHERE lantai_id = ? AND gudang_id = ? AND lantai_status = 1)", lantaiID, gudangID).Scan(&lantaiExists)
+		if err != nil || !lantaiExists {
+			return fmt.Errorf("Invalid lantai_id: lantai does not exist, is inactive or does not belong to gudang")
+		}
+	}
+
+	if lokasiID != "" {
+		if lantaiID == "" {
+			return fmt.Errorf("lantai_id is required when lokasi_id is set")
+		}
+		if err := validateLokasi(q, lokasiID, lantaiID); err != nil {
+			return fmt.Errorf("Invalid lokasi_id: %v", err)
+		}
+	}
+	return nil
+}
+
+// createSaleItem adds a line to a draft or confirmed sale
 func createSaleItem(w http.ResponseWriter, r *http.Request) {
 	db, err := database.GetDBConnection()
 	if err != nil {
@@ -939,6 +1274,8 @@ func createSaleItem(w http.ResponseWriter, r *http.Request) {
 		SalesID         string `json:"sales_id"`
 		BarangID        string `json:"barang_id"`
 		GudangID        string `json:"gudang_id"`
+		LantaiID        string `json:"lantai_id,omitempty"`
+		LokasiID        string `json:"lokasi_id,omitempty"`
 		SaleItemsAmount int    `json:"sale_items_amount"`
 		SaleValue       int    `json:"sale_value"`
 	}
@@ -969,32 +1306,19 @@ func createSaleItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.SaleValue <= 0 {
-		http.Error(w, "sale_value must be greater than 0", http.StatusBadRequest)
+	if req.SaleValue < 0 {
+		http.Error(w, "sale_value cannot be negative", http.StatusBadRequest)
 		return
 	}
 
-	// Validate sales exists
-	var salesExists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM sales WHERE sales_id = ?)", req.SalesID).Scan(&salesExists)
-	if err != nil || !salesExists {
-		http.Error(w, "Invalid sales_id: sales does not exist", http.StatusBadRequest)
-		return
-	}
-
-	// Validate barang exists
-	var barangExists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM barang WHERE barang_id = ?)", req.BarangID).Scan(&barangExists)
-	if err != nil || !barangExists {
-		http.Error(w, "Invalid barang_id: barang does not exist", http.StatusBadRequest)
+	if err := validateSaleLine(db, req.BarangID, req.GudangID, req.LantaiID, req.LokasiID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Validate gudang exists
-	var gudangExists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM list_gudang WHERE gudang_id = ?)", req.GudangID).Scan(&gudangExists)
-	if err != nil || !gudangExists {
-		http.Error(w, "Invalid gudang_id: gudang does not exist", http.StatusBadRequest)
+	user, err := requestUser(db, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
@@ -1006,6 +1330,20 @@ func createSaleItem(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tx.Rollback()
 
+	salesStatus, err := lockSalesForLineEdit(tx, req.SalesID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if req.SaleValue == 0 {
+		req.SaleValue, err = hargaSaleLineForSales(tx, req.SalesID, req.BarangID, req.SaleItemsAmount)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Generate new sale item ID
 	var lastID string
 	err = tx.QueryRow("SELECT sale_items_id FROM sale_items ORDER BY sale_items_id DESC LIMIT 1").Scan(&lastID)
@@ -1021,63 +1359,40 @@ func createSaleItem(w http.ResponseWriter, r *http.Request) {
 		newID = fmt.Sprintf("SI_%07d", lastNum+1)
 	}
 
-	// Check and reduce stock
-	var currentStock int
-	var stockID string
-	err = tx.QueryRow("SELECT stock_id, stock_barang FROM stock_gudang WHERE barang_id = ? AND gudang_id = ?",
-		req.BarangID, req.GudangID).Scan(&stockID, &currentStock)
-
-	if err == sql.ErrNoRows {
-		tx.Rollback()
-		http.Error(w, fmt.Sprintf("Stock not found for barang_id %s in gudang_id %s", req.BarangID, req.GudangID), http.StatusBadRequest)
-		return
-	} else if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	newStock := currentStock - req.SaleItemsAmount
-	if newStock < 0 {
-		tx.Rollback()
-		http.Error(w, fmt.Sprintf("Insufficient stock. Available: %d, Required: %d", currentStock, req.SaleItemsAmount), http.StatusBadRequest)
-		return
+	// Confirmed sales take the stock right away, drafts on confirm
+	lantaiID := req.LantaiID
+	if salesHoldsStock(salesStatus) {
+		lantaiID, err = takeSaleStock(tx, req.BarangID, req.GudangID, req.LantaiID, req.LokasiID, req.SaleItemsAmount)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
 
-	_, err = tx.Exec("UPDATE stock_gudang SET stock_barang = ? WHERE stock_id = ?", newStock, stockID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	audit := startAudit(tx, r, "sale_items", newID)
 
 	// Insert sale item
-	itemQuery := `INSERT INTO sale_items (sale_items_id, sales_id, barang_id, gudang_id, sale_items_amount, sale_value) 
-	              VALUES (?, ?, ?, ?, ?, ?)`
+	itemQuery := `INSERT INTO sale_items (sale_items_id, sales_id, barang_id, gudang_id, lantai_id, lokasi_id, sale_items_amount, sale_value, created_by) 
+	              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err = tx.Exec(itemQuery, newID, req.SalesID, req.BarangID, req.GudangID, req.SaleItemsAmount, req.SaleValue)
+	_, err = tx.Exec(itemQuery, newID, req.SalesID, req.BarangID, req.GudangID, lantaiID, processNullableStringValue(req.LokasiID), req.SaleItemsAmount, req.SaleValue, requestUserID(user))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Update sales total
-	var newTotal int
-	err = tx.QueryRow(`
-		SELECT COALESCE(SUM(sale_items_amount * sale_value), 0) 
-		FROM sale_items 
-		WHERE sales_id = ?
-	`, req.SalesID).Scan(&newTotal)
-
-	if err != nil {
+	if err := updateSalesTotal(tx, req.SalesID); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	_, err = tx.Exec("UPDATE sales SET sales_total = ? WHERE sales_id = ?", newTotal, req.SalesID)
-	if err != nil {
+	if err := touchSales(tx, req.SalesID, user); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	audit.record("create")
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -1089,6 +1404,8 @@ func createSaleItem(w http.ResponseWriter, r *http.Request) {
 		"sales_id":          req.SalesID,
 		"barang_id":         req.BarangID,
 		"gudang_id":         req.GudangID,
+		"lantai_id":         lantaiID,
+		"lokasi_id":         req.LokasiID,
 		"sale_items_amount": req.SaleItemsAmount,
 		"sale_value":        req.SaleValue,
 		"status":            "Created",
@@ -1099,7 +1416,7 @@ func createSaleItem(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, response)
 }
 
-// updateSaleItem updates a sale item
+// updateSaleItem updates a line of a draft or confirmed sale
 func updateSaleItem(w http.ResponseWriter, r *http.Request) {
 	db, err := database.GetDBConnection()
 	if err != nil {
@@ -1114,6 +1431,8 @@ func updateSaleItem(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		BarangID        string `json:"barang_id"`
 		GudangID        string `json:"gudang_id"`
+		LantaiID        string `json:"lantai_id,omitempty"`
+		LokasiID        string `json:"lokasi_id,omitempty"`
 		SaleItemsAmount int    `json:"sale_items_amount"`
 		SaleValue       int    `json:"sale_value"`
 	}
@@ -1139,24 +1458,19 @@ func updateSaleItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.SaleValue <= 0 {
-		http.Error(w, "sale_value must be greater than 0", http.StatusBadRequest)
+	if req.SaleValue < 0 {
+		http.Error(w, "sale_value cannot be negative", http.StatusBadRequest)
 		return
 	}
 
-	// Validate barang exists
-	var barangExists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM barang WHERE barang_id = ?)", req.BarangID).Scan(&barangExists)
-	if err != nil || !barangExists {
-		http.Error(w, "Invalid barang_id: barang does not exist", http.StatusBadRequest)
+	if err := validateSaleLine(db, req.BarangID, req.GudangID, req.LantaiID, req.LokasiID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Validate gudang exists
-	var gudangExists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM list_gudang WHERE gudang_id = ?)", req.GudangID).Scan(&gudangExists)
-	if err != nil || !gudangExists {
-		http.Error(w, "Invalid gudang_id: gudang does not exist", http.StatusBadRequest)
+	user, err := requestUser(db, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
@@ -1179,10 +1493,26 @@ func updateSaleItem(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tx.Rollback()
 
+	salesStatus, err := lockSalesForLineEdit(tx, salesID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if req.SaleValue == 0 {
+		req.SaleValue, err = hargaSaleLineForSales(tx, salesID, req.BarangID, req.SaleItemsAmount)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Get old sale item data to restore stock
 	var oldBarangID, oldGudangID string
+	var oldLantaiID, oldLokasiID sql.NullString
 	var oldAmount int
-	err = tx.QueryRow("SELECT barang_id, gudang_id, sale_items_amount FROM sale_items WHERE sale_items_id = ?", itemID).Scan(&oldBarangID, &oldGudangID, &oldAmount)
+	err = tx.QueryRow("SELECT barang_id, gudang_id, lantai_id, lokasi_id, sale_items_amount FROM sale_items WHERE sale_items_id = ?", itemID).
+		Scan(&oldBarangID, &oldGudangID, &oldLantaiID, &oldLokasiID, &oldAmount)
 	if err == sql.ErrNoRows {
 		http.Error(w, "Sale item not found", http.StatusNotFound)
 		return
@@ -1191,89 +1521,71 @@ func updateSaleItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Restore old stock (add back the old amount)
-	var oldStockID string
-	var oldCurrentStock int
-	err = tx.QueryRow("SELECT stock_id, stock_barang FROM stock_gudang WHERE barang_id = ? AND gudang_id = ?",
-		oldBarangID, oldGudangID).Scan(&oldStockID, &oldCurrentStock)
-
-	if err != nil && err != sql.ErrNoRows {
+	// A line on a surat jalan keeps its barang and floor and cannot drop below
+	// what is shipped
+	covered, err := suratJalanCovered(tx, itemID)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	if err != sql.ErrNoRows {
-		_, err = tx.Exec("UPDATE stock_gudang SET stock_barang = ? WHERE stock_id = ?", oldCurrentStock+oldAmount, oldStockID)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	if covered > 0 {
+		if req.LantaiID == "" {
+			req.LantaiID = oldLantaiID.String
+		}
+		if req.BarangID != oldBarangID || req.GudangID != oldGudangID || req.LantaiID != oldLantaiID.String {
+			http.Error(w, "Sale item is on a surat jalan, its barang, gudang and lantai cannot change", http.StatusConflict)
+			return
+		}
+		if req.SaleItemsAmount < covered {
+			http.Error(w, fmt.Sprintf("sale_items_amount cannot go below the %d already on surat jalan", covered), http.StatusConflict)
 			return
 		}
 	}
 
-	// Check and reduce new stock
-	var newStockID string
-	var newCurrentStock int
-	err = tx.QueryRow("SELECT stock_id, stock_barang FROM stock_gudang WHERE barang_id = ? AND gudang_id = ?",
-		req.BarangID, req.GudangID).Scan(&newStockID, &newCurrentStock)
-
-	if err == sql.ErrNoRows {
-		tx.Rollback()
-		http.Error(w, fmt.Sprintf("Stock not found for barang_id %s in gudang_id %s", req.BarangID, req.GudangID), http.StatusBadRequest)
-		return
-	} else if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	newStock := newCurrentStock - req.SaleItemsAmount
-	if newStock < 0 {
-		tx.Rollback()
-		http.Error(w, fmt.Sprintf("Insufficient stock. Available: %d, Required: %d", newCurrentStock, req.SaleItemsAmount), http.StatusBadRequest)
-		return
-	}
+	audit := startAudit(tx, r, "sale_items", itemID)
 
-	_, err = tx.Exec("UPDATE stock_gudang SET stock_barang = ? WHERE stock_id = ?", newStock, newStockID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	// Put the old amount back and take the new one
+	lantaiID := req.LantaiID
+	if salesHoldsStock(salesStatus) {
+		err = returnSaleStock(tx, oldBarangID, oldGudangID, oldLantaiID.String, oldLokasiID.String, oldAmount)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		lantaiID, err = takeSaleStock(tx, req.BarangID, req.GudangID, req.LantaiID, req.LokasiID, req.SaleItemsAmount)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
 
-	// Update sale item
+	// Update sale item. A quoted price only stays fixed while the line keeps
+	// its barang and price; MySQL assigns left to right, so the first SET
+	// still compares against the old values.
 	query := `UPDATE sale_items 
-	          SET barang_id = ?, gudang_id = ?, sale_items_amount = ?, sale_value = ?
+	          SET sale_harga_tetap = (sale_harga_tetap AND barang_id = ? AND sale_value = ?),
+	              barang_id = ?, gudang_id = ?, lantai_id = ?, lokasi_id = ?, sale_items_amount = ?, sale_value = ?,
+	              updated_by = ?, updated_at = NOW()
 	          WHERE sale_items_id = ?`
 
-	result, err := tx.Exec(query, req.BarangID, req.GudangID, req.SaleItemsAmount, req.SaleValue, itemID)
+	_, err = tx.Exec(query, req.BarangID, req.SaleValue, req.BarangID, req.GudangID, lantaiID, processNullableStringValue(req.LokasiID), req.SaleItemsAmount, req.SaleValue, requestUserID(user), itemID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Sale item not found", http.StatusNotFound)
-		return
-	}
-
 	// Update sales total
-	var newTotal int
-	err = tx.QueryRow(`
-		SELECT COALESCE(SUM(sale_items_amount * sale_value), 0) 
-		FROM sale_items 
-		WHERE sales_id = ?
-	`, salesID).Scan(&newTotal)
-
-	if err != nil {
+	if err := updateSalesTotal(tx, salesID); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	_, err = tx.Exec("UPDATE sales SET sales_total = ? WHERE sales_id = ?", newTotal, salesID)
-	if err != nil {
+	if err := touchSales(tx, salesID, user); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	audit.record("update")
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -1284,6 +1596,8 @@ func updateSaleItem(w http.ResponseWriter, r *http.Request) {
 		"sale_items_id":     itemID,
 		"barang_id":         req.BarangID,
 		"gudang_id":         req.GudangID,
+		"lantai_id":         lantaiID,
+		"lokasi_id":         req.LokasiID,
 		"sale_items_amount": req.SaleItemsAmount,
 		"sale_value":        req.SaleValue,
 		"status":            "Updated",
@@ -1293,7 +1607,7 @@ func updateSaleItem(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, response)
 }
 
-// deleteSaleItem deletes a sale item and updates sales total
+// deleteSaleItem deletes a line of a draft or confirmed sale and updates sales total
 func deleteSaleItem(w http.ResponseWriter, r *http.Request) {
 	db, err := database.GetDBConnection()
 	if err != nil {
@@ -1305,6 +1619,12 @@ func deleteSaleItem(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	itemID := vars["id"]
 
+	user, err := requestUser(db, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
 	// Get sales_id for this item
 	var salesID string
 	err = db.QueryRow("SELECT sales_id FROM sale_items WHERE sale_items_id = ?", itemID).Scan(&salesID)
@@ -1324,10 +1644,18 @@ func deleteSaleItem(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tx.Rollback()
 
+	salesStatus, err := lockSalesForLineEdit(tx, salesID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
 	// Get sale item data before deletion to restore stock
 	var barangID, gudangID string
+	var lantaiID, lokasiID sql.NullString
 	var amount int
-	err = tx.QueryRow("SELECT barang_id, gudang_id, sale_items_amount FROM sale_items WHERE sale_items_id = ?", itemID).Scan(&barangID, &gudangID, &amount)
+	err = tx.QueryRow("SELECT barang_id, gudang_id, lantai_id, lokasi_id, sale_items_amount FROM sale_items WHERE sale_items_id = ?", itemID).
+		Scan(&barangID, &gudangID, &lantaiID, &lokasiID, &amount)
 	if err == sql.ErrNoRows {
 		http.Error(w, "Sale item not found", http.StatusNotFound)
 		return
@@ -1336,57 +1664,45 @@ func deleteSaleItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Restore stock (add back the sold amount)
-	var stockID string
-	var currentStock int
-	err = tx.QueryRow("SELECT stock_id, stock_barang FROM stock_gudang WHERE barang_id = ? AND gudang_id = ?",
-		barangID, gudangID).Scan(&stockID, &currentStock)
-
-	if err != nil && err != sql.ErrNoRows {
+	covered, err := suratJalanCovered(tx, itemID)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if covered > 0 {
+		http.Error(w, fmt.Sprintf("Sale item has %d on surat jalan, cancel the surat jalan first", covered), http.StatusConflict)
+		return
+	}
 
-	if err != sql.ErrNoRows {
-		_, err = tx.Exec("UPDATE stock_gudang SET stock_barang = ? WHERE stock_id = ?", currentStock+amount, stockID)
-		if err != nil {
+	audit := startAudit(tx, r, "sale_items", itemID)
+
+	// Restore stock (add back the sold amount)
+	if salesHoldsStock(salesStatus) {
+		if err := returnSaleStock(tx, barangID, gudangID, lantaiID.String, lokasiID.String, amount); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 	}
 
 	// Delete sale item
-	result, err := tx.Exec("DELETE FROM sale_items WHERE sale_items_id = ?", itemID)
+	_, err = tx.Exec("DELETE FROM sale_items WHERE sale_items_id = ?", itemID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Sale item not found", http.StatusNotFound)
-		return
-	}
-
 	// Update sales total
-	var newTotal int
-	err = tx.QueryRow(`
-		SELECT COALESCE(SUM(sale_items_amount * sale_value), 0) 
-		FROM sale_items 
-		WHERE sales_id = ?
-	`, salesID).Scan(&newTotal)
-
-	if err != nil {
+	if err := updateSalesTotal(tx, salesID); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	_, err = tx.Exec("UPDATE sales SET sales_total = ? WHERE sales_id = ?", newTotal, salesID)
-	if err != nil {
+	if err := touchSales(tx, salesID, user); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	audit.record("delete")
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -1463,7 +1779,7 @@ func getFloorsByGudang(w http.ResponseWriter, r *http.Request) {
 	query := `
 		SELECT gl.lantai_id, gl.lantai_no, gl.lantai_nama, gl.gudang_id
 		FROM gudang_lantai gl
-		WHERE gl.gudang_id = ?
+		WHERE gl.gudang_id = ? AND gl.lantai_status = 1
 		ORDER BY gl.lantai_no
 	`
 
@@ -1661,13 +1977,18 @@ func getDailySalesReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if format := exportFormat(r); format != "" {
+		exportSalesReport(w, db, format, "laporan-harian-"+date, "DATE(s.sales_date) = ?", date)
+		return
+	}
+
 	// Query to get all sales for the specified date
 	salesQuery := `
 		SELECT DISTINCT s.sales_id, s.sales_date, s.sales_total, s.sales_payment, s.sales_status,
 		       s.customer_id, c.customer_nama, c.customer_kontak, c.customer_alamat
 		FROM sales s
 		LEFT JOIN customer c ON s.customer_id = c.customer_id
-		WHERE DATE(s.sales_date) = ?
+		WHERE DATE(s.sales_date) = ? AND s.sales_status NOT IN (0, 5)
 		ORDER BY s.sales_id DESC
 	`
 
@@ -1710,13 +2031,13 @@ func getDailySalesReport(w http.ResponseWriter, r *http.Request) {
 	itemsQuery := `
 		SELECT si.sale_items_id, si.sales_id, si.barang_id, b.barang_nama, 
 		       b.brand_id, br.brand_nama, si.sale_items_amount, si.sale_value,
-		       b.barang_harga_asli,
-		       ((si.sale_value - b.barang_harga_asli) * si.sale_items_amount) as item_profit
+		       ` + hargaAsliSaatJual + `,
+		       ((si.sale_value - ` + hargaAsliSaatJual + `) * si.sale_items_amount - si.sale_promo_potongan) as item_profit
 		FROM sale_items si
 		JOIN barang b ON si.barang_id = b.barang_id
 		LEFT JOIN brand br ON b.brand_id = br.brand_id
 		JOIN sales s ON si.sales_id = s.sales_id
-		WHERE DATE(s.sales_date) = ?
+		WHERE DATE(s.sales_date) = ? AND s.sales_status NOT IN (0, 5)
 		ORDER BY si.sales_id, si.sale_items_id
 	`
 
@@ -1787,13 +2108,18 @@ func getMonthlySalesReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if format := exportFormat(r); format != "" {
+		exportSalesReport(w, db, format, "laporan-bulanan-"+month, "DATE_FORMAT(s.sales_date, '%Y-%m') = ?", month)
+		return
+	}
+
 	// Query to get all sales for the specified month
 	salesQuery := `
 		SELECT DISTINCT s.sales_id, s.sales_date, s.sales_total, s.sales_payment, s.sales_status,
 		       s.customer_id, c.customer_nama, c.customer_kontak, c.customer_alamat
 		FROM sales s
 		LEFT JOIN customer c ON s.customer_id = c.customer_id
-		WHERE DATE_FORMAT(s.sales_date, '%Y-%m') = ?
+		WHERE DATE_FORMAT(s.sales_date, '%Y-%m') = ? AND s.sales_status NOT IN (0, 5)
 		ORDER BY s.sales_date DESC, s.sales_id DESC
 	`
 
@@ -1836,13 +2162,13 @@ func getMonthlySalesReport(w http.ResponseWriter, r *http.Request) {
 	itemsQuery := `
 		SELECT si.sale_items_id, si.sales_id, si.barang_id, b.barang_nama, 
 		       b.brand_id, br.brand_nama, si.sale_items_amount, si.sale_value,
-		       b.barang_harga_asli,
-		       ((si.sale_value - b.barang_harga_asli) * si.sale_items_amount) as item_profit
+		       ` + hargaAsliSaatJual + `,
+		       ((si.sale_value - ` + hargaAsliSaatJual + `) * si.sale_items_amount - si.sale_promo_potongan) as item_profit
 		FROM sale_items si
 		JOIN barang b ON si.barang_id = b.barang_id
 		LEFT JOIN brand br ON b.brand_id = br.brand_id
 		JOIN sales s ON si.sales_id = s.sales_id
-		WHERE DATE_FORMAT(s.sales_date, '%Y-%m') = ?
+		WHERE DATE_FORMAT(s.sales_date, '%Y-%m') = ? AND s.sales_status NOT IN (0, 5)
 		ORDER BY si.sales_id, si.sale_items_id
 	`
 
@@ -1916,6 +2242,12 @@ func getYearlySalesReport(w http.ResponseWriter, r *http.Request) {
 
 	// Check if detailed report is requested
 	detailed := r.URL.Query().Get("detailed") == "true"
+	format := exportFormat(r)
+
+	if detailed && format != "" {
+		exportSalesReport(w, db, format, "laporan-tahunan-"+year, "YEAR(s.sales_date) = ?", year)
+		return
+	}
 
 	if detailed {
 		// Return detailed report with all transactions
@@ -1929,11 +2261,11 @@ func getYearlySalesReport(w http.ResponseWriter, r *http.Request) {
 			DATE_FORMAT(s.sales_date, '%Y-%m') as month,
 			COUNT(DISTINCT s.sales_id) as total_transactions,
 			SUM(s.sales_total) as total_sales,
-			SUM((si.sale_value - b.barang_harga_asli) * si.sale_items_amount) as total_profit
+			SUM((si.sale_value - ` + hargaAsliSaatJual + `) * si.sale_items_amount - si.sale_promo_potongan) as total_profit
 		FROM sales s
 		JOIN sale_items si ON s.sales_id = si.sales_id
 		JOIN barang b ON si.barang_id = b.barang_id
-		WHERE YEAR(s.sales_date) = ?
+		WHERE YEAR(s.sales_date) = ? AND s.sales_status NOT IN (0, 5)
 		GROUP BY DATE_FORMAT(s.sales_date, '%Y-%m')
 		ORDER BY month DESC
 	`
@@ -1945,6 +2277,19 @@ func getYearlySalesReport(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	if format != "" {
+		kolom := []exportKolom{
+			{"Bulan", exportTeks},
+			{"Jumlah Transaksi", exportAngka},
+			{"Total Penjualan", exportRupiah},
+			{"Total Laba", exportRupiah},
+		}
+		if err := exportRows(w, format, "laporan-tahunan-"+year, kolom, rows); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
 	var monthlySummaries []YearlyReportSummary
 	yearTotalTransactions := 0
 	yearTotalSales := 0
@@ -1982,7 +2327,7 @@ func getDetailedYearlyReport(w http.ResponseWriter, r *http.Request, db *sql.DB,
 		       s.customer_id, c.customer_nama, c.customer_kontak, c.customer_alamat
 		FROM sales s
 		LEFT JOIN customer c ON s.customer_id = c.customer_id
-		WHERE YEAR(s.sales_date) = ?
+		WHERE YEAR(s.sales_date) = ? AND s.sales_status NOT IN (0, 5)
 		ORDER BY s.sales_date DESC, s.sales_id DESC
 	`
 
@@ -2025,13 +2370,13 @@ func getDetailedYearlyReport(w http.ResponseWriter, r *http.Request, db *sql.DB,
 	itemsQuery := `
 		SELECT si.sale_items_id, si.sales_id, si.barang_id, b.barang_nama, 
 		       b.brand_id, br.brand_nama, si.sale_items_amount, si.sale_value,
-		       b.barang_harga_asli,
-		       ((si.sale_value - b.barang_harga_asli) * si.sale_items_amount) as item_profit
+		       ` + hargaAsliSaatJual + `,
+		       ((si.sale_value - ` + hargaAsliSaatJual + `) * si.sale_items_amount - si.sale_promo_potongan) as item_profit
 		FROM sale_items si
 		JOIN barang b ON si.barang_id = b.barang_id
 		LEFT JOIN brand br ON b.brand_id = br.brand_id
 		JOIN sales s ON si.sales_id = s.sales_id
-		WHERE YEAR(s.sales_date) = ?
+		WHERE YEAR(s.sales_date) = ? AND s.sales_status NOT IN (0, 5)
 		ORDER BY si.sales_id, si.sale_items_id
 	`
 
@@ -2085,6 +2430,78 @@ func getDetailedYearlyReport(w http.ResponseWriter, r *http.Request, db *sql.DB,
 	respondWithJSON(w, response)
 }
 
+var salesReportExportKolom = []exportKolom{
+	{"No. Transaksi", exportTeks},
+	{"Tanggal", exportWaktu},
+	{"Customer", exportTeks},
+	{"Pembayaran", exportTeks},
+	{"Status", exportTeks},
+	{"Kode Barang", exportTeks},
+	{"Nama Barang", exportTeks},
+	{"Brand", exportTeks},
+	{"Jumlah", exportAngka},
+	{"Harga Jual", exportRupiah},
+	{"Harga Pokok", exportRupiah},
+	{"Potongan Promo", exportRupiah},
+	{"Subtotal", exportRupiah},
+	{"Laba", exportRupiah},
+}
+
+// exportSalesReport streams a daily, monthly or detailed yearly report as a
+// spreadsheet, one row per sale item. where selects the period on s.sales_date.
+func exportSalesReport(w http.ResponseWriter, db *sql.DB, format, nama, where, period string) {
+	query := `
+		SELECT s.sales_id, s.sales_date, c.customer_nama, s.sales_payment, s.sales_status,
+		       si.barang_id, b.barang_nama, br.brand_nama, si.sale_items_amount, si.sale_value,
+		       ` + hargaAsliSaatJual + `, si.sale_promo_potongan,
+		       (si.sale_value * si.sale_items_amount - si.sale_promo_potongan),
+		       ((si.sale_value - ` + hargaAsliSaatJual + `) * si.sale_items_amount - si.sale_promo_potongan)
+		FROM sale_items si
+		JOIN sales s ON si.sales_id = s.sales_id
+		JOIN barang b ON si.barang_id = b.barang_id
+		LEFT JOIN brand br ON b.brand_id = br.brand_id
+		LEFT JOIN customer c ON s.customer_id = c.customer_id
+		WHERE ` + where + ` AND s.sales_status NOT IN (0, 5)
+		ORDER BY s.sales_date DESC, s.sales_id DESC, si.sale_items_id`
+
+	rows, err := db.Query(query, period)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	export, err := newExportWriter(w, format, nama, salesReportExportKolom)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for rows.Next() {
+		var salesID, salesDate, salesPayment, barangID, barangNama string
+		var customerNama, brandNama sql.NullString
+		var salesStatus, amount, value, hargaAsli, potongan, subtotal, laba int
+		if err := rows.Scan(&salesID, &salesDate, &customerNama, &salesPayment, &salesStatus,
+			&barangID, &barangNama, &brandNama, &amount, &value,
+			&hargaAsli, &potongan, &subtotal, &laba); err != nil {
+			http.Error(w, export.Gagal(err).Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := export.Tulis(salesID, salesDate, customerNama.String, salesPayment, salesStatusNama[salesStatus],
+			barangID, barangNama, brandNama.String, amount, value, hargaAsli, potongan, subtotal, laba); err != nil {
+			http.Error(w, export.Gagal(err).Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, export.Gagal(err).Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := export.Selesai(); err != nil {
+		http.Error(w, export.Gagal(err).Error(), http.StatusInternalServerError)
+	}
+}
+
 // getItemSalesReport retrieves sales statistics for items based on period (daily, monthly, yearly)
 // Query params:
 // - period: "daily", "monthly", or "yearly" (required)
@@ -2144,12 +2561,12 @@ func getItemSalesReport(w http.ResponseWriter, r *http.Request) {
 			b.barang_harga_jual,
 			COUNT(DISTINCT s.sales_id) as transaction_count,
 			COALESCE(SUM(si.sale_items_amount), 0) as total_quantity_sold,
-			COALESCE(SUM(si.sale_items_amount * si.sale_value), 0) as total_revenue,
+			COALESCE(SUM(si.sale_items_amount * si.sale_value - si.sale_promo_potongan), 0) as total_revenue,
 			COALESCE(AVG(si.sale_value), 0) as avg_sale_price
 		FROM barang b
 		LEFT JOIN brand br ON b.brand_id = br.brand_id
 		LEFT JOIN sale_items si ON b.barang_id = si.barang_id
-		LEFT JOIN sales s ON si.sales_id = s.sales_id AND s.sales_status = 1
+		LEFT JOIN sales s ON si.sales_id = s.sales_id AND s.sales_status IN (1, 4)
 	`
 
 	switch period {
@@ -2200,6 +2617,23 @@ func getItemSalesReport(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	if format := exportFormat(r); format != "" {
+		kolom := []exportKolom{
+			{"Kode Barang", exportTeks},
+			{"Nama Barang", exportTeks},
+			{"Brand", exportTeks},
+			{"Harga Jual", exportRupiah},
+			{"Jumlah Transaksi", exportAngka},
+			{"Jumlah Terjual", exportAngka},
+			{"Total Pendapatan", exportRupiah},
+			{"Rata-rata Harga", exportRupiah},
+		}
+		if err := exportRows(w, format, "laporan-barang-"+date, kolom, rows); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
 	// Collect results
 	var items []ItemSalesStats
 	for rows.Next() {
@@ -0,0 +1,523 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"src/database"
+	"strconv"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// Sales status values. 1 and 2 keep their original meaning.
+const (
+	salesStatusDraft    = 0
+	salesStatusSelesai  = 1 // shipped
+	salesStatusDiproses = 2 // confirmed, stock taken
+	salesStatusDipick   = 3
+	salesStatusLunas    = 4 // paid
+	salesStatusBatal    = 5
+)
+
+var salesStatusNama = map[int]string{
+	salesStatusDraft:    "Draft",
+	salesStatusSelesai:  "Selesai",
+	salesStatusDiproses: "Diproses",
+	salesStatusDipick:   "Dipick",
+	salesStatusLunas:    "Lunas",
+	salesStatusBatal:    "Dibatalkan",
+}
+
+// salesTransition lists the states an action may start from and where it ends
+type salesTransition struct {
+	From []int
+	To   int
+}
+
+var salesTransitions = map[string]salesTransition{
+	"confirm": {From: []int{salesStatusDraft}, To: salesStatusDiproses},
+	"pick":    {From: []int{salesStatusDiproses}, To: salesStatusDipick},
+	"ship":    {From: []int{salesStatusDiproses, salesStatusDipick}, To: salesStatusSelesai},
+	"pay":     {From: []int{salesStatusSelesai}, To: salesStatusLunas},
+	"cancel":  {From: []int{salesStatusDraft, salesStatusDiproses, salesStatusDipick}, To: salesStatusBatal},
+}
+
+type SalesStatusLog struct {
+	LogID      string `json:"log_id"`
+	SalesID    string `json:"sales_id"`
+	StatusFrom *int   `json:"status_from"`
+	StatusTo   int    `json:"status_to"`
+	StatusNama string `json:"status_nama"`
+	Action     string `json:"log_action"`
+	UsersID    string `json:"users_id"`
+	UsersNama  string `json:"users_nama"`
+	LogDate    string `json:"log_date"`
+	Note       string `json:"log_note"`
+}
+
+type SalesTransitionRequest struct {
+	SalesPayment string              `json:"sales_payment,omitempty"` // pay: optional final payment method
+	Pembayaran   []PembayaranRequest `json:"pembayaran,omitempty"`    // pay: optional payment lines
+	KreditSisa   bool                `json:"kredit_sisa,omitempty"`   // pay: book the unpaid remainder as kredit
+	Note         string              `json:"note,omitempty"`
+}
+
+// Helper function to tell whether stock has been taken for a sale in this status
+func salesHoldsStock(status int) bool {
+	return status == salesStatusDiproses || status == salesStatusDipick ||
+		status == salesStatusSelesai || status == salesStatusLunas
+}
+
+// Helper function to tell whether the lines of a sale may still change
+func salesLinesEditable(status int) bool {
+	return status == salesStatusDraft || status == salesStatusDiproses
+}
+
+// Helper function to validate a status given on create. Later states are
+// only reachable through the transition endpoints.
+func validSalesCreateStatus(status int) bool {
+	return status == salesStatusDraft || status == salesStatusSelesai || status == salesStatusDiproses
+}
+
+// logSalesStatus records one step of the sales lifecycle. from is nil on create.
+func logSalesStatus(q sqlExecutor, salesID string, from *int, to int, action string, user *UserData, note string) error {
+	var lastID string
+	err := q.QueryRow("SELECT log_id FROM sales_status_log ORDER BY log_id DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error generating log_id: %v", err)
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:]) // "SS_0000004" -> "0000004"
+		nextNum = n + 1
+	}
+
+	var statusFrom interface{}
+	if from != nil {
+		statusFrom = *from
+	}
+	_, err = q.Exec(`INSERT INTO sales_status_log (log_id, sales_id, status_from, status_to, log_action, users_id, log_date, log_note)
+		VALUES (?, ?, ?, ?, ?, ?, NOW(), ?)`,
+		fmt.Sprintf("SS_%07d", nextNum), salesID, statusFrom, to, action, requestUserID(user), processNullableStringValue(note))
+	if err != nil {
+		return fmt.Errorf("error writing sales status log: %v", err)
+	}
+	return nil
+}
+
+// resolveSaleLantai returns lantaiID, or the first active floor of the gudang when empty
+func resolveSaleLantai(q sqlExecutor, gudangID, lantaiID string) (string, error) {
+	if lantaiID != "" {
+		return lantaiID, nil
+	}
+	err := q.QueryRow("SELECT lantai_id FROM gudang_lantai WHERE gudang_id = ? AND lantai_status = 1 ORDER BY lantai_no LIMIT 1", gudangID).Scan(&lantaiID)
+	if err != nil {
+		return "", fmt.Errorf("error finding lantai for gudang '%s': %v", gudangID, err)
+	}
+	return lantaiID, nil
+}
+
+// takeSaleStock removes a sold amount from stock_gudang (and the bin when given).
+// Returns the lantai the stock was taken from.
+func takeSaleStock(q sqlExecutor, barangID, gudangID, lantaiID, lokasiID string, amount int) (string, error) {
+	lantaiID, err := resolveSaleLantai(q, gudangID, lantaiID)
+	if err != nil {
+		return "", err
+	}
+
+	var currentStock int
+	err = q.QueryRow("SELECT stock_barang FROM stock_gudang WHERE barang_id = ? AND lantai_id = ?", barangID, lantaiID).Scan(&currentStock)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("stock not found for barang_id %s in gudang_id %s", barangID, gudangID)
+	} else if err != nil {
+		return "", fmt.Errorf("error checking stock: %v", err)
+	}
+	if currentStock < amount {
+		return "", fmt.Errorf("insufficient stock for barang_id %s. Available: %d, Required: %d", barangID, currentStock, amount)
+	}
+
+	_, err = q.Exec("UPDATE stock_gudang SET stock_barang = ? WHERE barang_id = ? AND lantai_id = ?", currentStock-amount, barangID, lantaiID)
+	if err != nil {
+		return "", fmt.Errorf("error updating stock: %v", err)
+	}
+
+	// Pick from the chosen bin, otherwise keep bins within the new lantai total
+	if lokasiID != "" {
+		if err := takeStockLokasi(q, barangID, lantaiID, lokasiID, amount); err != nil {
+			return "", err
+		}
+	} else if err := trimStockLokasi(q, barangID, lantaiID); err != nil {
+		return "", err
+	}
+	return lantaiID, nil
+}
+
+// returnSaleStock puts a sold amount back on its floor (and bin when given)
+func returnSaleStock(q sqlExecutor, barangID, gudangID, lantaiID, lokasiID string, amount int) error {
+	lantaiID, err := resolveSaleLantai(q, gudangID, lantaiID)
+	if err != nil {
+		return err
+	}
+	if err := addStockGudang(q, barangID, lantaiID, amount); err != nil {
+		return err
+	}
+	if lokasiID != "" {
+		return addStockLokasi(q, barangID, lantaiID, lokasiID, amount)
+	}
+	return nil
+}
+
+// saleStockLine is one sale item as needed for stock effects
+type saleStockLine struct {
+	ItemID   string
+	BarangID string
+	GudangID string
+	LantaiID string
+	LokasiID string
+	Amount   int
+}
+
+// Helper function to load the lines of a sale (rows are closed before returning)
+func getSaleStockLines(q sqlExecutor, salesID string) ([]saleStockLine, error) {
+	rows, err := q.Query(`SELECT sale_items_id, barang_id, gudang_id, lantai_id, lokasi_id, sale_items_amount
+		FROM sale_items WHERE sales_id = ? ORDER BY sale_items_id`, salesID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching sale items: %v", err)
+	}
+	defer rows.Close()
+
+	var lines []saleStockLine
+	for rows.Next() {
+		var line saleStockLine
+		var lantaiID, lokasiID sql.NullString
+		if err := rows.Scan(&line.ItemID, &line.BarangID, &line.GudangID, &lantaiID, &lokasiID, &line.Amount); err != nil {
+			return nil, fmt.Errorf("error scanning sale item: %v", err)
+		}
+		line.LantaiID = lantaiID.String
+		line.LokasiID = lokasiID.String
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// transitionSales moves a sale through its lifecycle: PUT /sales/{id}/{action}
+func transitionSales(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	salesID := vars["id"]
+	action := vars["action"]
+
+	transition, ok := salesTransitions[action]
+	if !ok {
+		http.Error(w, "action must be one of confirm, pick, ship, pay, cancel", http.StatusBadRequest)
+		return
+	}
+
+	// Body is optional
+	var req SalesTransitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.SalesPayment != "" && req.SalesPayment != "1" && req.SalesPayment != "2" && req.SalesPayment != "3" {
+		http.Error(w, "sales_payment must be 1 (Tunai), 2 (Transfer), or 3 (Kredit)", http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var current int
+	err = tx.QueryRow("SELECT sales_status FROM sales WHERE sales_id = ? FOR UPDATE", salesID).Scan(&current)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Sales not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	allowed := false
+	for _, from := range transition.From {
+		if current == from {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		http.Error(w, fmt.Sprintf("Cannot %s a sale in status %d (%s)", action, current, salesStatusNama[current]), http.StatusConflict)
+		return
+	}
+
+	audit := startAudit(tx, r, "sales", salesID)
+	var creditNoteNo string
+
+	lines, err := getSaleStockLines(tx, salesID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch action {
+	case "confirm":
+		if len(lines) == 0 {
+			http.Error(w, "Cannot confirm a sale without items", http.StatusBadRequest)
+			return
+		}
+		for _, line := range lines {
+			lantaiID, err := takeSaleStock(tx, line.BarangID, line.GudangID, line.LantaiID, line.LokasiID, line.Amount)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Item %s: %v", line.ItemID, err), http.StatusBadRequest)
+				return
+			}
+			if line.LantaiID == "" {
+				if _, err := tx.Exec("UPDATE sale_items SET lantai_id = ? WHERE sale_items_id = ?", lantaiID, line.ItemID); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+		if _, err := assignSalesInvoiceNo(tx, salesID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case "cancel":
+		// Goods on a surat jalan have left or are about to leave the shelf
+		sjID, sjStatus, err := activeSuratJalan(tx, salesID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if sjStatus == sjDiterima {
+			http.Error(w, fmt.Sprintf("Surat jalan %s is already delivered, a delivered sale cannot be cancelled", sjID), http.StatusConflict)
+			return
+		} else if sjID != "" {
+			http.Error(w, fmt.Sprintf("Surat jalan %s is %s, cancel it first", sjID, sjStatusNama[sjStatus]), http.StatusConflict)
+			return
+		}
+		if salesHoldsStock(current) {
+			for _, line := range lines {
+				if err := returnSaleStock(tx, line.BarangID, line.GudangID, line.LantaiID, line.LokasiID, line.Amount); err != nil {
+					http.Error(w, fmt.Sprintf("Item %s: %v", line.ItemID, err), http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+		// An issued invoice is voided with a numbered credit note
+		if creditNoteNo, err = assignSalesCreditNote(tx, salesID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case "pay":
+		var total, recorded int
+		err := tx.QueryRow(`SELECT s.sales_total, (SELECT COUNT(*) FROM sales_pembayaran p WHERE p.sales_id = s.sales_id)
+			FROM sales s WHERE s.sales_id = ?`, salesID).Scan(&total, &recorded)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var payments []PembayaranRequest
+		if len(req.Pembayaran) > 0 || req.KreditSisa {
+			payments, err = buildPembayaran(total, req.Pembayaran, req.KreditSisa)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else if recorded == 0 || req.SalesPayment != "" {
+			// No breakdown given: the whole total is paid with one method
+			code := req.SalesPayment
+			if code == "" {
+				if err := tx.QueryRow("SELECT sales_payment FROM sales WHERE sales_id = ?", salesID).Scan(&code); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+			metode := bayarTransfer
+			switch code {
+			case "1":
+				metode = bayarTunai
+			case "3":
+				metode = bayarKredit
+			}
+			payments = []PembayaranRequest{{Metode: metode, Amount: total}}
+			if metode == bayarTunai {
+				payments[0].Diterima = total
+			}
+		}
+
+		if payments != nil {
+			before, err := getSalesPembayaran(tx, salesID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if err := savePembayaran(tx, salesID, payments, user); errors.Is(err, errKasirUser) {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			} else if errors.Is(err, errKasirShift) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			auditPembayaran(tx, r, salesID, before)
+		} else if err := checkPembayaranCover(tx, salesID, total); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	}
+
+	_, err = tx.Exec("UPDATE sales SET sales_status = ?, updated_by = ?, updated_at = NOW() WHERE sales_id = ?",
+		transition.To, requestUserID(user), salesID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := logSalesStatus(tx, salesID, &current, transition.To, action, user, req.Note); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	audit.record(action)
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"sales_id":          salesID,
+		"action":            action,
+		"status_from":       current,
+		"sales_status":      transition.To,
+		"sales_status_nama": salesStatusNama[transition.To],
+		"message":           fmt.Sprintf("Sales %s: %s -> %s", salesID, salesStatusNama[current], salesStatusNama[transition.To]),
+	}
+	if creditNoteNo != "" {
+		response["sales_credit_note_no"] = creditNoteNo
+	}
+	respondWithJSON(w, response)
+}
+
+// getSalesHistory lists who moved a sale through which state and when
+func getSalesHistory(w http.ResponseWriter, r *http.Request) {
+	salesID := mux.Vars(r)["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var salesExists bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM sales WHERE sales_id = ?)", salesID).Scan(&salesExists)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !salesExists {
+		http.Error(w, "Sales not found", http.StatusNotFound)
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT l.log_id, l.sales_id, l.status_from, l.status_to, l.log_action,
+		       l.users_id, u.users_nama, l.log_date, l.log_note
+		FROM sales_status_log l
+		LEFT JOIN users u ON l.users_id = u.users_id
+		WHERE l.sales_id = ?
+		ORDER BY l.log_date, l.log_id`, salesID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	history := []SalesStatusLog{}
+	for rows.Next() {
+		var l SalesStatusLog
+		var statusFrom sql.NullInt64
+		var usersID, usersNama, note sql.NullString
+		if err := rows.Scan(&l.LogID, &l.SalesID, &statusFrom, &l.StatusTo, &l.Action,
+			&usersID, &usersNama, &l.LogDate, &note); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if statusFrom.Valid {
+			from := int(statusFrom.Int64)
+			l.StatusFrom = &from
+		}
+		l.StatusNama = salesStatusNama[l.StatusTo]
+		l.UsersID = usersID.String
+		l.UsersNama = usersNama.String
+		l.Note = note.String
+		history = append(history, l)
+	}
+
+	respondWithJSON(w, history)
+}
+
+// SetupSalesStatusRoutes sets up the sales lifecycle routes
+func SetupSalesStatusRoutes(router *mux.Router) {
+	router.HandleFunc("/sales/{id}/history", getSalesHistory).Methods("GET")
+	router.HandleFunc("/sales/{id}/{action}", transitionSales).Methods("PUT")
+}
+
+// lockSalesForLineEdit locks a sale row and returns its status, or an error
+// when its lines may no longer change
+func lockSalesForLineEdit(q sqlExecutor, salesID string) (int, error) {
+	var status int
+	err := q.QueryRow("SELECT sales_status FROM sales WHERE sales_id = ? FOR UPDATE", salesID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("Invalid sales_id: sales does not exist")
+	} else if err != nil {
+		return 0, fmt.Errorf("error fetching sales: %v", err)
+	}
+	if !salesLinesEditable(status) {
+		return status, fmt.Errorf("Lines of a sale in status %s can no longer be changed", salesStatusNama[status])
+	}
+	return status, nil
+}
+
+// touchSales records who last changed a sale, e.g. after editing its lines
+func touchSales(q sqlExecutor, salesID string, user *UserData) error {
+	_, err := q.Exec("UPDATE sales SET updated_by = ?, updated_at = NOW() WHERE sales_id = ?", requestUserID(user), salesID)
+	if err != nil {
+		return fmt.Errorf("error updating sales: %v", err)
+	}
+	return nil
+}
+
+// updateSalesTotal applies the active promotions and recalculates
+// sales_total (with PPN) from the sale lines
+func updateSalesTotal(q sqlExecutor, salesID string) error {
+	if err := terapkanPromosi(q, salesID); err != nil {
+		return err
+	}
+	return hitungPajakSales(q, salesID)
+}
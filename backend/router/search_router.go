@@ -0,0 +1,424 @@
+package router
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"src/database"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// Search across master data and documents. Candidates come from the ngram
+// FULLTEXT indexes (migration 021) and the document number keys; each is then
+// scored against the query by edit distance, so "semne grsik" still finds
+// "Semen Gresik". Without the indexes the names are matched with LIKE.
+
+const (
+	searchDefaultLimit = 20
+	searchMaxLimit     = 100
+	searchKandidat     = 50   // candidates read per type before ranking
+	searchSkorMin      = 0.45 // results scoring lower are dropped
+)
+
+// SearchResult is one hit: its type, id, display label and the main columns
+// of the record
+type SearchResult struct {
+	Type  string                 `json:"type"`
+	ID    string                 `json:"id"`
+	Label string                 `json:"label"`
+	Score float64                `json:"score"`
+	Data  map[string]interface{} `json:"data"`
+}
+
+// searchKolom is a column a source selects into SearchResult.Data
+type searchKolom struct {
+	Nama  string
+	Angka bool // numeric, returned as a number
+	Cari  bool // scored against the query
+}
+
+// searchSource describes one searchable type
+type searchSource struct {
+	Type string
+	// SELECT and FROM; the first column is the id, Kolom names every column
+	Query string
+	Kolom []searchKolom
+	Label string // Data key shown as label
+	Base  string // condition every row must meet, "" for none
+	// Name columns of the FULLTEXT index, in index order
+	Teks []string
+	// Document number columns, matched with LIKE
+	Nomor []string
+	// Adds derived fields to Data
+	Lengkapi func(data map[string]interface{})
+}
+
+// Searchable types in result order for equal scores
+var searchSources = []searchSource{
+	{
+		Type: "barang",
+		Query: `SELECT b.barang_id, b.barang_nama, br.brand_nama, b.barang_harga_jual, b.barang_status
+			FROM barang b LEFT JOIN brand br ON b.brand_id = br.brand_id`,
+		Kolom: []searchKolom{
+			{Nama: "barang_id", Cari: true},
+			{Nama: "barang_nama", Cari: true},
+			{Nama: "brand_nama"},
+			{Nama: "barang_harga_jual", Angka: true},
+			{Nama: "barang_status", Angka: true},
+		},
+		Label: "barang_nama",
+		Base:  "b.deleted_at IS NULL",
+		Teks:  []string{"b.barang_nama"},
+		Nomor: []string{"b.barang_id"},
+	},
+	{
+		Type:  "brand",
+		Query: "SELECT brand_id, brand_nama FROM brand",
+		Kolom: []searchKolom{
+			{Nama: "brand_id", Cari: true},
+			{Nama: "brand_nama", Cari: true},
+		},
+		Label: "brand_nama",
+		Base:  "deleted_at IS NULL",
+		Teks:  []string{"brand_nama"},
+		Nomor: []string{"brand_id"},
+	},
+	{
+		Type:  "customer",
+		Query: "SELECT customer_id, customer_nama, customer_kontak, customer_alamat FROM customer",
+		Kolom: []searchKolom{
+			{Nama: "customer_id", Cari: true},
+			{Nama: "customer_nama", Cari: true},
+			{Nama: "customer_kontak", Cari: true},
+			{Nama: "customer_alamat"},
+		},
+		Label: "customer_nama",
+		Base:  "deleted_at IS NULL",
+		Teks:  []string{"customer_nama", "customer_kontak"},
+		Nomor: []string{"customer_id"},
+	},
+	{
+		Type: "sales",
+		Query: `SELECT s.sales_id, s.sales_invoice_no, c.customer_nama, DATE_FORMAT(s.sales_date, '%Y-%m-%d'),
+			       s.sales_total, s.sales_status
+			FROM sales s LEFT JOIN customer c ON s.customer_id = c.customer_id`,
+		Kolom: []searchKolom{
+			{Nama: "sales_id", Cari: true},
+			{Nama: "sales_invoice_no", Cari: true},
+			{Nama: "customer_nama"},
+			{Nama: "sales_date"},
+			{Nama: "sales_total", Angka: true},
+			{Nama: "sales_status", Angka: true},
+		},
+		Label: "sales_id",
+		Nomor: []string{"s.sales_id", "s.sales_invoice_no"},
+		Lengkapi: func(data map[string]interface{}) {
+			if status, ok := data["sales_status"].(int64); ok {
+				data["sales_status_nama"] = salesStatusNama[int(status)]
+			}
+		},
+	},
+	{
+		Type: "logs",
+		Query: `SELECT logs_id, logs_po_no, IF(logs_status = 1, 'Masuk', 'Keluar'),
+			       DATE_FORMAT(logs_date, '%Y-%m-%d'), logs_desc
+			FROM barang_logs`,
+		Kolom: []searchKolom{
+			{Nama: "logs_id", Cari: true},
+			{Nama: "logs_po_no", Cari: true},
+			{Nama: "logs_jenis"},
+			{Nama: "logs_date"},
+			{Nama: "logs_desc"},
+		},
+		Label: "logs_id",
+		Nomor: []string{"logs_id", "logs_po_no"},
+	},
+}
+
+// search handles GET /search?q=. Optional: types (comma separated, e.g.
+// barang,customer), limit (default 20, at most 100).
+func search(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len([]rune(q)) < 2 {
+		respondWithError(w, http.StatusBadRequest, "q must be at least 2 characters")
+		return
+	}
+
+	limit := searchDefaultLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > searchMaxLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(searchMaxLimit))
+			return
+		}
+		limit = n
+	}
+
+	sources := searchSources
+	if value := r.URL.Query().Get("types"); value != "" {
+		sources = nil
+		for _, t := range strings.Split(value, ",") {
+			t = strings.TrimSpace(t)
+			found := false
+			for _, source := range searchSources {
+				if source.Type == t {
+					sources = append(sources, source)
+					found = true
+				}
+			}
+			if !found {
+				respondWithError(w, http.StatusBadRequest, "Unknown search type: "+t)
+				return
+			}
+		}
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	results := []SearchResult{}
+	for _, source := range sources {
+		found, err := searchType(db, source, q)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Search error ("+source.Type+"): "+err.Error())
+			return
+		}
+		results = append(results, found...)
+	}
+
+	// Best first; types keep their order on equal scores
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	total := len(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	respondWithJSON(w, map[string]interface{}{
+		"query":   q,
+		"total":   total,
+		"results": results,
+	})
+}
+
+// searchType reads the candidates of one source and scores them
+func searchType(db *sql.DB, source searchSource, q string) ([]SearchResult, error) {
+	rows, err := searchKandidatRows(db, source, q, true)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1191 {
+		// No FULLTEXT index yet (migration 021 not run): match with LIKE
+		rows, err = searchKandidatRows(db, source, q, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	query := strings.ToLower(q)
+	values := make([]sql.NullString, len(source.Kolom))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var results []SearchResult
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		data := make(map[string]interface{}, len(source.Kolom))
+		score := 0.0
+		for i, k := range source.Kolom {
+			if !values[i].Valid {
+				data[k.Nama] = nil
+				continue
+			}
+			data[k.Nama] = values[i].String
+			if k.Angka {
+				if n, err := strconv.ParseInt(values[i].String, 10, 64); err == nil {
+					data[k.Nama] = n
+				} else if f, err := strconv.ParseFloat(values[i].String, 64); err == nil {
+					data[k.Nama] = f
+				}
+			}
+			if k.Cari {
+				if s := searchSkor(query, values[i].String); s > score {
+					score = s
+				}
+			}
+		}
+		if score < searchSkorMin {
+			continue
+		}
+		if source.Lengkapi != nil {
+			source.Lengkapi(data)
+		}
+		label, _ := data[source.Label].(string)
+		results = append(results, SearchResult{
+			Type:  source.Type,
+			ID:    values[0].String,
+			Label: label,
+			Score: float64(int(score*1000+0.5)) / 1000,
+			Data:  data,
+		})
+	}
+	return results, rows.Err()
+}
+
+// searchKandidatRows queries the candidates of a source: a document number
+// containing q, or a name matching it through the FULLTEXT index (fulltext)
+// or containing one of its words (LIKE fallback)
+func searchKandidatRows(db *sql.DB, source searchSource, q string, fulltext bool) (*sql.Rows, error) {
+	var match []string
+	var args []interface{}
+	for _, column := range source.Nomor {
+		match = append(match, column+" LIKE ?")
+		args = append(args, "%"+q+"%")
+	}
+
+	order := "1"
+	if len(source.Teks) > 0 {
+		if fulltext {
+			against := "MATCH(" + strings.Join(source.Teks, ", ") + ") AGAINST (? IN NATURAL LANGUAGE MODE)"
+			match = append(match, against)
+			args = append(args, q)
+			order = against + " DESC"
+			args = append(args, q)
+		} else {
+			for _, word := range strings.Fields(q) {
+				for _, column := range source.Teks {
+					match = append(match, column+" LIKE ?")
+					args = append(args, "%"+word+"%")
+				}
+			}
+		}
+	}
+
+	query := source.Query + " WHERE (" + strings.Join(match, " OR ") + ")"
+	if source.Base != "" {
+		query += " AND " + source.Base
+	}
+	query += " ORDER BY " + order + " LIMIT " + strconv.Itoa(searchKandidat)
+	return db.Query(query, args...)
+}
+
+// searchSkor rates how well text matches the lower-cased query, from 0 to 1.
+// Exact, prefix and substring matches score highest; otherwise the query is
+// compared with every run of as many words of text, allowing typos.
+func searchSkor(q, text string) float64 {
+	text = strings.ToLower(strings.TrimSpace(text))
+	switch {
+	case text == "":
+		return 0
+	case text == q:
+		return 1
+	case strings.HasPrefix(text, q):
+		return 0.95
+	case strings.Contains(text, q):
+		return 0.9
+	}
+
+	// Phone numbers are written with and without separators
+	if digits := searchDigits(q); len(digits) >= 4 && strings.Trim(q, "0123456789 +-.()") == "" {
+		if strings.Contains(searchDigits(text), digits) {
+			return 0.9
+		}
+	}
+
+	qWords := strings.Fields(q)
+	words := strings.Fields(text)
+	n := len(qWords)
+	if n > len(words) {
+		n = len(words)
+	}
+	qRunes := []rune(q)
+	best := 0.0
+	for i := 0; i+n <= len(words); i++ {
+		window := []rune(strings.Join(words[i:i+n], " "))
+		s := searchMirip(qRunes, window)
+		// A word that starts like the query: compare the same length
+		if len(window) > len(qRunes) {
+			if p := searchMirip(qRunes, window[:len(qRunes)]) * 0.95; p > s {
+				s = p
+			}
+		}
+		if s > best {
+			best = s
+		}
+	}
+	return best * 0.8
+}
+
+// searchMirip is 1 minus the edit distance relative to the longer string
+func searchMirip(a, b []rune) float64 {
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(searchJarak(a, b))/float64(longest)
+}
+
+// searchJarak is the edit distance between a and b where inserting,
+// deleting, replacing or swapping two adjacent characters costs one
+// (optimal string alignment)
+func searchJarak(a, b []rune) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func searchDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// SetupSearchRoutes sets up the global search route
+func SetupSearchRoutes(router *mux.Router) {
+	router.HandleFunc("/search", search).Methods("GET")
+}
@@ -0,0 +1,942 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"src/database"
+	"strconv"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// Surat jalan status values
+const (
+	sjDisiapkan  = 1
+	sjDikirim    = 2
+	sjDiterima   = 3
+	sjDibatalkan = 4
+)
+
+var sjStatusNama = map[int]string{
+	sjDisiapkan:  "Disiapkan",
+	sjDikirim:    "Dikirim",
+	sjDiterima:   "Diterima",
+	sjDibatalkan: "Dibatalkan",
+}
+
+type SuratJalanItem struct {
+	ID          string `json:"sji_id"`
+	SjID        string `json:"sj_id"`
+	SaleItemsID string `json:"sale_items_id,omitempty"`
+	OrdersID    string `json:"orders_id,omitempty"`
+	BarangID    string `json:"barang_id"`
+	BarangNama  string `json:"barang_nama"`
+	GudangID    string `json:"gudang_id"`
+	GudangNama  string `json:"gudang_nama"`
+	LantaiID    string `json:"lantai_id"`
+	LantaiNama  string `json:"lantai_nama"`
+	Amount      int    `json:"sji_amount"`
+	Delivered   *int   `json:"sji_delivered"`
+}
+
+type SuratJalan struct {
+	ID           string           `json:"sj_id"`
+	No           string           `json:"sj_no,omitempty"`
+	SalesID      string           `json:"sales_id,omitempty"`
+	LogsID       string           `json:"logs_id,omitempty"`
+	CustomerID   string           `json:"customer_id,omitempty"`
+	Penerima     string           `json:"sj_penerima"`
+	Alamat       string           `json:"sj_alamat"`
+	Kontak       string           `json:"sj_kontak"`
+	Kendaraan    string           `json:"sj_kendaraan"`
+	Supir        string           `json:"sj_supir"`
+	Date         string           `json:"sj_date"`
+	Status       int              `json:"sj_status"`
+	StatusNama   string           `json:"sj_status_nama"`
+	DispatchedAt string           `json:"sj_dispatched_at,omitempty"`
+	DeliveredAt  string           `json:"sj_delivered_at,omitempty"`
+	ReceiverNama string           `json:"sj_receiver_nama,omitempty"`
+	Note         string           `json:"sj_note,omitempty"`
+	Items        []SuratJalanItem `json:"items,omitempty"`
+}
+
+type SuratJalanItemRequest struct {
+	SaleItemsID string `json:"sale_items_id,omitempty"`
+	OrdersID    string `json:"orders_id,omitempty"`
+	LantaiID    string `json:"lantai_id,omitempty"` // Optional: another floor of the same gudang
+	Amount      int    `json:"sji_amount"`
+}
+
+type SuratJalanRequest struct {
+	SalesID   string                  `json:"sales_id,omitempty"`
+	LogsID    string                  `json:"logs_id,omitempty"`
+	Penerima  string                  `json:"sj_penerima,omitempty"` // default: customer_nama
+	Alamat    string                  `json:"sj_alamat,omitempty"`   // default: customer_alamat
+	Kontak    string                  `json:"sj_kontak,omitempty"`   // default: customer_kontak
+	Kendaraan string                  `json:"sj_kendaraan"`
+	Supir     string                  `json:"sj_supir"`
+	Date      string                  `json:"sj_date"`
+	Note      string                  `json:"sj_note"`
+	Items     []SuratJalanItemRequest `json:"items"` // empty: everything still outstanding
+}
+
+type SuratJalanDispatchRequest struct {
+	Kendaraan string `json:"sj_kendaraan,omitempty"`
+	Supir     string `json:"sj_supir,omitempty"`
+}
+
+type SuratJalanDeliverRequest struct {
+	ReceiverNama string `json:"sj_receiver_nama"`
+	Items        []struct {
+		ID        string `json:"sji_id"`
+		Delivered int    `json:"sji_delivered"`
+	} `json:"items"` // lines not listed are delivered in full
+}
+
+// deliveryLine is one shippable line of a sale or outbound log with what is
+// already covered by surat jalan
+type deliveryLine struct {
+	RefID       string `json:"ref_id"`
+	BarangID    string `json:"barang_id"`
+	BarangNama  string `json:"barang_nama"`
+	GudangID    string `json:"gudang_id"`
+	LantaiID    string `json:"lantai_id"`
+	Amount      int    `json:"amount"`
+	Delivered   int    `json:"delivered"`
+	InTransit   int    `json:"in_transit"`
+	Outstanding int    `json:"outstanding"`
+}
+
+// Shipped quantity per line: delivered amounts of received surat jalan plus
+// the planned amounts of open ones
+const deliveryCoveredSQL = `
+	COALESCE(SUM(CASE WHEN sj.sj_status = 3 THEN sji.sji_delivered ELSE 0 END), 0),
+	COALESCE(SUM(CASE WHEN sj.sj_status IN (1, 2) THEN sji.sji_amount ELSE 0 END), 0)`
+
+// getDeliveryLines loads the lines of a sale or an outbound log (exactly one of
+// salesID/logsID is set) with their delivery progress
+func getDeliveryLines(q sqlExecutor, salesID, logsID string) ([]deliveryLine, error) {
+	var query, refID string
+	if salesID != "" {
+		query = `SELECT si.sale_items_id, si.barang_id, COALESCE(b.barang_nama, ''), si.gudang_id, si.lantai_id, si.sale_items_amount,` + deliveryCoveredSQL + `
+			FROM sale_items si
+			LEFT JOIN barang b ON si.barang_id = b.barang_id
+			LEFT JOIN surat_jalan_items sji ON sji.sale_items_id = si.sale_items_id
+			LEFT JOIN surat_jalan sj ON sji.sj_id = sj.sj_id
+			WHERE si.sales_id = ?
+			GROUP BY si.sale_items_id, si.barang_id, b.barang_nama, si.gudang_id, si.lantai_id, si.sale_items_amount
+			ORDER BY si.sale_items_id`
+		refID = salesID
+	} else {
+		// Only finished outbound orders have left stock
+		query = `SELECT ok.orders_id, ok.barang_id, COALESCE(b.barang_nama, ''), ok.gudang_id, ok.lantai_id, ok.orders_amount,` + deliveryCoveredSQL + `
+			FROM orders_keluar ok
+			LEFT JOIN barang b ON ok.barang_id = b.barang_id
+			LEFT JOIN surat_jalan_items sji ON sji.orders_id = ok.orders_id
+			LEFT JOIN surat_jalan sj ON sji.sj_id = sj.sj_id
+			WHERE ok.logs_id = ? AND ok.orders_status = 1
+			GROUP BY ok.orders_id, ok.barang_id, b.barang_nama, ok.gudang_id, ok.lantai_id, ok.orders_amount
+			ORDER BY ok.orders_id`
+		refID = logsID
+	}
+
+	rows, err := q.Query(query, refID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching delivery lines: %v", err)
+	}
+	defer rows.Close()
+
+	var lines []deliveryLine
+	for rows.Next() {
+		var line deliveryLine
+		var lantaiID sql.NullString
+		if err := rows.Scan(&line.RefID, &line.BarangID, &line.BarangNama, &line.GudangID, &lantaiID,
+			&line.Amount, &line.Delivered, &line.InTransit); err != nil {
+			return nil, fmt.Errorf("error scanning delivery line: %v", err)
+		}
+		line.LantaiID = lantaiID.String
+		line.Outstanding = line.Amount - line.Delivered - line.InTransit
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// activeSuratJalan returns a surat jalan of the sale that is being prepared,
+// on the road or delivered (empty when there is none)
+func activeSuratJalan(q sqlExecutor, salesID string) (string, int, error) {
+	var sjID string
+	var status int
+	err := q.QueryRow(`SELECT sj.sj_id, sj.sj_status
+		FROM surat_jalan_items sji
+		JOIN surat_jalan sj ON sji.sj_id = sj.sj_id
+		JOIN sale_items si ON sji.sale_items_id = si.sale_items_id
+		WHERE si.sales_id = ? AND sj.sj_status IN (1, 2, 3)
+		ORDER BY sj.sj_status DESC, sj.sj_id
+		LIMIT 1`, salesID).Scan(&sjID, &status)
+	if err == sql.ErrNoRows {
+		return "", 0, nil
+	} else if err != nil {
+		return "", 0, fmt.Errorf("error checking surat jalan: %v", err)
+	}
+	return sjID, status, nil
+}
+
+// suratJalanCovered returns how much of a sale line is on surat jalan that are
+// being prepared or on the road, or was delivered
+func suratJalanCovered(q sqlExecutor, saleItemsID string) (int, error) {
+	var covered int
+	err := q.QueryRow(`SELECT COALESCE(SUM(CASE WHEN sj.sj_status = 3 THEN sji.sji_delivered ELSE sji.sji_amount END), 0)
+		FROM surat_jalan_items sji
+		JOIN surat_jalan sj ON sji.sj_id = sj.sj_id
+		WHERE sji.sale_items_id = ? AND sj.sj_status IN (1, 2, 3)`, saleItemsID).Scan(&covered)
+	if err != nil {
+		return 0, fmt.Errorf("error checking surat jalan: %v", err)
+	}
+	return covered, nil
+}
+
+// suratJalanSourceLantai returns the floor the stock of a surat jalan line was
+// booked off: the floor of its sale line or outbound order
+func suratJalanSourceLantai(q sqlExecutor, item SuratJalanItem) (string, error) {
+	query, refID := "SELECT lantai_id FROM sale_items WHERE sale_items_id = ?", item.SaleItemsID
+	if item.SaleItemsID == "" {
+		query, refID = "SELECT lantai_id FROM orders_keluar WHERE orders_id = ?", item.OrdersID
+	}
+	var lantaiID sql.NullString
+	if err := q.QueryRow(query, refID).Scan(&lantaiID); err != nil {
+		return "", fmt.Errorf("error fetching line '%s': %v", refID, err)
+	}
+	return resolveSaleLantai(q, item.GudangID, lantaiID.String)
+}
+
+// Helper function to load the lines of a surat jalan (rows are closed before returning)
+func getSuratJalanItems(q sqlExecutor, sjID string) ([]SuratJalanItem, error) {
+	rows, err := q.Query(`
+		SELECT sji.sji_id, sji.sj_id, sji.sale_items_id, sji.orders_id, sji.barang_id, COALESCE(b.barang_nama, ''),
+		       sji.gudang_id, COALESCE(g.gudang_nama, ''), sji.lantai_id, COALESCE(gl.lantai_nama, ''), sji.sji_amount, sji.sji_delivered
+		FROM surat_jalan_items sji
+		LEFT JOIN barang b ON sji.barang_id = b.barang_id
+		LEFT JOIN list_gudang g ON sji.gudang_id = g.gudang_id
+		LEFT JOIN gudang_lantai gl ON sji.lantai_id = gl.lantai_id
+		WHERE sji.sj_id = ?
+		ORDER BY sji.sji_id`, sjID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching surat jalan items: %v", err)
+	}
+	defer rows.Close()
+
+	items := []SuratJalanItem{}
+	for rows.Next() {
+		var item SuratJalanItem
+		var saleItemsID, ordersID, lantaiID sql.NullString
+		var delivered sql.NullInt64
+		if err := rows.Scan(&item.ID, &item.SjID, &saleItemsID, &ordersID, &item.BarangID, &item.BarangNama,
+			&item.GudangID, &item.GudangNama, &lantaiID, &item.LantaiNama, &item.Amount, &delivered); err != nil {
+			return nil, fmt.Errorf("error scanning surat jalan item: %v", err)
+		}
+		item.SaleItemsID = saleItemsID.String
+		item.OrdersID = ordersID.String
+		item.LantaiID = lantaiID.String
+		if delivered.Valid {
+			d := int(delivered.Int64)
+			item.Delivered = &d
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+const suratJalanSelect = `
+	SELECT sj_id, sj_no, sales_id, logs_id, customer_id, sj_penerima, sj_alamat, sj_kontak, sj_kendaraan, sj_supir,
+	       sj_date, sj_status, sj_dispatched_at, sj_delivered_at, sj_receiver_nama, sj_note
+	FROM surat_jalan`
+
+// Helper function to scan one row of suratJalanSelect
+func scanSuratJalan(scan func(dest ...interface{}) error) (*SuratJalan, error) {
+	var sj SuratJalan
+	var no, salesID, logsID, customerID, kontak, kendaraan, supir, dispatchedAt, deliveredAt, receiver, note sql.NullString
+	err := scan(&sj.ID, &no, &salesID, &logsID, &customerID, &sj.Penerima, &sj.Alamat, &kontak, &kendaraan, &supir,
+		&sj.Date, &sj.Status, &dispatchedAt, &deliveredAt, &receiver, &note)
+	if err != nil {
+		return nil, err
+	}
+	sj.No = no.String
+	sj.SalesID = salesID.String
+	sj.LogsID = logsID.String
+	sj.CustomerID = customerID.String
+	sj.Kontak = kontak.String
+	sj.Kendaraan = kendaraan.String
+	sj.Supir = supir.String
+	sj.DispatchedAt = dispatchedAt.String
+	sj.DeliveredAt = deliveredAt.String
+	sj.ReceiverNama = receiver.String
+	sj.Note = note.String
+	sj.StatusNama = sjStatusNama[sj.Status]
+	return &sj, nil
+}
+
+func createSuratJalan(w http.ResponseWriter, r *http.Request) {
+	var req SuratJalanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if (req.SalesID == "") == (req.LogsID == "") {
+		respondWithError(w, http.StatusBadRequest, "exactly one of sales_id or logs_id is required")
+		return
+	}
+	if req.Date == "" {
+		req.Date = time.Now().Format("2006-01-02")
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Recipient defaults to the customer of the sale
+	var customerID interface{}
+	if req.SalesID != "" {
+		var status int
+		var custID string
+		var custNama, custKontak, custAlamat sql.NullString
+		err = tx.QueryRow(`SELECT s.sales_status, s.customer_id, c.customer_nama, c.customer_kontak, c.customer_alamat
+			FROM sales s LEFT JOIN customer c ON s.customer_id = c.customer_id
+			WHERE s.sales_id = ? FOR UPDATE`, req.SalesID).Scan(&status, &custID, &custNama, &custKontak, &custAlamat)
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Sales not found")
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		if status != salesStatusDiproses && status != salesStatusDipick && status != salesStatusSelesai {
+			respondWithError(w, http.StatusConflict, fmt.Sprintf("Sales in status %s cannot be shipped", salesStatusNama[status]))
+			return
+		}
+		customerID = custID
+		if req.Penerima == "" {
+			req.Penerima = custNama.String
+		}
+		if req.Alamat == "" {
+			req.Alamat = custAlamat.String
+		}
+		if req.Kontak == "" {
+			req.Kontak = custKontak.String
+		}
+	} else {
+		var logsStatus int
+		err = tx.QueryRow("SELECT logs_status FROM barang_logs WHERE logs_id = ?", req.LogsID).Scan(&logsStatus)
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Barang logs not found")
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		if logsStatus != 2 {
+			respondWithError(w, http.StatusBadRequest, "logs_id is not an outbound (keluar) log")
+			return
+		}
+	}
+	if req.Penerima == "" || req.Alamat == "" {
+		respondWithError(w, http.StatusBadRequest, "sj_penerima and sj_alamat are required")
+		return
+	}
+
+	lines, err := getDeliveryLines(tx, req.SalesID, req.LogsID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	byRef := map[string]deliveryLine{}
+	for _, line := range lines {
+		byRef[line.RefID] = line
+	}
+
+	// Without explicit lines ship everything still outstanding
+	if len(req.Items) == 0 {
+		for _, line := range lines {
+			if line.Outstanding <= 0 {
+				continue
+			}
+			itemReq := SuratJalanItemRequest{Amount: line.Outstanding}
+			if req.SalesID != "" {
+				itemReq.SaleItemsID = line.RefID
+			} else {
+				itemReq.OrdersID = line.RefID
+			}
+			req.Items = append(req.Items, itemReq)
+		}
+		if len(req.Items) == 0 {
+			respondWithError(w, http.StatusConflict, "Nothing left to ship")
+			return
+		}
+	}
+
+	var items []SuratJalanItem
+	var sourceLantai []string // floor each line's stock was booked off
+	planned := map[string]int{}
+	for i, itemReq := range req.Items {
+		refID := itemReq.SaleItemsID
+		if req.LogsID != "" {
+			refID = itemReq.OrdersID
+		}
+		line, ok := byRef[refID]
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Item #%d: line '%s' does not belong to this document", i+1, refID))
+			return
+		}
+		if itemReq.Amount <= 0 {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Item #%d: sji_amount must be greater than 0", i+1))
+			return
+		}
+		planned[refID] += itemReq.Amount
+		if planned[refID] > line.Outstanding {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Item #%d: only %d of %s left to ship", i+1, line.Outstanding, line.BarangNama))
+			return
+		}
+
+		sourceLantaiID, err := resolveSaleLantai(tx, line.GudangID, line.LantaiID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Item #%d: %v", i+1, err))
+			return
+		}
+		lantaiID := itemReq.LantaiID
+		if lantaiID == "" {
+			lantaiID = sourceLantaiID
+		} else {
+			var sameGudang bool
+			err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM gudang_lantai WHERE lantai_id = ? AND gudang_id = ? AND lantai_status = 1)", lantaiID, line.GudangID).Scan(&sameGudang)
+			if err != nil || !sameGudang {
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Item #%d: lantai_id must be an active floor of gudang %s", i+1, line.GudangID))
+				return
+			}
+		}
+		items = append(items, SuratJalanItem{
+			SaleItemsID: itemReq.SaleItemsID,
+			OrdersID:    itemReq.OrdersID,
+			BarangID:    line.BarangID,
+			BarangNama:  line.BarangNama,
+			GudangID:    line.GudangID,
+			LantaiID:    lantaiID,
+			Amount:      itemReq.Amount,
+		})
+		sourceLantai = append(sourceLantai, sourceLantaiID)
+	}
+
+	// Get last sj_id
+	var lastID string
+	err = tx.QueryRow("SELECT sj_id FROM surat_jalan ORDER BY sj_id DESC LIMIT 1").Scan(&lastID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching last sj_id")
+		return
+	}
+	nextNum := 1
+	if lastID != "" {
+		n, _ := strconv.Atoi(lastID[3:]) // "SJ_0000002" -> "0000002"
+		nextNum = n + 1
+	}
+	newID := fmt.Sprintf("SJ_%07d", nextNum)
+	audit := startAudit(tx, r, "surat_jalan", newID)
+
+	sjNo, err := assignNomor(tx, "surat_jalan", parseNomorDate(req.Date))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	_, err = tx.Exec(`INSERT INTO surat_jalan (sj_id, sj_no, sales_id, logs_id, customer_id, sj_penerima, sj_alamat, sj_kontak,
+			sj_kendaraan, sj_supir, sj_date, sj_status, sj_note, users_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		newID, sjNo, processNullableStringValue(req.SalesID), processNullableStringValue(req.LogsID), customerID,
+		req.Penerima, req.Alamat, processNullableStringValue(req.Kontak),
+		processNullableStringValue(req.Kendaraan), processNullableStringValue(req.Supir), req.Date, sjDisiapkan,
+		processNullableStringValue(req.Note), requestUserID(user))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Insert error: "+err.Error())
+		return
+	}
+
+	var lastItemID string
+	err = tx.QueryRow("SELECT sji_id FROM surat_jalan_items ORDER BY sji_id DESC LIMIT 1").Scan(&lastItemID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching last sji_id")
+		return
+	}
+	itemNum := 1
+	if lastItemID != "" {
+		n, _ := strconv.Atoi(lastItemID[3:])
+		itemNum = n + 1
+	}
+	for i := range items {
+		items[i].ID = fmt.Sprintf("SD_%07d", itemNum)
+		items[i].SjID = newID
+		_, err = tx.Exec(`INSERT INTO surat_jalan_items (sji_id, sj_id, sale_items_id, orders_id, barang_id, gudang_id, lantai_id, sji_amount)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			items[i].ID, newID, processNullableStringValue(items[i].SaleItemsID), processNullableStringValue(items[i].OrdersID),
+			items[i].BarangID, items[i].GudangID, items[i].LantaiID, items[i].Amount)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Insert item error: "+err.Error())
+			return
+		}
+		itemNum++
+	}
+
+	// Goods shipped from another floor leave that floor, so the floor the
+	// stock was booked off gets it back
+	for i, item := range items {
+		if item.LantaiID == sourceLantai[i] {
+			continue
+		}
+		if err := pindahStokBarang(tx, item.BarangID, item.LantaiID, sourceLantai[i], item.Amount, "surat jalan "+sjNo, user); err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Item #%d: %v", i+1, err))
+			return
+		}
+	}
+
+	audit.record("create")
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respondWithJSON(w, SuratJalan{
+		ID:         newID,
+		No:         sjNo,
+		SalesID:    req.SalesID,
+		LogsID:     req.LogsID,
+		Penerima:   req.Penerima,
+		Alamat:     req.Alamat,
+		Kontak:     req.Kontak,
+		Kendaraan:  req.Kendaraan,
+		Supir:      req.Supir,
+		Date:       req.Date,
+		Status:     sjDisiapkan,
+		StatusNama: sjStatusNama[sjDisiapkan],
+		Note:       req.Note,
+		Items:      items,
+	})
+}
+
+func getSuratJalans(w http.ResponseWriter, r *http.Request) {
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	query := suratJalanSelect + " WHERE 1 = 1"
+	var args []interface{}
+	for _, filter := range []string{"sales_id", "logs_id", "sj_status"} {
+		if value := r.URL.Query().Get(filter); value != "" {
+			query += " AND " + filter + " = ?"
+			args = append(args, value)
+		}
+	}
+	query += " ORDER BY sj_date DESC, sj_id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	list := []SuratJalan{}
+	for rows.Next() {
+		sj, err := scanSuratJalan(rows.Scan)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Scan error: "+err.Error())
+			return
+		}
+		list = append(list, *sj)
+	}
+	respondWithJSON(w, list)
+}
+
+func getSuratJalanDetail(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	sj, err := scanSuratJalan(db.QueryRow(suratJalanSelect+" WHERE sj_id = ?", id).Scan)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Surat jalan not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+
+	sj.Items, err = getSuratJalanItems(db, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, sj)
+}
+
+// getDeliveryProgress shows per line how much of a sale or outbound log is
+// delivered, on the road and still outstanding
+func getDeliveryProgress(w http.ResponseWriter, r *http.Request) {
+	salesID := r.URL.Query().Get("sales_id")
+	logsID := r.URL.Query().Get("logs_id")
+	if (salesID == "") == (logsID == "") {
+		respondWithError(w, http.StatusBadRequest, "exactly one of sales_id or logs_id is required")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	lines, err := getDeliveryLines(db, salesID, logsID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if lines == nil {
+		lines = []deliveryLine{}
+	}
+	respondWithJSON(w, lines)
+}
+
+// Helper function to lock a surat jalan and check its status
+func lockSuratJalan(q sqlExecutor, id string, allowed ...int) (int, error) {
+	var status int
+	err := q.QueryRow("SELECT sj_status FROM surat_jalan WHERE sj_id = ? FOR UPDATE", id).Scan(&status)
+	if err != nil {
+		return 0, err
+	}
+	for _, s := range allowed {
+		if status == s {
+			return status, nil
+		}
+	}
+	return status, fmt.Errorf("surat jalan is %s", sjStatusNama[status])
+}
+
+func dispatchSuratJalan(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req SuratJalanDispatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = lockSuratJalan(tx, id, sjDisiapkan)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Surat jalan not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusConflict, "Cannot dispatch: "+err.Error())
+		return
+	}
+
+	audit := startAudit(tx, r, "surat_jalan", id)
+
+	_, err = tx.Exec(`UPDATE surat_jalan
+		SET sj_status = ?, sj_dispatched_at = NOW(),
+		    sj_kendaraan = COALESCE(?, sj_kendaraan), sj_supir = COALESCE(?, sj_supir)
+		WHERE sj_id = ?`,
+		sjDikirim, processNullableStringValue(req.Kendaraan), processNullableStringValue(req.Supir), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+
+	audit.record("dispatch")
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
+	respondWithJSON(w, map[string]interface{}{
+		"sj_id":          id,
+		"sj_status":      sjDikirim,
+		"sj_status_nama": sjStatusNama[sjDikirim],
+	})
+}
+
+// deliverSuratJalan confirms receipt. Quantities below the planned amount stay
+// outstanding on the sale/log for a later surat jalan.
+func deliverSuratJalan(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req SuratJalanDeliverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.ReceiverNama == "" {
+		respondWithError(w, http.StatusBadRequest, "sj_receiver_nama is required")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = lockSuratJalan(tx, id, sjDikirim)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Surat jalan not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusConflict, "Cannot confirm delivery: "+err.Error())
+		return
+	}
+
+	audit := startAudit(tx, r, "surat_jalan", id)
+
+	items, err := getSuratJalanItems(tx, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	delivered := map[string]int{}
+	for _, item := range items {
+		delivered[item.ID] = item.Amount
+	}
+	for _, d := range req.Items {
+		planned, ok := delivered[d.ID]
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Line '%s' does not belong to this surat jalan", d.ID))
+			return
+		}
+		if d.Delivered < 0 || d.Delivered > planned {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("sji_delivered for '%s' must be between 0 and %d", d.ID, planned))
+			return
+		}
+		delivered[d.ID] = d.Delivered
+	}
+
+	partial := false
+	for _, item := range items {
+		if delivered[item.ID] < item.Amount {
+			partial = true
+		}
+		if _, err := tx.Exec("UPDATE surat_jalan_items SET sji_delivered = ? WHERE sji_id = ?", delivered[item.ID], item.ID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+			return
+		}
+	}
+
+	_, err = tx.Exec("UPDATE surat_jalan SET sj_status = ?, sj_delivered_at = NOW(), sj_receiver_nama = ? WHERE sj_id = ?",
+		sjDiterima, req.ReceiverNama, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+
+	// A sale whose lines are all delivered counts as shipped
+	var salesID sql.NullString
+	if err := tx.QueryRow("SELECT sales_id FROM surat_jalan WHERE sj_id = ?", id).Scan(&salesID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	salesShipped := false
+	if salesID.Valid {
+		lines, err := getDeliveryLines(tx, salesID.String, "")
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		complete := true
+		for _, line := range lines {
+			if line.Delivered < line.Amount {
+				complete = false
+				break
+			}
+		}
+		var status int
+		if err := tx.QueryRow("SELECT sales_status FROM sales WHERE sales_id = ? FOR UPDATE", salesID.String).Scan(&status); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		if complete && (status == salesStatusDiproses || status == salesStatusDipick) {
+			salesAudit := startAudit(tx, r, "sales", salesID.String)
+			if _, err := tx.Exec("UPDATE sales SET sales_status = ?, updated_by = ?, updated_at = NOW() WHERE sales_id = ?",
+				salesStatusSelesai, requestUserID(user), salesID.String); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+				return
+			}
+			if err := logSalesStatus(tx, salesID.String, &status, salesStatusSelesai, "ship", user, "Delivered with surat jalan "+id); err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			salesAudit.record("ship")
+			salesShipped = true
+		}
+	}
+
+	audit.record("deliver")
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
+	respondWithJSON(w, map[string]interface{}{
+		"sj_id":            id,
+		"sj_status":        sjDiterima,
+		"sj_status_nama":   sjStatusNama[sjDiterima],
+		"sj_receiver_nama": req.ReceiverNama,
+		"partial":          partial,
+		"sales_shipped":    salesShipped,
+	})
+}
+
+// cancelSuratJalan drops a surat jalan that has not left yet; its lines become
+// outstanding again and stock moved to ship from another floor goes back
+func cancelSuratJalan(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = lockSuratJalan(tx, id, sjDisiapkan)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Surat jalan not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusConflict, "Cannot cancel: "+err.Error())
+		return
+	}
+
+	audit := startAudit(tx, r, "surat_jalan", id)
+
+	// Undo the floor moves of lines that were to ship from another floor
+	var sjNo string
+	if err := tx.QueryRow("SELECT COALESCE(sj_no, sj_id) FROM surat_jalan WHERE sj_id = ?", id).Scan(&sjNo); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	items, err := getSuratJalanItems(tx, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, item := range items {
+		sourceLantaiID, err := suratJalanSourceLantai(tx, item)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if item.LantaiID == sourceLantaiID {
+			continue
+		}
+		if err := pindahStokBarang(tx, item.BarangID, sourceLantaiID, item.LantaiID, item.Amount, "batal surat jalan "+sjNo, user); err != nil {
+			respondWithError(w, http.StatusConflict, fmt.Sprintf("Line %s: %v", item.ID, err))
+			return
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE surat_jalan SET sj_status = ? WHERE sj_id = ?", sjDibatalkan, id); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Update error: "+err.Error())
+		return
+	}
+	audit.record("cancel")
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+
+	respondWithJSON(w, map[string]interface{}{
+		"sj_id":          id,
+		"sj_status":      sjDibatalkan,
+		"sj_status_nama": sjStatusNama[sjDibatalkan],
+	})
+}
+
+// SetupSuratJalanRoutes sets up all surat jalan (delivery order) routes
+func SetupSuratJalanRoutes(router *mux.Router) {
+	router.HandleFunc("/createsuratjalan", createSuratJalan).Methods("POST")
+	router.HandleFunc("/getsuratjalans", getSuratJalans).Methods("GET")
+	router.HandleFunc("/getsuratjalan/{id}", getSuratJalanDetail).Methods("GET")
+	router.HandleFunc("/getdeliveryprogress", getDeliveryProgress).Methods("GET")
+	router.HandleFunc("/suratjalan/{id}/dispatch", dispatchSuratJalan).Methods("PUT")
+	router.HandleFunc("/suratjalan/{id}/deliver", deliverSuratJalan).Methods("PUT")
+	router.HandleFunc("/suratjalan/{id}/cancel", cancelSuratJalan).Methods("PUT")
+}
@@ -0,0 +1,339 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"src/database"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+type TrashItem struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	Nama      string `json:"nama"`
+	DeletedAt string `json:"deleted_at"`
+	DeletedBy string `json:"deleted_by"`
+}
+
+// trashReference counts rows that still point at a deleted record; every ?
+// in Query is bound to the record's id
+type trashReference struct {
+	Label string
+	Query string
+}
+
+// trashEntity describes a soft-deletable master table
+type trashEntity struct {
+	Table   string
+	IDCol   string
+	NamaCol string
+	// Extra columns reset on restore (e.g. gudang_status)
+	RestoreSet string
+	References []trashReference
+	// Owned rows removed before the record itself on purge
+	Purge []string
+}
+
+// Master tables that support soft delete, keyed by the {type} route variable
+var trashEntities = map[string]trashEntity{
+	"barang": {
+		Table:   "barang",
+		IDCol:   "barang_id",
+		NamaCol: "barang_nama",
+		References: []trashReference{
+			{"sale_items", "SELECT COUNT(*) FROM sale_items WHERE barang_id = ?"},
+			{"orders_masuk", "SELECT COUNT(*) FROM orders_masuk WHERE barang_id = ?"},
+			{"orders_keluar", "SELECT COUNT(*) FROM orders_keluar WHERE barang_id = ?"},
+			{"stock_gudang", "SELECT COUNT(*) FROM stock_gudang WHERE barang_id = ? AND stock_barang <> 0"},
+			{"penawaran_items", "SELECT COUNT(*) FROM penawaran_items WHERE barang_id = ?"},
+			{"surat_jalan_items", "SELECT COUNT(*) FROM surat_jalan_items WHERE barang_id = ?"},
+			{"pick_list_items", "SELECT COUNT(*) FROM pick_list_items WHERE barang_id = ?"},
+			{"daftar_harga_item", "SELECT COUNT(*) FROM daftar_harga_item WHERE barang_id = ?"},
+			{"barang_harga", "SELECT COUNT(*) FROM barang_harga WHERE barang_id = ?"},
+			{"barang_diskon_history", "SELECT COUNT(*) FROM barang_diskon_history WHERE barang_id = ?"},
+			{"promosi", "SELECT COUNT(*) FROM promosi WHERE promo_barang_id = ?"},
+		},
+		Purge: []string{
+			"DELETE FROM stock_lokasi WHERE barang_id = ?",
+			"DELETE FROM stock_gudang WHERE barang_id = ?",
+		},
+	},
+	"brand": {
+		Table:   "brand",
+		IDCol:   "brand_id",
+		NamaCol: "brand_nama",
+		References: []trashReference{
+			{"barang", "SELECT COUNT(*) FROM barang WHERE brand_id = ?"},
+			{"promosi", "SELECT COUNT(*) FROM promosi WHERE promo_brand_id = ?"},
+		},
+	},
+	"customer": {
+		Table:   "customer",
+		IDCol:   "customer_id",
+		NamaCol: "customer_nama",
+		References: []trashReference{
+			{"sales", "SELECT COUNT(*) FROM sales WHERE customer_id = ?"},
+			{"penawaran", "SELECT COUNT(*) FROM penawaran WHERE customer_id = ?"},
+			{"surat_jalan", "SELECT COUNT(*) FROM surat_jalan WHERE customer_id = ?"},
+		},
+	},
+	"gudang": {
+		Table:      "list_gudang",
+		IDCol:      "gudang_id",
+		NamaCol:    "gudang_nama",
+		RestoreSet: ", gudang_status = 1",
+		References: []trashReference{
+			{"sale_items", "SELECT COUNT(*) FROM sale_items WHERE gudang_id = ?"},
+			{"orders_masuk", "SELECT COUNT(*) FROM orders_masuk WHERE gudang_id = ?"},
+			{"orders_keluar", "SELECT COUNT(*) FROM orders_keluar WHERE gudang_id = ?"},
+			{"stock_gudang", `SELECT COUNT(*) FROM stock_gudang sg
+				JOIN gudang_lantai gl ON sg.lantai_id = gl.lantai_id
+				WHERE gl.gudang_id = ? AND sg.stock_barang <> 0`},
+			{"surat_jalan_items", "SELECT COUNT(*) FROM surat_jalan_items WHERE gudang_id = ?"},
+			{"pick_list_items", "SELECT COUNT(*) FROM pick_list_items WHERE gudang_id = ?"},
+		},
+		Purge: []string{
+			`DELETE sk FROM stock_lokasi sk
+				JOIN gudang_lokasi gk ON sk.lokasi_id = gk.lokasi_id
+				JOIN gudang_lantai gl ON gk.lantai_id = gl.lantai_id
+				WHERE gl.gudang_id = ?`,
+			`DELETE sg FROM stock_gudang sg
+				JOIN gudang_lantai gl ON sg.lantai_id = gl.lantai_id
+				WHERE gl.gudang_id = ?`,
+			`DELETE gk FROM gudang_lokasi gk
+				JOIN gudang_lantai gl ON gk.lantai_id = gl.lantai_id
+				WHERE gl.gudang_id = ?`,
+			"DELETE FROM gudang_lantai WHERE gudang_id = ?",
+		},
+	},
+	"users": {
+		Table:   "users",
+		IDCol:   "users_id",
+		NamaCol: "users_nama",
+		References: []trashReference{
+			{"users_login", "SELECT COUNT(*) FROM users_login WHERE users_id = ?"},
+			{"sales", "SELECT COUNT(*) FROM sales WHERE created_by = ? OR updated_by = ?"},
+			{"sale_items", "SELECT COUNT(*) FROM sale_items WHERE created_by = ? OR updated_by = ?"},
+			{"sales_status_log", "SELECT COUNT(*) FROM sales_status_log WHERE users_id = ?"},
+			{"barang_logs", "SELECT COUNT(*) FROM barang_logs WHERE created_by = ? OR updated_by = ?"},
+			{"orders_masuk", "SELECT COUNT(*) FROM orders_masuk WHERE created_by = ? OR updated_by = ?"},
+			{"orders_keluar", "SELECT COUNT(*) FROM orders_keluar WHERE created_by = ? OR updated_by = ?"},
+			{"penawaran", "SELECT COUNT(*) FROM penawaran WHERE users_id = ?"},
+			{"surat_jalan", "SELECT COUNT(*) FROM surat_jalan WHERE users_id = ?"},
+			{"pick_list", "SELECT COUNT(*) FROM pick_list WHERE users_id = ? OR picked_by = ?"},
+			{"kasir_shift", "SELECT COUNT(*) FROM kasir_shift WHERE users_id = ?"},
+			{"kasir_kas", "SELECT COUNT(*) FROM kasir_kas WHERE users_id = ?"},
+			{"sales_pembayaran", "SELECT COUNT(*) FROM sales_pembayaran WHERE users_id = ?"},
+			{"audit_log", "SELECT COUNT(*) FROM audit_log WHERE users_id = ?"},
+		},
+	},
+}
+
+// Order used when listing every type at once
+var trashTypes = []string{"barang", "brand", "customer", "gudang", "users"}
+
+// Helper function to list the deleted rows of one entity
+func listTrash(q sqlExecutor, entityType string) ([]TrashItem, error) {
+	e := trashEntities[entityType]
+	rows, err := q.Query(`SELECT ` + e.IDCol + `, ` + e.NamaCol + `, deleted_at, deleted_by
+		FROM ` + e.Table + ` WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []TrashItem
+	for rows.Next() {
+		var item TrashItem
+		var deletedBy sql.NullString
+		if err := rows.Scan(&item.ID, &item.Nama, &item.DeletedAt, &deletedBy); err != nil {
+			return nil, err
+		}
+		item.Type = entityType
+		item.DeletedBy = nullStringToString(deletedBy)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func getTrash(w http.ResponseWriter, r *http.Request) {
+	types := trashTypes
+	if t := r.URL.Query().Get("type"); t != "" {
+		if _, ok := trashEntities[t]; !ok {
+			respondWithError(w, http.StatusBadRequest, "Unknown type '"+t+"'")
+			return
+		}
+		types = []string{t}
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	items := []TrashItem{}
+	for _, t := range types {
+		list, err := listTrash(db, t)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+			return
+		}
+		items = append(items, list...)
+	}
+
+	respondWithJSON(w, items)
+}
+
+func restoreTrash(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	entityType := params["type"]
+	id := params["id"]
+
+	e, ok := trashEntities[entityType]
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Unknown type '"+entityType+"'")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	audit := startAudit(db, r, entityType, id)
+	res, err := db.Exec("UPDATE "+e.Table+" SET deleted_at = NULL, deleted_by = NULL"+e.RestoreSet+
+		" WHERE "+e.IDCol+" = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Restore error: "+err.Error())
+		return
+	}
+
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Deleted "+entityType+" not found")
+		return
+	}
+	audit.record("restore")
+
+	respondWithJSON(w, map[string]string{
+		"type":   entityType,
+		"id":     id,
+		"status": "Restored",
+	})
+}
+
+// purgeTrash permanently removes a deleted record. Admin only, and refused
+// while other rows still reference it.
+func purgeTrash(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	entityType := params["type"]
+	id := params["id"]
+
+	e, ok := trashEntities[entityType]
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Unknown type '"+entityType+"'")
+		return
+	}
+
+	db, err := database.GetDBConnection()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer db.Close()
+
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if user == nil || user.UsersLevel != 1 {
+		respondWithError(w, http.StatusForbidden, "Only admin can permanently delete records")
+		return
+	}
+
+	audit := startAudit(db, r, entityType, id)
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM "+e.Table+" WHERE "+e.IDCol+" = ? AND deleted_at IS NOT NULL)", id).Scan(&exists)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		return
+	}
+	if !exists {
+		respondWithError(w, http.StatusNotFound, "Deleted "+entityType+" not found")
+		return
+	}
+
+	references := map[string]int{}
+	for _, ref := range e.References {
+		var count int
+		args := make([]interface{}, strings.Count(ref.Query, "?"))
+		for i := range args {
+			args[i] = id
+		}
+		if err := tx.QueryRow(ref.Query, args...).Scan(&count); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error checking references: "+err.Error())
+			return
+		}
+		if count > 0 {
+			references[ref.Label] = count
+		}
+	}
+	if len(references) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":      "Record is still referenced and cannot be purged",
+			"type":       entityType,
+			"id":         id,
+			"references": references,
+		})
+		return
+	}
+
+	for _, stmt := range e.Purge {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Purge error: "+err.Error())
+			return
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM "+e.Table+" WHERE "+e.IDCol+" = ?", id); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Purge error: "+err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Commit error")
+		return
+	}
+	audit.record("purge")
+
+	respondWithJSON(w, map[string]string{
+		"type":   entityType,
+		"id":     id,
+		"status": "Purged",
+	})
+}
+
+// SetupTrashRoutes sets up all trash-related routes
+func SetupTrashRoutes(router *mux.Router) {
+	router.HandleFunc("/trash", getTrash).Methods("GET")
+	router.HandleFunc("/trash/{type}/{id}/restore", restoreTrash).Methods("PUT")
+	router.HandleFunc("/trash/{type}/{id}", purgeTrash).Methods("DELETE")
+}
@@ -113,11 +113,13 @@ func registerUser(w http.ResponseWriter, r *http.Request) {
 	insertQuery := `INSERT INTO users (users_id, users_nama, users_tlp, users_pass, users_level, users_daftar, users_status) 
 	                VALUES (?, ?, ?, ?, 2, ?, 0)`
 
+	audit := startAudit(db, r, "users", newUserID)
 	_, err = db.Exec(insertQuery, newUserID, regReq.UsersNama, regReq.UsersTlp, string(hashedPassword), currentDate)
 	if err != nil {
 		respondWithErrorUser(w, http.StatusInternalServerError, "Error creating user")
 		return
 	}
+	audit.record("register")
 
 	// Return success response (without password)
 	newUser := User{
@@ -199,12 +201,14 @@ func createUser(w http.ResponseWriter, r *http.Request) {
 	insertQuery := `INSERT INTO users (users_id, users_nama, users_tlp, users_pass, users_level, users_daftar, users_status) 
 	                VALUES (?, ?, ?, ?, ?, ?, ?)`
 
+	audit := startAudit(db, r, "users", newUserID)
 	_, err = db.Exec(insertQuery, newUserID, userReq.UsersNama, userReq.UsersTlp, string(hashedPassword),
 		userReq.UsersLevel, daftar, userReq.UsersStatus)
 	if err != nil {
 		respondWithErrorUser(w, http.StatusInternalServerError, "Error creating user")
 		return
 	}
+	audit.record("create")
 
 	// Return success response (without password)
 	newUser := User{
@@ -246,7 +250,7 @@ func changePassword(w http.ResponseWriter, r *http.Request) {
 
 	// Get current password hash
 	var currentHashedPassword string
-	query := `SELECT users_pass FROM users WHERE users_id = ?`
+	query := `SELECT users_pass FROM users WHERE users_id = ? AND deleted_at IS NULL`
 	err = db.QueryRow(query, changeReq.UsersID).Scan(&currentHashedPassword)
 
 	if err == sql.ErrNoRows {
@@ -273,11 +277,13 @@ func changePassword(w http.ResponseWriter, r *http.Request) {
 
 	// Update password
 	updateQuery := `UPDATE users SET users_pass = ? WHERE users_id = ?`
+	audit := startAudit(db, r, "users", changeReq.UsersID)
 	_, err = db.Exec(updateQuery, string(newHashedPassword), changeReq.UsersID)
 	if err != nil {
 		respondWithErrorUser(w, http.StatusInternalServerError, "Error updating password")
 		return
 	}
+	audit.record("change_password")
 
 	respondWithJSONUser(w, UserResponse{
 		Success: true,
@@ -301,6 +307,7 @@ func getUsers(w http.ResponseWriter, r *http.Request) {
 
 	query := `SELECT users_id, users_nama, users_tlp, users_pass, users_level, users_daftar, users_status 
 	          FROM users 
+	          WHERE deleted_at IS NULL
 	          ORDER BY users_id`
 
 	rows, err := db.Query(query)
@@ -342,7 +349,7 @@ func getUser(w http.ResponseWriter, r *http.Request) {
 
 	var user User
 	query := `SELECT users_id, users_nama, users_tlp, users_pass, users_level, users_daftar, users_status 
-	          FROM users WHERE users_id = ?`
+	          FROM users WHERE users_id = ? AND deleted_at IS NULL`
 
 	err = db.QueryRow(query, userID).Scan(&user.UsersID, &user.UsersNama, &user.UsersTlp, &user.UsersPass, &user.UsersLevel, &user.UsersDaftar, &user.UsersStatus)
 
@@ -377,8 +384,9 @@ func updateUser(w http.ResponseWriter, r *http.Request) {
 
 	// Update user (not including password change - use changePassword endpoint for that)
 	updateQuery := `UPDATE users SET users_nama = ?, users_tlp = ?, users_level = ?, users_status = ? 
-	                WHERE users_id = ?`
+	                WHERE users_id = ? AND deleted_at IS NULL`
 
+	audit := startAudit(db, r, "users", userID)
 	result, err := db.Exec(updateQuery, user.UsersNama, user.UsersTlp, user.UsersLevel, user.UsersStatus, userID)
 	if err != nil {
 		respondWithErrorUser(w, http.StatusInternalServerError, "Error updating user")
@@ -390,6 +398,7 @@ func updateUser(w http.ResponseWriter, r *http.Request) {
 		respondWithErrorUser(w, http.StatusNotFound, "User not found")
 		return
 	}
+	audit.record("update")
 
 	respondWithJSONUser(w, UserResponse{
 		Success: true,
@@ -410,8 +419,9 @@ func approveUser(w http.ResponseWriter, r *http.Request) {
 	defer db.Close()
 
 	// Update user status to active (1)
-	updateQuery := `UPDATE users SET users_status = 1 WHERE users_id = ?`
+	updateQuery := `UPDATE users SET users_status = 1 WHERE users_id = ? AND deleted_at IS NULL`
 
+	audit := startAudit(db, r, "users", userID)
 	result, err := db.Exec(updateQuery, userID)
 	if err != nil {
 		respondWithErrorUser(w, http.StatusInternalServerError, "Error approving user")
@@ -423,6 +433,7 @@ func approveUser(w http.ResponseWriter, r *http.Request) {
 		respondWithErrorUser(w, http.StatusNotFound, "User not found")
 		return
 	}
+	audit.record("approve")
 
 	respondWithJSONUser(w, UserResponse{
 		Success: true,
@@ -442,10 +453,21 @@ func deleteUser(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	// Delete user
-	deleteQuery := `DELETE FROM users WHERE users_id = ?`
+	user, err := requestUser(db, r)
+	if err != nil {
+		respondWithErrorUser(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if user != nil && user.UsersID == userID {
+		respondWithErrorUser(w, http.StatusBadRequest, "You cannot delete your own account")
+		return
+	}
+
+	// Soft delete user, login history keeps pointing at the row
+	deleteQuery := `UPDATE users SET deleted_at = NOW(), deleted_by = ? WHERE users_id = ? AND deleted_at IS NULL`
 
-	result, err := db.Exec(deleteQuery, userID)
+	audit := startAudit(db, r, "users", userID)
+	result, err := db.Exec(deleteQuery, requestUserID(user), userID)
 	if err != nil {
 		respondWithErrorUser(w, http.StatusInternalServerError, "Error deleting user")
 		return
@@ -456,6 +478,7 @@ func deleteUser(w http.ResponseWriter, r *http.Request) {
 		respondWithErrorUser(w, http.StatusNotFound, "User not found")
 		return
 	}
+	audit.record("delete")
 
 	respondWithJSONUser(w, UserResponse{
 		Success: true,